@@ -1,16 +1,17 @@
 HUMAN_VERSION="1.0.1"
 GIT_HASH=$(git describe --always --dirty --tags --long)
+BUILD_DATE=$(date -u +"%Y-%m-%dT%H:%M:%SZ")
 
 rm -rf ./bin
 
-GOOS=linux GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/linux/x86/sqlpipe ./cmd
-GOOS=darwin GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/macos/x86/sqlpipe ./cmd
-GOOS=windows GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/windows/x86/sqlpipe.exe ./cmd
-GOOS=freebsd GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/freebsd/x86/sqlpipe ./cmd
-GOOS=linux GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/linux/arm/sqlpipe ./cmd
-GOOS=darwin GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/macos/arm/sqlpipe ./cmd
-GOOS=windows GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/windows/arm/sqlpipe.exe ./cmd
-GOOS=freebsd GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION}" -o=./bin/freebsd/arm/sqlpipe ./cmd
+GOOS=linux GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/linux/x86/sqlpipe ./cmd
+GOOS=darwin GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/macos/x86/sqlpipe ./cmd
+GOOS=windows GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/windows/x86/sqlpipe.exe ./cmd
+GOOS=freebsd GOARCH=amd64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/freebsd/x86/sqlpipe ./cmd
+GOOS=linux GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/linux/arm/sqlpipe ./cmd
+GOOS=darwin GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/macos/arm/sqlpipe ./cmd
+GOOS=windows GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/windows/arm/sqlpipe.exe ./cmd
+GOOS=freebsd GOARCH=arm64 go build -ldflags="-s -X main.gitHash=${GIT_HASH} -X main.sqlpipeVersion=${HUMAN_VERSION} -X main.buildDate=${BUILD_DATE}" -o=./bin/freebsd/arm/sqlpipe ./cmd
 
 gon ./gon-arm-config.json
 gon ./gon-x86-config.json
@@ -0,0 +1,440 @@
+package apply
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+var ApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Make a SQLpipe server's connections and transfers match a manifest",
+	Long: `Reads connections and transfers from a YAML manifest and reconciles them
+against a SQLpipe server. Connections are matched by name: missing ones are
+created, and ones whose fields differ are updated. Transfers are matched by
+their target connection and table: a transfer is queued if none has run for
+that table yet, or if its definition differs from the most recent one that
+has. Transfers are never deleted. With --prune, connections that aren't in the
+manifest are deleted.
+
+Passwords can't be read back from the server, so they are only sent when a
+connection is created, or for every connection with --passwords. A password
+written as ${NAME} is read from the NAME environment variable.`,
+	Args: cobra.NoArgs,
+	Run:  runApply,
+}
+
+type applyOptions struct {
+	file      string
+	dryRun    bool
+	prune     bool
+	passwords bool
+}
+
+var (
+	applyClient apiClient.Client
+	opts        applyOptions
+)
+
+func init() {
+	applyClient.AddFlags(ApplyCmd)
+	ApplyCmd.Flags().StringVarP(&opts.file, "file", "f", "", "Path to the manifest")
+	ApplyCmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the plan without changing anything")
+	ApplyCmd.Flags().BoolVar(&opts.prune, "prune", false, "Delete connections that aren't in the manifest")
+	ApplyCmd.Flags().BoolVar(&opts.passwords, "passwords", false, "Send the password of every connection, not just new ones")
+	ApplyCmd.MarkFlagRequired("file")
+}
+
+func runApply(cmd *cobra.Command, args []string) {
+	m, err := readManifest(opts.file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = apply(&applyClient, m, opts, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+// One change the plan makes. Connection changes carry the request body, and
+// transfer changes the spec to queue.
+type change struct {
+	action     string
+	kind       string
+	name       string
+	fields     []string
+	id         int64
+	connection map[string]interface{}
+	transfer   transferSpec
+}
+
+func (c change) String() string {
+	symbol := map[string]string{actionCreate: "+", actionUpdate: "~", actionDelete: "-"}[c.action]
+	s := fmt.Sprintf("%s %s %s", symbol, c.kind, c.name)
+	if len(c.fields) > 0 {
+		s += " (" + strings.Join(c.fields, ", ") + ")"
+	}
+	return s
+}
+
+// What the server has now
+type state struct {
+	connections []data.Connection
+	// the most recent transfer for each target, keyed by transferSpec.key
+	transfers map[string]transferSpec
+}
+
+func apply(client *apiClient.Client, m manifest, opts applyOptions, out io.Writer) error {
+	current, err := getState(client)
+	if err != nil {
+		return err
+	}
+
+	changes := plan(m, current, opts)
+
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No changes, the server matches the manifest")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Fprintln(out, c)
+	}
+	if opts.dryRun {
+		fmt.Fprintf(out, "%d changes planned, none made (dry run)\n", len(changes))
+		return nil
+	}
+
+	connectionIDs := map[string]int64{}
+	for _, connection := range current.connections {
+		connectionIDs[connection.Name] = connection.ID
+	}
+
+	for _, c := range changes {
+		err = applyChange(client, c, connectionIDs)
+		if err != nil {
+			return fmt.Errorf("unable to %s %s %s: %w", c.action, c.kind, c.name, validationDetail(err))
+		}
+	}
+
+	fmt.Fprintf(out, "%d changes made\n", len(changes))
+	return nil
+}
+
+func applyChange(client *apiClient.Client, c change, connectionIDs map[string]int64) error {
+	var body struct {
+		Connection data.Connection `json:"connection"`
+	}
+
+	switch {
+	case c.kind == "connection" && c.action == actionCreate:
+		err := client.Post("/api/v1/connections", c.connection, &body)
+		if err != nil {
+			return err
+		}
+		connectionIDs[c.name] = body.Connection.ID
+		return nil
+	case c.kind == "connection" && c.action == actionUpdate:
+		return client.Do("PATCH", fmt.Sprintf("/api/v1/connections/%d", c.id), c.connection, nil)
+	case c.kind == "connection" && c.action == actionDelete:
+		return client.Do("DELETE", fmt.Sprintf("/api/v1/connections/%d", c.id), nil, nil)
+	default:
+		return client.Post("/api/v1/transfers", transferBody(c.transfer, connectionIDs), nil)
+	}
+}
+
+// Connections are created and updated first, so transfers can use them, and
+// deleted last, once nothing new depends on them
+func plan(m manifest, current state, opts applyOptions) []change {
+	var changes, deletes []change
+
+	existing := map[string]data.Connection{}
+	for _, connection := range current.connections {
+		existing[connection.Name] = connection
+	}
+
+	declared := map[string]bool{}
+	for _, desired := range m.Connections {
+		declared[desired.Name] = true
+
+		connection, ok := existing[desired.Name]
+		if !ok {
+			changes = append(changes, change{
+				action:     actionCreate,
+				kind:       "connection",
+				name:       desired.Name,
+				connection: connectionBody(desired, nil),
+			})
+			continue
+		}
+
+		fields := connectionDiff(desired, connection)
+		if opts.passwords && desired.Password != "" {
+			fields = append(fields, "password")
+		}
+		if len(fields) > 0 {
+			changes = append(changes, change{
+				action:     actionUpdate,
+				kind:       "connection",
+				name:       desired.Name,
+				fields:     fields,
+				id:         connection.ID,
+				connection: connectionBody(desired, fields),
+			})
+		}
+	}
+
+	if opts.prune {
+		for _, connection := range current.connections {
+			if !declared[connection.Name] {
+				deletes = append(deletes, change{
+					action: actionDelete,
+					kind:   "connection",
+					name:   connection.Name,
+					id:     connection.ID,
+				})
+			}
+		}
+	}
+
+	for _, desired := range m.Transfers {
+		stored, ok := current.transfers[desired.key()]
+		if !ok {
+			changes = append(changes, change{action: actionCreate, kind: "transfer", name: desired.key(), transfer: desired})
+			continue
+		}
+		if fields := specDiff(desired, stored); len(fields) > 0 {
+			changes = append(changes, change{action: actionUpdate, kind: "transfer", name: desired.key(), fields: fields, transfer: desired})
+		}
+	}
+
+	return append(changes, deletes...)
+}
+
+// Only fields the manifest sets are compared, so ones the server fills in,
+// like default ports, don't show up as changes
+func connectionDiff(desired connectionSpec, stored data.Connection) []string {
+	have := connectionSpec{
+		Name:           stored.Name,
+		DsType:         stored.DsType,
+		Hostname:       stored.Hostname,
+		Port:           stored.Port,
+		AccountId:      stored.AccountId,
+		DbName:         stored.DbName,
+		DefaultSchema:  stored.DefaultSchema,
+		Labels:         stored.Labels,
+		Keepalive:      stored.Keepalive,
+		ConnectTimeout: stored.ConnectTimeout,
+		Username:       stored.Username,
+	}
+
+	var fields []string
+	want := reflect.ValueOf(desired)
+	got := reflect.ValueOf(have)
+	for i := 0; i < want.NumField(); i++ {
+		name := yamlName(want.Type().Field(i))
+		if name == "password" || name == "skipTest" || want.Field(i).IsZero() {
+			continue
+		}
+		if !reflect.DeepEqual(want.Field(i).Interface(), got.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Every field is compared, since the server stores transfers as they're sent.
+// Empty and missing lists are the same.
+func specDiff(desired transferSpec, stored transferSpec) []string {
+	var fields []string
+	want := reflect.ValueOf(desired)
+	got := reflect.ValueOf(stored)
+	for i := 0; i < want.NumField(); i++ {
+		a, b := want.Field(i), got.Field(i)
+		if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			fields = append(fields, yamlName(want.Type().Field(i)))
+		}
+	}
+	return fields
+}
+
+func yamlName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("yaml"), ",")[0]
+}
+
+// The create body when fields is nil, otherwise a PATCH body with just the
+// changed fields
+func connectionBody(desired connectionSpec, fields []string) map[string]interface{} {
+	all := map[string]interface{}{
+		"name":           desired.Name,
+		"dsType":         desired.DsType,
+		"hostname":       desired.Hostname,
+		"port":           desired.Port,
+		"accountId":      desired.AccountId,
+		"dbName":         desired.DbName,
+		"defaultSchema":  desired.DefaultSchema,
+		"labels":         desired.Labels,
+		"keepalive":      desired.Keepalive,
+		"connectTimeout": desired.ConnectTimeout,
+		"username":       desired.Username,
+		"password":       desired.Password,
+	}
+	if fields == nil {
+		all["skipTest"] = desired.SkipTest
+		return all
+	}
+
+	body := map[string]interface{}{}
+	for _, field := range fields {
+		body[field] = all[field]
+	}
+	return body
+}
+
+func transferBody(spec transferSpec, connectionIDs map[string]int64) map[string]interface{} {
+	return map[string]interface{}{
+		"sourceID":           connectionIDs[spec.Source],
+		"targetID":           connectionIDs[spec.Target],
+		"query":              spec.Query,
+		"queryArgs":          spec.QueryArgs,
+		"targetSchema":       spec.TargetSchema,
+		"targetTable":        spec.TargetTable,
+		"targetTablePattern": spec.TargetTablePattern,
+		"createTargetTable":  spec.CreateTargetTable,
+		"createTargetSchema": spec.CreateTargetSchema,
+		"overwrite":          spec.Overwrite,
+		"writeMode":          spec.WriteMode,
+		"preLoadSQL":         spec.PreLoadSQL,
+		"parallelism":        spec.Parallelism,
+		"chunkColumn":        spec.ChunkColumn,
+		"sourceColumns":      spec.SourceColumns,
+		"excludeColumns":     spec.ExcludeColumns,
+		"conflictColumns":    spec.ConflictColumns,
+		"targetColumnOrder":  spec.TargetColumnOrder,
+		"verifyChecksum":     spec.VerifyChecksum,
+		"isolationLevel":     spec.IsolationLevel,
+		"statementTimeout":   spec.StatementTimeout,
+		"sourceLimit":        spec.SourceLimit,
+		"sampleRate":         spec.SampleRate,
+		"maxBufferedRows":    spec.MaxBufferedRows,
+		"maxBufferedBytes":   spec.MaxBufferedBytes,
+	}
+}
+
+func getState(client *apiClient.Client) (state, error) {
+	current := state{transfers: map[string]transferSpec{}}
+
+	names := map[int64]string{}
+	for page := 1; ; page++ {
+		var body struct {
+			Connections []data.Connection `json:"connections"`
+			Metadata    data.Metadata     `json:"metadata"`
+		}
+		err := client.Get("/api/v1/connections?"+pageQuery(page, "id"), &body)
+		if err != nil {
+			return current, fmt.Errorf("unable to list connections: %w", err)
+		}
+		for _, connection := range body.Connections {
+			current.connections = append(current.connections, connection)
+			names[connection.ID] = connection.Name
+		}
+		if page >= body.Metadata.LastPage {
+			break
+		}
+	}
+
+	// newest first, so the first transfer seen for a target is its latest
+	for page := 1; ; page++ {
+		var body struct {
+			Transfers []data.Transfer `json:"transfers"`
+			Metadata  data.Metadata   `json:"metadata"`
+		}
+		err := client.Get("/api/v1/transfers?"+pageQuery(page, "-id"), &body)
+		if err != nil {
+			return current, fmt.Errorf("unable to list transfers: %w", err)
+		}
+		for _, transfer := range body.Transfers {
+			spec := storedSpec(transfer, names)
+			if _, seen := current.transfers[spec.key()]; !seen {
+				current.transfers[spec.key()] = spec
+			}
+		}
+		if page >= body.Metadata.LastPage {
+			break
+		}
+	}
+
+	return current, nil
+}
+
+func pageQuery(page int, sort string) string {
+	qs := url.Values{}
+	qs.Set("page", strconv.Itoa(page))
+	qs.Set("page_size", "100")
+	qs.Set("sort", sort)
+	return qs.Encode()
+}
+
+func storedSpec(transfer data.Transfer, names map[int64]string) transferSpec {
+	return transferSpec{
+		Source:             names[transfer.SourceID],
+		Target:             names[transfer.TargetID],
+		Query:              transfer.Query,
+		QueryArgs:          transfer.QueryArgs,
+		TargetSchema:       transfer.TargetSchema,
+		TargetTable:        transfer.TargetTable,
+		TargetTablePattern: transfer.TargetTablePattern,
+		CreateTargetTable:  transfer.CreateTargetTable,
+		CreateTargetSchema: transfer.CreateTargetSchema,
+		Overwrite:          transfer.Overwrite,
+		WriteMode:          transfer.WriteMode,
+		PreLoadSQL:         transfer.PreLoadSQL,
+		Parallelism:        transfer.Parallelism,
+		ChunkColumn:        transfer.ChunkColumn,
+		SourceColumns:      transfer.SourceColumns,
+		ExcludeColumns:     transfer.ExcludeColumns,
+		ConflictColumns:    transfer.ConflictColumns,
+		TargetColumnOrder:  transfer.TargetColumnOrder,
+		VerifyChecksum:     transfer.VerifyChecksum,
+		IsolationLevel:     transfer.IsolationLevel,
+		StatementTimeout:   transfer.StatementTimeout,
+		SourceLimit:        transfer.SourceLimit,
+		SampleRate:         transfer.SampleRate,
+		MaxBufferedRows:    transfer.MaxBufferedRows,
+		MaxBufferedBytes:   transfer.MaxBufferedBytes,
+	}
+}
+
+// Validation errors only say how many fields failed, so spell them out
+func validationDetail(err error) error {
+	validationErr, ok := err.(*apiClient.ValidationError)
+	if !ok {
+		return err
+	}
+	var details []string
+	for field, message := range validationErr.Errors {
+		details = append(details, field+": "+message)
+	}
+	sort.Strings(details)
+	return fmt.Errorf("%s", strings.Join(details, ", "))
+}
@@ -0,0 +1,243 @@
+package apply
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Keeps connections and transfers in memory, and records each request that
+// changes them
+type fakeServer struct {
+	connections []data.Connection
+	transfers   []data.Transfer
+	requests    []string
+	bodies      []map[string]interface{}
+}
+
+func newFakeServer(t *testing.T, fake *fakeServer) *apiClient.Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			fake.requests = append(fake.requests, r.Method+" "+r.URL.Path)
+			fake.bodies = append(fake.bodies, body)
+		}
+
+		metadata := data.Metadata{CurrentPage: 1, LastPage: 1}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/connections":
+			json.NewEncoder(w).Encode(map[string]interface{}{"connections": fake.connections, "metadata": metadata})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/transfers":
+			// newest first, as apply asks for
+			transfers := make([]data.Transfer, len(fake.transfers))
+			for i, transfer := range fake.transfers {
+				transfers[len(transfers)-1-i] = transfer
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"transfers": transfers, "metadata": metadata})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/connections":
+			body := fake.bodies[len(fake.bodies)-1]
+			connection := data.Connection{ID: int64(len(fake.connections) + 1), Name: body["name"].(string), DsType: body["dsType"].(string)}
+			fake.connections = append(fake.connections, connection)
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{"connection": connection})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/transfers":
+			body := fake.bodies[len(fake.bodies)-1]
+			transfer := data.Transfer{
+				ID:          int64(len(fake.transfers) + 1),
+				SourceID:    int64(body["sourceID"].(float64)),
+				TargetID:    int64(body["targetID"].(float64)),
+				Query:       body["query"].(string),
+				TargetTable: body["targetTable"].(string),
+			}
+			fake.transfers = append(fake.transfers, transfer)
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{"transfer": transfer})
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/api/v1/connections/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"connection": data.Connection{}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+}
+
+func testManifest(query string) manifest {
+	return manifest{
+		Connections: []connectionSpec{
+			{Name: "app", DsType: "postgresql", Hostname: "app.internal", Password: "secret"},
+			{Name: "warehouse", DsType: "snowflake", AccountId: "acme"},
+		},
+		Transfers: []transferSpec{
+			{Source: "app", Target: "warehouse", Query: query, TargetTable: "users"},
+		},
+	}
+}
+
+func TestApplyCreatesMissingState(t *testing.T) {
+	fake := &fakeServer{}
+	client := newFakeServer(t, fake)
+
+	var out strings.Builder
+	err := apply(client, testManifest("select * from users"), applyOptions{}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"POST /api/v1/connections", "POST /api/v1/connections", "POST /api/v1/transfers"}
+	if strings.Join(fake.requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got requests %v, want %v", fake.requests, want)
+	}
+	if fake.bodies[0]["password"] != "secret" {
+		t.Errorf("a new connection's password wasn't sent")
+	}
+	transfer := fake.transfers[0]
+	if transfer.SourceID != 1 || transfer.TargetID != 2 {
+		t.Errorf("transfer was queued from connection %d to %d, want 1 to 2", transfer.SourceID, transfer.TargetID)
+	}
+	if !strings.Contains(out.String(), "+ transfer warehouse/users") {
+		t.Errorf("plan didn't include the transfer: %q", out.String())
+	}
+}
+
+func TestApplyUpdatesChangedQuery(t *testing.T) {
+	fake := &fakeServer{
+		connections: []data.Connection{
+			{ID: 1, Name: "app", DsType: "postgresql", Hostname: "app.internal", Port: 5432},
+			{ID: 2, Name: "warehouse", DsType: "snowflake", AccountId: "acme"},
+		},
+		transfers: []data.Transfer{
+			{ID: 1, SourceID: 1, TargetID: 2, Query: "select * from users", TargetTable: "users"},
+		},
+	}
+	client := newFakeServer(t, fake)
+
+	var out strings.Builder
+	err := apply(client, testManifest("select id, name from users"), applyOptions{}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.requests) != 1 || fake.requests[0] != "POST /api/v1/transfers" {
+		t.Fatalf("got requests %v, want only the transfer to be queued", fake.requests)
+	}
+	if fake.transfers[1].Query != "select id, name from users" {
+		t.Errorf("queued transfer has query %q", fake.transfers[1].Query)
+	}
+	if !strings.Contains(out.String(), "~ transfer warehouse/users (query)") {
+		t.Errorf("plan didn't report the changed query: %q", out.String())
+	}
+}
+
+func TestApplyNoChanges(t *testing.T) {
+	fake := &fakeServer{
+		connections: []data.Connection{
+			{ID: 1, Name: "app", DsType: "postgresql", Hostname: "app.internal", Port: 5432},
+			{ID: 2, Name: "warehouse", DsType: "snowflake", AccountId: "acme"},
+			{ID: 3, Name: "old", DsType: "mysql"},
+		},
+		transfers: []data.Transfer{
+			{ID: 1, SourceID: 1, TargetID: 2, Query: "select * from users", TargetTable: "users"},
+			{ID: 2, SourceID: 1, TargetID: 2, Query: "select id from users", TargetTable: "users"},
+			{ID: 3, SourceID: 1, TargetID: 2, Query: "select * from users", TargetTable: "users"},
+		},
+	}
+	client := newFakeServer(t, fake)
+
+	var out strings.Builder
+	err := apply(client, testManifest("select * from users"), applyOptions{}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.requests) != 0 {
+		t.Errorf("got requests %v, want none", fake.requests)
+	}
+	if !strings.Contains(out.String(), "No changes") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestApplyDryRunAndPrune(t *testing.T) {
+	fake := &fakeServer{
+		connections: []data.Connection{
+			{ID: 1, Name: "app", DsType: "postgresql", Hostname: "old.internal"},
+			{ID: 3, Name: "old", DsType: "mysql"},
+		},
+	}
+	client := newFakeServer(t, fake)
+
+	var out strings.Builder
+	err := apply(client, testManifest("select * from users"), applyOptions{dryRun: true, prune: true}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.requests) != 0 {
+		t.Errorf("a dry run made requests %v", fake.requests)
+	}
+	want := "~ connection app (hostname)\n+ connection warehouse\n+ transfer warehouse/users\n- connection old\n"
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("got plan %q, want %q", out.String(), want)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	contents := `
+connections:
+  - name: app
+    dsType: postgresql
+    hostname: app.internal
+    password: ${APPLY_TEST_PASSWORD}
+  - name: warehouse
+    dsType: snowflake
+    accountId: acme
+transfers:
+  - source: app
+    target: warehouse
+    query: select * from users where id > $1
+    queryArgs:
+      - type: integer
+        value: "100"
+    targetSchema: public
+    targetTable: users
+`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("APPLY_TEST_PASSWORD", "from-env")
+	defer os.Unsetenv("APPLY_TEST_PASSWORD")
+
+	m, err := readManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Connections[0].Password != "from-env" {
+		t.Errorf("password wasn't read from the environment, got %q", m.Connections[0].Password)
+	}
+	if key := m.Transfers[0].key(); key != "warehouse/public.users" {
+		t.Errorf("got transfer key %q", key)
+	}
+	if args := m.Transfers[0].QueryArgs; len(args) != 1 || args[0].Type != "integer" || args[0].Value != "100" {
+		t.Errorf("got query args %v", args)
+	}
+
+	bad := strings.Replace(contents, "target: warehouse", "target: nowhere", 1)
+	if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readManifest(path); err == nil || !strings.Contains(err.Error(), strconv.Quote("nowhere")) {
+		t.Errorf("got error %v, want one about the unknown target", err)
+	}
+}
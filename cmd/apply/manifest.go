@@ -0,0 +1,155 @@
+package apply
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"gopkg.in/yaml.v2"
+)
+
+// The desired state of a SQLpipe server: the connections it should have, and
+// the transfers that should have run against them
+type manifest struct {
+	Connections []connectionSpec `yaml:"connections"`
+	Transfers   []transferSpec   `yaml:"transfers"`
+}
+
+// Field names match the create connection API. Passwords are expanded with
+// environment variables, so they don't have to be kept in the manifest.
+type connectionSpec struct {
+	Name           string            `yaml:"name"`
+	DsType         string            `yaml:"dsType"`
+	Hostname       string            `yaml:"hostname"`
+	Port           int               `yaml:"port"`
+	AccountId      string            `yaml:"accountId"`
+	DbName         string            `yaml:"dbName"`
+	DefaultSchema  string            `yaml:"defaultSchema"`
+	Labels         map[string]string `yaml:"labels"`
+	Keepalive      int               `yaml:"keepalive"`
+	ConnectTimeout int               `yaml:"connectTimeout"`
+	Username       string            `yaml:"username"`
+	Password       string            `yaml:"password"`
+	SkipTest       bool              `yaml:"skipTest"`
+}
+
+// Field names match the create transfer API, except the source and target
+// are connection names rather than IDs
+type transferSpec struct {
+	Source             string         `yaml:"source"`
+	Target             string         `yaml:"target"`
+	Query              string         `yaml:"query"`
+	QueryArgs          data.QueryArgs `yaml:"queryArgs"`
+	TargetSchema       string         `yaml:"targetSchema"`
+	TargetTable        string         `yaml:"targetTable"`
+	TargetTablePattern string         `yaml:"targetTablePattern"`
+	CreateTargetTable  bool           `yaml:"createTargetTable"`
+	CreateTargetSchema bool           `yaml:"createTargetSchema"`
+	Overwrite          bool           `yaml:"overwrite"`
+	WriteMode          string         `yaml:"writeMode"`
+	PreLoadSQL         []string       `yaml:"preLoadSQL"`
+	Parallelism        int            `yaml:"parallelism"`
+	ChunkColumn        string         `yaml:"chunkColumn"`
+	SourceColumns      []string       `yaml:"sourceColumns"`
+	ExcludeColumns     []string       `yaml:"excludeColumns"`
+	ConflictColumns    []string       `yaml:"conflictColumns"`
+	TargetColumnOrder  []string       `yaml:"targetColumnOrder"`
+	VerifyChecksum     bool           `yaml:"verifyChecksum"`
+	IsolationLevel     string         `yaml:"isolationLevel"`
+	StatementTimeout   int            `yaml:"statementTimeout"`
+	SourceLimit        int            `yaml:"sourceLimit"`
+	SampleRate         float64        `yaml:"sampleRate"`
+	MaxBufferedRows    int            `yaml:"maxBufferedRows"`
+	MaxBufferedBytes   int64          `yaml:"maxBufferedBytes"`
+}
+
+// Transfers are matched to the ones already on the server by where they
+// write to
+func (t transferSpec) key() string {
+	table := t.TargetTable
+	if t.TargetTablePattern != "" {
+		table = t.TargetTablePattern
+	}
+	if t.TargetSchema != "" {
+		table = t.TargetSchema + "." + table
+	}
+	return t.Target + "/" + table
+}
+
+func readManifest(path string) (manifest, error) {
+	var m manifest
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+
+	err = yaml.UnmarshalStrict(contents, &m)
+	if err != nil {
+		return m, fmt.Errorf("unable to read manifest: %w", err)
+	}
+
+	for i, connection := range m.Connections {
+		password, err := expandPassword(connection.Password)
+		if err != nil {
+			return m, fmt.Errorf("connection %s: %w", connection.Name, err)
+		}
+		m.Connections[i].Password = password
+	}
+
+	return m, validateManifest(m)
+}
+
+// Reads a password written as ${NAME} from the environment. Anything else is
+// taken literally, so passwords containing $ don't need escaping.
+func expandPassword(password string) (string, error) {
+	if !strings.HasPrefix(password, "${") || !strings.HasSuffix(password, "}") {
+		return password, nil
+	}
+	name := password[2 : len(password)-1]
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("password environment variable %s isn't set", name)
+	}
+	return value, nil
+}
+
+// Names must be unique, and every transfer must use connections the
+// manifest declares. Everything else is left to the server to validate.
+func validateManifest(m manifest) error {
+	connections := map[string]bool{}
+	for i, connection := range m.Connections {
+		if connection.Name == "" {
+			return fmt.Errorf("connection %d has no name", i+1)
+		}
+		if connections[connection.Name] {
+			return fmt.Errorf("connection %s is declared more than once", connection.Name)
+		}
+		connections[connection.Name] = true
+	}
+
+	transfers := map[string]bool{}
+	for i, transfer := range m.Transfers {
+		if !connections[transfer.Source] {
+			return fmt.Errorf("transfer %d's source, %q, isn't a connection in the manifest", i+1, transfer.Source)
+		}
+		if !connections[transfer.Target] {
+			return fmt.Errorf("transfer %d's target, %q, isn't a connection in the manifest", i+1, transfer.Target)
+		}
+		if transfer.TargetTable == "" && transfer.TargetTablePattern == "" {
+			return fmt.Errorf("transfer %d has no target table", i+1)
+		}
+		if transfers[transfer.key()] {
+			return fmt.Errorf("more than one transfer writes to %s", transfer.key())
+		}
+		transfers[transfer.key()] = true
+	}
+
+	if len(m.Connections) == 0 && len(m.Transfers) == 0 {
+		return errors.New("manifest has no connections or transfers")
+	}
+
+	return nil
+}
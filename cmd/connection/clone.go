@@ -0,0 +1,163 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+var CloneCmd = &cobra.Command{
+	Use:   "clone <id>",
+	Short: "Create a connection on a SQLpipe server from a copy of an existing one",
+	Long: `Create a connection on a SQLpipe server from a copy of an existing one.
+
+The new connection gets every setting of the original except its ID and
+password. Servers never send passwords, so the new connection's password must
+be given again with --connection-password. Any flag that's set replaces the
+copied setting.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runClone,
+}
+
+// Settings that replace the copied ones when their flag is set
+type cloneOptions struct {
+	name           string
+	hostname       string
+	port           int
+	accountId      string
+	dbName         string
+	defaultSchema  string
+	keepalive      int
+	connectTimeout int
+	username       string
+	password       string
+	skipTest       bool
+}
+
+var (
+	cloneClient apiClient.Client
+	clone       cloneOptions
+)
+
+func init() {
+	cloneClient.AddFlags(CloneCmd)
+	CloneCmd.Flags().StringVar(&clone.name, "name", "", "Name of the new connection. Must not already be used")
+	CloneCmd.Flags().StringVar(&clone.hostname, "connection-hostname", "", "New connection's hostname")
+	CloneCmd.Flags().IntVar(&clone.port, "connection-port", 0, "New connection's port")
+	CloneCmd.Flags().StringVar(&clone.accountId, "connection-account-id", "", "New connection's account ID (Snowflake only)")
+	CloneCmd.Flags().StringVar(&clone.dbName, "connection-db-name", "", "New connection's DB name")
+	CloneCmd.Flags().StringVar(&clone.defaultSchema, "connection-default-schema", "", "Schema unqualified names resolve to (a search path on PostgreSQL and Redshift)")
+	CloneCmd.Flags().IntVar(&clone.keepalive, "connection-keepalive", 0, "Seconds an idle connection waits before keepalives are sent. 0 leaves it to the driver")
+	CloneCmd.Flags().IntVar(&clone.connectTimeout, "connection-connect-timeout", 0, "Seconds to wait for the database to accept a connection. 0 leaves it to the driver")
+	CloneCmd.Flags().StringVar(&clone.username, "connection-username", "", "New connection's username")
+	CloneCmd.Flags().StringVar(&clone.password, "connection-password", "", "New connection's password")
+	CloneCmd.Flags().BoolVar(&clone.skipTest, "skip-test", false, "Create the connection without checking that it can connect")
+	CloneCmd.MarkFlagRequired("name")
+	CloneCmd.MarkFlagRequired("connection-password")
+
+	ConnectionCmd.AddCommand(CloneCmd)
+}
+
+func runClone(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		globals.Errorf("connection ID must be a positive integer\n")
+		os.Exit(1)
+	}
+
+	connection, err := cloneConnection(&cloneClient, id, clone, cmd.Flags().Changed)
+	if err != nil {
+		globals.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+
+	globals.Infof("Connection %d, %s, created as a clone of connection %d\n", connection.ID, connection.Name, id)
+}
+
+// Copies connection id into a new connection, with the settings whose flags
+// were changed replaced by opts
+func cloneConnection(
+	client *apiClient.Client,
+	id int64,
+	opts cloneOptions,
+	changed func(flag string) bool,
+) (
+	data.Connection,
+	error,
+) {
+	var shown struct {
+		Connection data.Connection `json:"connection"`
+	}
+	err := client.Get(fmt.Sprintf("/api/v1/connections/%d", id), &shown)
+	if err != nil {
+		return data.Connection{}, err
+	}
+	original := shown.Connection
+
+	if strings.EqualFold(opts.name, original.Name) {
+		return data.Connection{}, fmt.Errorf("the clone needs a name other than %s", original.Name)
+	}
+	if opts.password == "" {
+		return data.Connection{}, errors.New("the clone needs its own --connection-password")
+	}
+
+	input := map[string]interface{}{
+		"name":           opts.name,
+		"dsType":         original.DsType,
+		"hostname":       original.Hostname,
+		"port":           original.Port,
+		"accountId":      original.AccountId,
+		"dbName":         original.DbName,
+		"defaultSchema":  original.DefaultSchema,
+		"labels":         original.Labels,
+		"keepalive":      original.Keepalive,
+		"connectTimeout": original.ConnectTimeout,
+		"username":       original.Username,
+		"password":       opts.password,
+		"skipTest":       opts.skipTest,
+	}
+	overrides := []struct {
+		flag  string
+		field string
+		value interface{}
+	}{
+		{"connection-hostname", "hostname", opts.hostname},
+		{"connection-port", "port", opts.port},
+		{"connection-account-id", "accountId", opts.accountId},
+		{"connection-db-name", "dbName", opts.dbName},
+		{"connection-default-schema", "defaultSchema", opts.defaultSchema},
+		{"connection-keepalive", "keepalive", opts.keepalive},
+		{"connection-connect-timeout", "connectTimeout", opts.connectTimeout},
+		{"connection-username", "username", opts.username},
+	}
+	for _, override := range overrides {
+		if changed(override.flag) {
+			input[override.field] = override.value
+		}
+	}
+
+	var created struct {
+		Connection data.Connection `json:"connection"`
+	}
+	err = client.Post("/api/v1/connections", input, &created)
+
+	var validationErr *apiClient.ValidationError
+	if errors.As(err, &validationErr) {
+		var problems []string
+		for field, message := range validationErr.Errors {
+			problems = append(problems, fmt.Sprintf("%s: %s", field, message))
+		}
+		sort.Strings(problems)
+		return data.Connection{}, errors.New(strings.Join(problems, ", "))
+	}
+
+	return created.Connection, err
+}
@@ -0,0 +1,106 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+// A server holding connections by id, that like the real one never sends
+// passwords and refuses duplicate names
+func newFakeConnectionServer(t *testing.T) (*httptest.Server, map[int64]map[string]interface{}) {
+	var mu sync.Mutex
+	connections := map[int64]map[string]interface{}{
+		1: {"id": 1, "name": "prod", "dsType": "postgresql", "hostname": "prod.internal", "port": 5432, "dbName": "app", "username": "etl", "password": "prod-secret", "labels": map[string]string{"env": "prod"}},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/connections/1":
+			shown := map[string]interface{}{}
+			for field, value := range connections[1] {
+				if field != "password" {
+					shown[field] = value
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"connection": shown})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/connections":
+			var input map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&input)
+			for _, connection := range connections {
+				if connection["name"] == input["name"] {
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					fmt.Fprint(w, `{"error":{"name":"a connection with this name already exists"}}`)
+					return
+				}
+			}
+			id := int64(len(connections) + 1)
+			input["id"] = id
+			connections[id] = input
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintf(w, `{"connection":{"id":%d,"name":%q}}`, id, input["name"])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, connections
+}
+
+func TestCloneConnection(t *testing.T) {
+	srv, connections := newFakeConnectionServer(t)
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	changed := func(flag string) bool { return flag == "connection-hostname" }
+	opts := cloneOptions{name: "staging", hostname: "staging.internal", password: "staging-secret"}
+	connection, err := cloneConnection(client, 1, opts, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connection.ID != 2 || connection.Name != "staging" {
+		t.Fatalf("wanted connection 2 named staging, got %d named %s", connection.ID, connection.Name)
+	}
+
+	clone, original := connections[2], connections[1]
+	for field, want := range map[string]interface{}{
+		"dsType":   "postgresql",
+		"hostname": "staging.internal",
+		"port":     float64(5432),
+		"dbName":   "app",
+		"username": "etl",
+		"password": "staging-secret",
+	} {
+		if clone[field] != want {
+			t.Errorf("wanted the clone's %s to be %v, got %v", field, want, clone[field])
+		}
+	}
+	if labels, _ := clone["labels"].(map[string]interface{}); labels["env"] != "prod" {
+		t.Errorf("wanted the labels copied, got %v", clone["labels"])
+	}
+	if original["hostname"] != "prod.internal" || original["password"] != "prod-secret" {
+		t.Errorf("cloning changed the original: %v", original)
+	}
+
+	_, err = cloneConnection(client, 1, cloneOptions{name: "staging-2"}, changed)
+	if err == nil || !strings.Contains(err.Error(), "--connection-password") {
+		t.Errorf("wanted a clone without a password refused, got %v", err)
+	}
+
+	_, err = cloneConnection(client, 1, cloneOptions{name: "staging", password: "x"}, changed)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("wanted a duplicate name refused, got %v", err)
+	}
+	if len(connections) != 2 {
+		t.Errorf("wanted no connection created by refused clones, got %d connections", len(connections))
+	}
+}
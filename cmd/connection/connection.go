@@ -0,0 +1,10 @@
+package connection
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var ConnectionCmd = &cobra.Command{
+	Use:   "connection",
+	Short: "Manage connections on a SQLpipe server",
+}
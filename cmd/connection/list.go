@@ -0,0 +1,129 @@
+package connection
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+var ListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the connections on a SQLpipe server",
+	Args:  cobra.NoArgs,
+	Run:   runList,
+}
+
+type listOptions struct {
+	page     int
+	pageSize int
+	sort     string
+	ping     bool
+	labels   []string
+}
+
+var (
+	listClient apiClient.Client
+	list       listOptions
+)
+
+// Only the fields that are safe to print. Credentials are never decoded, even
+// if a server sends them.
+type listedConnection struct {
+	ID         int64       `json:"id"`
+	Name       string      `json:"name"`
+	DsType     string      `json:"dsType"`
+	AccountId  string      `json:"accountID"`
+	Hostname   string      `json:"hostname"`
+	Port       int         `json:"port"`
+	CanConnect bool        `json:"canConnect"`
+	Labels     data.Labels `json:"labels"`
+}
+
+func init() {
+	listClient.AddFlags(ListCmd)
+	ListCmd.Flags().IntVar(&list.page, "page", 1, "Page of results to show")
+	ListCmd.Flags().IntVar(&list.pageSize, "page-size", 20, "Connections per page")
+	ListCmd.Flags().StringVar(&list.sort, "sort", "id", "Sort by id, created_at, name or ds_type. Prefix with - to sort descending")
+	ListCmd.Flags().BoolVar(&list.ping, "ping", false, "Test each connection and show whether it's reachable")
+	ListCmd.Flags().StringArrayVar(&list.labels, "label", nil, "Only list connections with these labels, like env=prod or env=prod,team=data. May be repeated")
+
+	ConnectionCmd.AddCommand(ListCmd)
+}
+
+func runList(cmd *cobra.Command, args []string) {
+	err := listConnections(&listClient, list, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func listConnections(client *apiClient.Client, opts listOptions, out io.Writer) error {
+	qs := url.Values{}
+	qs.Set("page", strconv.Itoa(opts.page))
+	qs.Set("page_size", strconv.Itoa(opts.pageSize))
+	qs.Set("sort", opts.sort)
+	qs.Set("ping", strconv.FormatBool(opts.ping))
+	for _, selector := range opts.labels {
+		qs.Add("label", selector)
+	}
+
+	var body struct {
+		Connections []listedConnection `json:"connections"`
+		Metadata    data.Metadata      `json:"metadata"`
+	}
+	err := client.Get("/api/v1/connections?"+qs.Encode(), &body)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if opts.ping {
+		fmt.Fprintln(tw, "ID\tNAME\tTYPE\tHOST\tLABELS\tREACHABLE")
+	} else {
+		fmt.Fprintln(tw, "ID\tNAME\tTYPE\tHOST\tLABELS")
+	}
+
+	for _, connection := range body.Connections {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s", connection.ID, connection.Name, connection.DsType, connectionHost(connection), connection.Labels)
+		if opts.ping {
+			fmt.Fprintf(tw, "\t%s", yesNo(connection.CanConnect))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if body.Metadata.LastPage > 1 {
+		fmt.Fprintf(out, "Page %d of %d, %d connections\n", body.Metadata.CurrentPage, body.Metadata.LastPage, body.Metadata.TotalRecords)
+	}
+	return nil
+}
+
+// Snowflake connections are addressed by account rather than host
+func connectionHost(connection listedConnection) string {
+	if connection.DsType == "snowflake" {
+		return connection.AccountId
+	}
+	if connection.Port == 0 {
+		return connection.Hostname
+	}
+	return fmt.Sprintf("%s:%d", connection.Hostname, connection.Port)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
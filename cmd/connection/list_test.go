@@ -0,0 +1,64 @@
+package connection
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestListConnections(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/connections" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		// a server that wrongly sends passwords still mustn't get them printed
+		fmt.Fprint(w, `{"connections":[
+			{"id":1,"name":"app","dsType":"postgresql","hostname":"db.internal","port":5432,"username":"etl","password":"hunter2","canConnect":true,"labels":{"team":"data","env":"prod"}},
+			{"id":2,"name":"warehouse","dsType":"snowflake","accountID":"xy12345","username":"etl","password":"s3cret","canConnect":false}
+		],"metadata":{"current_page":1,"page_size":20,"first_page":1,"last_page":1,"total_records":2}}`)
+	}))
+	defer srv.Close()
+
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	var out bytes.Buffer
+	err := listConnections(client, listOptions{page: 1, pageSize: 20, sort: "-name", ping: true, labels: []string{"env=prod"}}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, param := range []string{"page=1", "page_size=20", "sort=-name", "ping=true", "label=env%3Dprod"} {
+		if !strings.Contains(query, param) {
+			t.Errorf("wanted %s in the request's query, got %q", param, query)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("wanted a header and 2 connections, got:\n%s", out.String())
+	}
+	want := [][]string{
+		{"ID", "NAME", "TYPE", "HOST", "LABELS", "REACHABLE"},
+		{"1", "app", "postgresql", "db.internal:5432", "env=prod,team=data", "yes"},
+		{"2", "warehouse", "snowflake", "xy12345", "no"},
+	}
+	for i, fields := range want {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(fields, " ") {
+			t.Errorf("line %d: wanted %v, got %v", i, fields, got)
+		}
+	}
+
+	for _, secret := range []string{"hunter2", "s3cret", "password"} {
+		if strings.Contains(strings.ToLower(out.String()), secret) {
+			t.Errorf("listing leaked %q:\n%s", secret, out.String())
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package doctor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/cmd/initialize"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/engine"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+var (
+	DoctorCmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that SQLpipe's database and connections are set up correctly",
+		Long: `Check that SQLpipe's database and connections are set up correctly.
+
+Connects to the PostgreSQL database serve runs on, checks initialize has
+created its tables and that there's an admin, then pings every configured
+connection. Exits non-zero if any check fails.`,
+		Args: cobra.NoArgs,
+		Run:  runDoctor,
+	}
+
+	dsn         string
+	engineFlags globals.EngineFlags
+)
+
+func init() {
+	DoctorCmd.Flags().StringVar(&dsn, "dsn", "", "Database backend connection string. Defaults to one assembled from PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE and PGSSLMODE")
+	engineFlags.Register(DoctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	if dsn == "" {
+		dsn = globals.PostgresEnvDSN(os.Getenv)
+	}
+
+	// connections are pinged the way serve reaches them
+	errProperties, err := engineFlags.Apply()
+	if err != nil {
+		globals.Errorf("%v %v\n", err, errProperties)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		globals.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	if !diagnose(db, globals.Stdout, isTerminal(os.Stdout)) {
+		os.Exit(1)
+	}
+}
+
+type report struct {
+	out   io.Writer
+	color bool
+	ok    bool
+}
+
+func (r *report) pass(check string, format string, a ...interface{}) {
+	r.line("\x1b[32m", "ok", check, fmt.Sprintf(format, a...))
+}
+
+func (r *report) fail(check string, format string, a ...interface{}) {
+	r.ok = false
+	r.line("\x1b[31m", "FAIL", check, fmt.Sprintf(format, a...))
+}
+
+func (r *report) line(color string, status string, check string, detail string) {
+	status = fmt.Sprintf("%-4s", status)
+	if r.color {
+		status = color + status + "\x1b[0m"
+	}
+	fmt.Fprintf(r.out, "[%s] %s: %s\n", status, check, detail)
+}
+
+// Runs every check against db, printing a line for each, and reports
+// whether they all passed. Checks that need something an earlier check
+// found missing are left out.
+func diagnose(db *sql.DB, out io.Writer, color bool) bool {
+	r := &report{out: out, color: color, ok: true}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var serverVersion string
+	err := db.QueryRowContext(ctx, "SHOW server_version").Scan(&serverVersion)
+	if err != nil {
+		r.fail("PostgreSQL", "unable to connect: %v", err)
+		return r.ok
+	}
+	r.pass("PostgreSQL", "connected")
+
+	version := globals.SqlpipeVersion
+	if version == "" {
+		version = "development build"
+	}
+	r.pass("Version", "SQLpipe %s, PostgreSQL %s", version, serverVersion)
+
+	missing, err := initialize.MissingTables(db)
+	if err != nil {
+		r.fail("Schema", "unable to check: %v", err)
+		return r.ok
+	}
+	if len(missing) > 0 {
+		r.fail("Schema", "missing tables %s. Run sqlpipe initialize", strings.Join(missing, ", "))
+	} else {
+		r.pass("Schema", "initialized")
+	}
+
+	models := data.NewModels(db)
+
+	if !validator.In("users", missing...) {
+		admins, err := models.Users.CountAdmins()
+		switch {
+		case err != nil:
+			r.fail("Admin", "unable to count admins: %v", err)
+		case admins == 0:
+			r.fail("Admin", "no admin users. Create one with sqlpipe serve --create-admin")
+		default:
+			r.pass("Admin", "%d found", admins)
+		}
+	}
+
+	if !validator.In("connections", missing...) {
+		checkConnections(r, models.Connections)
+	}
+
+	return r.ok
+}
+
+// Pings every connection, a page at a time
+func checkConnections(r *report, connections data.ConnectionModel) {
+	filters := data.Filters{Page: 1, PageSize: data.DefaultMaxPageSize, Sort: "id"}
+	checked := 0
+	for {
+		page, metadata, err := connections.GetAll(filters)
+		if err != nil {
+			r.fail("Connections", "unable to list: %v", err)
+			return
+		}
+
+		for _, connection := range page {
+			check := fmt.Sprintf("Connection %s", connection.Name)
+			_, errProperties, err := engine.TestConnection(connection)
+			if err != nil {
+				r.fail(check, "%v %v", err, errProperties)
+			} else {
+				r.pass(check, "%s reachable", connection.DsType)
+			}
+			checked++
+		}
+
+		if filters.Page >= metadata.LastPage {
+			break
+		}
+		filters.Page++
+	}
+
+	if checked == 0 {
+		r.pass("Connections", "none configured")
+	}
+}
+
+// Colors are only written to terminals, not to files or pipes
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
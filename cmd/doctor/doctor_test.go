@@ -0,0 +1,170 @@
+package doctor
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+// A database initialize has run on, with one admin and no connections,
+// except for the tables in missing
+func fakeEnvironment(missing ...string) fakeHandler {
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "server_version"):
+			return []string{"server_version"}, [][]driver.Value{{"14.2"}}, nil
+		case strings.Contains(query, "to_regclass"):
+			exists := true
+			for _, table := range missing {
+				if args[0] == table {
+					exists = false
+				}
+			}
+			return []string{"exists"}, [][]driver.Value{{exists}}, nil
+		case strings.Contains(query, "from users where admin"):
+			return []string{"count"}, [][]driver.Value{{int64(1)}}, nil
+		case strings.Contains(query, "FROM connections"):
+			return make([]string, 17), nil, nil
+		}
+		return nil, nil, fmt.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		var out strings.Builder
+		if !diagnose(newFakeDB(t, fakeEnvironment()), &out, false) {
+			t.Errorf("wanted every check to pass, got:\n%s", out.String())
+		}
+		for _, line := range []string{
+			"[ok  ] PostgreSQL: connected\n",
+			"[ok  ] Schema: initialized\n",
+			"[ok  ] Admin: 1 found\n",
+			"[ok  ] Connections: none configured\n",
+		} {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("wanted %q in the report, got:\n%s", line, out.String())
+			}
+		}
+		if strings.Contains(out.String(), "\x1b[") {
+			t.Errorf("wanted no colors when they weren't asked for, got %q", out.String())
+		}
+	})
+
+	t.Run("missing schema", func(t *testing.T) {
+		var out strings.Builder
+		if diagnose(newFakeDB(t, fakeEnvironment("queries", "transfer_templates")), &out, true) {
+			t.Errorf("wanted the missing tables to fail the checks, got:\n%s", out.String())
+		}
+		want := "[\x1b[31mFAIL\x1b[0m] Schema: missing tables queries, transfer_templates. Run sqlpipe initialize\n"
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("wanted %q in the report, got:\n%s", want, out.String())
+		}
+		if !strings.Contains(out.String(), "Admin: 1 found") {
+			t.Errorf("wanted the checks that don't need the missing tables still run, got:\n%s", out.String())
+		}
+	})
+}
+
+// Listens like a PostgreSQL server that asks for a cleartext password, and
+// sends each password it's given on passwords
+func fakePostgreSQLServer(t *testing.T, passwords chan<- string) (host string, port int) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+				if _, err := backend.ReceiveStartupMessage(); err != nil {
+					return
+				}
+				backend.Send(&pgproto3.AuthenticationCleartextPassword{})
+				message, err := backend.Receive()
+				if err != nil {
+					return
+				}
+				if password, ok := message.(*pgproto3.PasswordMessage); ok {
+					passwords <- password.Password
+				}
+				backend.Send(&pgproto3.AuthenticationOk{})
+				backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+				for {
+					message, err := backend.Receive()
+					if err != nil {
+						return
+					}
+					if _, ok := message.(*pgproto3.Query); ok {
+						backend.Send(&pgproto3.EmptyQueryResponse{})
+						backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestDiagnoseResolvesSecretReferences(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/app" || r.Header.Get("X-Vault-Token") != "root" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"password":"hunter2"}}`)
+	}))
+	defer vault.Close()
+
+	flags := globals.EngineFlags{SecretProvider: "vault", VaultAddr: vault.URL, VaultToken: "root"}
+	if errProperties, err := flags.Apply(); err != nil {
+		t.Fatalf("%v %v", err, errProperties)
+	}
+	defer globals.EngineFlags{}.Apply()
+
+	passwords := make(chan string, 1)
+	host, port := fakePostgreSQLServer(t, passwords)
+
+	environment := fakeEnvironment()
+	db := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "FROM connections") {
+			return make([]string, 17), [][]driver.Value{{int64(1), int64(1), time.Now(), "app", "postgresql", "etl", "secret:kv/app#password", "", host, int64(port), "app", "", []byte("{}"), int64(0), int64(0), "disable", int64(1)}}, nil
+		}
+		return environment(query, args)
+	})
+
+	var out strings.Builder
+	if !diagnose(db, &out, false) {
+		t.Errorf("wanted the connection reached with its resolved password, got:\n%s", out.String())
+	}
+	select {
+	case password := <-passwords:
+		if password != "hunter2" {
+			t.Errorf("wanted the secret sent as the password, got %q", password)
+		}
+	default:
+		t.Error("wanted the connection to log in")
+	}
+	if want := "[ok  ] Connection app: postgresql reachable\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("wanted %q in the report, got:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,17 @@
+package doctor
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+// Answers a query with rows, so the checks can run without a live database
+type fakeHandler func(query string, args []driver.Value) (columns []string, rows [][]driver.Value, err error)
+
+func newFakeDB(t *testing.T, handler fakeHandler) *sql.DB {
+	t.Helper()
+	return testdb.Open(t, "doctor", &testdb.DB{Handler: testdb.Rows(handler)})
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
 	"github.com/sqlpipe/sqlpipe/internal/jsonLog"
 )
 
@@ -30,7 +31,9 @@ var (
 			created_at timestamp(0) NOT NULL DEFAULT NOW(),
 			username text UNIQUE NOT NULL,
 			password_hash bytea NOT NULL,
+			password_history bytea[] NOT NULL DEFAULT '{}',
 			admin bool NOT NULL DEFAULT false,
+			deleted_at timestamp(0),
 			version INT NOT NULL DEFAULT 1
 		);
 	`
@@ -47,6 +50,11 @@ var (
 			hostname TEXT NOT NULL DEFAULT '',
 			port INT NOT NULL DEFAULT 0,
 			db_name TEXT NOT NULL,
+			default_schema TEXT NOT NULL DEFAULT '',
+			labels jsonb NOT NULL DEFAULT '{}',
+			keepalive INT NOT NULL DEFAULT 0,
+			connect_timeout INT NOT NULL DEFAULT 0,
+			ssl_mode TEXT NOT NULL DEFAULT '',
 			version INT NOT NULL DEFAULT 1
 		);
 	`
@@ -55,15 +63,43 @@ var (
 		CREATE TABLE transfers (
 			id bigserial PRIMARY KEY,
 			created_at timestamp(0) NOT NULL DEFAULT NOW(),
+			created_by bigint not null default 0,
+			request_id text not null default '',
 			source_id bigint not null,
 			target_id bigint not null,
 			query text not null,
+			query_args jsonb not null default '[]',
 			target_schema text not null,
 			target_table text not null,
 			overwrite bool not null,
+			write_mode text not null default '',
+			conflict_columns text[] not null default '{}',
+			target_column_order text[] not null default '{}',
+			verify_checksum bool not null default false,
+			isolation_level text not null default '',
+			statement_timeout int not null default 0,
+			max_errors int not null default 0,
+			rejected_rows bigint not null default 0,
+			rows_written bigint not null default 0,
+			source_limit int not null default 0,
+			sample_rate double precision not null default 0,
+			create_target_schema bool not null default false,
+			pre_load_sql text[] not null default '{}',
+			parallelism int not null default 0,
+			chunk_column text not null default '',
+			target_table_pattern text not null default '',
+			create_target_table bool not null default false,
+			source_columns text[] not null default '{}',
+			exclude_columns text[] not null default '{}',
+			max_buffered_rows integer not null default 0,
+			max_buffered_bytes bigint not null default 0,
+			rerun_of bigint not null default 0,
+			depends_on bigint[] not null default '{}',
 			status text not null default 'queued',
 			error text not null default '',
 			error_properties text not null default '',
+			log text[] not null default '{}',
+			log_dropped bigint not null default 0,
 			stopped_at timestamp(0) not null,
 			Version int not null default 1,
 			FOREIGN KEY (source_id) REFERENCES connections(id),
@@ -85,22 +121,56 @@ var (
 		FOREIGN KEY (connection_id) REFERENCES connections(id)
 	);
 `
+
+	createIdempotencyKeys = `
+		CREATE TABLE idempotency_keys (
+			user_id bigint not null,
+			key text not null,
+			transfer_id bigint,
+			created_at timestamp(0) NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, key),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (transfer_id) REFERENCES transfers(id) ON DELETE CASCADE
+		);
+	`
+
+	createTransferTemplates = `
+		CREATE TABLE transfer_templates (
+			id bigserial PRIMARY KEY,
+			created_at timestamp(0) NOT NULL DEFAULT NOW(),
+			name text UNIQUE NOT NULL,
+			definition jsonb NOT NULL,
+			version INT NOT NULL DEFAULT 1
+		);
+	`
 )
 
 func init() {
-	InitializeCmd.Flags().StringVar(&dsn, "dsn", "", "Database backend connection string")
+	InitializeCmd.Flags().StringVar(&dsn, "dsn", "", "Database backend connection string. Defaults to one assembled from PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE and PGSSLMODE")
 	InitializeCmd.Flags().BoolVar(&force, "force", false, "Do not ask for confirmation")
 }
 
 func initialize(cmd *cobra.Command, args []string) {
-	logger := jsonLog.New(os.Stdout, jsonLog.LevelInfo)
+	logger := jsonLog.New(os.Stdout, globals.LogLevel())
+
+	shownDsn := dsn
+	if dsn == "" {
+		dsn = globals.PostgresEnvDSN(os.Getenv)
+		// Keep PGPASSWORD, which wasn't typed in, out of the confirmation prompt
+		shownDsn = globals.PostgresEnvDSN(func(name string) string {
+			if name == "PGPASSWORD" {
+				return ""
+			}
+			return os.Getenv(name)
+		})
+	}
 
 	if dsn == "" {
-		logger.PrintFatal(errors.New("you must supply a database connection string, or DSN, to initialize a DB"), nil)
+		logger.PrintFatal(errors.New("you must supply a database connection string, or DSN, or set the PG* environment variables, to initialize a DB"), nil)
 	}
 
 	if !force {
-		confirmed := confirm()
+		confirmed := confirm(shownDsn)
 		if !confirmed {
 			logger.PrintInfo("Exiting.", nil)
 			return
@@ -118,7 +188,7 @@ func initialize(cmd *cobra.Command, args []string) {
 	logger.PrintInfo("successfully migrated DB", nil)
 }
 
-func confirm() bool {
+func confirm(dsn string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	var answer bool
 
@@ -158,6 +228,29 @@ func openDB(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
+// The tables initialize creates, in the order it creates them
+var tables = []string{"users", "connections", "transfers", "queries", "idempotency_keys", "transfer_templates"}
+
+// The tables initialize creates that aren't in db, in the order it creates
+// them. Empty once the database has been initialized.
+func MissingTables(db *sql.DB) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var missing []string
+	for _, table := range tables {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, table)
+		}
+	}
+	return missing, nil
+}
+
 func runMigrations(db *sql.DB) error {
 	_, err := db.Exec(createUsers)
 	if err != nil {
@@ -186,5 +279,19 @@ func runMigrations(db *sql.DB) error {
 		os.Exit(1)
 	}
 
+	_, err = db.Exec(createIdempotencyKeys)
+	if err != nil {
+		fmt.Println("Error running migrations on idempotency_keys table:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	_, err = db.Exec(createTransferTemplates)
+	if err != nil {
+		fmt.Println("Error running migrations on transfer_templates table:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	return err
 }
@@ -1,35 +1,60 @@
 package main
 
 import (
+	"errors"
+	"os"
+
 	_ "github.com/lib/pq"
 	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/cmd/apply"
+	"github.com/sqlpipe/sqlpipe/cmd/connection"
+	"github.com/sqlpipe/sqlpipe/cmd/doctor"
 	"github.com/sqlpipe/sqlpipe/cmd/initialize"
 	"github.com/sqlpipe/sqlpipe/cmd/query"
 	"github.com/sqlpipe/sqlpipe/cmd/serve"
 	"github.com/sqlpipe/sqlpipe/cmd/transfer"
+	"github.com/sqlpipe/sqlpipe/cmd/user"
 	"github.com/sqlpipe/sqlpipe/cmd/version"
+	"github.com/sqlpipe/sqlpipe/cmd/whoami"
 	"github.com/sqlpipe/sqlpipe/internal/globals"
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "sqlpipe",
 	Short: "SQLPipe makes it easy to move data between data systems.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if globals.Quiet && globals.Verbose {
+			return errors.New("--quiet and --verbose can't be used together")
+		}
+		return nil
+	},
 }
 
 var gitHash string
 var sqlpipeVersion string
+var buildDate string
 
 func init() {
+	rootCmd.PersistentFlags().BoolVarP(&globals.Quiet, "quiet", "q", false, "Only print errors and results, not progress or success messages")
+	rootCmd.PersistentFlags().BoolVarP(&globals.Verbose, "verbose", "v", false, "Print debug detail, such as each request made to a SQLpipe server")
 	rootCmd.AddCommand(serve.ServeCmd)
 	rootCmd.AddCommand(initialize.InitializeCmd)
 	rootCmd.AddCommand(transfer.TransferCmd)
 	rootCmd.AddCommand(query.QueryCmd)
+	rootCmd.AddCommand(whoami.WhoamiCmd)
+	rootCmd.AddCommand(user.UserCmd)
+	rootCmd.AddCommand(connection.ConnectionCmd)
+	rootCmd.AddCommand(apply.ApplyCmd)
+	rootCmd.AddCommand(doctor.DoctorCmd)
 
 	globals.GitHash = gitHash
 	globals.SqlpipeVersion = sqlpipeVersion
+	globals.BuildDate = buildDate
 	rootCmd.AddCommand(version.VersionCmd)
 }
 
 func main() {
-	rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
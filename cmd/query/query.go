@@ -1,12 +1,15 @@
 package query
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/sqlpipe/sqlpipe/internal/data"
 	"github.com/sqlpipe/sqlpipe/internal/engine"
 	"github.com/sqlpipe/sqlpipe/internal/globals"
+	"github.com/sqlpipe/sqlpipe/internal/validator"
 )
 
 var QueryCmd = &cobra.Command{
@@ -15,26 +18,140 @@ var QueryCmd = &cobra.Command{
 	Run:   runQuery,
 }
 
-var query data.Query
+var (
+	query           data.Query
+	explain         bool
+	analyze         bool
+	outputFile      string
+	scriptFile      string
+	continueOnError bool
+	noHeader        bool
+)
 
 func init() {
 	QueryCmd.Flags().StringVar(&query.Query, "query", "", "Query to run")
+	QueryCmd.Flags().BoolVar(&explain, "explain", false, "Print the query's execution plan instead of running it")
+	QueryCmd.Flags().BoolVar(&analyze, "analyze", false, "Use EXPLAIN ANALYZE with --explain. Only allowed on SELECT queries")
+	QueryCmd.Flags().StringVar(&scriptFile, "file", "", "Run each statement of this SQL script in order, instead of --query, printing what each one did")
+	QueryCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "With --file, keep running statements after one fails")
+	QueryCmd.Flags().StringVar(&outputFile, "output-file", "", "Write the query's results to this file. The format comes from the extension, one of [.csv, .ndjson, .jsonl], optionally followed by .gz")
+	QueryCmd.Flags().BoolVar(&noHeader, "no-header", false, "Leave the line of column names out of a .csv --output-file")
+	QueryCmd.Flags().DurationVar(&query.Timeout, "timeout", 0, "Cancel the query if it runs longer than this, like 30s or 5m. 0 means no limit")
 
 	QueryCmd.Flags().StringVar(&query.Connection.DsType, "connection-ds-type", "", "Connection type. Must be one of [postgresql, mysql, mssql, oracle, redshift, snowflake]")
 	QueryCmd.Flags().StringVar(&query.Connection.Hostname, "connection-hostname", "", "Connection's hostname")
 	QueryCmd.Flags().IntVar(&query.Connection.Port, "connection-port", 0, "Connection's port")
 	QueryCmd.Flags().StringVar(&query.Connection.AccountId, "connection-account-id", "", "Connection's account ID (Snowflake only)")
 	QueryCmd.Flags().StringVar(&query.Connection.DbName, "connection-db-name", "", "Connection's DB name")
+	QueryCmd.Flags().StringVar(&query.Connection.DefaultSchema, "connection-default-schema", "", "Schema unqualified names resolve to (a search path on PostgreSQL and Redshift)")
+	QueryCmd.Flags().IntVar(&query.Connection.Keepalive, "connection-keepalive", 0, "Seconds an idle connection waits before keepalives are sent. 0 leaves it to the driver")
+	QueryCmd.Flags().IntVar(&query.Connection.ConnectTimeout, "connection-connect-timeout", 0, "Seconds to wait for the database to accept a connection. 0 leaves it to the driver")
 	QueryCmd.Flags().StringVar(&query.Connection.Username, "connection-username", "", "Connection username")
 	QueryCmd.Flags().StringVar(&query.Connection.Password, "connection-password", "", "Connection password")
 }
 
 func runQuery(cmd *cobra.Command, args []string) {
+	if analyze && !explain {
+		globals.Errorf("--analyze can only be used with --explain\n")
+		os.Exit(1)
+	}
+
+	if continueOnError && scriptFile == "" {
+		globals.Errorf("--continue-on-error can only be used with --file\n")
+		os.Exit(1)
+	}
+
+	if noHeader && outputFile == "" {
+		globals.Errorf("--no-header can only be used with --output-file\n")
+		os.Exit(1)
+	}
+
+	if query.Timeout < 0 {
+		globals.Errorf("--timeout can't be negative\n")
+		os.Exit(1)
+	}
+
+	if query.Timeout > 0 && (scriptFile != "" || explain || outputFile != "") {
+		globals.Errorf("--timeout can't be used with --file, --explain or --output-file\n")
+		os.Exit(1)
+	}
+
+	v := validator.New()
+	data.ValidateKeepalive(v, "keepalive", &query.Connection)
+	data.ValidateConnectTimeout(v, "connectTimeout", &query.Connection)
+	if !v.Valid() {
+		for _, problem := range v.Errors {
+			globals.Errorf("%s\n", problem)
+		}
+		os.Exit(1)
+	}
+
+	if scriptFile != "" {
+		if query.Query != "" || explain || outputFile != "" {
+			globals.Errorf("--file can't be used with --query, --explain or --output-file\n")
+			os.Exit(1)
+		}
+		runScript()
+		return
+	}
+
+	if explain {
+		runExplain()
+		return
+	}
+
+	if outputFile != "" {
+		runExport()
+		return
+	}
+
+	globals.Debugf("running query on %s %s\n", query.Connection.DsType, query.Connection.Hostname)
 	errProperties, err := engine.RunQuery(&query)
+	if errors.Is(err, engine.ErrQueryTimedOut) {
+		globals.Errorf("query timed out after %s\n", query.Timeout)
+		os.Exit(1)
+	}
 	if err != nil {
-		fmt.Println(errProperties, err)
-		return
+		globals.Errorf("%v %v\n", errProperties, err)
+		os.Exit(1)
+	}
+	globals.SendAnonymizedQueryAnalytics(query, false)
+	globals.Infof("Query complete. We make a good team!\n")
+}
+
+func runExplain() {
+	plan, errProperties, err := engine.ExplainQuery(&query, analyze)
+	if err != nil {
+		globals.Errorf("%v %v\n", errProperties, err)
+		os.Exit(1)
+	}
+	for _, line := range plan {
+		fmt.Println(line)
+	}
+}
+
+func runExport() {
+	bytesWritten, errProperties, err := engine.ExportQuery(&query, outputFile, noHeader)
+	if err != nil {
+		globals.Errorf("%v %v\n", errProperties, err)
+		os.Exit(1)
+	}
+	globals.SendAnonymizedQueryAnalytics(query, false)
+	globals.Infof("Wrote %d bytes to %s\n", bytesWritten, outputFile)
+}
+
+func runScript() {
+	script, err := os.ReadFile(scriptFile)
+	if err != nil {
+		globals.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+	query.Query = string(script)
+
+	errProperties, err := engine.RunScript(&query, continueOnError, globals.Stdout)
+	if err != nil {
+		globals.Errorf("%v %v\n", errProperties, err)
+		os.Exit(1)
 	}
 	globals.SendAnonymizedQueryAnalytics(query, false)
-	fmt.Println("Query complete. We make a good team!")
 }
@@ -0,0 +1,250 @@
+package serve
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Field name styles the API can send responses in. By default, names are
+// sent as the response models define them, which mixes the two.
+const (
+	jsonCasingCamel = "camel"
+	jsonCasingSnake = "snake"
+)
+
+func validateJSONCasing(casing string) error {
+	switch casing {
+	case "", jsonCasingCamel, jsonCasingSnake:
+		return nil
+	default:
+		return fmt.Errorf("--json-casing must be camel or snake, not %q", casing)
+	}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	envelopeType      = reflect.TypeOf(envelope{})
+)
+
+// Marshals value like json.Marshal, with its struct field names, and the keys
+// of envelopes, in the given casing. Other maps are keyed by data, such as
+// column names, labels and template variables, so their keys are sent as
+// they are.
+func marshalCased(value interface{}, casing string) ([]byte, error) {
+	if casing == "" {
+		return json.Marshal(value)
+	}
+
+	rename := snakeCase
+	if casing == jsonCasingCamel {
+		rename = camelCase
+	}
+
+	var buf bytes.Buffer
+	err := writeCased(&buf, reflect.ValueOf(value), rename)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCased(buf *bytes.Buffer, v reflect.Value, rename func(string) string) error {
+	if !v.IsValid() {
+		buf.WriteString("null")
+		return nil
+	}
+
+	// types that marshal themselves, like time.Time, are written as they are
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return writeMarshaled(buf, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeCased(buf, v.Elem(), rename)
+	case reflect.Struct:
+		buf.WriteByte('{')
+		_, err := writeCasedFields(buf, v, rename, true)
+		buf.WriteByte('}')
+		return err
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return writeMarshaled(buf, v)
+		}
+		return writeCasedMap(buf, v, rename)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			return writeMarshaled(buf, v)
+		}
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCased(buf, v.Index(i), rename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	default:
+		return writeMarshaled(buf, v)
+	}
+}
+
+func writeMarshaled(buf *bytes.Buffer, v reflect.Value) error {
+	js, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(js)
+	return nil
+}
+
+// Writes v's exported fields the way encoding/json names and omits them, with
+// the names recased. The fields of untagged embedded structs are promoted.
+func writeCasedFields(buf *bytes.Buffer, v reflect.Value, rename func(string) string, first bool) (bool, error) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma:]
+		}
+
+		value := v.Field(i)
+		if field.Anonymous && name == "" {
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				var err error
+				first, err = writeCasedFields(buf, value, rename, first)
+				if err != nil {
+					return first, err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if strings.Contains(options, ",omitempty") && isEmptyJSONValue(value) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		key, _ := json.Marshal(rename(name))
+		buf.Write(key)
+		buf.WriteByte(':')
+		if err := writeCased(buf, value, rename); err != nil {
+			return first, err
+		}
+	}
+	return first, nil
+}
+
+// Envelopes hold the response's top level fields, so only their keys are
+// recased. Keys are sorted, like encoding/json sorts them.
+func writeCasedMap(buf *bytes.Buffer, v reflect.Value, rename func(string) string) error {
+	keys := make([]string, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name := key
+		if v.Type() == envelopeType {
+			name = rename(key)
+		}
+		js, _ := json.Marshal(name)
+		buf.Write(js)
+		buf.WriteByte(':')
+		if err := writeCased(buf, v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())), rename); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// The values omitempty leaves out
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// createdAt becomes created_at, and a run of capitals is one word, so
+// sourceID becomes source_id and preLoadSQL becomes pre_load_sql
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				builder.WriteRune('_')
+			}
+		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
+	return builder.String()
+}
+
+// Goes through snakeCase first, so sourceID and current_page come out as
+// sourceId and currentPage
+func camelCase(name string) string {
+	words := strings.Split(snakeCase(name), "_")
+	var builder strings.Builder
+	for i, word := range words {
+		if i > 0 && word != "" {
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			word = string(runes)
+		}
+		builder.WriteString(word)
+	}
+	return builder.String()
+}
@@ -17,6 +17,7 @@ import (
 type listConnectionsInput struct {
 	Name   string
 	DsType string
+	Ping   bool
 	data.Filters
 }
 
@@ -27,9 +28,16 @@ func (app *application) getListConnectionsInput(r *http.Request) (input listConn
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 10, v)
+	input.Filters.MaxPageSize = app.config.maxPageSize
 
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+	input.Filters.Sort = app.readString(qs, "sort", app.defaultSort("connections"))
+	input.Filters.SortSafelist = sortSafelists["connections"]
+
+	// testing every connection on the page can be slow, so API clients may
+	// skip it
+	input.Ping = app.readBool(qs, "ping", true, v)
+
+	input.Filters.Labels = app.readLabels(qs, "label", v)
 
 	data.ValidateFilters(v, input.Filters)
 
@@ -52,7 +60,7 @@ func (app *application) listConnectionsUiHandler(w http.ResponseWriter, r *http.
 	connections, errProperties, err := engine.TestConnections(connections)
 	if err != nil {
 		if err != nil {
-			app.logger.PrintError(err, errProperties)
+			app.logEngineError(r, err, errProperties)
 		}
 	}
 
@@ -80,15 +88,36 @@ func (app *application) createConnectionUiHandler(w http.ResponseWriter, r *http
 		}
 	}
 
+	keepalive := 0
+	if r.PostForm.Get("keepalive") != "" {
+		keepalive, err = strconv.Atoi(r.PostForm.Get("keepalive"))
+		if err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "non int value given to keepalive")
+			return
+		}
+	}
+
+	connectTimeout := 0
+	if r.PostForm.Get("connectTimeout") != "" {
+		connectTimeout, err = strconv.Atoi(r.PostForm.Get("connectTimeout"))
+		if err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "non int value given to connect timeout")
+			return
+		}
+	}
+
 	connection := &data.Connection{
-		Name:      r.PostForm.Get("name"),
-		DsType:    r.PostForm.Get("dsType"),
-		Hostname:  r.PostForm.Get("hostname"),
-		Port:      port,
-		AccountId: r.PostForm.Get("accountId"),
-		DbName:    r.PostForm.Get("dbName"),
-		Username:  r.PostForm.Get("username"),
-		Password:  r.PostForm.Get("password"),
+		Name:           r.PostForm.Get("name"),
+		DsType:         r.PostForm.Get("dsType"),
+		Hostname:       r.PostForm.Get("hostname"),
+		Port:           port,
+		AccountId:      r.PostForm.Get("accountId"),
+		DbName:         r.PostForm.Get("dbName"),
+		DefaultSchema:  r.PostForm.Get("defaultSchema"),
+		Keepalive:      keepalive,
+		ConnectTimeout: connectTimeout,
+		Username:       r.PostForm.Get("username"),
+		Password:       r.PostForm.Get("password"),
 	}
 
 	form := forms.New(r.PostForm)
@@ -101,7 +130,7 @@ func (app *application) createConnectionUiHandler(w http.ResponseWriter, r *http
 	if r.PostForm.Get("skipTest") != "on" {
 		connection, errProperties, err := engine.TestConnection(connection)
 		if err != nil {
-			app.logger.PrintError(err, errProperties)
+			app.logEngineError(r, err, errProperties)
 		}
 		if !connection.CanConnect {
 			form.Validator.AddError("canConnect", "Unable to connect with given credentials")
@@ -145,7 +174,7 @@ func (app *application) showConnectionUiHandler(w http.ResponseWriter, r *http.R
 
 	connection, errProperties, err := engine.TestConnection(connection)
 	if err != nil {
-		app.logger.PrintError(err, errProperties)
+		app.logEngineError(r, err, errProperties)
 	}
 
 	app.render(w, r, "connection-detail.page.tmpl", &templateData{Connection: connection})
@@ -170,13 +199,16 @@ func (app *application) updateConnectionFormUiHandler(w http.ResponseWriter, r *
 
 	form := forms.New(
 		url.Values{
-			"name":      []string{connection.Name},
-			"dsType":    []string{connection.DsType},
-			"hostname":  []string{connection.Hostname},
-			"port":      []string{fmt.Sprint(connection.Port)},
-			"accountId": []string{connection.AccountId},
-			"dbName":    []string{connection.DbName},
-			"username":  []string{connection.Username},
+			"name":           []string{connection.Name},
+			"dsType":         []string{connection.DsType},
+			"hostname":       []string{connection.Hostname},
+			"port":           []string{fmt.Sprint(connection.Port)},
+			"accountId":      []string{connection.AccountId},
+			"dbName":         []string{connection.DbName},
+			"defaultSchema":  []string{connection.DefaultSchema},
+			"keepalive":      []string{fmt.Sprint(connection.Keepalive)},
+			"connectTimeout": []string{fmt.Sprint(connection.ConnectTimeout)},
+			"username":       []string{connection.Username},
 		},
 	)
 
@@ -211,17 +243,38 @@ func (app *application) updateConnectionUiHandler(w http.ResponseWriter, r *http
 		}
 	}
 
+	keepalive := 0
+	if r.PostForm.Get("keepalive") != "" {
+		keepalive, err = strconv.Atoi(r.PostForm.Get("keepalive"))
+		if err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "non int value given to keepalive")
+			return
+		}
+	}
+
+	connectTimeout := 0
+	if r.PostForm.Get("connectTimeout") != "" {
+		connectTimeout, err = strconv.Atoi(r.PostForm.Get("connectTimeout"))
+		if err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "non int value given to connect timeout")
+			return
+		}
+	}
+
 	connection := &data.Connection{
-		ID:        id,
-		Name:      r.PostForm.Get("name"),
-		DsType:    r.PostForm.Get("dsType"),
-		Hostname:  r.PostForm.Get("hostname"),
-		Port:      port,
-		AccountId: r.PostForm.Get("accountId"),
-		DbName:    r.PostForm.Get("dbName"),
-		Username:  r.PostForm.Get("username"),
-		Password:  r.PostForm.Get("password"),
-		Version:   version,
+		ID:             id,
+		Name:           r.PostForm.Get("name"),
+		DsType:         r.PostForm.Get("dsType"),
+		Hostname:       r.PostForm.Get("hostname"),
+		Port:           port,
+		AccountId:      r.PostForm.Get("accountId"),
+		DbName:         r.PostForm.Get("dbName"),
+		DefaultSchema:  r.PostForm.Get("defaultSchema"),
+		Keepalive:      keepalive,
+		ConnectTimeout: connectTimeout,
+		Username:       r.PostForm.Get("username"),
+		Password:       r.PostForm.Get("password"),
+		Version:        version,
 	}
 
 	form := forms.New(r.PostForm)
@@ -234,7 +287,7 @@ func (app *application) updateConnectionUiHandler(w http.ResponseWriter, r *http
 	if r.PostForm.Get("skipTest") != "on" {
 		connection, errProperties, err := engine.TestConnection(connection)
 		if err != nil {
-			app.logger.PrintError(err, errProperties)
+			app.logEngineError(r, err, errProperties)
 		}
 		if !connection.CanConnect {
 			form.Validator.AddError("canConnect", "Unable to connect with given credentials")
@@ -254,6 +307,9 @@ func (app *application) updateConnectionUiHandler(w http.ResponseWriter, r *http
 		}
 		return
 	}
+
+	engine.EvictDb(connection.ID)
+
 	app.session.Put(r, "flash", fmt.Sprintf("Connection %d updated", id))
 	http.Redirect(w, r, fmt.Sprintf("/ui/connections/%d", id), http.StatusSeeOther)
 }
@@ -276,6 +332,8 @@ func (app *application) deleteConnectionUiHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	engine.EvictDb(id)
+
 	app.session.Put(r, "flash", fmt.Sprintf("Connection %d deleted", id))
 	http.Redirect(w, r, "/ui/connections", http.StatusSeeOther)
 }
@@ -283,15 +341,21 @@ func (app *application) deleteConnectionUiHandler(w http.ResponseWriter, r *http
 func (app *application) createConnectionApiHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
-		Name      string `json:"name"`
-		DsType    string `json:"dsType"`
-		Hostname  string `json:"hostname"`
-		Port      int    `json:"port"`
-		AccountId string `json:"accountId"`
-		DbName    string `json:"dbName"`
-		Username  string `json:"username"`
-		Password  string `json:"password"`
-		SkipTest  bool   `json:"skipTest"`
+		Name           string      `json:"name"`
+		DsType         string      `json:"dsType"`
+		Hostname       string      `json:"hostname"`
+		Port           int         `json:"port"`
+		AccountId      string      `json:"accountId"`
+		DbName         string      `json:"dbName"`
+		DefaultSchema  string      `json:"defaultSchema"`
+		Labels         data.Labels `json:"labels"`
+		Keepalive      int         `json:"keepalive"`
+		ConnectTimeout int         `json:"connectTimeout"`
+		SSLMode        string      `json:"sslMode"`
+		Username       string      `json:"username"`
+		Password       string      `json:"password"`
+		DSN            string      `json:"dsn"`
+		SkipTest       bool        `json:"skipTest"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -301,18 +365,43 @@ func (app *application) createConnectionApiHandler(w http.ResponseWriter, r *htt
 	}
 
 	connection := &data.Connection{
-		Name:      input.Name,
-		DsType:    input.DsType,
-		Hostname:  input.Hostname,
-		Port:      input.Port,
-		AccountId: input.AccountId,
-		DbName:    input.DbName,
-		Username:  input.Username,
-		Password:  input.Password,
+		Name:           input.Name,
+		DsType:         input.DsType,
+		Hostname:       input.Hostname,
+		Port:           input.Port,
+		AccountId:      input.AccountId,
+		DbName:         input.DbName,
+		DefaultSchema:  input.DefaultSchema,
+		Labels:         input.Labels,
+		Keepalive:      input.Keepalive,
+		ConnectTimeout: input.ConnectTimeout,
+		SSLMode:        input.SSLMode,
+		Username:       input.Username,
+		Password:       input.Password,
 	}
 
 	v := validator.New()
 
+	if input.DSN != "" {
+		connection, err = data.ParseConnectionURL(input.DSN)
+		if err != nil {
+			v.AddError("dsn", err.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		connection.Name = input.Name
+		connection.Labels = input.Labels
+		connection.Keepalive = input.Keepalive
+		// a timeout or SSL mode in the URL stands unless the request gives
+		// one too
+		if input.ConnectTimeout != 0 {
+			connection.ConnectTimeout = input.ConnectTimeout
+		}
+		if input.SSLMode != "" {
+			connection.SSLMode = input.SSLMode
+		}
+	}
+
 	if data.ValidateConnection(v, connection); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
@@ -321,7 +410,7 @@ func (app *application) createConnectionApiHandler(w http.ResponseWriter, r *htt
 	if !input.SkipTest {
 		connection, errProperties, err := engine.TestConnection(connection)
 		if err != nil {
-			app.logger.PrintError(err, errProperties)
+			app.logEngineError(r, err, errProperties)
 		}
 		if !connection.CanConnect {
 			v.AddError("canConnect", "Unable to connect with given credentials")
@@ -352,6 +441,7 @@ func (app *application) listConnectionsApiHandler(w http.ResponseWriter, r *http
 	input, validationErrors := app.getListConnectionsInput(r)
 	if !reflect.DeepEqual(validationErrors, map[string]string{}) {
 		app.failedValidationResponse(w, r, validationErrors)
+		return
 	}
 
 	connections, metadata, err := app.models.Connections.GetAll(input.Filters)
@@ -360,9 +450,12 @@ func (app *application) listConnectionsApiHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	connections, errProperties, err := engine.TestConnections(connections)
-	if err != nil {
-		app.logger.PrintError(err, errProperties)
+	if input.Ping {
+		var errProperties map[string]string
+		connections, errProperties, err = engine.TestConnections(connections)
+		if err != nil {
+			app.logEngineError(r, err, errProperties)
+		}
 	}
 
 	err = app.writeJSON(w, http.StatusOK, envelope{"connections": connections, "metadata": metadata}, nil)
@@ -403,14 +496,19 @@ func (app *application) updateConnectionApiHandler(w http.ResponseWriter, r *htt
 	}
 
 	var input struct {
-		Name      *string
-		DsType    *string
-		Hostname  *string
-		Port      *int
-		AccountId *string
-		DbName    *string
-		Username  *string
-		Password  *string
+		Name           *string
+		DsType         *string
+		Hostname       *string
+		Port           *int
+		AccountId      *string
+		DbName         *string
+		DefaultSchema  *string
+		Labels         *data.Labels
+		Keepalive      *int
+		ConnectTimeout *int
+		SSLMode        *string
+		Username       *string
+		Password       *string
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -450,6 +548,21 @@ func (app *application) updateConnectionApiHandler(w http.ResponseWriter, r *htt
 	if input.DbName != nil {
 		connection.DbName = *input.DbName
 	}
+	if input.DefaultSchema != nil {
+		connection.DefaultSchema = *input.DefaultSchema
+	}
+	if input.Labels != nil {
+		connection.Labels = *input.Labels
+	}
+	if input.Keepalive != nil {
+		connection.Keepalive = *input.Keepalive
+	}
+	if input.ConnectTimeout != nil {
+		connection.ConnectTimeout = *input.ConnectTimeout
+	}
+	if input.SSLMode != nil {
+		connection.SSLMode = *input.SSLMode
+	}
 	if input.Username != nil {
 		connection.Username = *input.Username
 	}
@@ -473,6 +586,8 @@ func (app *application) updateConnectionApiHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
+	engine.EvictDb(connection.ID)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"connection": connection}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -497,6 +612,8 @@ func (app *application) deleteConnectionApiHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
+	engine.EvictDb(id)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "connection successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
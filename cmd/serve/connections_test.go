@@ -0,0 +1,91 @@
+package serve
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestListConnectionsHidesPasswords(t *testing.T) {
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		return make([]string, 17), [][]driver.Value{
+			{int64(2), int64(1), created, "app", "postgresql", "etl", "hunter2", "", "db.internal", int64(5432), "app", "", []byte("{}"), int64(0), int64(0), "", int64(1)},
+			{int64(2), int64(2), created, "warehouse", "mssql", "etl", "s3cret", "", "mssql.internal", int64(1433), "dw", "", []byte("{}"), int64(0), int64(0), "", int64(1)},
+		}, nil
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	rr := httptest.NewRecorder()
+	app.listConnectionsApiHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/connections?ping=false", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wanted 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var body struct {
+		Connections []data.Connection `json:"connections"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Connections) != 2 || body.Connections[0].Name != "app" || body.Connections[1].Name != "warehouse" {
+		t.Errorf("wanted connections app and warehouse, got %+v", body.Connections)
+	}
+
+	for _, secret := range []string{"hunter2", "s3cret", "password"} {
+		if strings.Contains(rr.Body.String(), secret) {
+			t.Errorf("listing leaked %q: %s", secret, rr.Body.String())
+		}
+	}
+}
+
+func TestListConnectionsByLabel(t *testing.T) {
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if !strings.Contains(query, "where\n\t(labels @> $3::jsonb)") {
+			return nil, nil, fmt.Errorf("wanted a label condition, got %s", query)
+		}
+		if len(args) != 3 || string(args[2].([]byte)) != `{"env":"prod","team":"data"}` {
+			return nil, nil, fmt.Errorf("wanted the selector bound, got %v", args)
+		}
+		created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		return make([]string, 17), [][]driver.Value{
+			{int64(1), int64(1), created, "app", "postgresql", "etl", "hunter2", "", "db.internal", int64(5432), "app", "", []byte(`{"env":"prod","team":"data"}`), int64(0), int64(0), "", int64(1)},
+		}, nil
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	rr := httptest.NewRecorder()
+	app.listConnectionsApiHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/connections?ping=false&label=env=prod&label=team=data", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wanted 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var body struct {
+		Connections []data.Connection `json:"connections"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Connections) != 1 || body.Connections[0].Labels["env"] != "prod" {
+		t.Errorf("wanted the prod connection with its labels, got %+v", body.Connections)
+	}
+
+	for _, selector := range []string{"env", "env=prod,env=dev", "-env=prod"} {
+		rr := httptest.NewRecorder()
+		app.listConnectionsApiHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/connections?ping=false&label="+url.QueryEscape(selector), nil))
+		if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "label") {
+			t.Errorf("%s: wanted a 422 for a malformed selector, got %d: %s", selector, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -2,14 +2,20 @@ package serve
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/sqlpipe/sqlpipe/internal/data"
 )
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey      = contextKey("user")
+	requestIDContextKey = contextKey("requestID")
+	connContextKey      = contextKey("conn")
+)
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -24,3 +30,31 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 
 	return user
 }
+
+func (app *application) contextSetRequestID(r *http.Request, requestID string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetRequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// Used as the server's ConnContext, so handlers can reach the connection
+// they're writing to
+func contextSetConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey, conn)
+}
+
+// Pushes the connection's write deadline out by the server's write timeout.
+// Streaming handlers call this before each write, so a long stream is only
+// cut off if a single write stalls, not because the whole response takes
+// longer than WriteTimeout.
+func (app *application) extendWriteDeadline(r *http.Request) {
+	conn, ok := r.Context().Value(connContextKey).(net.Conn)
+	if !ok || app.config.timeouts.write <= 0 {
+		return
+	}
+	conn.SetWriteDeadline(time.Now().Add(app.config.timeouts.write))
+}
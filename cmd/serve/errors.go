@@ -1,6 +1,7 @@
 package serve
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 )
@@ -9,9 +10,18 @@ func (app *application) logError(r *http.Request, err error) {
 	app.logger.PrintError(err, map[string]string{
 		"request_method": r.Method,
 		"request_url":    r.URL.String(),
+		"request_id":     app.contextGetRequestID(r),
 	})
 }
 
+func (app *application) logEngineError(r *http.Request, err error, errProperties map[string]string) {
+	properties := map[string]string{"request_id": app.contextGetRequestID(r)}
+	for key, val := range errProperties {
+		properties[key] = val
+	}
+	app.logger.PrintError(err, properties)
+}
+
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
 	env := envelope{"error": message}
 
@@ -48,7 +58,14 @@ func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
 }
 
+// Bodies over the size limit get 413 rather than 400, since the request
+// wasn't wrong, only too big
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *bodyTooLargeError
+	if errors.As(err, &tooLarge) {
+		app.errorResponse(w, r, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
 
@@ -73,6 +90,11 @@ func (app *application) RequireAdminResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
 
+func (app *application) readOnlyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the server is in read only mode, and can't change anything"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
 	app.errorResponse(w, r, http.StatusConflict, message)
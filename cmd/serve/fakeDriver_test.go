@@ -0,0 +1,23 @@
+package serve
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+// Answers a query with rows, so handlers can run against real models without
+// a live database. Exec reports how many rows it returns as rows affected.
+type fakeHandler func(query string, args []driver.Value) (columns []string, rows [][]driver.Value, err error)
+
+// The returned testdb.DB counts the rows handed to database/sql so far.
+// Statements run as they're sent, so transactions have nothing to commit or
+// roll back.
+func newFakeDB(t *testing.T, handler fakeHandler) (*sql.DB, *testdb.DB) {
+	t.Helper()
+
+	fake := &testdb.DB{Handler: testdb.Rows(handler)}
+	return testdb.Open(t, "serve", fake), fake
+}
@@ -11,9 +11,11 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	"github.com/sqlpipe/sqlpipe/internal/data"
 	"github.com/sqlpipe/sqlpipe/internal/validator"
 	"github.com/sqlpipe/sqlpipe/pkg"
 )
@@ -21,12 +23,18 @@ import (
 type envelope map[string]interface{}
 
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	js, err := json.MarshalIndent(data, "", "\t")
+	compact, err := marshalCased(data, app.config.jsonCasing)
 	if err != nil {
 		return err
 	}
 
-	js = append(js, '\n')
+	var js bytes.Buffer
+	err = json.Indent(&js, compact, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	js.WriteByte('\n')
 
 	for key, value := range headers {
 		w.Header()[key] = value
@@ -34,7 +42,7 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	w.Write(js)
+	w.Write(js.Bytes())
 
 	return nil
 }
@@ -65,10 +73,74 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return defaultValue
+	}
+
+	return b
+}
+
+// Reads label selectors, which may be repeated, like
+// ?label=env=prod&label=team=data
+func (app *application) readLabels(qs url.Values, key string, v *validator.Validator) data.Labels {
+	if len(qs[key]) == 0 {
+		return nil
+	}
+
+	labels, err := data.ParseLabelSelector(qs[key])
+	if err != nil {
+		v.AddError(key, err.Error())
+		return nil
+	}
+
+	return labels
+}
+
+// Reads either an RFC3339 timestamp, or a duration like 24h meaning that long
+// before now. Missing values return the zero time.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t
+	}
+
+	d, err := time.ParseDuration(s)
+	if err == nil && d >= 0 {
+		return time.Now().Add(-d)
+	}
+
+	v.AddError(key, "must be an RFC3339 timestamp or a duration like 24h")
+	return time.Time{}
+}
+
+// Returned by readJSON for a body larger than --max-request-body
+type bodyTooLargeError struct {
+	maxBytes int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("body must not be larger than %d bytes", e.maxBytes)
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 
-	maxBytes := 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	maxBytes := app.config.maxRequestBody
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
 
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
@@ -100,7 +172,7 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 			return fmt.Errorf("body contains unknown key %s", fieldName)
 
 		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+			return &bodyTooLargeError{maxBytes: maxBytes}
 
 		case errors.As(err, &invalidUnmarshalError):
 			panic(err)
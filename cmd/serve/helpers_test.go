@@ -0,0 +1,139 @@
+package serve
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+func TestReadTime(t *testing.T) {
+	app := newTestApplication()
+
+	v := validator.New()
+	got := app.readTime(url.Values{"since": {"2022-01-03T10:00:00Z"}}, "since", v)
+	if want := time.Date(2022, 1, 3, 10, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("wanted %v, got %v", want, got)
+	}
+
+	got = app.readTime(url.Values{"since": {"24h"}}, "since", v)
+	if ago := time.Since(got); ago < 24*time.Hour || ago > 24*time.Hour+time.Minute {
+		t.Errorf("wanted about 24h ago, got %v", got)
+	}
+
+	got = app.readTime(url.Values{}, "until", v)
+	if !got.IsZero() {
+		t.Errorf("wanted zero time for a missing value, got %v", got)
+	}
+
+	if !v.Valid() {
+		t.Fatalf("wanted no errors, got %v", v.Errors)
+	}
+
+	app.readTime(url.Values{"until": {"yesterday"}}, "until", v)
+	if _, ok := v.Errors["until"]; !ok {
+		t.Error("wanted an error for an unparseable value")
+	}
+}
+
+func TestWriteJSONCasing(t *testing.T) {
+	transfer := data.Transfer{ID: 9007199254740993, CreatedAt: time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), SourceID: 1, PreLoadSQL: []string{}}
+	metadata := data.Metadata{CurrentPage: 1}
+
+	tests := []struct {
+		casing       string
+		transferKeys []string
+		metadataKey  string
+	}{
+		{"", []string{"createdAt", "sourceID", "preLoadSQL"}, "current_page"},
+		{jsonCasingCamel, []string{"createdAt", "sourceId", "preLoadSql"}, "currentPage"},
+		{jsonCasingSnake, []string{"created_at", "source_id", "pre_load_sql"}, "current_page"},
+	}
+	for _, tt := range tests {
+		app := newTestApplication()
+		app.config.jsonCasing = tt.casing
+
+		rr := httptest.NewRecorder()
+		err := app.writeJSON(rr, 200, envelope{"transfer": transfer, "metadata": metadata}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body map[string]map[string]json.RawMessage
+		err = json.Unmarshal(rr.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range tt.transferKeys {
+			if _, ok := body["transfer"][key]; !ok {
+				t.Errorf("%q casing: wanted a %s field, got %s", tt.casing, key, rr.Body.String())
+			}
+		}
+		if _, ok := body["metadata"][tt.metadataKey]; !ok {
+			t.Errorf("%q casing: wanted a %s field, got %s", tt.casing, tt.metadataKey, rr.Body.String())
+		}
+		if id := string(body["transfer"]["id"]); id != "9007199254740993" {
+			t.Errorf("%q casing: wanted the ID unchanged, got %s", tt.casing, id)
+		}
+	}
+}
+
+func TestWriteJSONCasingKeepsDataKeys(t *testing.T) {
+	for casing, dsTypeKey := range map[string]string{jsonCasingCamel: "dsType", jsonCasingSnake: "ds_type"} {
+		app := newTestApplication()
+		app.config.jsonCasing = casing
+
+		rr := httptest.NewRecorder()
+		err := app.writeJSON(rr, 200, envelope{
+			"connection": data.Connection{Labels: data.Labels{"costCenter": "1", "owner_team": "data"}},
+			"error":      map[string]string{"sourceID": "must be provided"},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var body struct {
+			Connection map[string]json.RawMessage `json:"connection"`
+			Error      map[string]string          `json:"error"`
+		}
+		err = json.Unmarshal(rr.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var labels map[string]string
+		if err = json.Unmarshal(body.Connection["labels"], &labels); err != nil {
+			t.Fatal(err)
+		}
+		if labels["costCenter"] != "1" || labels["owner_team"] != "data" {
+			t.Errorf("%q casing: wanted label keys unchanged, got %s", casing, rr.Body.String())
+		}
+		if _, ok := body.Error["sourceID"]; !ok {
+			t.Errorf("%q casing: wanted validation error keys unchanged, got %s", casing, rr.Body.String())
+		}
+		if _, ok := body.Connection[dsTypeKey]; !ok {
+			t.Errorf("%q casing: wanted a %s field, got %s", casing, dsTypeKey, rr.Body.String())
+		}
+	}
+}
+
+func TestFieldNameCasing(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"createdAt":      {"created_at", "createdAt"},
+		"sourceID":       {"source_id", "sourceId"},
+		"preLoadSQL":     {"pre_load_sql", "preLoadSql"},
+		"current_page":   {"current_page", "currentPage"},
+		"SQLPipeVersion": {"sql_pipe_version", "sqlPipeVersion"},
+		"id":             {"id", "id"},
+	} {
+		if got := snakeCase(name); got != want[0] {
+			t.Errorf("snake case of %s: wanted %s, got %s", name, want[0], got)
+		}
+		if got := camelCase(name); got != want[1] {
+			t.Errorf("camel case of %s: wanted %s, got %s", name, want[1], got)
+		}
+	}
+}
@@ -6,11 +6,14 @@ import (
 	"expvar"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/felixge/httpsnoop"
+	"github.com/google/uuid"
 	"github.com/justinas/nosurf"
 	"github.com/sqlpipe/sqlpipe/internal/data"
 	"github.com/sqlpipe/sqlpipe/internal/validator"
@@ -31,6 +34,21 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+var requestIDRX = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validator.Matches(requestID, requestIDRX) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+
+		next.ServeHTTP(w, app.contextSetRequestID(r, requestID))
+	})
+}
+
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.logger.PrintInfo(
@@ -40,6 +58,7 @@ func (app *application) logRequest(next http.Handler) http.Handler {
 				"Protocol":          r.Proto,
 				"Method":            r.Method,
 				"Requested address": r.URL.RequestURI(),
+				"Request ID":        app.contextGetRequestID(r),
 			},
 		)
 
@@ -121,6 +140,94 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// Requests that don't use a safe method, but don't change anything either
+var readOnlyAllowed = map[string]bool{
+	"/ui/login":                 true,
+	"/api/v1/validate-transfer": true,
+	"/api/v1/estimate-transfer": true,
+}
+
+// With --read-only, refuses every request that could change something,
+// before it reaches authentication, so no role can get around it
+func (app *application) readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.readOnly || readOnlyAllowed[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			app.readOnlyResponse(w, r)
+		}
+	})
+}
+
+// Answers cross-origin requests to the API from the trusted origins. Other
+// origins get no CORS headers, so browsers keep them to the same origin.
+// Preflight requests from a trusted origin are answered here, without
+// reaching the router or authentication.
+func (app *application) enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !originTrusted(origin, app.config.cors.trustedOrigins) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if app.config.cors.allowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(app.config.cors.allowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(app.config.cors.allowedHeaders, ", "))
+			if app.config.cors.maxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(app.config.cors.maxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// A * in a trusted origin matches any run of characters, so
+// https://*.example.com trusts every subdomain
+func originTrusted(origin string, trustedOrigins []string) bool {
+	for _, trusted := range trustedOrigins {
+		star := strings.IndexByte(trusted, '*')
+		if star == -1 {
+			if strings.EqualFold(origin, trusted) {
+				return true
+			}
+			continue
+		}
+
+		prefix, suffix := strings.ToLower(trusted[:star]), strings.ToLower(trusted[star+1:])
+		lower := strings.ToLower(origin)
+		if len(lower) >= len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (app *application) authenticateApi(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		username, password, ok := r.BasicAuth()
@@ -156,7 +263,7 @@ func (app *application) authenticateApi(next http.Handler) http.Handler {
 			return
 		}
 
-		if !match {
+		if !match || user.IsDisabled() {
 			app.invalidCredentialsResponse(w, r)
 			return
 		}
@@ -224,7 +331,11 @@ func (app *application) authenticateUi(next http.Handler) http.Handler {
 		}
 
 		user, err := app.models.Users.GetById(int64(userId))
-		if err == nil {
+		if err == nil && user.IsDisabled() {
+			// sessions started before the user was disabled
+			ctx := context.WithValue(r.Context(), userContextKey, data.AnonymousUser)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		} else if err == nil {
 			ctx := context.WithValue(r.Context(), userContextKey, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		} else if errors.Is(err, data.ErrRecordNotFound) {
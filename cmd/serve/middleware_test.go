@@ -0,0 +1,266 @@
+package serve
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/jsonLog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestApplication() *application {
+	return &application{
+		logger: jsonLog.New(io.Discard, jsonLog.LevelOff),
+		config: config{maxRequestBody: 1_048_576},
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	app := newTestApplication()
+
+	handler := app.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, app.contextGetRequestID(r))
+	}))
+
+	t.Run("echoes incoming id", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/healthcheck", nil)
+		r.Header.Set("X-Request-ID", "trace-1234")
+
+		handler.ServeHTTP(rr, r)
+
+		if got := rr.Header().Get("X-Request-ID"); got != "trace-1234" {
+			t.Errorf("wanted response header %q, got %q", "trace-1234", got)
+		}
+		if rr.Body.String() != "trace-1234" {
+			t.Errorf("wanted request ID %q in context, got %q", "trace-1234", rr.Body.String())
+		}
+	})
+
+	t.Run("generates id when missing or invalid", func(t *testing.T) {
+		for _, incoming := range []string{"", "bad id\nwith newline"} {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/healthcheck", nil)
+			r.Header.Set("X-Request-ID", incoming)
+
+			handler.ServeHTTP(rr, r)
+
+			got := rr.Header().Get("X-Request-ID")
+			if _, err := uuid.Parse(got); err != nil {
+				t.Errorf("wanted a generated UUID for %q, got %q", incoming, got)
+			}
+			if rr.Body.String() != got {
+				t.Errorf("wanted context ID to match header, got %q and %q", rr.Body.String(), got)
+			}
+		}
+	})
+}
+
+func TestEnableCORS(t *testing.T) {
+	app := newTestApplication()
+	app.config.cors.trustedOrigins = []string{"https://admin.example.com", "https://*.tools.example.com"}
+	app.config.cors.allowedMethods = []string{"GET", "POST"}
+	app.config.cors.allowedHeaders = []string{"Authorization", "Content-Type"}
+	app.config.cors.maxAge = time.Minute
+
+	reached := false
+	handler := app.enableCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	t.Run("trusted origin gets the headers", func(t *testing.T) {
+		for _, origin := range []string{"https://admin.example.com", "https://ops.tools.example.com"} {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/transfers", nil)
+			r.Header.Set("Origin", origin)
+
+			handler.ServeHTTP(rr, r)
+
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != origin {
+				t.Errorf("wanted Access-Control-Allow-Origin %q, got %q", origin, got)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Errorf("credentials weren't enabled, but got Access-Control-Allow-Credentials %q", got)
+			}
+		}
+	})
+
+	t.Run("trusted preflight is answered", func(t *testing.T) {
+		reached = false
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodOptions, "/api/v1/transfers", nil)
+		r.Header.Set("Origin", "https://admin.example.com")
+		r.Header.Set("Access-Control-Request-Method", "POST")
+
+		handler.ServeHTTP(rr, r)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("wanted status %d, got %d", http.StatusNoContent, rr.Code)
+		}
+		if reached {
+			t.Error("preflight request reached the router")
+		}
+		want := map[string]string{
+			"Access-Control-Allow-Origin":  "https://admin.example.com",
+			"Access-Control-Allow-Methods": "GET, POST",
+			"Access-Control-Allow-Headers": "Authorization, Content-Type",
+			"Access-Control-Max-Age":       "60",
+		}
+		for header, value := range want {
+			if got := rr.Header().Get(header); got != value {
+				t.Errorf("wanted %s %q, got %q", header, value, got)
+			}
+		}
+	})
+
+	t.Run("untrusted origin gets no headers", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodOptions} {
+			reached = false
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(method, "/api/v1/transfers", nil)
+			r.Header.Set("Origin", "https://evil.example.com")
+			r.Header.Set("Access-Control-Request-Method", "POST")
+
+			handler.ServeHTTP(rr, r)
+
+			for _, header := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Allow-Credentials"} {
+				if got := rr.Header().Get(header); got != "" {
+					t.Errorf("%s from an untrusted origin got %s %q", method, header, got)
+				}
+			}
+			if !reached {
+				t.Errorf("%s from an untrusted origin didn't reach the router", method)
+			}
+		}
+	})
+}
+
+func TestValidateCORSConfig(t *testing.T) {
+	var cfg config
+	cfg.cors.trustedOrigins = []string{"*"}
+	if err := validateCORSConfig(cfg); err != nil {
+		t.Errorf("wanted * to be allowed without credentials, got %v", err)
+	}
+
+	cfg.cors.allowCredentials = true
+	if err := validateCORSConfig(cfg); err == nil {
+		t.Error("wanted an error for * with credentials")
+	}
+
+	cfg.cors.trustedOrigins = []string{"admin.example.com"}
+	if err := validateCORSConfig(cfg); err == nil {
+		t.Error("wanted an error for an origin without a scheme")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	db, _ := newFakeDB(t, fakeTransfersTable(2))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.readOnly = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/transfers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			app.createTransferApiHandler(w, r)
+			return
+		}
+		app.listTransfersApiHandler(w, r)
+	})
+	checked := 0
+	check := func(w http.ResponseWriter, r *http.Request) { checked++ }
+	mux.HandleFunc("/api/v1/validate-transfer", check)
+	mux.HandleFunc("/api/v1/estimate-transfer", check)
+	handler := app.readOnly(mux)
+
+	rr := httptest.NewRecorder()
+	body := strings.NewReader(`{"sourceID": 1, "targetID": 2, "query": "select 1", "targetTable": "t"}`)
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/transfers", body))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("wanted a create to be refused with 403, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, app.contextSetUser(httptest.NewRequest(http.MethodGet, "/api/v1/transfers", nil), &data.User{ID: 1, Admin: true}))
+	if rr.Code != http.StatusOK {
+		t.Errorf("wanted a list to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	for _, path := range []string{"/api/v1/validate-transfer", "/api/v1/estimate-transfer"} {
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`)))
+		if rr.Code == http.StatusForbidden {
+			t.Errorf("wanted %s let through, since it only reads", path)
+		}
+	}
+	if checked != 2 {
+		t.Errorf("wanted both checks to reach their handlers, got %d", checked)
+	}
+}
+
+func TestDisabledUserCantAuthenticate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pa55word!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deletedAt interface{}
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "SET deleted_at = NOW()"):
+			deletedAt = time.Now()
+			return nil, [][]driver.Value{{}}, nil
+		case strings.Contains(query, "SET deleted_at = NULL"):
+			deletedAt = nil
+			return nil, [][]driver.Value{{}}, nil
+		case strings.Contains(query, "FROM users"):
+			columns := []string{"id", "created_at", "username", "password_hash", "password_history", "admin", "deleted_at", "version"}
+			return columns, [][]driver.Value{{int64(4), time.Now(), "alice_smith", hash, []byte("{}"), false, deletedAt, int64(1)}}, nil
+		}
+		return nil, nil, fmt.Errorf("unexpected query %s", query)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	protected := app.authenticateApi(app.requireAuthApi(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	authenticate := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/transfers", nil)
+		r.SetBasicAuth("alice_smith", "pa55word!")
+		rr := httptest.NewRecorder()
+		protected.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	toggle := func(handler http.HandlerFunc) {
+		r := httptest.NewRequest(http.MethodPatch, "/api/v1/disable-user/4", nil)
+		r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "4"}}))
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("wanted 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	if code := authenticate(); code != http.StatusOK {
+		t.Fatalf("wanted an enabled user to authenticate, got %d", code)
+	}
+
+	toggle(app.disableUserApiHandler)
+	if code := authenticate(); code != http.StatusUnauthorized {
+		t.Errorf("wanted a disabled user's credentials rejected, got %d", code)
+	}
+
+	toggle(app.restoreUserApiHandler)
+	if code := authenticate(); code != http.StatusOK {
+		t.Errorf("wanted a re-enabled user to authenticate, got %d", code)
+	}
+}
@@ -0,0 +1,133 @@
+package serve
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Server wide transfer counts, published at /api/v1/debug/vars
+var (
+	transfersByStatus    = expvar.NewMap("transfers_by_status")
+	transferRowsWritten  = expvar.NewInt("transfer_rows_written")
+	transferErrors       = expvar.NewInt("transfer_errors")
+	transferRowsRejected = expvar.NewInt("transfer_rows_rejected")
+)
+
+type metricsReporter struct{}
+
+func (metricsReporter) OnBatch(rows int64)                                                { transferRowsWritten.Add(rows) }
+func (metricsReporter) OnStatus(status string)                                            { transfersByStatus.Add(status, 1) }
+func (metricsReporter) OnError(err error)                                                 { transferErrors.Add(1) }
+func (metricsReporter) OnReject(row string, err error)                                    { transferRowsRejected.Add(1) }
+func (metricsReporter) OnRetry(dsType string, attempt int, err error, wait time.Duration) {}
+
+// Rejected rows past this many are counted, but left out of the log
+const maxLoggedRejects = 100
+
+// Rejected rows are cut to this many bytes in the log
+const maxLoggedRowBytes = 500
+
+// The log is saved at most this often while a transfer runs, so a batch
+// doesn't wait on the database to be reported
+const logSaveInterval = time.Second
+
+// Adds each committed batch to the transfer's log, which clients can follow
+// over HTTP while it runs. Lines are saved with the transfer within
+// logSaveInterval. The runner logs how the transfer started and ended with
+// logNow, since it knows more about it than the engine does.
+type transferLogReporter struct {
+	app *application
+	id  int64
+
+	mu       sync.Mutex
+	log      *data.TransferLog
+	written  int64
+	rejected int
+	// set while a save is scheduled
+	saveTimer *time.Timer
+	lastSave  time.Time
+
+	// held while the log is saved, so saves land in the order they're made
+	saveMu sync.Mutex
+}
+
+func (r *transferLogReporter) OnBatch(rows int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written += rows
+	r.add("wrote %d rows, %d so far", rows, r.written)
+}
+
+func (r *transferLogReporter) OnStatus(status string) {}
+func (r *transferLogReporter) OnError(err error)      {}
+
+func (r *transferLogReporter) OnReject(row string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected++
+	switch {
+	case r.rejected <= maxLoggedRejects:
+		if len(row) > maxLoggedRowBytes {
+			row = row[:maxLoggedRowBytes] + "..."
+		}
+		r.add("rejected row %s: %v", row, err)
+	case r.rejected == maxLoggedRejects+1:
+		r.add("rejected more than %d rows, only counting the rest", maxLoggedRejects)
+	}
+}
+
+func (r *transferLogReporter) OnRetry(dsType string, attempt int, err error, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add("couldn't connect to %s on attempt %d: %v. Retrying in %s", dsType, attempt, err, wait)
+}
+
+// Adds a line to the log, and schedules a save unless one already is. r.mu
+// must be held.
+func (r *transferLogReporter) add(format string, args ...interface{}) {
+	r.log.Add(format, args...)
+	if r.saveTimer != nil {
+		return
+	}
+	wait := logSaveInterval - time.Since(r.lastSave)
+	if wait < 0 {
+		wait = 0
+	}
+	r.saveTimer = time.AfterFunc(wait, r.save)
+}
+
+// Adds a line to the log and saves it straight away, with any lines still
+// waiting to be saved
+func (r *transferLogReporter) logNow(format string, args ...interface{}) {
+	r.mu.Lock()
+	r.log.Add(format, args...)
+	r.mu.Unlock()
+	r.save()
+}
+
+// Saves the log, and the rows written, as they are now. Batches go on being reported while it's
+// saved, so it's saved from a copy.
+func (r *transferLogReporter) save() {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	r.mu.Lock()
+	if r.saveTimer != nil {
+		r.saveTimer.Stop()
+		r.saveTimer = nil
+	}
+	r.lastSave = time.Now()
+	log := *r.log
+	log.Lines = append([]string{}, r.log.Lines...)
+	written := r.written
+	r.mu.Unlock()
+
+	err := r.app.models.Transfers.SaveProgress(r.id, &log, written)
+	if err != nil {
+		r.app.logger.PrintError(err, map[string]string{"transfer": fmt.Sprint(r.id)})
+	}
+}
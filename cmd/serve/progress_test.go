@@ -0,0 +1,117 @@
+package serve
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestTransferLogReporterLogsRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		maxLines int
+	}{
+		// short lines roll off at the line bound, long ones at the byte bound
+		{"line bound", errors.New("connection refused"), 500},
+		{"byte bound", errors.New(strings.Repeat("x", 1000)), 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var saved []driver.Value
+			db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+				if strings.Contains(query, "SET log = $1") {
+					mu.Lock()
+					saved = args
+					mu.Unlock()
+				}
+				return nil, nil, nil
+			})
+			app := newTestApplication()
+			app.models = data.NewModels(db)
+
+			log := &data.TransferLog{}
+			reporter := &transferLogReporter{app: app, id: 1, log: log}
+			for attempt := 1; attempt <= 600; attempt++ {
+				reporter.OnRetry("postgresql", attempt, tt.err, time.Second)
+			}
+			reporter.save()
+
+			bytes := 0
+			for _, line := range log.Lines {
+				bytes += len(line)
+			}
+			if len(log.Lines) > tt.maxLines || bytes > 64*1024 || log.Dropped != int64(600-len(log.Lines)) {
+				t.Errorf("wanted the log kept to %d lines within 64KB, got %d lines of %d bytes, %d dropped", tt.maxLines, len(log.Lines), bytes, log.Dropped)
+			}
+			if last := log.Lines[len(log.Lines)-1]; !strings.Contains(last, "couldn't connect to postgresql on attempt 600: ") || !strings.HasSuffix(last, ". Retrying in 1s") {
+				t.Errorf("wanted the last retry logged, got %q", last)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(saved) != 4 || saved[1] != log.Dropped {
+				t.Errorf("wanted the retries saved with the transfer, got %v", saved)
+			}
+		})
+	}
+}
+
+func TestTransferLogReporterThrottlesSaves(t *testing.T) {
+	var mu sync.Mutex
+	var saves []string
+	var written driver.Value
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "SET log = $1") {
+			mu.Lock()
+			saves = append(saves, fmt.Sprint(args[0]))
+			written = args[2]
+			mu.Unlock()
+		}
+		return nil, nil, nil
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	reporter := &transferLogReporter{app: app, id: 1, log: &data.TransferLog{}}
+
+	reporter.logNow("running")
+	for i := 0; i < 100; i++ {
+		reporter.OnBatch(10)
+	}
+	mu.Lock()
+	if len(saves) != 1 {
+		t.Errorf("wanted the batches left to a later save, got %d saves", len(saves))
+	}
+	mu.Unlock()
+
+	// the batches are saved once the interval is up
+	deadline := time.Now().Add(2 * logSaveInterval)
+	for {
+		mu.Lock()
+		n := len(saves)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	reporter.logNow("complete")
+	mu.Lock()
+	defer mu.Unlock()
+	if len(saves) != 3 {
+		t.Fatalf("wanted a save for the start, the batches and the end, got %d", len(saves))
+	}
+	if !strings.Contains(saves[1], "wrote 10 rows, 1000 so far") || !strings.Contains(saves[2], "complete") {
+		t.Errorf("wanted every line saved, got %q", saves)
+	}
+	if written != int64(1000) {
+		t.Errorf("wanted 1000 rows written saved, got %v", written)
+	}
+}
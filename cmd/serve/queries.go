@@ -61,9 +61,10 @@ func (app *application) getListQueriesInput(r *http.Request) (input listQueriesI
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 10, v)
+	input.Filters.MaxPageSize = app.config.maxPageSize
 
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+	input.Filters.Sort = app.readString(qs, "sort", app.defaultSort("queries"))
+	input.Filters.SortSafelist = sortSafelists["queries"]
 
 	data.ValidateFilters(v, input.Filters)
 
@@ -74,6 +75,7 @@ func (app *application) listQueriesApiHandler(w http.ResponseWriter, r *http.Req
 	input, validationErrors := app.getListQueriesInput(r)
 	if !reflect.DeepEqual(validationErrors, map[string]string{}) {
 		app.failedValidationResponse(w, r, validationErrors)
+		return
 	}
 
 	queries, metadata, err := app.models.Queries.GetAll(input.Filters)
@@ -13,7 +13,7 @@ func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	// Middleware
-	commonMiddleware := alice.New(app.metrics, app.recoverPanic, app.logRequest, app.rateLimit)
+	commonMiddleware := alice.New(app.metrics, app.requestID, app.recoverPanic, app.logRequest, app.enableCORS, app.rateLimit, app.readOnly)
 
 	apiRequireLoggedInUser := alice.New(app.authenticateApi, app.requireAuthApi)
 	apiRequireAdmin := apiRequireLoggedInUser.Append(app.requireAdminApi)
@@ -33,6 +33,10 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/api/v1/users/:id", apiRequireAdmin.ThenFunc(app.showUserApiHandler))
 	router.Handler(http.MethodPatch, "/api/v1/users/:id", apiRequireAdmin.ThenFunc(app.updateUserApiHandler))
 	router.Handler(http.MethodDelete, "/api/v1/users/:id", apiRequireAdmin.ThenFunc(app.deleteUserApiHandler))
+	router.Handler(http.MethodPatch, "/api/v1/restore-user/:id", apiRequireAdmin.ThenFunc(app.restoreUserApiHandler))
+	router.Handler(http.MethodPatch, "/api/v1/disable-user/:id", apiRequireAdmin.ThenFunc(app.disableUserApiHandler))
+	router.Handler(http.MethodPatch, "/api/v1/enable-user/:id", apiRequireAdmin.ThenFunc(app.restoreUserApiHandler))
+	router.Handler(http.MethodGet, "/api/v1/whoami", apiRequireLoggedInUser.ThenFunc(app.whoamiApiHandler))
 	// UI
 	router.Handler(http.MethodGet, "/ui/create-user", uiRequireAdmin.ThenFunc(app.createUserFormUiHandler))
 	router.Handler(http.MethodPost, "/ui/create-user", uiRequireAdmin.ThenFunc(app.createUserUiHandler))
@@ -64,10 +68,18 @@ func (app *application) routes() http.Handler {
 	// Transfers
 	// API
 	router.Handler(http.MethodPost, "/api/v1/transfers", apiRequireLoggedInUser.ThenFunc(app.createTransferApiHandler))
+	router.Handler(http.MethodPost, "/api/v1/transfers/batch", apiRequireLoggedInUser.ThenFunc(app.createTransfersBatchApiHandler))
 	router.Handler(http.MethodGet, "/api/v1/transfers", apiRequireLoggedInUser.ThenFunc(app.listTransfersApiHandler))
 	router.Handler(http.MethodGet, "/api/v1/transfers/:id", apiRequireLoggedInUser.ThenFunc(app.showTransferApiHandler))
+	router.Handler(http.MethodGet, "/api/v1/transfers/:id/logs", apiRequireLoggedInUser.ThenFunc(app.showTransferLogApiHandler))
 	router.Handler(http.MethodPatch, "/api/v1/cancel-transfer/:id", apiRequireLoggedInUser.ThenFunc(app.cancelTransferApiHandler))
+	router.Handler(http.MethodPost, "/api/v1/rerun-transfer/:id", apiRequireLoggedInUser.ThenFunc(app.rerunTransferApiHandler))
+	router.Handler(http.MethodGet, "/api/v1/export-transfer-result/:id", apiRequireLoggedInUser.ThenFunc(app.exportTransferResultApiHandler))
+	router.Handler(http.MethodGet, "/api/v1/diff-transfer/:id", apiRequireLoggedInUser.ThenFunc(app.diffTransferApiHandler))
+	router.Handler(http.MethodPost, "/api/v1/validate-transfer", apiRequireLoggedInUser.ThenFunc(app.validateTransferApiHandler))
+	router.Handler(http.MethodPost, "/api/v1/estimate-transfer", apiRequireLoggedInUser.ThenFunc(app.estimateTransferApiHandler))
 	router.Handler(http.MethodDelete, "/api/v1/transfers/:id", apiRequireAdmin.ThenFunc(app.deleteTransferApiHandler))
+	router.Handler(http.MethodDelete, "/api/v1/transfers", apiRequireAdmin.ThenFunc(app.deleteTransfersApiHandler))
 	// UI
 	router.Handler(http.MethodGet, "/ui/create-transfer", uiRequireLoggedInUser.ThenFunc(app.createTransferFormUiHandler))
 	router.Handler(http.MethodPost, "/ui/create-transfer", uiRequireLoggedInUser.ThenFunc(app.createTransferUiHandler))
@@ -76,6 +88,14 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodPost, "/ui/cancel-transfer/:id", uiRequireLoggedInUser.ThenFunc(app.cancelTransferUiHandler))
 	router.Handler(http.MethodPost, "/ui/delete-transfer/:id", uiRequireAdmin.ThenFunc(app.deleteTransferUiHandler))
 
+	// Transfer templates
+	// API
+	router.Handler(http.MethodPost, "/api/v1/transfer-templates", apiRequireLoggedInUser.ThenFunc(app.createTransferTemplateApiHandler))
+	router.Handler(http.MethodGet, "/api/v1/transfer-templates", apiRequireLoggedInUser.ThenFunc(app.listTransferTemplatesApiHandler))
+	router.Handler(http.MethodGet, "/api/v1/transfer-templates/:id", apiRequireLoggedInUser.ThenFunc(app.showTransferTemplateApiHandler))
+	router.Handler(http.MethodPost, "/api/v1/instantiate-transfer-template/:id", apiRequireLoggedInUser.ThenFunc(app.instantiateTransferTemplateApiHandler))
+	router.Handler(http.MethodDelete, "/api/v1/transfer-templates/:id", apiRequireAdmin.ThenFunc(app.deleteTransferTemplateApiHandler))
+
 	// Queries
 	// API
 	router.Handler(http.MethodPost, "/api/v1/queries", apiRequireLoggedInUser.ThenFunc(app.createQueryApiHandler))
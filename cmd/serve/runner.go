@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/sqlpipe/sqlpipe/internal/data"
 	"github.com/sqlpipe/sqlpipe/internal/engine"
 	"github.com/sqlpipe/sqlpipe/internal/globals"
 	"github.com/sqlpipe/sqlpipe/pkg"
@@ -22,6 +23,7 @@ func (app *application) toDoScanner() {
 		if err != nil {
 			app.logger.PrintError(err, nil)
 		}
+		queuedTransfers = app.readyTransfers(queuedTransfers)
 
 		queuedQueries, err := app.models.Queries.GetQueued()
 		if err != nil {
@@ -47,7 +49,7 @@ func (app *application) toDoScanner() {
 
 						app.logger.PrintError(
 							errors.New(transfer.Error),
-							errProperties,
+							withRequestID(transfer, errProperties),
 						)
 						numLocalActiveTransfers -= 1
 						errProperties, err = globals.SendAnonymizedTransferAnalytics(*transfer, true)
@@ -66,13 +68,34 @@ func (app *application) toDoScanner() {
 							"Query":        transfer.Query,
 							"TargetSchema": transfer.TargetSchema,
 							"TargetTable":  transfer.TargetTable,
-							"Overwrite":    fmt.Sprint(transfer.Overwrite),
+							"WriteMode":    transfer.Mode(),
 							"Status":       transfer.Status,
+							"RequestID":    transfer.RequestID,
 						},
 					)
-					errProperties, err := engine.RunTransfer(transfer)
+					logReporter := &transferLogReporter{app: app, id: transfer.ID, log: &data.TransferLog{}}
+					logReporter.logNow("running, writing to %s in %s mode", transferTargetName(transfer), transfer.Mode())
+					transfer.Progress = engine.MultiReporter{
+						logReporter,
+						metricsReporter{},
+					}
+
+					// only one transfer at a time may write to a target table
+					var errProperties map[string]string
+					release, err := app.models.TargetLocks.Acquire(transfer, app.config.waitForTarget)
+					if err == nil {
+						errProperties, err = engine.RunTransfer(transfer)
+						release()
+					} else {
+						errProperties = map[string]string{
+							"error":        err.Error(),
+							"targetSchema": transfer.TargetSchema,
+							"targetTable":  transfer.TargetTable,
+						}
+					}
 					if err != nil {
-						app.logger.PrintError(err, errProperties)
+						app.logger.PrintError(err, withRequestID(transfer, errProperties))
+						logReporter.logNow("failed: %v %v", err, loggableErrProperties(errProperties))
 						transfer.Status = "error"
 						transfer.Error = err.Error()
 						transfer.ErrorProperties = fmt.Sprint(errProperties)
@@ -86,7 +109,7 @@ func (app *application) toDoScanner() {
 							}
 							app.logger.PrintError(
 								errors.New("unable to update transfer"),
-								errProperties,
+								withRequestID(transfer, errProperties),
 							)
 						}
 						numLocalActiveTransfers -= 1
@@ -97,6 +120,7 @@ func (app *application) toDoScanner() {
 						return
 					}
 
+					logReporter.logNow("complete")
 					transfer.Status = "complete"
 					transfer.StoppedAt = time.Now()
 					err = app.models.Transfers.Update(transfer)
@@ -107,7 +131,7 @@ func (app *application) toDoScanner() {
 						}
 						app.logger.PrintError(
 							errors.New("unable to update transfer"),
-							errProperties,
+							withRequestID(transfer, errProperties),
 						)
 					}
 					numLocalActiveTransfers -= 1
@@ -199,3 +223,95 @@ func (app *application) toDoScanner() {
 		}
 	}
 }
+
+// Returns the queued transfers whose dependencies have all completed. The
+// rest wait for a later scan, except those with a dependency that errored,
+// was cancelled or was skipped, which are marked skipped since they can never
+// run.
+func (app *application) readyTransfers(queued []*data.Transfer) []*data.Transfer {
+	var ids []int64
+	for _, transfer := range queued {
+		ids = append(ids, transfer.DependsOn...)
+	}
+	if len(ids) == 0 {
+		return queued
+	}
+
+	states, err := app.models.Transfers.DependencyStates(ids)
+	if err != nil {
+		// without knowing which dependencies are done, only the transfers
+		// that have none can start
+		app.logger.PrintError(err, nil)
+		ready := []*data.Transfer{}
+		for _, transfer := range queued {
+			if len(transfer.DependsOn) == 0 {
+				ready = append(ready, transfer)
+			}
+		}
+		return ready
+	}
+
+	ready := []*data.Transfer{}
+	for _, transfer := range queued {
+		ok, failed := data.CheckDependencies(transfer.DependsOn, states)
+		switch {
+		case ok:
+			ready = append(ready, transfer)
+		case failed > 0:
+			transfer.Status = "skipped"
+			transfer.Error = fmt.Sprintf("dependency %d ended with status %s", failed, states[failed].Status)
+			transfer.StoppedAt = time.Now()
+			err = app.models.Transfers.Update(transfer)
+			if err != nil {
+				app.logger.PrintError(err, withRequestID(transfer, map[string]string{"transfer": fmt.Sprint(transfer.ID)}))
+				continue
+			}
+			app.logTransfer(transfer.ID, &data.TransferLog{}, "skipped: %s", transfer.Error)
+		}
+	}
+	return ready
+}
+
+// Adds the ID of the request that created transfer to the properties of a
+// line logged about it, so the line can be matched to the request's
+func withRequestID(transfer *data.Transfer, properties map[string]string) map[string]string {
+	withID := map[string]string{"RequestID": transfer.RequestID}
+	for key, value := range properties {
+		withID[key] = value
+	}
+	return withID
+}
+
+// The error properties a transfer's log can show. Anyone who can see the
+// transfer can read its log, so the statements the engine ran, which quote
+// the query, are left out.
+func loggableErrProperties(errProperties map[string]string) map[string]string {
+	loggable := map[string]string{}
+	for key, value := range errProperties {
+		if key != "query" && key != "transfer" {
+			loggable[key] = value
+		}
+	}
+	return loggable
+}
+
+// Adds a line to a running transfer's log, and saves the log with the
+// transfer so it can be read after the fact
+func (app *application) logTransfer(id int64, log *data.TransferLog, format string, args ...interface{}) {
+	log.Add(format, args...)
+	err := app.models.Transfers.SaveLog(id, log)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{"transfer": fmt.Sprint(id)})
+	}
+}
+
+func transferTargetName(transfer *data.Transfer) string {
+	switch {
+	case transfer.TargetTablePattern != "":
+		return transfer.TargetSchema + "." + transfer.TargetTablePattern
+	case transfer.TargetSchema != "":
+		return transfer.TargetSchema + "." + transfer.TargetTable
+	default:
+		return transfer.TargetTable
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -21,6 +22,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/engine"
 	"github.com/sqlpipe/sqlpipe/internal/globals"
 	"github.com/sqlpipe/sqlpipe/internal/jsonLog"
 )
@@ -65,11 +67,46 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+	timeouts struct {
+		read       time.Duration
+		readHeader time.Duration
+		write      time.Duration
+		idle       time.Duration
+	}
 	createAdmin      bool
+	passwordHistory  int
+	waitForTarget    bool
+	softDeleteUsers  bool
+	dbCacheTTL       time.Duration
+	connectAttempts  int
+	connectBackoff   time.Duration
+	idempotencyTTL   time.Duration
+	maxBufferedRows  int
+	maxBufferedBytes int64
+	jsonCasing       string
+	readOnly         bool
+	redactQueries    bool
+	maxPageSize      int
+	maxRequestBody   int64
+	engine           globals.EngineFlags
+	defaultSorts     map[string]string
 	adminCredentials struct {
 		username string
 		password string
 	}
+	cors struct {
+		trustedOrigins   []string
+		allowedMethods   []string
+		allowedHeaders   []string
+		allowCredentials bool
+		maxAge           time.Duration
+	}
+	tls struct {
+		cert       string
+		key        string
+		minVersion string
+		http2      bool
+	}
 }
 
 type application struct {
@@ -86,8 +123,12 @@ type application struct {
 
 func init() {
 	ServeCmd.Flags().IntVar(&cfg.port, "port", 9000, "The port SQLPipe will run on. Default 9000")
+	ServeCmd.Flags().StringVar(&cfg.tls.cert, "tls-cert", "", "PEM certificate file to serve HTTPS with, given with --tls-key. Defaults to "+defaultTLSCert)
+	ServeCmd.Flags().StringVar(&cfg.tls.key, "tls-key", "", "PEM private key file for --tls-cert. Defaults to "+defaultTLSKey)
+	ServeCmd.Flags().StringVar(&cfg.tls.minVersion, "tls-min-version", "1.2", "Oldest TLS version clients may connect with: 1.0, 1.1, 1.2 or 1.3")
+	ServeCmd.Flags().BoolVar(&cfg.tls.http2, "http2", true, "Offer HTTP/2 to clients that support it")
 
-	ServeCmd.Flags().StringVar(&cfg.db.dsn, "dsn", "", "Database backend connection string")
+	ServeCmd.Flags().StringVar(&cfg.db.dsn, "dsn", "", "Database backend connection string. Defaults to one assembled from PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE and PGSSLMODE")
 
 	ServeCmd.Flags().IntVar(&cfg.db.maxOpenConns, "max-connections", 50, "Max backend db connections")
 	ServeCmd.Flags().IntVar(&cfg.db.maxIdleConns, "max-idle-connections", 50, "Max idle backend db connections")
@@ -97,20 +138,52 @@ func init() {
 	ServeCmd.Flags().IntVar(&cfg.limiter.burst, "limiter-burst", 200, "Rate limiter maximum burst")
 	ServeCmd.Flags().BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	ServeCmd.Flags().DurationVar(&cfg.timeouts.read, "read-timeout", 10*time.Second, "Max time to read a request, including its body")
+	ServeCmd.Flags().DurationVar(&cfg.timeouts.readHeader, "read-header-timeout", 5*time.Second, "Max time to read a request's headers")
+	ServeCmd.Flags().DurationVar(&cfg.timeouts.write, "write-timeout", 30*time.Second, "Max time to write a response. Streamed responses get this long between writes instead")
+	ServeCmd.Flags().DurationVar(&cfg.timeouts.idle, "idle-timeout", time.Minute, "Max time to keep an idle keep-alive connection open")
+
+	ServeCmd.Flags().StringSliceVar(&cfg.cors.trustedOrigins, "cors-trusted-origins", []string{}, "Origins allowed to call the API from a browser, comma separated. A * matches any part of an origin, e.g. https://*.example.com, and * on its own allows every origin. Empty means same origin only")
+	ServeCmd.Flags().StringSliceVar(&cfg.cors.allowedMethods, "cors-allowed-methods", []string{"GET", "POST", "PATCH", "DELETE"}, "Methods trusted origins may use, comma separated")
+	ServeCmd.Flags().StringSliceVar(&cfg.cors.allowedHeaders, "cors-allowed-headers", []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID"}, "Request headers trusted origins may send, comma separated")
+	ServeCmd.Flags().BoolVar(&cfg.cors.allowCredentials, "cors-allow-credentials", false, "Let trusted origins send credentials, such as basic auth. Can't be used with a * origin")
+	ServeCmd.Flags().DurationVar(&cfg.cors.maxAge, "cors-max-age", 10*time.Minute, "How long browsers may cache the answer to a preflight request")
+
+	ServeCmd.Flags().Int64Var(&cfg.maxRequestBody, "max-request-body", 1_048_576, "Largest JSON request body, in bytes, that the API reads. Larger ones get 413 Request Entity Too Large")
+	ServeCmd.Flags().IntVar(&cfg.maxPageSize, "max-page-size", data.DefaultMaxPageSize, "Largest page_size a listing may ask for. sqlpipe apply asks for pages of 100")
+	ServeCmd.Flags().StringToStringVar(&cfg.defaultSorts, "default-sort", map[string]string{}, "How a listing is sorted when the request doesn't say, e.g. transfers=-created_at,connections=name. Listings are users, connections, transfers, queries and transfer_templates, and each otherwise sorts by id, except templates, which sort by name")
+	ServeCmd.Flags().BoolVar(&cfg.readOnly, "read-only", false, "Refuse every request that would change users, connections, transfers or queries, whatever the user's role, and don't run queued transfers or queries")
+	ServeCmd.Flags().BoolVar(&cfg.redactQueries, "redact-queries", false, "Only show admins transfer queries. Other users see a placeholder in transfer listings and details")
+	ServeCmd.Flags().StringVar(&cfg.jsonCasing, "json-casing", "", "Send API response field names in camel or snake case. By default they're sent as defined, which the sqlpipe CLI expects")
+
 	ServeCmd.Flags().BoolVar(&cfg.createAdmin, "create-admin", false, "Create admin user")
 	ServeCmd.Flags().StringVar(&cfg.adminCredentials.username, "admin-username", "", "Admin username")
 	ServeCmd.Flags().StringVar(&cfg.adminCredentials.password, "admin-password", "", "Admin password")
+	ServeCmd.Flags().BoolVar(&cfg.softDeleteUsers, "soft-delete-users", false, "Disable deleted users instead of removing them, keeping their history. Disabled users can't log in, and can be restored")
+	ServeCmd.Flags().IntVar(&cfg.passwordHistory, "password-history", 0, "Number of previous passwords a user may not reuse. 0 disables the check")
 
 	ServeCmd.Flags().StringVar(&secret, "secret", "", "Secret key")
 
 	ServeCmd.Flags().BoolVar(&globals.Analytics, "analytics", true, "Send anonymized usage data to SQLpipe for product improvements")
 
 	ServeCmd.Flags().IntVar(&maxConcurrentTransfers, "max-concurrency", 20, "Max number of concurrent transfers to run on this server")
+	ServeCmd.Flags().DurationVar(&cfg.dbCacheTTL, "connection-cache-ttl", 5*time.Minute, "How long to keep an unused pool of connections to a source or target open for later transfers. 0 opens a new pool for every transfer")
+	ServeCmd.Flags().IntVar(&cfg.connectAttempts, "connect-attempts", 3, "How many times a transfer tries to reach its source and target when it starts, before failing")
+	ServeCmd.Flags().DurationVar(&cfg.connectBackoff, "connect-backoff", time.Second, "How long a transfer waits after its first failed try to reach its source or target. Each later wait doubles")
+	ServeCmd.Flags().IntVar(&cfg.maxBufferedRows, "max-buffered-rows", 10000, "Max rows a transfer may read from its source ahead of its target. Transfers can set their own. 0 means no limit")
+	ServeCmd.Flags().Int64Var(&cfg.maxBufferedBytes, "max-buffered-bytes", 64<<20, "Max bytes of rows a transfer may read from its source ahead of its target. Transfers can set their own. 0 means no limit")
+	ServeCmd.Flags().DurationVar(&cfg.idempotencyTTL, "idempotency-key-ttl", 24*time.Hour, "How long an Idempotency-Key sent when creating a transfer keeps returning the transfer it created")
+	cfg.engine.Register(ServeCmd)
+	ServeCmd.Flags().BoolVar(&cfg.waitForTarget, "wait-for-target", true, "Wait when another transfer is writing to the same target table. If false, the transfer fails instead")
 }
 
 func serve(cmd *cobra.Command, args []string) {
 
-	logger := jsonLog.New(os.Stdout, jsonLog.LevelInfo)
+	logger := jsonLog.New(os.Stdout, globals.LogLevel())
+
+	if cfg.db.dsn == "" {
+		cfg.db.dsn = globals.PostgresEnvDSN(os.Getenv)
+	}
 
 	db, err := openDB(cfg)
 	if err != nil {
@@ -121,6 +194,48 @@ func serve(cmd *cobra.Command, args []string) {
 
 	publishMetrics(db)
 
+	engine.EnableDbCache(cfg.dbCacheTTL)
+	engine.SetBufferLimits(cfg.maxBufferedRows, cfg.maxBufferedBytes)
+	engine.SetConnectRetry(cfg.connectAttempts, cfg.connectBackoff)
+
+	errProperties, err := cfg.engine.Apply()
+	if err != nil {
+		logger.PrintFatal(err, errProperties)
+	}
+
+	err = validateCORSConfig(cfg)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	err = validateJSONCasing(cfg.jsonCasing)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	err = validateDefaultSorts(cfg.defaultSorts)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	cfg.tls.cert, cfg.tls.key, err = tlsFiles(cfg.tls.cert, cfg.tls.key)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	tlsConfig.MinVersion, err = parseTLSVersion(cfg.tls.minVersion)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	if cfg.maxPageSize < 1 {
+		logger.PrintFatal(errors.New("--max-page-size must be at least 1"), nil)
+	}
+
+	if cfg.maxRequestBody < 1 {
+		logger.PrintFatal(errors.New("--max-request-body must be at least 1"), nil)
+	}
+
 	templateCache, err := newTemplateCache()
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -142,6 +257,10 @@ func serve(cmd *cobra.Command, args []string) {
 		templateCache: templateCache,
 	}
 
+	if cfg.readOnly && cfg.createAdmin {
+		logger.PrintFatal(errors.New("--create-admin can't be used with --read-only"), nil)
+	}
+
 	if cfg.createAdmin {
 		app.createAdminUser(
 			cfg.adminCredentials.username,
@@ -149,7 +268,11 @@ func serve(cmd *cobra.Command, args []string) {
 		)
 	}
 
-	go app.toDoScanner()
+	if cfg.readOnly {
+		logger.PrintInfo("read only mode, queued transfers and queries won't run", nil)
+	} else {
+		go app.toDoScanner()
+	}
 
 	err = app.serve()
 	if err != nil {
@@ -198,17 +321,30 @@ func publishMetrics(db *sql.DB) {
 	}))
 }
 
-func (app *application) serve() error {
-
+func (app *application) newServer(handler http.Handler) *http.Server {
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", app.config.port),
-		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		TLSConfig:    app.tlsConfig,
+		Addr:              fmt.Sprintf(":%d", app.config.port),
+		Handler:           handler,
+		IdleTimeout:       app.config.timeouts.idle,
+		ReadTimeout:       app.config.timeouts.read,
+		ReadHeaderTimeout: app.config.timeouts.readHeader,
+		WriteTimeout:      app.config.timeouts.write,
+		TLSConfig:         app.tlsConfig,
+		ConnContext:       contextSetConn,
 	}
 
+	// a non-nil TLSNextProto keeps the server from upgrading to HTTP/2
+	if !app.config.tls.http2 {
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+
+	return srv
+}
+
+func (app *application) serve() error {
+
+	srv := app.newServer(app.routes())
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -240,7 +376,7 @@ func (app *application) serve() error {
 		"addr": srv.Addr,
 	})
 
-	err := srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	err := srv.ListenAndServeTLS(app.config.tls.cert, app.config.tls.key)
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -277,3 +413,20 @@ func randomCharacters(length int) string {
 
 	return string(b)
 }
+
+// Browsers refuse credentials from a bare * origin, and echoing every origin
+// back instead would let any site use a logged in user's credentials
+func validateCORSConfig(cfg config) error {
+	for _, origin := range cfg.cors.trustedOrigins {
+		if origin == "*" {
+			if cfg.cors.allowCredentials {
+				return errors.New("--cors-allow-credentials can't be used with a * trusted origin")
+			}
+			continue
+		}
+		if !strings.Contains(origin, "://") {
+			return fmt.Errorf("trusted origin %q must include a scheme, e.g. https://", origin)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package serve
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerDropsSlowHeaders(t *testing.T) {
+	app := newTestApplication()
+	app.config.timeouts.readHeader = 100 * time.Millisecond
+	app.config.timeouts.read = 10 * time.Second
+
+	srv := app.newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the request line, then nothing more
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	_, err = io.ReadAll(conn)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			t.Fatal("connection was still open after 5s")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("connection was dropped after %v, want about 100ms", elapsed)
+	}
+}
+
+func TestStreamOutlivesWriteTimeout(t *testing.T) {
+	app := newTestApplication()
+	app.config.timeouts.write = 200 * time.Millisecond
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 4; i++ {
+			app.extendWriteDeadline(r)
+			io.WriteString(w, "line\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	srv.Config = app.newServer(srv.Config.Handler)
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "line" {
+			lines++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("stream was cut off after %d lines: %v", lines, err)
+	}
+	if lines != 4 {
+		t.Errorf("got %d lines, want 4", lines)
+	}
+}
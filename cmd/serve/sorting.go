@@ -0,0 +1,60 @@
+package serve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+// The sorts each listing accepts. The column is formatted into the listing's
+// ORDER BY, so a sort that isn't here is rejected before any query runs.
+var sortSafelists = map[string][]string{
+	"users":              {"id", "created_at", "-id", "-created_at"},
+	"connections":        {"id", "created_at", "name", "ds_type", "-id", "-created_at", "-name", "-ds_type"},
+	"transfers":          {"id", "created_at", "-id", "-created_at"},
+	"queries":            {"id", "created_at", "-id", "-created_at"},
+	"transfer_templates": {"id", "created_at", "name", "-id", "-created_at", "-name"},
+}
+
+// Listings that sort by something other than id unless told otherwise.
+// Templates were listed by name before they could be sorted.
+var builtinSorts = map[string]string{
+	"transfer_templates": "name",
+}
+
+// The sort a listing uses when the request doesn't ask for one
+func (app *application) defaultSort(resource string) string {
+	if order, ok := app.config.defaultSorts[resource]; ok {
+		return order
+	}
+	if order, ok := builtinSorts[resource]; ok {
+		return order
+	}
+	return "id"
+}
+
+// Checks every --default-sort is for a listing that exists, and is a sort
+// that listing accepts
+func validateDefaultSorts(sorts map[string]string) error {
+	for resource, order := range sorts {
+		safelist, ok := sortSafelists[resource]
+		if !ok {
+			return fmt.Errorf("--default-sort is for an unknown listing %q, must be one of %s", resource, strings.Join(sortedResources(), ", "))
+		}
+		if !validator.In(order, safelist...) {
+			return fmt.Errorf("--default-sort for %s must be one of %s, not %q", resource, strings.Join(safelist, ", "), order)
+		}
+	}
+	return nil
+}
+
+func sortedResources() []string {
+	resources := make([]string, 0, len(sortSafelists))
+	for resource := range sortSafelists {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
@@ -0,0 +1,84 @@
+package serve
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestUnlistedSortIsRejected(t *testing.T) {
+	handlers := map[string]func(*application) http.HandlerFunc{
+		"users":       func(app *application) http.HandlerFunc { return app.listUsersApiHandler },
+		"connections": func(app *application) http.HandlerFunc { return app.listConnectionsApiHandler },
+		"transfers":   func(app *application) http.HandlerFunc { return app.listTransfersApiHandler },
+		"queries":     func(app *application) http.HandlerFunc { return app.listQueriesApiHandler },
+		"transfer_templates": func(app *application) http.HandlerFunc {
+			return app.listTransferTemplatesApiHandler
+		},
+	}
+	for resource, handler := range handlers {
+		t.Run(resource, func(t *testing.T) {
+			var queries []string
+			db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+				queries = append(queries, query)
+				return nil, nil, nil
+			})
+			app := newTestApplication()
+			app.models = data.NewModels(db)
+
+			rr := httptest.NewRecorder()
+			handler(app)(rr, httptest.NewRequest(http.MethodGet, "/api/v1/"+resource+"?sort=id%3Bdrop+table+users", nil))
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("wanted 422, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if len(queries) != 0 {
+				t.Errorf("wanted no queries run, got %v", queries)
+			}
+		})
+	}
+}
+
+func TestDefaultSort(t *testing.T) {
+	var listed string
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		listed = query
+		return fakeTransfersTable(0)(query, args)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.defaultSorts = map[string]string{"transfers": "-created_at"}
+
+	rr := httptest.NewRecorder()
+	app.listTransfersApiHandler(rr, app.contextSetUser(httptest.NewRequest(http.MethodGet, "/api/v1/transfers", nil), &data.User{ID: 1, Admin: true}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wanted 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(listed, "transfers.created_at DESC") {
+		t.Errorf("wanted transfers sorted by -created_at, got %s", listed)
+	}
+}
+
+func TestValidateDefaultSorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		sorts map[string]string
+		valid bool
+	}{
+		{"none", map[string]string{}, true},
+		{"listed", map[string]string{"transfers": "-created_at", "connections": "name"}, true},
+		{"unknown listing", map[string]string{"widgets": "id"}, false},
+		{"unlisted column", map[string]string{"users": "password_hash"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDefaultSorts(tt.sorts)
+			if (err == nil) != tt.valid {
+				t.Errorf("wanted valid %t, got %v", tt.valid, err)
+			}
+		})
+	}
+}
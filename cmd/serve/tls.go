@@ -0,0 +1,44 @@
+package serve
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// Where the server's certificate and key are read from when neither
+// --tls-cert nor --tls-key is given
+const (
+	defaultTLSCert = "./tls/cert.pem"
+	defaultTLSKey  = "./tls/key.pem"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// The certificate and key files to serve with. They're given together, or
+// not at all, in which case the ones in ./tls are used.
+func tlsFiles(cert string, key string) (string, string, error) {
+	switch {
+	case cert == "" && key == "":
+		return defaultTLSCert, defaultTLSKey, nil
+	case cert == "":
+		return "", "", errors.New("--tls-key was given without --tls-cert")
+	case key == "":
+		return "", "", errors.New("--tls-cert was given without --tls-key")
+	default:
+		return cert, key, nil
+	}
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("--tls-min-version must be 1.0, 1.1, 1.2 or 1.3, not %q", version)
+	}
+	return v, nil
+}
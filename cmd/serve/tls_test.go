@@ -0,0 +1,158 @@
+package serve
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Writes a self-signed certificate for 127.0.0.1 and its key to dir
+func writeSelfSignedCert(t *testing.T, dir string) (certFile string, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, pool := writeSelfSignedCert(t, t.TempDir())
+
+	app := newTestApplication()
+	app.config.tls.http2 = true
+	app.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS13}
+	srv := app.newServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- srv.ServeTLS(listener, certFile, keyFile) }()
+	defer func() {
+		srv.Close()
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("wanted the server closed, got %v", err)
+		}
+	}()
+	addr := listener.Addr().String()
+
+	get := func(url string, tlsConfig *tls.Config) (*http.Response, string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}}
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, string(body), err
+	}
+
+	t.Run("https", func(t *testing.T) {
+		resp, body, err := get("https://"+addr, &tls.Config{RootCAs: pool})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || body != "HTTP/2.0" {
+			t.Errorf("wanted 200 over HTTP/2, got %d over %s", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		resp, body, err := get("http://"+addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("wanted plaintext refused with 400, got %d: %s", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("below the minimum version", func(t *testing.T) {
+		_, _, err := get("https://"+addr, &tls.Config{RootCAs: pool, MaxVersion: tls.VersionTLS12})
+		if err == nil {
+			t.Error("wanted a TLS 1.2 client refused")
+		}
+	})
+}
+
+func TestServeWithoutHTTP2(t *testing.T) {
+	app := newTestApplication()
+	if srv := app.newServer(http.NotFoundHandler()); srv.TLSNextProto == nil {
+		t.Error("wanted HTTP/2 turned off")
+	}
+}
+
+func TestTLSFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		cert, key string
+		wantCert  string
+		wantErr   bool
+	}{
+		{"defaults", "", "", defaultTLSCert, false},
+		{"both", "server.pem", "server.key", "server.pem", false},
+		{"cert only", "server.pem", "", "", true},
+		{"key only", "", "server.key", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, _, err := tlsFiles(tt.cert, tt.key)
+			if cert != tt.wantCert || (err != nil) != tt.wantErr {
+				t.Errorf("wanted %q with error %t, got %q and %v", tt.wantCert, tt.wantErr, cert, err)
+			}
+		})
+	}
+}
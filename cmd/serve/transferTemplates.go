@@ -0,0 +1,211 @@
+package serve
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+func (app *application) createTransferTemplateApiHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name       string        `json:"name"`
+		Definition transferInput `json:"definition"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	template := &data.TransferTemplate{
+		Name:       input.Name,
+		Definition: *input.Definition.transfer(),
+	}
+
+	v := validator.New()
+	if data.ValidateTransferTemplate(v, template); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	template, err = app.models.TransferTemplates.Insert(template)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTemplateName):
+			v.AddError("name", "a transfer template with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if app.hideQueries(r, true) {
+		redactTemplateQueries(template)
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"transferTemplate": template}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+type listTransferTemplatesInput struct {
+	data.Filters
+}
+
+func (app *application) getListTransferTemplatesInput(r *http.Request) (input listTransferTemplatesInput, err map[string]string) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 10, v)
+	input.Filters.MaxPageSize = app.config.maxPageSize
+
+	input.Filters.Sort = app.readString(qs, "sort", app.defaultSort("transfer_templates"))
+	input.Filters.SortSafelist = sortSafelists["transfer_templates"]
+
+	data.ValidateFilters(v, input.Filters)
+
+	return input, v.Errors
+}
+
+func (app *application) listTransferTemplatesApiHandler(w http.ResponseWriter, r *http.Request) {
+	input, validationErrors := app.getListTransferTemplatesInput(r)
+	if len(validationErrors) > 0 {
+		app.failedValidationResponse(w, r, validationErrors)
+		return
+	}
+
+	templates, metadata, err := app.models.TransferTemplates.GetAll(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if app.hideQueries(r, true) {
+		redactTemplateQueries(templates...)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"transferTemplates": templates, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showTransferTemplateApiHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := app.readTransferTemplate(w, r)
+	if !ok {
+		return
+	}
+	if app.hideQueries(r, true) {
+		redactTemplateQueries(template)
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"transferTemplate": template}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Queues a transfer made from a template, once every one of its variables
+// has a value. The result is checked like any new transfer.
+func (app *application) instantiateTransferTemplateApiHandler(w http.ResponseWriter, r *http.Request) {
+	template, ok := app.readTransferTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Variables map[string]string `json:"variables"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	transfer := template.Instantiate(v, input.Variables)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	transfer.CreatedBy = app.contextGetUser(r).ID
+	transfer.RequestID = app.contextGetRequestID(r)
+
+	err = app.validateTransfer(v, transfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	transfer, err = app.models.Transfers.Insert(transfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if app.hideQueries(r, true) {
+		redactQueries(transfer)
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"transfer": transfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteTransferTemplateApiHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.TransferTemplates.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "transfer template successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Looks up the template named by the :id param. On failure it has already
+// written the error response.
+func (app *application) readTransferTemplate(w http.ResponseWriter, r *http.Request) (*data.TransferTemplate, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	template, err := app.models.TransferTemplates.GetById(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return template, true
+}
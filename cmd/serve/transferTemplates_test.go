@@ -0,0 +1,61 @@
+package serve
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestListTransferTemplatesPaginates(t *testing.T) {
+	var listed string
+	var bound []driver.Value
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		listed, bound = query, args
+		definition := []byte(`{"query":"select 1","targetTable":"t"}`)
+		var rows [][]driver.Value
+		for id := 3; id <= 4; id++ {
+			rows = append(rows, []driver.Value{int64(5), int64(id), time.Now(), fmt.Sprintf("template-%d", id), definition, int64(1)})
+		}
+		return make([]string, 6), rows, nil
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	rr := httptest.NewRecorder()
+	app.listTransferTemplatesApiHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/transfer-templates?page=2&page_size=2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("wanted 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if !strings.Contains(listed, "ORDER BY name ASC") {
+		t.Errorf("wanted templates sorted by name unless told otherwise, got %s", listed)
+	}
+	if len(bound) != 2 || bound[0] != int64(2) || bound[1] != int64(2) {
+		t.Errorf("wanted a limit and offset of 2, got %v", bound)
+	}
+
+	var envelope struct {
+		TransferTemplates []data.TransferTemplate `json:"transferTemplates"`
+		Metadata          data.Metadata           `json:"metadata"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope.TransferTemplates) != 2 || envelope.Metadata.TotalRecords != 5 || envelope.Metadata.LastPage != 3 {
+		t.Errorf("wanted the second page of 2 out of 5 templates, got %s", rr.Body.String())
+	}
+
+	app.config.maxPageSize = 10
+	rr = httptest.NewRecorder()
+	app.listTransferTemplatesApiHandler(rr, httptest.NewRequest(http.MethodGet, "/api/v1/transfer-templates?page_size=11", nil))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("wanted a page larger than --max-page-size refused, got %d", rr.Code)
+	}
+}
@@ -6,15 +6,19 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/engine"
 	"github.com/sqlpipe/sqlpipe/internal/forms.go"
 	"github.com/sqlpipe/sqlpipe/internal/validator"
 )
 
 type listTransfersInput struct {
 	data.Filters
+	Mine         bool
+	IncludeQuery bool
 }
 
 func (app *application) getListTransfersInput(r *http.Request) (input listTransfersInput, err map[string]string) {
@@ -24,19 +28,103 @@ func (app *application) getListTransfersInput(r *http.Request) (input listTransf
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 10, v)
+	input.Filters.MaxPageSize = app.config.maxPageSize
 
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+	input.Filters.Sort = app.readString(qs, "sort", app.defaultSort("transfers"))
+	input.Filters.SortSafelist = sortSafelists["transfers"]
+
+	input.Filters.CreatedAfter = app.readTime(qs, "since", v)
+	input.Filters.CreatedBefore = app.readTime(qs, "until", v)
+
+	// matches transfers whose source or target has the labels
+	input.Filters.Labels = app.readLabels(qs, "label", v)
+
+	input.Mine = app.readBool(qs, "mine", false, v)
+	input.IncludeQuery = app.readBool(qs, "include_query", true, v)
 
 	data.ValidateFilters(v, input.Filters)
 
 	return input, v.Errors
 }
 
+// Shown in place of a transfer's query to users who may not see it
+const redactedQuery = "[redacted]"
+
+// Whether transfer queries are kept from the request's user: when they ask
+// for that with include_query=false, or when the server only shows queries
+// to admins and they aren't one
+func (app *application) hideQueries(r *http.Request, includeQuery bool) bool {
+	return !includeQuery || (app.config.redactQueries && !app.contextGetUser(r).Admin)
+}
+
+// Hides each transfer's query, including where a failure quotes it. Error
+// properties are dropped whole, since the engine puts the statement it ran
+// in them.
+func redactQueries(transfers ...*data.Transfer) {
+	for _, transfer := range transfers {
+		if transfer.Query != "" {
+			transfer.Error = strings.ReplaceAll(transfer.Error, transfer.Query, redactedQuery)
+		}
+		if transfer.ErrorProperties != "" {
+			transfer.ErrorProperties = redactedQuery
+		}
+		transfer.Query = redactedQuery
+	}
+}
+
+// Hides the queries of each template's definition, like redactQueries
+func redactTemplateQueries(templates ...*data.TransferTemplate) {
+	for _, template := range templates {
+		redactQueries(&template.Definition)
+	}
+}
+
+// Hides query wherever a line of log quotes it. Logs written since queries
+// were kept out of them won't, but older ones may.
+func redactLog(log *data.TransferLog, query string) {
+	if query == "" {
+		return
+	}
+	for i, line := range log.Lines {
+		log.Lines[i] = strings.ReplaceAll(line, query, redactedQuery)
+	}
+}
+
+// Non-admins only see the transfers they created. Admins see everyone's,
+// unless they ask for just their own.
+func (input *listTransfersInput) scopeTo(user *data.User) {
+	if input.Mine || !user.Admin {
+		input.Filters.CreatedBy = user.ID
+	}
+}
+
+// Fetches a transfer the request's user may see, which is any of them for an
+// admin and their own otherwise. Anyone else's is ErrRecordNotFound, so its ID
+// isn't given away.
+func (app *application) getVisibleTransfer(r *http.Request, id int64) (*data.Transfer, error) {
+	transfer, err := app.models.Transfers.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if user := app.contextGetUser(r); !user.Admin && transfer.CreatedBy != user.ID {
+		return nil, data.ErrRecordNotFound
+	}
+	return transfer, nil
+}
+
 func (app *application) listTransfersApiHandler(w http.ResponseWriter, r *http.Request) {
 	input, validationErrors := app.getListTransfersInput(r)
 	if !reflect.DeepEqual(validationErrors, map[string]string{}) {
 		app.failedValidationResponse(w, r, validationErrors)
+		return
+	}
+	input.scopeTo(app.contextGetUser(r))
+
+	hideQueries := app.hideQueries(r, input.IncludeQuery)
+
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		app.streamTransfers(w, r, input.Filters, hideQueries)
+		return
 	}
 
 	transfers, metadata, err := app.models.Transfers.GetAll(input.Filters)
@@ -44,6 +132,9 @@ func (app *application) listTransfersApiHandler(w http.ResponseWriter, r *http.R
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	if hideQueries {
+		redactQueries(transfers...)
+	}
 
 	err = app.writeJSON(w, http.StatusOK, envelope{"transfers": transfers, "metadata": metadata}, nil)
 	if err != nil {
@@ -51,23 +142,92 @@ func (app *application) listTransfersApiHandler(w http.ResponseWriter, r *http.R
 	}
 }
 
-func (app *application) createTransferApiHandler(w http.ResponseWriter, r *http.Request) {
+// Writes one transfer per line as each is scanned, so large pages are never
+// held in memory. Pagination metadata isn't included. The write deadline is
+// extended before each line, so the stream isn't cut off by WriteTimeout. Once the first line is
+// out the status can't change, so later errors end the stream and are only
+// logged.
+func (app *application) streamTransfers(w http.ResponseWriter, r *http.Request, filters data.Filters, hideQueries bool) {
+	flusher, _ := w.(http.Flusher)
+	started := false
 
-	var input struct {
-		SourceID     int64  `json:"sourceID"`
-		TargetID     int64  `json:"targetID"`
-		Query        string `json:"query"`
-		TargetSchema string `json:"targetSchema"`
-		TargetTable  string `json:"targetTable"`
-		Overwrite    *bool  `json:"overwrite"`
-	}
+	_, err := app.models.Transfers.Each(filters, func(transfer *data.Transfer) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
 
-	err := app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
+		if hideQueries {
+			redactQueries(transfer)
+		}
+
+		line, err := marshalCased(transfer, app.config.jsonCasing)
+		if err != nil {
+			return err
+		}
+
+		app.extendWriteDeadline(r)
+		_, err = w.Write(append(line, '\n'))
+		if err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+
+	switch {
+	case err != nil && !started:
+		app.serverErrorResponse(w, r, err)
+	case err != nil:
+		app.logError(r, err)
+	case !started:
+		// an empty page
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
 	}
+}
+
+// A transfer definition, as the API takes it
+type transferInput struct {
+	SourceID     int64    `json:"sourceID"`
+	TargetID     int64    `json:"targetID"`
+	Query        string   `json:"query"`
+	TargetSchema string   `json:"targetSchema"`
+	TargetTable  string   `json:"targetTable"`
+	Overwrite    *bool    `json:"overwrite"`
+	WriteMode    string   `json:"writeMode"`
+	PreLoadSQL   []string `json:"preLoadSQL"`
+	Parallelism  int      `json:"parallelism"`
+	ChunkColumn  string   `json:"chunkColumn"`
 
+	TargetTablePattern string `json:"targetTablePattern"`
+	CreateTargetTable  bool   `json:"createTargetTable"`
+	CreateTargetSchema bool   `json:"createTargetSchema"`
+
+	SourceColumns   []string `json:"sourceColumns"`
+	ExcludeColumns  []string `json:"excludeColumns"`
+	ConflictColumns []string `json:"conflictColumns"`
+
+	TargetColumnOrder []string `json:"targetColumnOrder"`
+	VerifyChecksum    bool     `json:"verifyChecksum"`
+	IsolationLevel    string   `json:"isolationLevel"`
+	StatementTimeout  int      `json:"statementTimeout"`
+	MaxErrors         int      `json:"maxErrors"`
+
+	SourceLimit int     `json:"sourceLimit"`
+	SampleRate  float64 `json:"sampleRate"`
+
+	MaxBufferedRows  int   `json:"maxBufferedRows"`
+	MaxBufferedBytes int64 `json:"maxBufferedBytes"`
+
+	QueryArgs data.QueryArgs `json:"queryArgs"`
+	DependsOn []int64        `json:"dependsOn"`
+}
+
+func (input transferInput) transfer() *data.Transfer {
 	overwrite := false
 	if input.Overwrite != nil {
 		overwrite = *input.Overwrite
@@ -80,22 +240,313 @@ func (app *application) createTransferApiHandler(w http.ResponseWriter, r *http.
 		TargetSchema: input.TargetSchema,
 		TargetTable:  input.TargetTable,
 		Overwrite:    overwrite,
+		WriteMode:    input.WriteMode,
+		PreLoadSQL:   input.PreLoadSQL,
+		Parallelism:  input.Parallelism,
+		ChunkColumn:  input.ChunkColumn,
+
+		TargetTablePattern: input.TargetTablePattern,
+		CreateTargetTable:  input.CreateTargetTable,
+		CreateTargetSchema: input.CreateTargetSchema,
+
+		SourceColumns:   input.SourceColumns,
+		ExcludeColumns:  input.ExcludeColumns,
+		ConflictColumns: input.ConflictColumns,
+
+		TargetColumnOrder: input.TargetColumnOrder,
+		VerifyChecksum:    input.VerifyChecksum,
+		IsolationLevel:    input.IsolationLevel,
+		StatementTimeout:  input.StatementTimeout,
+		MaxErrors:         input.MaxErrors,
+
+		SourceLimit: input.SourceLimit,
+		SampleRate:  input.SampleRate,
+
+		MaxBufferedRows:  input.MaxBufferedRows,
+		MaxBufferedBytes: input.MaxBufferedBytes,
+
+		QueryArgs: input.QueryArgs,
+		DependsOn: input.DependsOn,
+	}
+
+	return transfer
+}
+
+// Reads a transfer definition from the request body. On failure it has
+// already written the error response.
+func (app *application) readTransferInput(w http.ResponseWriter, r *http.Request) (*data.Transfer, bool) {
+	var input transferInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	return input.transfer(), true
+}
+
+// Runs ValidateTransfer, then checks that the source and target connections
+// and the transfers it depends on exist. Returns an error only if they
+// couldn't be looked up.
+func (app *application) validateTransfer(v *validator.Validator, transfer *data.Transfer) error {
+	data.ValidateTransfer(v, transfer)
+
+	connections := []struct {
+		key string
+		id  int64
+	}{
+		{"sourceId", transfer.SourceID},
+		{"targetId", transfer.TargetID},
+	}
+
+	for _, connection := range connections {
+		if connection.id < 1 {
+			continue
+		}
+		found, err := app.models.Connections.GetById(connection.id)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError(connection.key, "Connection not found")
+		case err != nil:
+			return err
+		case connection.key == "sourceId":
+			// placeholders can only be counted, and isolation levels and timeouts
+			// checked, once the source's type is known
+			data.ValidateQueryArgs(v, transfer.Query, transfer.QueryArgs, found.DsType)
+			data.ValidateIsolationLevel(v, transfer, found.DsType)
+			data.ValidateStatementTimeout(v, transfer, found.DsType)
+		}
+	}
+
+	if len(transfer.DependsOn) > 0 {
+		graph, err := app.models.Transfers.DependencyGraph(transfer.DependsOn)
+		if err != nil {
+			return err
+		}
+		data.ValidateDependencies(v, transfer, graph)
+	}
+
+	return nil
+}
+
+func (app *application) createTransferApiHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := app.readTransferInput(w, r)
+	if !ok {
+		return
 	}
 
 	v := validator.New()
 
-	if data.ValidateTransfer(v, transfer); !v.Valid() {
+	err := app.validateTransfer(v, transfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	transfer, err = app.models.Transfers.Insert(transfer)
+	transfer.CreatedBy = app.contextGetUser(r).ID
+	transfer.RequestID = app.contextGetRequestID(r)
+
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		transfer, err = app.models.Transfers.Insert(transfer)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if app.hideQueries(r, true) {
+			redactQueries(transfer)
+		}
+		err = app.writeJSON(w, http.StatusAccepted, envelope{"transfer": transfer}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.createTransferOnce(w, r, transfer, key)
+}
+
+// Creates transfer unless key already created one within the idempotency
+// window, in which case that transfer is returned instead
+func (app *application) createTransferOnce(w http.ResponseWriter, r *http.Request, transfer *data.Transfer, key string) {
+	if len(key) > 255 {
+		app.failedValidationResponse(w, r, map[string]string{"idempotencyKey": "Idempotency key must not be more than 255 characters"})
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	transferID, claimed, err := app.models.IdempotencyKeys.Claim(user.ID, key, app.config.idempotencyTTL)
+	switch {
+	case errors.Is(err, data.ErrIdempotencyKeyInUse):
+		app.errorResponse(w, r, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+
+	if claimed {
+		transfer, err = app.models.Transfers.Insert(transfer)
+		if err == nil {
+			err = app.models.IdempotencyKeys.Complete(user.ID, key, transfer.ID)
+		}
+		if err != nil {
+			app.models.IdempotencyKeys.Release(user.ID, key)
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	} else {
+		transfer, err = app.models.Transfers.GetById(transferID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		headers.Set("Idempotent-Replayed", "true")
+	}
+	if app.hideQueries(r, true) {
+		redactQueries(transfer)
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"transfer": transfer}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// The most transfers one batch may create
+const maxBatchTransfers = 1000
+
+// What became of one transfer of a batch: the transfer it created, or why
+// it wasn't created
+type batchTransferResult struct {
+	Transfer *data.Transfer    `json:"transfer,omitempty"`
+	Error    map[string]string `json:"error,omitempty"`
+}
+
+// Creates several transfers at once, answering with a result for each, in
+// the order they were given. An atomic batch is all or nothing: if any
+// transfer is invalid none are created, and the rest are inserted in one
+// transaction. Otherwise every valid transfer is created, whatever becomes
+// of the others.
+func (app *application) createTransfersBatchApiHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Transfers []transferInput `json:"transfers"`
+		Atomic    bool            `json:"atomic"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusAccepted, envelope{"transfer": transfer}, nil)
+	v := validator.New()
+	v.Check(len(input.Transfers) > 0, "transfers", "At least one transfer is required")
+	v.Check(len(input.Transfers) <= maxBatchTransfers, "transfers", fmt.Sprintf("A batch must not have more than %d transfers", maxBatchTransfers))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	transfers := make([]*data.Transfer, len(input.Transfers))
+	results := make([]batchTransferResult, len(input.Transfers))
+	allValid := true
+
+	for i, transferInput := range input.Transfers {
+		transfer := transferInput.transfer()
+		transfer.CreatedBy = user.ID
+		transfer.RequestID = app.contextGetRequestID(r)
+
+		v := validator.New()
+		err = app.validateTransfer(v, transfer)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !v.Valid() {
+			results[i].Error = v.Errors
+			allValid = false
+			continue
+		}
+		transfers[i] = transfer
+	}
+
+	if input.Atomic {
+		if !allValid {
+			err = app.writeJSON(w, http.StatusUnprocessableEntity, envelope{"results": results}, nil)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		err = app.models.Transfers.InsertAll(transfers)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		for i, transfer := range transfers {
+			results[i].Transfer = transfer
+		}
+	} else {
+		for i, transfer := range transfers {
+			if transfer == nil {
+				continue
+			}
+			_, err = app.models.Transfers.Insert(transfer)
+			if err != nil {
+				app.logError(r, err)
+				results[i].Error = map[string]string{"transfer": "the server encountered a problem and could not create this transfer"}
+				continue
+			}
+			results[i].Transfer = transfer
+		}
+	}
+
+	if app.hideQueries(r, true) {
+		for _, result := range results {
+			if result.Transfer != nil {
+				redactQueries(result.Transfer)
+			}
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Checks a transfer definition the same way creating it would, without
+// saving anything
+func (app *application) validateTransferApiHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := app.readTransferInput(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+
+	err := app.validateTransfer(v, transfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"valid": true}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -108,7 +559,14 @@ func (app *application) showTransferApiHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	transfer, err := app.models.Transfers.GetById(id)
+	v := validator.New()
+	includeQuery := app.readBool(r.URL.Query(), "include_query", true, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	transfer, err := app.getVisibleTransfer(r, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -118,6 +576,9 @@ func (app *application) showTransferApiHandler(w http.ResponseWriter, r *http.Re
 		}
 		return
 	}
+	if app.hideQueries(r, includeQuery) {
+		redactQueries(transfer)
+	}
 
 	err = app.writeJSON(w, http.StatusOK, envelope{"transfer": transfer}, nil)
 	if err != nil {
@@ -125,6 +586,47 @@ func (app *application) showTransferApiHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// Serves the log kept with a transfer. Only the persisted log is kept, so
+// persisted=false can't be served.
+func (app *application) showTransferLogApiHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	persisted := app.readBool(r.URL.Query(), "persisted", true, v)
+	v.Check(persisted, "persisted", "Only the persisted log is kept")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var log *data.TransferLog
+	transfer, err := app.getVisibleTransfer(r, id)
+	if err == nil {
+		log, err = app.models.Transfers.GetLog(id)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if app.hideQueries(r, true) {
+		redactLog(log, transfer.Query)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"log": log}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *application) cancelTransferApiHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -133,7 +635,7 @@ func (app *application) cancelTransferApiHandler(w http.ResponseWriter, r *http.
 	}
 
 	v := validator.New()
-	transfer, err := app.models.Transfers.GetById(id)
+	transfer, err := app.getVisibleTransfer(r, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -164,6 +666,9 @@ func (app *application) cancelTransferApiHandler(w http.ResponseWriter, r *http.
 		}
 		return
 	}
+	if app.hideQueries(r, true) {
+		redactQueries(transfer)
+	}
 
 	err = app.writeJSON(w, http.StatusOK, envelope{"transfer": transfer}, nil)
 	if err != nil {
@@ -171,6 +676,266 @@ func (app *application) cancelTransferApiHandler(w http.ResponseWriter, r *http.
 	}
 }
 
+// Queues a new transfer with the same definition as an earlier one, which is
+// left as it was. The connections are checked again, since they may have
+// been deleted since.
+func (app *application) rerunTransferApiHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	original, err := app.getVisibleTransfer(r, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	transfer := original.Rerun()
+	transfer.CreatedBy = app.contextGetUser(r).ID
+	transfer.RequestID = app.contextGetRequestID(r)
+
+	v := validator.New()
+
+	err = app.validateTransfer(v, transfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	transfer, err = app.models.Transfers.Insert(transfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if app.hideQueries(r, true) {
+		redactQueries(transfer)
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"transfer": transfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Streams the rows a completed transfer loaded into its target table, as csv
+// or ndjson. Once the first row is out the status can't change, so later
+// errors end the stream and are only logged.
+func (app *application) exportTransferResultApiHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	format := app.readString(r.URL.Query(), "format", "csv")
+	v.Check(validator.In(format, "csv", "json"), "format", "Format must be csv or json")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	transfer, err := app.getVisibleTransfer(r, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	switch {
+	case transfer.TargetTable == "":
+		app.errorResponse(w, r, http.StatusConflict, "transfer didn't load a single target table, so there is no result to export")
+		return
+	case transfer.Status != "complete":
+		app.errorResponse(w, r, http.StatusConflict, fmt.Sprintf("transfer is %s, only complete transfers can be exported", transfer.Status))
+		return
+	}
+
+	target, err := app.models.Connections.GetById(transfer.TargetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusConflict, "transfer's target connection has been deleted")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	transfer.Target = *target
+
+	contentType := "text/csv"
+	if format == "json" {
+		contentType = "application/x-ndjson"
+	}
+	out := &streamWriter{app: app, w: w, r: r, contentType: contentType}
+
+	errProperties, err := engine.ExportTransferResult(*transfer, format, out)
+	switch {
+	case err != nil && !out.started:
+		app.logEngineError(r, err, errProperties)
+		app.errorResponse(w, r, http.StatusBadGateway, err.Error())
+	case err != nil:
+		app.logEngineError(r, err, errProperties)
+	case !out.started:
+		// a json export of an empty table
+		out.Write(nil)
+	}
+}
+
+// Compares the row counts of a completed transfer's source query and its
+// target table, and optionally the sums of a key column
+func (app *application) diffTransferApiHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	checksumColumn := app.readString(r.URL.Query(), "checksumColumn", "")
+	v.Check(checksumColumn == "" || validator.Matches(checksumColumn, validator.IdentifierRX), "checksumColumn", "Checksum column must be a plain column name")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	transfer, err := app.getVisibleTransfer(r, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	switch {
+	case transfer.TargetTable == "":
+		app.errorResponse(w, r, http.StatusConflict, "transfer didn't load a single target table, so there is nothing to compare")
+		return
+	case transfer.Status != "complete":
+		app.errorResponse(w, r, http.StatusConflict, fmt.Sprintf("transfer is %s, only complete transfers can be compared", transfer.Status))
+		return
+	case transfer.SampleRate > 0:
+		app.errorResponse(w, r, http.StatusConflict, engine.ErrSampledDiff.Error())
+		return
+	}
+
+	source, err := app.models.Connections.GetById(transfer.SourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusConflict, "transfer's source connection has been deleted")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	transfer.Source = *source
+
+	target, err := app.models.Connections.GetById(transfer.TargetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusConflict, "transfer's target connection has been deleted")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	transfer.Target = *target
+
+	diff, errProperties, err := engine.DiffTransfer(*transfer, checksumColumn)
+	if err != nil {
+		app.logEngineError(r, err, errProperties)
+		app.errorResponse(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"diff": diff}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Estimates how much a transfer definition would move, without creating or
+// running it
+func (app *application) estimateTransferApiHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, ok := app.readTransferInput(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+
+	err := app.validateTransfer(v, transfer)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	source, err := app.models.Connections.GetById(transfer.SourceID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	transfer.Source = *source
+
+	estimate, errProperties, err := engine.EstimateTransfer(*transfer)
+	if err != nil {
+		app.logEngineError(r, err, errProperties)
+		app.errorResponse(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"estimate": estimate}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Sends the status and headers on the first write, so errors before any
+// output can still get an error response. The write deadline is extended
+// before each write, so long exports aren't cut off by WriteTimeout.
+type streamWriter struct {
+	app         *application
+	w           http.ResponseWriter
+	r           *http.Request
+	contentType string
+	started     bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if !s.started {
+		s.w.Header().Set("Content-Type", s.contentType)
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	s.app.extendWriteDeadline(s.r)
+	return s.w.Write(p)
+}
+
 func (app *application) deleteTransferApiHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -195,18 +960,62 @@ func (app *application) deleteTransferApiHandler(w http.ResponseWriter, r *http.
 	}
 }
 
+// Deletes a batch of finished transfers, listed by id or matching a status
+// and a date they were created before
+func (app *application) deleteTransfersApiHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		IDs    []int64   `json:"ids"`
+		Status string    `json:"status"`
+		Before time.Time `json:"before"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	filters := data.Filters{Status: input.Status, CreatedBefore: input.Before}
+
+	v := validator.New()
+	if data.ValidateDeleteMany(v, input.IDs, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	deleted, err := app.models.Transfers.DeleteMany(input.IDs, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTransferNotFinished):
+			app.errorResponse(w, r, http.StatusConflict, "queued and active transfers can't be deleted")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"deleted": deleted}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *application) listTransfersUiHandler(w http.ResponseWriter, r *http.Request) {
 	input, validationErrors := app.getListTransfersInput(r)
 	if !reflect.DeepEqual(validationErrors, map[string]string{}) {
 		app.failedValidationResponse(w, r, validationErrors)
 		return
 	}
+	input.scopeTo(app.contextGetUser(r))
 
 	transfers, metadata, err := app.models.Transfers.GetAll(input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	if app.hideQueries(r, input.IncludeQuery) {
+		redactQueries(transfers...)
+	}
 
 	paginationData := getPaginationData(metadata.CurrentPage, int(metadata.TotalRecords), metadata.PageSize, "transfers")
 
@@ -237,7 +1046,7 @@ func (app *application) cancelTransferUiHandler(w http.ResponseWriter, r *http.R
 	}
 
 	v := validator.New()
-	transfer, err := app.models.Transfers.GetById(id)
+	transfer, err := app.getVisibleTransfer(r, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -280,7 +1089,7 @@ func (app *application) showTransferUiHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	transfer, err := app.models.Transfers.GetById(id)
+	transfer, err := app.getVisibleTransfer(r, id)
 	if err != nil {
 		if errors.Is(err, data.ErrRecordNotFound) {
 			app.notFoundResponse(w, r)
@@ -289,6 +1098,9 @@ func (app *application) showTransferUiHandler(w http.ResponseWriter, r *http.Req
 		}
 		return
 	}
+	if app.hideQueries(r, true) {
+		redactQueries(transfer)
+	}
 
 	app.render(w, r, "transfer-detail.page.tmpl", &templateData{Transfer: transfer})
 }
@@ -325,6 +1137,8 @@ func (app *application) createTransferUiHandler(w http.ResponseWriter, r *http.R
 		TargetSchema: r.PostForm.Get("targetSchema"),
 		TargetTable:  r.PostForm.Get("targetTable"),
 		Overwrite:    r.PostForm.Get("overwrite") == "on",
+		CreatedBy:    app.contextGetUser(r).ID,
+		RequestID:    app.contextGetRequestID(r),
 	}
 
 	form := forms.New(r.PostForm)
@@ -0,0 +1,989 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+// Serves a page of numTransfers transfers for any transfer listing. Odd
+// transfers were created by user 1 and even ones by user 2.
+func fakeTransfersTable(numTransfers int) fakeHandler {
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		columns := make([]string, 47)
+		var rows [][]driver.Value
+		for id := 1; id <= numTransfers; id++ {
+			created := time.Date(2022, 1, id, 0, 0, 0, 0, time.UTC)
+			createdBy := int64(2 - id%2)
+			if match := createdByRX.FindStringSubmatch(query); match != nil {
+				arg, _ := strconv.Atoi(match[1])
+				if args[arg-1] != createdBy {
+					continue
+				}
+			}
+			rows = append(rows, []driver.Value{
+				int64(numTransfers), int64(id), created,
+				int64(1), "source", "postgresql", "", "app",
+				int64(2), "target", "mssql", "", "warehouse",
+				fmt.Sprintf("select * from t%d", id), "dbo", fmt.Sprintf("t%d", id), false, []byte("{}"),
+				int64(0), "", "", false, []byte("{}"), []byte("{}"), "", int64(0), int64(0), int64(0), []byte("[]"), []byte("{}"), []byte("{}"), false, "", int64(0), int64(0), int64(0), int64(0), int64(0), float64(0), false, createdBy, []byte("{}"),
+				"complete", "", "", created.Add(time.Minute), int64(1),
+			})
+		}
+		return columns, rows, nil
+	}
+}
+
+var createdByRX = regexp.MustCompile(`transfers\.created_by = \$([0-9]+)`)
+
+// Records how many rows the driver had served, since start, when each line
+// was written
+type servedAtWrite struct {
+	*httptest.ResponseRecorder
+	fake   *testdb.DB
+	start  int
+	served []int
+}
+
+func (w *servedAtWrite) Write(b []byte) (int, error) {
+	w.served = append(w.served, w.fake.Served()-w.start)
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestListTransfersNDJSON(t *testing.T) {
+	db, fake := newFakeDB(t, fakeTransfersTable(5))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	admin := &data.User{ID: 1, Admin: true}
+
+	rr := httptest.NewRecorder()
+	app.listTransfersApiHandler(rr, app.contextSetUser(httptest.NewRequest(http.MethodGet, "/api/v1/transfers?page_size=5", nil), admin))
+
+	var envelope struct {
+		Transfers []map[string]interface{} `json:"transfers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := &servedAtWrite{ResponseRecorder: httptest.NewRecorder(), fake: fake, start: fake.Served()}
+	r := app.contextSetUser(httptest.NewRequest(http.MethodGet, "/api/v1/transfers?page_size=5", nil), admin)
+	r.Header.Set("Accept", "application/x-ndjson")
+	app.listTransfersApiHandler(stream, r)
+
+	if got := stream.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("wanted an ndjson content type, got %q", got)
+	}
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(stream.Body.String()))
+	for scanner.Scan() {
+		var transfer map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &transfer); err != nil {
+			t.Fatalf("line %d isn't a JSON object: %v", len(lines)+1, err)
+		}
+		lines = append(lines, transfer)
+	}
+
+	if len(lines) != 5 || !reflect.DeepEqual(lines, envelope.Transfers) {
+		t.Errorf("wanted the same 5 transfers as the JSON listing, got %d lines:\n%s", len(lines), stream.Body.String())
+	}
+
+	// each line goes out before the next row is read
+	for i, served := range stream.served {
+		if served != i+1 {
+			t.Fatalf("wanted line %d written after %d rows were read, got %v", i+1, i+1, stream.served)
+		}
+	}
+}
+
+func TestListTransfersNDJSONCasing(t *testing.T) {
+	db, _ := newFakeDB(t, fakeTransfersTable(2))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.jsonCasing = jsonCasingSnake
+
+	rr := httptest.NewRecorder()
+	r := app.contextSetUser(httptest.NewRequest(http.MethodGet, "/api/v1/transfers", nil), &data.User{ID: 1, Admin: true})
+	r.Header.Set("Accept", "application/x-ndjson")
+	app.listTransfersApiHandler(rr, r)
+
+	scanner := bufio.NewScanner(strings.NewReader(rr.Body.String()))
+	lines := 0
+	for scanner.Scan() {
+		var transfer map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &transfer); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := transfer["source_id"]; !ok {
+			t.Errorf("wanted snake case field names, got %s", scanner.Text())
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("wanted 2 lines, got %d:\n%s", lines, rr.Body.String())
+	}
+}
+
+func TestListTransfersScopedToCreator(t *testing.T) {
+	db, _ := newFakeDB(t, fakeTransfersTable(4))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	list := func(user *data.User, path string) []int64 {
+		rr := httptest.NewRecorder()
+		app.listTransfersApiHandler(rr, app.contextSetUser(httptest.NewRequest(http.MethodGet, path, nil), user))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("wanted 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		var envelope struct {
+			Transfers []data.Transfer `json:"transfers"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, transfer := range envelope.Transfers {
+			if transfer.CreatedBy != user.ID && !user.Admin {
+				t.Errorf("user %d was shown transfer %d created by user %d", user.ID, transfer.ID, transfer.CreatedBy)
+			}
+			ids = append(ids, transfer.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name string
+		user *data.User
+		path string
+		want []int64
+	}{
+		{"non-admin", &data.User{ID: 2}, "/api/v1/transfers", []int64{2, 4}},
+		{"non-admin asking for mine", &data.User{ID: 2}, "/api/v1/transfers?mine=true", []int64{2, 4}},
+		{"admin", &data.User{ID: 1, Admin: true}, "/api/v1/transfers", []int64{1, 2, 3, 4}},
+		{"admin asking for mine", &data.User{ID: 1, Admin: true}, "/api/v1/transfers?mine=true", []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list(tt.user, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted transfers %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// Keeps just enough of the idempotency_keys and transfers tables to create
+// transfers and replay them
+func fakeIdempotentTables(created *int) fakeHandler {
+	keys := map[string]driver.Value{}
+	transfer := fakeTransfersTable(1)
+
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "INSERT INTO idempotency_keys"):
+			key := fmt.Sprint(args[0], "/", args[1])
+			if _, ok := keys[key]; ok {
+				return []string{"user_id"}, nil, nil
+			}
+			keys[key] = nil
+			return []string{"user_id"}, [][]driver.Value{{args[0]}}, nil
+		case strings.Contains(query, "SELECT transfer_id"):
+			return []string{"transfer_id"}, [][]driver.Value{{keys[fmt.Sprint(args[0], "/", args[1])]}}, nil
+		case strings.Contains(query, "UPDATE idempotency_keys"):
+			keys[fmt.Sprint(args[0], "/", args[1])] = args[2]
+			return nil, nil, nil
+		case strings.Contains(query, "INSERT INTO transfers"):
+			*created++
+			return make([]string, 4), [][]driver.Value{{int64(*created), time.Now(), "queued", int64(1)}}, nil
+		case strings.Contains(query, "where transfers.id = $1"):
+			_, rows, _ := transfer(query, args)
+			row := append([]driver.Value{}, rows[0][1:]...)
+			row[0] = args[0]
+			return make([]string, len(row)), [][]driver.Value{row}, nil
+		case strings.Contains(query, "FROM connections"):
+			return make([]string, 16), [][]driver.Value{{args[0], time.Now(), "conn", "postgresql", "u", "p", "", "h", int64(5432), "db", "", []byte("{}"), int64(0), int64(0), "", int64(1)}}, nil
+		}
+		return nil, nil, fmt.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestCreateTransferIdempotencyKey(t *testing.T) {
+	created := 0
+	db, _ := newFakeDB(t, fakeIdempotentTables(&created))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.idempotencyTTL = time.Hour
+
+	create := func(key string) (int64, *httptest.ResponseRecorder) {
+		body := `{"sourceID":1,"targetID":2,"query":"select 1","targetSchema":"public","targetTable":"t"}`
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		r = app.contextSetUser(r, &data.User{ID: 7})
+
+		rr := httptest.NewRecorder()
+		app.createTransferApiHandler(rr, r)
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("wanted 202, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		var envelope struct {
+			Transfer data.Transfer `json:"transfer"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		return envelope.Transfer.ID, rr
+	}
+
+	first, _ := create("retry-me")
+	second, rr := create("retry-me")
+	if created != 1 || first != second {
+		t.Errorf("wanted one transfer for a repeated key, got %d created, IDs %d and %d", created, first, second)
+	}
+	if rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("wanted the repeated create marked as replayed")
+	}
+
+	third, rr := create("another-key")
+	if created != 2 || third == first {
+		t.Errorf("wanted a second transfer for a new key, got %d created, IDs %d and %d", created, first, third)
+	}
+	if rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("wanted a new key's create not marked as replayed")
+	}
+}
+
+func TestCreateTransferKeepsRequestID(t *testing.T) {
+	created := 0
+	tables := fakeIdempotentTables(&created)
+	var requestID driver.Value
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "INSERT INTO transfers") {
+			requestID = args[len(args)-1]
+		}
+		return tables(query, args)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	body := `{"sourceID":1,"targetID":2,"query":"select 1","targetSchema":"public","targetTable":"t"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", strings.NewReader(body))
+	r = app.contextSetUser(r, &data.User{ID: 7})
+	r = app.contextSetRequestID(r, "req-123")
+	rr := httptest.NewRecorder()
+	app.createTransferApiHandler(rr, r)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("wanted 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if requestID != "req-123" {
+		t.Errorf("wanted the request ID saved with the transfer, got %v", requestID)
+	}
+
+	properties := withRequestID(&data.Transfer{RequestID: "req-123"}, map[string]string{"error": "boom"})
+	if !reflect.DeepEqual(properties, map[string]string{"RequestID": "req-123", "error": "boom"}) {
+		t.Errorf("wanted the request ID added to the log properties, got %v", properties)
+	}
+}
+
+func TestRedactQueries(t *testing.T) {
+	created := 0
+	show := fakeIdempotentTables(&created)
+	list := fakeTransfersTable(1)
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "where transfers.id = $1") {
+			return show(query, args)
+		}
+		return list(query, args)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.redactQueries = true
+
+	shownQueries := func(user *data.User, query string) []string {
+		var shown []string
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/transfers/1"+query, nil)
+		r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "1"}}))
+		rr := httptest.NewRecorder()
+		app.showTransferApiHandler(rr, app.contextSetUser(r, user))
+		var showed struct {
+			Transfer data.Transfer `json:"transfer"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &showed); err != nil {
+			t.Fatal(err)
+		}
+		shown = append(shown, showed.Transfer.Query)
+
+		rr = httptest.NewRecorder()
+		app.listTransfersApiHandler(rr, app.contextSetUser(httptest.NewRequest(http.MethodGet, "/api/v1/transfers"+query, nil), user))
+		var listed struct {
+			Transfers []data.Transfer `json:"transfers"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+			t.Fatal(err)
+		}
+		for _, transfer := range listed.Transfers {
+			shown = append(shown, transfer.Query)
+		}
+		return shown
+	}
+
+	tests := []struct {
+		name  string
+		user  *data.User
+		query string
+		want  string
+	}{
+		{"non-admin", &data.User{ID: 1}, "", redactedQuery},
+		{"admin", &data.User{ID: 1, Admin: true}, "", "select * from t1"},
+		{"admin leaving the query out", &data.User{ID: 1, Admin: true}, "?include_query=false", redactedQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shown := shownQueries(tt.user, tt.query)
+			if len(shown) != 2 {
+				t.Fatalf("wanted the transfer shown and listed, got queries %q", shown)
+			}
+			for _, query := range shown {
+				if query != tt.want {
+					t.Errorf("wanted query %q, got %q", tt.want, query)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactQueriesFromFailures(t *testing.T) {
+	created := 0
+	tables := fakeIdempotentTables(&created)
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "SELECT status, log, log_dropped"):
+			lines := `{"running","failed: unable to run select * from t1 map[error:timeout]"}`
+			return []string{"status", "log", "log_dropped"}, [][]driver.Value{{"error", []byte(lines), int64(0)}}, nil
+		case strings.Contains(query, "where transfers.id = $1"):
+			columns, rows, err := tables(query, args)
+			row := rows[0]
+			row[len(row)-5] = "error"
+			row[len(row)-4] = "syntax error in select * from t1"
+			row[len(row)-3] = "map[error:syntax error query:select * from t1]"
+			return columns, rows, err
+		}
+		return tables(query, args)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.redactQueries = true
+
+	get := func(handler http.HandlerFunc, user *data.User) string {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/transfers/1", nil)
+		r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "1"}}))
+		rr := httptest.NewRecorder()
+		handler(rr, app.contextSetUser(r, user))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("wanted 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	for _, handler := range []http.HandlerFunc{app.showTransferApiHandler, app.showTransferLogApiHandler} {
+		if body := get(handler, &data.User{ID: 1}); strings.Contains(body, "select * from t1") {
+			t.Errorf("wanted the query redacted for a non-admin, got %s", body)
+		}
+		if body := get(handler, &data.User{ID: 1, Admin: true}); !strings.Contains(body, "select * from t1") {
+			t.Errorf("wanted the query shown to an admin, got %s", body)
+		}
+	}
+
+	logged := loggableErrProperties(map[string]string{"error": "syntax error", "query": "select * from t1", "transfer": "{Query:select * from t1}"})
+	if !reflect.DeepEqual(logged, map[string]string{"error": "syntax error"}) {
+		t.Errorf("wanted only the error kept for the log, got %v", logged)
+	}
+}
+
+func TestRedactQueriesFromEveryResponse(t *testing.T) {
+	created := 0
+	tables := fakeIdempotentTables(&created)
+	definition := []byte(`{"sourceID":1,"targetID":2,"query":"select * from t1","targetSchema":"dbo","targetTable":"t1"}`)
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "FROM transfer_templates"):
+			row := []driver.Value{int64(1), time.Now(), "nightly", definition, int64(1)}
+			if strings.Contains(query, "count(*) OVER()") {
+				row = append([]driver.Value{int64(1)}, row...)
+			}
+			return make([]string, len(row)), [][]driver.Value{row}, nil
+		case strings.Contains(query, "UPDATE transfers"):
+			return []string{"version"}, [][]driver.Value{{int64(2)}}, nil
+		case strings.Contains(query, "where transfers.id = $1"):
+			// only queued and active transfers can be cancelled
+			columns, rows, err := tables(query, args)
+			rows[0][len(rows[0])-5] = "queued"
+			return columns, rows, err
+		}
+		return tables(query, args)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.redactQueries = true
+
+	handlers := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"create", http.MethodPost, app.createTransferApiHandler, `{"sourceID":1,"targetID":2,"query":"select * from t1","targetSchema":"dbo","targetTable":"t1"}`},
+		{"cancel", http.MethodPost, app.cancelTransferApiHandler, ""},
+		{"rerun", http.MethodPost, app.rerunTransferApiHandler, ""},
+		{"list templates", http.MethodGet, app.listTransferTemplatesApiHandler, ""},
+		{"show template", http.MethodGet, app.showTransferTemplateApiHandler, ""},
+		{"instantiate template", http.MethodPost, app.instantiateTransferTemplateApiHandler, `{"variables":{}}`},
+	}
+
+	for _, tt := range handlers {
+		for _, user := range []*data.User{{ID: 1}, {ID: 1, Admin: true}} {
+			r := httptest.NewRequest(tt.method, "/api/v1/1", strings.NewReader(tt.body))
+			r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "1"}}))
+			rr := httptest.NewRecorder()
+			tt.handler(rr, app.contextSetUser(r, user))
+			if rr.Code >= 300 {
+				t.Fatalf("%s: wanted success, got %d: %s", tt.name, rr.Code, rr.Body.String())
+			}
+
+			shown := strings.Contains(rr.Body.String(), "select * from t1")
+			if shown != user.Admin {
+				t.Errorf("%s: wanted the query shown only to admins, admin %v got %s", tt.name, user.Admin, rr.Body.String())
+			}
+		}
+	}
+}
+
+func TestCreateTransfersBatch(t *testing.T) {
+	created := 0
+	db, _ := newFakeDB(t, fakeIdempotentTables(&created))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	post := func(atomic bool) (int, []batchTransferResult) {
+		body := fmt.Sprintf(`{"atomic":%t,"transfers":[
+			{"sourceID":1,"targetID":2,"query":"select 1","targetSchema":"public","targetTable":"a"},
+			{"sourceID":1,"targetID":2,"query":"select 2","targetTable":"not an identifier"},
+			{"sourceID":1,"targetID":2,"query":"select 3","targetSchema":"public","targetTable":"c"}
+		]}`, atomic)
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/transfers/batch", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		app.createTransfersBatchApiHandler(rr, app.contextSetUser(r, &data.User{ID: 7}))
+
+		var envelope struct {
+			Results []batchTransferResult `json:"results"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("%v: %s", err, rr.Body.String())
+		}
+		if len(envelope.Results) != 3 {
+			t.Fatalf("wanted a result for each of the 3 transfers, got %s", rr.Body.String())
+		}
+		return rr.Code, envelope.Results
+	}
+
+	code, results := post(true)
+	if code != http.StatusUnprocessableEntity || created != 0 {
+		t.Errorf("wanted an atomic batch with an invalid transfer refused whole, got %d with %d created", code, created)
+	}
+	if results[1].Error["targetTable"] == "" || results[0].Error != nil || results[2].Error != nil {
+		t.Errorf("wanted only the second transfer reported invalid, got %+v", results)
+	}
+
+	code, results = post(false)
+	if code != http.StatusAccepted || created != 2 {
+		t.Fatalf("wanted the 2 valid transfers created, got %d with %d created", code, created)
+	}
+	for _, i := range []int{0, 2} {
+		if results[i].Transfer == nil || results[i].Transfer.ID == 0 || results[i].Error != nil {
+			t.Errorf("wanted transfer %d created, got %+v", i, results[i])
+		}
+	}
+	if results[1].Transfer != nil || results[1].Error["targetTable"] != "Target table must be a plain identifier of letters, digits and underscores" {
+		t.Errorf("wanted the invalid transfer's error reported, got %+v", results[1])
+	}
+	if results[0].Transfer.CreatedBy != 7 {
+		t.Errorf("wanted the batch's transfers created by user 7, got %d", results[0].Transfer.CreatedBy)
+	}
+}
+
+func TestRerunTransfer(t *testing.T) {
+	created := 0
+	inserted := map[string]driver.Value{}
+	tables := fakeIdempotentTables(&created)
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "INSERT INTO transfers") {
+			columns := query[strings.Index(query, "(")+1 : strings.Index(query, ")")]
+			for i, column := range strings.Split(columns, ", ") {
+				inserted[column] = args[i]
+			}
+		}
+		return tables(query, args)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/rerun-transfer/4", nil)
+	r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "4"}}))
+	r = app.contextSetUser(r, &data.User{ID: 3, Admin: true})
+	rr := httptest.NewRecorder()
+	app.rerunTransferApiHandler(rr, r)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("wanted 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var envelope struct {
+		Transfer data.Transfer `json:"transfer"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	rerun := envelope.Transfer
+	if created != 1 || rerun.ID == 4 {
+		t.Fatalf("wanted a new transfer, got %d created with ID %d", created, rerun.ID)
+	}
+	if rerun.RerunOf != 4 {
+		t.Errorf("wanted rerunOf 4, got %d", rerun.RerunOf)
+	}
+	if rerun.SourceID != 1 || rerun.TargetID != 2 || rerun.Query != "select * from t1" || rerun.TargetSchema != "dbo" || rerun.TargetTable != "t1" {
+		t.Errorf("wanted the original definition, got %+v", rerun)
+	}
+
+	// the rerun belongs to whoever reran it
+	want := map[string]driver.Value{"source_id": int64(1), "target_id": int64(2), "query": "select * from t1", "target_schema": "dbo", "target_table": "t1", "rerun_of": int64(4), "created_by": int64(3)}
+	for column, value := range want {
+		if inserted[column] != value {
+			t.Errorf("wanted %s inserted as %v, got %v", column, value, inserted[column])
+		}
+	}
+}
+
+func TestValidateTransferCountsQueryArgs(t *testing.T) {
+	created := 0
+	db, _ := newFakeDB(t, fakeIdempotentTables(&created))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	validate := func(args string) *httptest.ResponseRecorder {
+		body := `{"sourceID":1,"targetID":2,"query":"select * from events where created_at >= $1 and created_at < $2","targetSchema":"public","targetTable":"t","queryArgs":` + args + `}`
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/validate-transfer", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		app.validateTransferApiHandler(rr, r)
+		return rr
+	}
+
+	rr := validate(`[{"type":"date","value":"2022-01-01"},{"type":"date","value":"2022-02-01"}]`)
+	if rr.Code != http.StatusOK {
+		t.Errorf("wanted 200 for matching args, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = validate(`[{"type":"date","value":"2022-01-01"}]`)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "2 placeholders, but 1 query args") {
+		t.Errorf("wanted 422 for a missing arg, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMaxRequestBody(t *testing.T) {
+	created := 0
+	db, _ := newFakeDB(t, fakeIdempotentTables(&created))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+	app.config.maxRequestBody = 512
+
+	validate := func(query string) *httptest.ResponseRecorder {
+		body := `{"sourceID":1,"targetID":2,"query":"` + query + `","targetSchema":"public","targetTable":"t"}`
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/validate-transfer", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		app.validateTransferApiHandler(rr, r)
+		return rr
+	}
+
+	rr := validate("select * from events where id in (" + strings.Repeat("1, ", 500) + "1)")
+	if rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), "body must not be larger than 512 bytes") {
+		t.Errorf("wanted 413 for an oversized body, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = validate("select * from events")
+	if rr.Code != http.StatusOK {
+		t.Errorf("wanted 200 for a body within the limit, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCreateTransferValidationErrors(t *testing.T) {
+	created := 0
+	db, _ := newFakeDB(t, fakeIdempotentTables(&created))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	body := `{"targetTable":"not an identifier","parallelism":-1}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/transfers", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	app.createTransferApiHandler(rr, r)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("wanted 422, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Error map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response isn't a JSON object of field errors: %v: %s", err, rr.Body.String())
+	}
+	want := map[string]string{
+		"sourceId":    "Source ID is required and must be an integer greater than 0",
+		"targetId":    "Target ID is required and must be an integer greater than 0",
+		"query":       "A query is required",
+		"targetTable": "Target table must be a plain identifier of letters, digits and underscores",
+		"parallelism": "Parallelism must not be negative",
+	}
+	if !reflect.DeepEqual(response.Error, want) {
+		t.Errorf("wanted field errors %v, got %v", want, response.Error)
+	}
+	if created != 0 {
+		t.Errorf("an invalid transfer was inserted")
+	}
+}
+
+func TestListTransfersInvalidFilters(t *testing.T) {
+	app := newTestApplication()
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/transfers?page=0&page_size=1000", nil)
+	rr := httptest.NewRecorder()
+	app.listTransfersApiHandler(rr, r)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("wanted 422, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// the handler used to carry on and write a second response
+	var response struct {
+		Error map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response isn't a single JSON object: %v: %s", err, rr.Body.String())
+	}
+	for _, key := range []string{"page", "page_size"} {
+		if response.Error[key] == "" {
+			t.Errorf("wanted an error for %s, got %v", key, response.Error)
+		}
+	}
+}
+
+func TestListingsUseMaxPageSize(t *testing.T) {
+	app := newTestApplication()
+	app.config.maxPageSize = 20
+
+	handlers := map[string]http.HandlerFunc{
+		"/api/v1/users":       app.listUsersApiHandler,
+		"/api/v1/connections": app.listConnectionsApiHandler,
+		"/api/v1/transfers":   app.listTransfersApiHandler,
+	}
+	for path, handler := range handlers {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodGet, path+"?page_size=50", nil))
+		if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "must be a maximum of 20") {
+			t.Errorf("%s: wanted a 422 for a page past the configured max, got %d: %s", path, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestShowTransferLog(t *testing.T) {
+	created := 0
+	tables := fakeIdempotentTables(&created)
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if !strings.Contains(query, "SELECT status, log, log_dropped") {
+			return tables(query, args)
+		}
+		return []string{"status", "log", "log_dropped"}, [][]driver.Value{{"complete", []byte(`{"running","complete"}`), int64(3)}}, nil
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "4"}}))
+		r = app.contextSetUser(r, &data.User{ID: 1})
+		rr := httptest.NewRecorder()
+		app.showTransferLogApiHandler(rr, r)
+		return rr
+	}
+
+	rr := get("/api/v1/transfers/4/logs?persisted=true")
+	var envelope struct {
+		Log data.TransferLog `json:"log"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if log := envelope.Log; log.Status != "complete" || log.Dropped != 3 || !reflect.DeepEqual(log.Lines, []string{"running", "complete"}) {
+		t.Errorf("unexpected log %+v", log)
+	}
+
+	if rr := get("/api/v1/transfers/4/logs?persisted=false"); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("wanted 422 for an unpersisted log, got %d", rr.Code)
+	}
+}
+
+func TestTransfersHiddenFromOtherUsers(t *testing.T) {
+	created := 0
+	tables := fakeIdempotentTables(&created)
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "SELECT status, log, log_dropped") {
+			return []string{"status", "log", "log_dropped"}, [][]driver.Value{{"complete", []byte(`{}`), int64(0)}}, nil
+		}
+		return tables(query, args)
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	// transfer 4 was created by user 1
+	handlers := map[string]http.HandlerFunc{
+		"show":   app.showTransferApiHandler,
+		"log":    app.showTransferLogApiHandler,
+		"cancel": app.cancelTransferApiHandler,
+		"rerun":  app.rerunTransferApiHandler,
+		"export": app.exportTransferResultApiHandler,
+		"diff":   app.diffTransferApiHandler,
+		"ui":     app.showTransferUiHandler,
+	}
+	for name, handler := range handlers {
+		r := httptest.NewRequest(http.MethodGet, "/transfers/4", nil)
+		r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "4"}}))
+		r = app.contextSetUser(r, &data.User{ID: 2})
+		rr := httptest.NewRecorder()
+		handler(rr, r)
+		if rr.Code != http.StatusNotFound && !(name == "cancel" && rr.Code == http.StatusUnprocessableEntity && strings.Contains(rr.Body.String(), "not found")) {
+			t.Errorf("%s: wanted another user's transfer not found, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+	if created != 0 {
+		t.Errorf("wanted no rerun of another user's transfer, got %d", created)
+	}
+
+	for _, user := range []*data.User{{ID: 1}, {ID: 2, Admin: true}} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/transfers/4", nil)
+		r = r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "id", Value: "4"}}))
+		r = app.contextSetUser(r, user)
+		rr := httptest.NewRecorder()
+		app.showTransferApiHandler(rr, r)
+		if rr.Code != http.StatusOK {
+			t.Errorf("user %d (admin %t): wanted the transfer shown, got %d", user.ID, user.Admin, rr.Code)
+		}
+	}
+}
+
+// Serves transfers with the given statuses, keyed by id, deleting the ones a
+// bulk delete's conditions match
+func fakeDeletableTransfers(statuses map[int64]string) fakeHandler {
+	inArray := func(array driver.Value, value string) bool {
+		for _, element := range strings.Split(strings.Trim(array.(string), "{}"), ",") {
+			if element == value || element == `"`+value+`"` {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.HasPrefix(query, "DELETE FROM transfers"):
+			var deleted [][]driver.Value
+			for id, status := range statuses {
+				matches := inArray(args[0], status)
+				if strings.Contains(query, "id = any($2)") {
+					matches = matches && inArray(args[1], fmt.Sprint(id))
+				} else if strings.Contains(query, "status = $2") {
+					matches = matches && status == args[1]
+				}
+				if matches {
+					delete(statuses, id)
+					deleted = append(deleted, []driver.Value{id})
+				}
+			}
+			return []string{"id"}, deleted, nil
+		case strings.HasPrefix(query, "SELECT count(*) FROM transfers"):
+			var left int64
+			for id := range statuses {
+				if inArray(args[0], fmt.Sprint(id)) {
+					left++
+				}
+			}
+			return []string{"count"}, [][]driver.Value{{left}}, nil
+		}
+		return nil, nil, fmt.Errorf("unexpected query %q", query)
+	}
+}
+
+func TestDeleteTransfers(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		code     int
+		deleted  int64
+		statuses map[int64]string
+	}{
+		{"by status", `{"status": "complete"}`, http.StatusOK, 2, map[int64]string{3: "active", 4: "error"}},
+		{"by ids", `{"ids": [1, 4]}`, http.StatusOK, 2, map[int64]string{2: "complete", 3: "active"}},
+		{"listing an active transfer", `{"ids": [1, 3]}`, http.StatusConflict, 0, nil},
+		{"by an unfinished status", `{"status": "active"}`, http.StatusUnprocessableEntity, 0, nil},
+		{"without ids or a filter", `{}`, http.StatusUnprocessableEntity, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses := map[int64]string{1: "complete", 2: "complete", 3: "active", 4: "error"}
+			db, _ := newFakeDB(t, fakeDeletableTransfers(statuses))
+			app := newTestApplication()
+			app.models = data.NewModels(db)
+
+			rr := httptest.NewRecorder()
+			app.deleteTransfersApiHandler(rr, httptest.NewRequest(http.MethodDelete, "/api/v1/transfers", strings.NewReader(tt.body)))
+			if rr.Code != tt.code {
+				t.Fatalf("wanted %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var envelope struct {
+				Deleted int64 `json:"deleted"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+				t.Fatal(err)
+			}
+			if envelope.Deleted != tt.deleted {
+				t.Errorf("wanted %d deleted, got %d", tt.deleted, envelope.Deleted)
+			}
+			if !reflect.DeepEqual(statuses, tt.statuses) {
+				t.Errorf("wanted %v left, got %v", tt.statuses, statuses)
+			}
+		})
+	}
+}
+
+// Serves the statuses of transfers a depended-on transfer lookup asks for,
+// and records the status each transfer is updated to
+func fakeDependencyTable(statuses map[int64]string, dependsOn map[int64][]int64) fakeHandler {
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "WHERE id = ANY($1)"):
+			var rows [][]driver.Value
+			for _, field := range strings.Split(strings.Trim(fmt.Sprint(args[0]), "{}"), ",") {
+				id, _ := strconv.ParseInt(field, 10, 64)
+				if status, ok := statuses[id]; ok {
+					deps := strings.Trim(fmt.Sprint(dependsOn[id]), "[]")
+					rows = append(rows, []driver.Value{id, status, []byte("{" + strings.ReplaceAll(deps, " ", ",") + "}")})
+				}
+			}
+			return make([]string, 3), rows, nil
+		case strings.Contains(query, "SET status"):
+			statuses[args[5].(int64)] = args[0].(string)
+			return []string{"version"}, [][]driver.Value{{int64(2)}}, nil
+		case strings.Contains(query, "SET log"):
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestReadyTransfersRunsChainsInOrder(t *testing.T) {
+	statuses := map[int64]string{1: "queued", 2: "queued"}
+	db, _ := newFakeDB(t, fakeDependencyTable(statuses, map[int64][]int64{2: {1}}))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	queued := func() []*data.Transfer {
+		var transfers []*data.Transfer
+		for _, transfer := range []*data.Transfer{{ID: 1}, {ID: 2, DependsOn: []int64{1}}} {
+			if statuses[transfer.ID] == "queued" {
+				transfers = append(transfers, transfer)
+			}
+		}
+		return transfers
+	}
+	ids := func(transfers []*data.Transfer) []int64 {
+		var ids []int64
+		for _, transfer := range transfers {
+			ids = append(ids, transfer.ID)
+		}
+		return ids
+	}
+
+	if got := ids(app.readyTransfers(queued())); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("wanted only transfer 1 started while 2 waits for it, got %v", got)
+	}
+
+	statuses[1] = "active"
+	if got := ids(app.readyTransfers(queued())); len(got) != 0 {
+		t.Fatalf("wanted transfer 2 held back while 1 runs, got %v", got)
+	}
+
+	statuses[1] = "complete"
+	if got := ids(app.readyTransfers(queued())); !reflect.DeepEqual(got, []int64{2}) {
+		t.Fatalf("wanted transfer 2 started once 1 completed, got %v", got)
+	}
+}
+
+func TestReadyTransfersSkipsDependentsOfFailures(t *testing.T) {
+	statuses := map[int64]string{1: "error", 2: "queued", 3: "queued"}
+	db, _ := newFakeDB(t, fakeDependencyTable(statuses, map[int64][]int64{2: {1}, 3: {2}}))
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	dependent := &data.Transfer{ID: 2, DependsOn: []int64{1}, Status: "queued", Version: 1}
+	next := &data.Transfer{ID: 3, DependsOn: []int64{2}, Status: "queued", Version: 1}
+
+	ready := app.readyTransfers([]*data.Transfer{dependent, next})
+	if len(ready) != 0 {
+		t.Fatalf("wanted nothing started, got %d transfers", len(ready))
+	}
+	if statuses[2] != "skipped" || dependent.Error != "dependency 1 ended with status error" {
+		t.Errorf("wanted transfer 2 skipped for its failed dependency, got %s: %q", statuses[2], dependent.Error)
+	}
+	if dependent.StoppedAt.IsZero() {
+		t.Error("wanted the skipped transfer's stop time set")
+	}
+
+	// the skip carries down the chain on the next scan
+	ready = app.readyTransfers([]*data.Transfer{next})
+	if len(ready) != 0 || statuses[3] != "skipped" {
+		t.Errorf("wanted transfer 3 skipped after 2 was, got status %s", statuses[3])
+	}
+}
+
+func TestReadyTransfersStartsIndependentOnLookupFailure(t *testing.T) {
+	db, _ := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		return nil, nil, fmt.Errorf("connection reset")
+	})
+	app := newTestApplication()
+	app.models = data.NewModels(db)
+
+	ready := app.readyTransfers([]*data.Transfer{{ID: 1}, {ID: 2, DependsOn: []int64{1}}, {ID: 3}})
+	var ids []int64
+	for _, transfer := range ready {
+		ids = append(ids, transfer.ID)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 3}) {
+		t.Errorf("wanted the transfers without dependencies started, got %v", ids)
+	}
+}
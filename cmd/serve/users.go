@@ -110,7 +110,8 @@ func (app *application) createUserApiHandler(w http.ResponseWriter, r *http.Requ
 }
 
 type listUsersInput struct {
-	Username string
+	Username        string
+	IncludeDisabled bool
 	data.Filters
 }
 
@@ -121,9 +122,12 @@ func (app *application) getListUsersInput(r *http.Request) (input listUsersInput
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 10, v)
+	input.Filters.MaxPageSize = app.config.maxPageSize
 
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+	input.Filters.Sort = app.readString(qs, "sort", app.defaultSort("users"))
+	input.Filters.SortSafelist = sortSafelists["users"]
+
+	input.IncludeDisabled = app.readBool(qs, "include_disabled", false, v)
 
 	data.ValidateFilters(v, input.Filters)
 
@@ -134,9 +138,10 @@ func (app *application) listUsersApiHandler(w http.ResponseWriter, r *http.Reque
 	input, validationErrors := app.getListUsersInput(r)
 	if !reflect.DeepEqual(validationErrors, map[string]string{}) {
 		app.failedValidationResponse(w, r, validationErrors)
+		return
 	}
 
-	users, metadata, err := app.models.Users.GetAll(input.Filters)
+	users, metadata, err := app.models.Users.GetAll(input.Filters, input.IncludeDisabled)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -187,9 +192,15 @@ func (app *application) updateUserApiHandler(w http.ResponseWriter, r *http.Requ
 		user.Admin = *input.Admin
 	}
 	if input.Password != nil {
-		err = user.Password.Set(*input.Password)
+		err = user.SetPassword(*input.Password, app.config.passwordHistory)
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
+			switch {
+			case errors.Is(err, data.ErrPasswordReused):
+				v.AddError("password", "Password must not match one of your recent passwords")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
 			return
 		}
 	}
@@ -247,7 +258,7 @@ func (app *application) deleteUserApiHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.models.Users.Delete(id)
+	err = app.deleteUser(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -258,7 +269,67 @@ func (app *application) deleteUserApiHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully deleted"}, nil)
+	message := "user successfully deleted"
+	if app.config.softDeleteUsers {
+		message = "user successfully disabled"
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// With --soft-delete-users, users are disabled rather than removed so
+// their history is kept
+func (app *application) deleteUser(id int64) error {
+	if app.config.softDeleteUsers {
+		return app.models.Users.SoftDelete(id)
+	}
+	return app.models.Users.Delete(id)
+}
+
+func (app *application) restoreUserApiHandler(w http.ResponseWriter, r *http.Request) {
+	app.setUserDisabled(w, r, false)
+}
+
+// Disabled users keep their row and history but can't authenticate, with
+// or without --soft-delete-users
+func (app *application) disableUserApiHandler(w http.ResponseWriter, r *http.Request) {
+	app.setUserDisabled(w, r, true)
+}
+
+// Disables or re-enables the user named by the :id param and writes the
+// result. A user that's already in the asked for state is not found.
+func (app *application) setUserDisabled(w http.ResponseWriter, r *http.Request, disable bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if disable {
+		err = app.models.Users.SoftDelete(id)
+	} else {
+		err = app.models.Users.Restore(id)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.Users.GetById(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -271,7 +342,7 @@ func (app *application) listUsersUiHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	users, metadata, err := app.models.Users.GetAll(input.Filters)
+	users, metadata, err := app.models.Users.GetAll(input.Filters, input.IncludeDisabled)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -386,7 +457,7 @@ func (app *application) deleteUserUiHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Users.Delete(id)
+	err = app.deleteUser(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -442,21 +513,33 @@ func (app *application) updateUserUiHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user := &data.User{
-		ID:       id,
-		Username: r.PostForm.Get("username"),
-		Admin:    r.PostForm.Get("admin") == "on",
-		Version:  version,
-	}
-
-	err = user.Password.Set(r.PostForm.Get("password"))
+	user, err := app.models.Users.GetById(id)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.notFoundResponse(w, r)
+		} else {
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	user.Username = r.PostForm.Get("username")
+	user.Admin = r.PostForm.Get("admin") == "on"
+	user.Version = version
+
 	form := forms.New(r.PostForm)
 
+	err = user.SetPassword(r.PostForm.Get("password"), app.config.passwordHistory)
+	if err != nil {
+		if errors.Is(err, data.ErrPasswordReused) {
+			form.Validator.AddError("password", "Password must not match one of your recent passwords")
+			app.render(w, r, "update-user.page.tmpl", &templateData{User: user, Form: form})
+		} else {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	if data.ValidateUser(form.Validator, user); !form.Validator.Valid() {
 		fmt.Printf("\n\n%v\n\n", form.Validator)
 		fmt.Printf("\n\n%v\n\n", form.Validator.Errors)
@@ -478,3 +561,12 @@ func (app *application) updateUserUiHandler(w http.ResponseWriter, r *http.Reque
 	app.session.Put(r, "flash", fmt.Sprintf("User %d updated", id))
 	http.Redirect(w, r, fmt.Sprintf("/ui/users/%d", id), http.StatusSeeOther)
 }
+
+func (app *application) whoamiApiHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
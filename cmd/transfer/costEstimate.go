@@ -0,0 +1,106 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/engine"
+)
+
+var CostEstimateCmd = &cobra.Command{
+	Use:   "cost-estimate",
+	Short: "Estimate the rows and bytes a transfer definition would move, without running it",
+	Long: `Asks the source for an estimate of the rows the definition's query returns,
+and how big they are. PostgreSQL and Redshift sources are only asked for a
+query plan, and the estimate is the planner's. Other sources count the
+query's rows, and their size is left unknown.
+
+With --throughput, the estimated size is turned into how long the transfer
+might take.`,
+	Run: runCostEstimate,
+}
+
+var (
+	costEstimateClient apiClient.Client
+	costEstimateFile   string
+	throughput         float64
+)
+
+func init() {
+	costEstimateClient.AddFlags(CostEstimateCmd)
+	CostEstimateCmd.Flags().StringVar(&costEstimateFile, "file", "-", "JSON transfer definition to estimate, in the same shape as the create transfer API. Use - for stdin")
+	CostEstimateCmd.Flags().Float64Var(&throughput, "throughput", 0, "Expected transfer speed in megabytes per second, to estimate how long the transfer will take")
+
+	TransferCmd.AddCommand(CostEstimateCmd)
+}
+
+func runCostEstimate(cmd *cobra.Command, args []string) {
+	if throughput < 0 {
+		fmt.Println("--throughput can't be negative")
+		os.Exit(1)
+	}
+
+	definition, err := readDefinition(costEstimateFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	estimate, err := estimateTransfer(&costEstimateClient, definition)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	printEstimate(os.Stdout, estimate, throughput)
+}
+
+// Reads a transfer definition from a file, or from stdin for -
+func readDefinition(path string) ([]byte, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	return io.ReadAll(in)
+}
+
+func estimateTransfer(client *apiClient.Client, definition []byte) (engine.TransferEstimate, error) {
+	var body struct {
+		Estimate engine.TransferEstimate `json:"estimate"`
+	}
+
+	if !json.Valid(definition) {
+		return body.Estimate, errors.New("transfer definition is not valid JSON")
+	}
+
+	err := client.Post("/api/v1/estimate-transfer", json.RawMessage(definition), &body)
+	return body.Estimate, err
+}
+
+// throughput is in megabytes per second, and 0 leaves out the duration
+func printEstimate(out io.Writer, estimate engine.TransferEstimate, throughput float64) {
+	fmt.Fprintf(out, "Estimated rows: %d\n", estimate.Rows)
+	if estimate.Method == "count" {
+		fmt.Fprintf(out, "Estimated size: unknown, the source can't estimate row sizes\n")
+		return
+	}
+
+	fmt.Fprintf(out, "Average row:    %d bytes\n", estimate.AvgRowBytes)
+	fmt.Fprintf(out, "Estimated size: %.1f MB\n", float64(estimate.Bytes)/1e6)
+	if throughput > 0 {
+		seconds := float64(estimate.Bytes) / (throughput * 1e6)
+		fmt.Fprintf(out, "Estimated time: %s at %g MB/s\n", (time.Duration(seconds * float64(time.Second))).Round(time.Second), throughput)
+	}
+}
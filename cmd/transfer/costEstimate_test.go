@@ -0,0 +1,46 @@
+package transfer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/engine"
+)
+
+func TestEstimateTransfer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/estimate-transfer" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"estimate":{"rows":2000000,"avgRowBytes":50,"bytes":100000000,"method":"explain"}}`)
+	}))
+	defer srv.Close()
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	if _, err := estimateTransfer(client, []byte("{")); err == nil {
+		t.Error("wanted a definition that isn't JSON refused")
+	}
+
+	estimate, err := estimateTransfer(client, []byte(`{"sourceId":1,"targetId":2,"query":"select 1","targetTable":"t"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	printEstimate(&out, estimate, 10)
+	want := "Estimated rows: 2000000\nAverage row:    50 bytes\nEstimated size: 100.0 MB\nEstimated time: 10s at 10 MB/s\n"
+	if out.String() != want {
+		t.Errorf("wanted %q, got %q", want, out.String())
+	}
+
+	out.Reset()
+	printEstimate(&out, engine.TransferEstimate{Rows: 5, Method: "count"}, 10)
+	if !strings.Contains(out.String(), "Estimated size: unknown") || strings.Contains(out.String(), "time") {
+		t.Errorf("wanted an unknown size and no time, got %q", out.String())
+	}
+}
@@ -0,0 +1,89 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/engine"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff <id>",
+	Short: "Compare the row counts of a completed transfer's source query and target table",
+	Long: `Counts the rows a completed transfer's query returns on its source, and the rows
+in its target table, and reports the difference. With --checksum, the given
+numeric key column is summed on both sides too.
+
+The whole target table is counted, so a table that other transfers also load
+will show their rows in the difference. Exits with status 1 if the counts or
+checksums don't match.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runDiff,
+}
+
+var (
+	diffClient   apiClient.Client
+	diffChecksum string
+)
+
+func init() {
+	diffClient.AddFlags(DiffCmd)
+	DiffCmd.Flags().StringVar(&diffChecksum, "checksum", "", "Numeric key column to sum on both sides")
+
+	TransferCmd.AddCommand(DiffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		globals.Errorf("transfer ID must be a positive integer\n")
+		os.Exit(1)
+	}
+
+	diff, err := diffTransfer(&diffClient, id, diffChecksum)
+	if err != nil {
+		globals.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+
+	if !printDiff(globals.Stdout, diff) {
+		os.Exit(1)
+	}
+}
+
+func diffTransfer(client *apiClient.Client, id int64, checksumColumn string) (engine.TransferDiff, error) {
+	var body struct {
+		Diff engine.TransferDiff `json:"diff"`
+	}
+
+	path := fmt.Sprintf("/api/v1/diff-transfer/%d", id)
+	if checksumColumn != "" {
+		qs := url.Values{}
+		qs.Set("checksumColumn", checksumColumn)
+		path += "?" + qs.Encode()
+	}
+
+	err := client.Get(path, &body)
+	return body.Diff, err
+}
+
+// Returns false if the source and target disagree
+func printDiff(out io.Writer, diff engine.TransferDiff) bool {
+	fmt.Fprintf(out, "Source rows: %d\n", diff.SourceRows)
+	fmt.Fprintf(out, "Target rows: %d\n", diff.TargetRows)
+	fmt.Fprintf(out, "Delta:       %+d\n", diff.Delta)
+
+	matched := diff.Delta == 0
+	if diff.ChecksumColumn != "" {
+		fmt.Fprintf(out, "Sum of %s: %s on the source, %s on the target\n", diff.ChecksumColumn, diff.SourceChecksum, diff.TargetChecksum)
+		matched = matched && diff.SourceChecksum == diff.TargetChecksum
+	}
+
+	return matched
+}
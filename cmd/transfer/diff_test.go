@@ -0,0 +1,55 @@
+package transfer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestDiffTransfer(t *testing.T) {
+	diffs := map[string]string{
+		"/api/v1/diff-transfer/1": `{"diff":{"sourceRows":3,"targetRows":3,"delta":0,"checksumColumn":"id","sourceChecksum":"6","targetChecksum":"6"}}`,
+		"/api/v1/diff-transfer/2": `{"diff":{"sourceRows":3,"targetRows":2,"delta":-1}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := diffs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/api/v1/diff-transfer/1" && r.URL.Query().Get("checksumColumn") != "id" {
+			t.Errorf("wanted the checksum column sent, got %q", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	diff, err := diffTransfer(client, 1, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if !printDiff(&out, diff) {
+		t.Errorf("wanted a matched pair to agree, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "Delta:       +0\n") || !strings.Contains(out.String(), "Sum of id: 6 on the source, 6 on the target") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	diff, err = diffTransfer(client, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if printDiff(&out, diff) {
+		t.Errorf("wanted a short load to disagree, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "Target rows: 2\nDelta:       -1\n") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
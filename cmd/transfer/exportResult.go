@@ -0,0 +1,58 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+var ExportResultCmd = &cobra.Command{
+	Use:   "export-result <id>",
+	Short: "Write the rows a completed transfer loaded into its target table to stdout",
+	Long: `Reads back every row of a completed transfer's target table on a SQLpipe server,
+and writes them to stdout as csv or newline delimited json. Only transfers that
+loaded a single database table, and that completed, can be exported.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runExportResult,
+}
+
+var (
+	exportResultClient apiClient.Client
+	exportResultFormat string
+)
+
+func init() {
+	exportResultClient.AddFlags(ExportResultCmd)
+	ExportResultCmd.Flags().StringVar(&exportResultFormat, "format", "csv", "Output format, csv or json")
+
+	TransferCmd.AddCommand(ExportResultCmd)
+}
+
+func runExportResult(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		fmt.Fprintln(os.Stderr, "transfer ID must be a positive integer")
+		os.Exit(1)
+	}
+
+	err = exportResult(&exportResultClient, id, exportResultFormat, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func exportResult(client *apiClient.Client, id int64, format string, out io.Writer) error {
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("format must be csv or json, not %q", format)
+	}
+
+	qs := url.Values{}
+	qs.Set("format", format)
+	return client.Download(fmt.Sprintf("/api/v1/export-transfer-result/%d?%s", id, qs.Encode()), out)
+}
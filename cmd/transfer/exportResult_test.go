@@ -0,0 +1,54 @@
+package transfer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestExportResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/export-transfer-result/3":
+			if r.URL.Query().Get("format") != "csv" {
+				http.Error(w, "wrong format", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/csv")
+			fmt.Fprint(w, "id,name\r\n1,a\r\n2,b\r\n")
+		case "/api/v1/export-transfer-result/4":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"error":"transfer is active, only complete transfers can be exported"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	var out strings.Builder
+	err := exportResult(client, 3, "csv", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,name\r\n1,a\r\n2,b\r\n"; out.String() != want {
+		t.Errorf("wanted %q, got %q", want, out.String())
+	}
+
+	out.Reset()
+	err = exportResult(client, 4, "csv", &out)
+	if err == nil || !strings.Contains(err.Error(), "only complete transfers") {
+		t.Errorf("wanted the server's error, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("wanted no output for a failed export, got %q", out.String())
+	}
+
+	if err = exportResult(client, 3, "xml", &out); err == nil {
+		t.Error("wanted an error for an unsupported format")
+	}
+}
@@ -0,0 +1,127 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+var GetCmd = &cobra.Command{
+	Use:   "get <id>",
+	Short: "Show the status of a transfer on a SQLpipe server",
+	Args:  cobra.ExactArgs(1),
+	Run:   runGet,
+}
+
+var (
+	getClient     apiClient.Client
+	watch         bool
+	watchInterval time.Duration
+	includeQuery  bool
+)
+
+func init() {
+	getClient.AddFlags(GetCmd)
+	GetCmd.Flags().BoolVar(&watch, "watch", false, "Keep polling until the transfer stops, then exit non-zero unless it completed")
+	GetCmd.Flags().DurationVar(&watchInterval, "interval", 2*time.Second, "How often to poll with --watch")
+	GetCmd.Flags().BoolVar(&includeQuery, "include-query", true, "Show the transfer's query. --include-query=false asks the server to leave it out")
+
+	TransferCmd.AddCommand(GetCmd)
+}
+
+func runGet(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		fmt.Println("transfer ID must be a positive integer")
+		os.Exit(1)
+	}
+
+	exitCode, err := getTransfer(&getClient, id, includeQuery, watch, watchInterval, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Exit(exitCode)
+}
+
+// Prints the transfer's status and rows written, after its query if
+// includeQuery. With watch, it polls every interval until the transfer
+// stops, showing how fast rows were written since the last poll, and the
+// exit code is 0 only if the transfer completed.
+func getTransfer(
+	client *apiClient.Client,
+	id int64,
+	includeQuery bool,
+	watch bool,
+	interval time.Duration,
+	out io.Writer,
+) (
+	exitCode int,
+	err error,
+) {
+	path := fmt.Sprintf("/api/v1/transfers/%d", id)
+	if !includeQuery {
+		path += "?include_query=false"
+	}
+
+	// the rows written as of the last poll, to tell the pace since
+	var lastPoll time.Time
+	var lastWritten int64
+	for polls := 0; ; polls++ {
+		var body struct {
+			Transfer data.Transfer `json:"transfer"`
+		}
+		err = client.Get(path, &body)
+		if err != nil {
+			return 1, err
+		}
+		polled := time.Now()
+		transfer := body.Transfer
+
+		stopped := validator.In(transfer.Status, data.FinishedTransferStatuses...)
+
+		elapsed := time.Since(transfer.CreatedAt)
+		if stopped {
+			elapsed = transfer.StoppedAt.Sub(transfer.CreatedAt)
+		}
+		if polls == 0 && includeQuery && transfer.Query != "" {
+			fmt.Fprintf(out, "Query: %s\n", transfer.Query)
+		}
+		pace := ""
+		if polls > 0 {
+			pace = ", " + rowsPerSecond(transfer.RowsWritten-lastWritten, polled.Sub(lastPoll))
+		}
+		fmt.Fprintf(out, "Transfer %d: %s (%s elapsed), %d rows written%s\n", transfer.ID, transfer.Status, elapsed.Round(time.Second), transfer.RowsWritten, pace)
+		lastPoll, lastWritten = polled, transfer.RowsWritten
+		if transfer.Error != "" {
+			fmt.Fprintf(out, "Error: %s\n", transfer.Error)
+		}
+
+		if !watch {
+			return 0, nil
+		}
+		if stopped {
+			if transfer.Status == "complete" {
+				return 0, nil
+			}
+			return 1, nil
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// How fast rows were written over d
+func rowsPerSecond(rows int64, d time.Duration) string {
+	if d <= 0 {
+		return "0 rows/s"
+	}
+	return fmt.Sprintf("%.0f rows/s", float64(rows)/d.Seconds())
+}
@@ -0,0 +1,84 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+// Serves transfer 3, reporting each status in turn on successive polls, with
+// 100 more rows written on each
+func newFakeTransferServer(t *testing.T, statuses ...string) (*apiClient.Client, *int) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/transfers/3" {
+			http.NotFound(w, r)
+			return
+		}
+		status := statuses[polls]
+		written := 100 * polls
+		if polls < len(statuses)-1 {
+			polls++
+		}
+		fmt.Fprintf(w, `{"transfer":{"id":3,"createdAt":"2022-01-03T10:00:00Z","status":%q,"rowsWritten":%d,"stoppedAt":"2022-01-03T10:00:05Z"}}`, status, written)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}, &polls
+}
+
+func TestGetTransferWatch(t *testing.T) {
+	client, polls := newFakeTransferServer(t, "queued", "active", "complete")
+
+	var out strings.Builder
+	exitCode, err := getTransfer(client, 3, true, true, time.Millisecond, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 0 {
+		t.Errorf("wanted exit code 0, got %d", exitCode)
+	}
+	if *polls != 2 {
+		t.Errorf("wanted polling to stop at the completed status, got %d polls", *polls)
+	}
+	if !regexp.MustCompile(`^Transfer 3: queued \([^)]+ elapsed\), 0 rows written\n` +
+		`Transfer 3: active \([^)]+ elapsed\), 100 rows written, \d+ rows/s\n` +
+		`Transfer 3: complete \(5s elapsed\), 200 rows written, \d+ rows/s\n$`).MatchString(out.String()) {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestRowsPerSecond(t *testing.T) {
+	for _, tt := range []struct {
+		rows int64
+		d    time.Duration
+		want string
+	}{
+		{500, 2 * time.Second, "250 rows/s"},
+		{0, time.Second, "0 rows/s"},
+		{10, 0, "0 rows/s"},
+	} {
+		if got := rowsPerSecond(tt.rows, tt.d); got != tt.want {
+			t.Errorf("%d rows in %s: wanted %q, got %q", tt.rows, tt.d, tt.want, got)
+		}
+	}
+}
+
+func TestGetTransferWatchFailure(t *testing.T) {
+	client, _ := newFakeTransferServer(t, "active", "error")
+
+	exitCode, err := getTransfer(client, 3, true, true, time.Millisecond, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 1 {
+		t.Errorf("wanted exit code 1, got %d", exitCode)
+	}
+}
@@ -0,0 +1,58 @@
+package transfer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+var InstantiateCmd = &cobra.Command{
+	Use:   "instantiate <template-id>",
+	Short: "Queue a transfer on a SQLpipe server made from a transfer template",
+	Long: `Fills in each {{name}} placeholder of a transfer template with the value given
+by --var name=value, and queues the resulting transfer. Every variable the
+template uses must be given a value.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runInstantiate,
+}
+
+var (
+	instantiateClient    apiClient.Client
+	instantiateVariables map[string]string
+)
+
+func init() {
+	instantiateClient.AddFlags(InstantiateCmd)
+	InstantiateCmd.Flags().StringToStringVar(&instantiateVariables, "var", map[string]string{}, "A template variable's value, as name=value. May be repeated")
+
+	TransferCmd.AddCommand(InstantiateCmd)
+}
+
+func runInstantiate(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		globals.Errorf("template ID must be a positive integer\n")
+		os.Exit(1)
+	}
+
+	transfer, err := instantiateTemplate(&instantiateClient, id, instantiateVariables)
+	if err != nil {
+		globals.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+
+	globals.Infof("Transfer %d queued from template %d. Follow it with: sqlpipe transfer get %d --watch\n", transfer.ID, id, transfer.ID)
+}
+
+func instantiateTemplate(client *apiClient.Client, id int64, variables map[string]string) (data.Transfer, error) {
+	var body struct {
+		Transfer data.Transfer `json:"transfer"`
+	}
+	err := client.Post(fmt.Sprintf("/api/v1/instantiate-transfer-template/%d", id), map[string]interface{}{"variables": variables}, &body)
+	return body.Transfer, err
+}
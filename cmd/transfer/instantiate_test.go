@@ -0,0 +1,38 @@
+package transfer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestInstantiateTemplate(t *testing.T) {
+	var sent map[string]map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/instantiate-transfer-template/2" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"transfer": map[string]interface{}{"id": 9, "targetTable": sent["variables"]["table"]}})
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+	transfer, err := instantiateTemplate(client, 2, map[string]string{"table": "users", "schema": "public"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]string{"table": "users", "schema": "public"}; !reflect.DeepEqual(sent["variables"], want) {
+		t.Errorf("wanted variables %v sent, got %v", want, sent)
+	}
+	if transfer.ID != 9 || transfer.TargetTable != "users" {
+		t.Errorf("unexpected transfer %+v", transfer)
+	}
+}
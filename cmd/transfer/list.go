@@ -0,0 +1,99 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+var ListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the transfers on a SQLpipe server",
+	Long: `List the transfers on a SQLpipe server.
+
+Admins see every transfer, and only the ones they created with --mine. Other
+users only ever see the transfers they created.`,
+	Args: cobra.NoArgs,
+	Run:  runList,
+}
+
+type listOptions struct {
+	page     int
+	pageSize int
+	sort     string
+	mine     bool
+	labels   []string
+}
+
+var (
+	listClient apiClient.Client
+	list       listOptions
+)
+
+func init() {
+	listClient.AddFlags(ListCmd)
+	ListCmd.Flags().IntVar(&list.page, "page", 1, "Page of results to show")
+	ListCmd.Flags().IntVar(&list.pageSize, "page-size", 20, "Transfers per page")
+	ListCmd.Flags().StringVar(&list.sort, "sort", "id", "Sort by id or created_at. Prefix with - to sort descending")
+	ListCmd.Flags().BoolVar(&list.mine, "mine", false, "Only list the transfers you created")
+	ListCmd.Flags().StringArrayVar(&list.labels, "label", nil, "Only list transfers whose source or target has these labels, like env=prod. May be repeated")
+
+	TransferCmd.AddCommand(ListCmd)
+}
+
+func runList(cmd *cobra.Command, args []string) {
+	err := listTransfers(&listClient, list, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func listTransfers(client *apiClient.Client, opts listOptions, out io.Writer) error {
+	qs := url.Values{}
+	qs.Set("page", strconv.Itoa(opts.page))
+	qs.Set("page_size", strconv.Itoa(opts.pageSize))
+	qs.Set("sort", opts.sort)
+	if opts.mine {
+		qs.Set("mine", "true")
+	}
+	for _, selector := range opts.labels {
+		qs.Add("label", selector)
+	}
+
+	var body struct {
+		Transfers []data.Transfer `json:"transfers"`
+		Metadata  data.Metadata   `json:"metadata"`
+	}
+	err := client.Get("/api/v1/transfers?"+qs.Encode(), &body)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tSOURCE\tTARGET\tTABLE\tCREATED")
+	for _, transfer := range body.Transfers {
+		table := transfer.TargetTable
+		if transfer.TargetSchema != "" {
+			table = transfer.TargetSchema + "." + table
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t%s\n", transfer.ID, transfer.Status, transfer.SourceID, transfer.TargetID, table, transfer.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	if body.Metadata.LastPage > 1 {
+		fmt.Fprintf(out, "Page %d of %d, %d transfers\n", body.Metadata.CurrentPage, body.Metadata.LastPage, body.Metadata.TotalRecords)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestListTransfers(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/transfers" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		fmt.Fprint(w, `{"transfers":[
+			{"id":3,"createdAt":"2022-01-03T00:00:00Z","createdBy":2,"sourceID":1,"targetID":2,"targetSchema":"public","targetTable":"orders","status":"complete"},
+			{"id":5,"createdAt":"2022-01-05T00:00:00Z","createdBy":2,"sourceID":1,"targetID":4,"targetTable":"users","status":"queued"}
+		],"metadata":{"current_page":1,"page_size":20,"first_page":1,"last_page":1,"total_records":2}}`)
+	}))
+	defer srv.Close()
+
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	var out bytes.Buffer
+	err := listTransfers(client, listOptions{page: 1, pageSize: 20, sort: "-created_at", mine: true}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, param := range []string{"page=1", "page_size=20", "sort=-created_at", "mine=true"} {
+		if !strings.Contains(query, param) {
+			t.Errorf("wanted %s in the request's query, got %q", param, query)
+		}
+	}
+
+	want := [][]string{
+		{"ID", "STATUS", "SOURCE", "TARGET", "TABLE", "CREATED"},
+		{"3", "complete", "1", "2", "public.orders", "2022-01-03", "00:00:00"},
+		{"5", "queued", "1", "4", "users", "2022-01-05", "00:00:00"},
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("wanted a header and 2 transfers, got:\n%s", out.String())
+	}
+	for i, fields := range want {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(fields, " ") {
+			t.Errorf("line %d: wanted %v, got %v", i, fields, got)
+		}
+	}
+
+	err = listTransfers(client, listOptions{page: 1, pageSize: 20, sort: "id"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(query, "mine") {
+		t.Errorf("wanted mine left to the server's default without --mine, got %q", query)
+	}
+}
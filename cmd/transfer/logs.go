@@ -0,0 +1,94 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+var LogsCmd = &cobra.Command{
+	Use:   "logs <id>",
+	Short: "Print the log kept with a transfer on a SQLpipe server",
+	Long: `Prints the log the server keeps with a transfer. Only its newest lines are
+kept, so a long running transfer's earliest lines may have rolled off.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runLogs,
+}
+
+var (
+	logsClient   apiClient.Client
+	follow       bool
+	followPeriod time.Duration
+)
+
+func init() {
+	logsClient.AddFlags(LogsCmd)
+	LogsCmd.Flags().BoolVar(&follow, "follow", false, "Keep printing new lines until the transfer stops")
+	LogsCmd.Flags().DurationVar(&followPeriod, "interval", 2*time.Second, "How often to poll with --follow")
+
+	TransferCmd.AddCommand(LogsCmd)
+}
+
+func runLogs(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		fmt.Println("transfer ID must be a positive integer")
+		os.Exit(1)
+	}
+
+	err = printTransferLog(&logsClient, id, follow, followPeriod, os.Stdout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Prints the transfer's log. With follow, it polls every interval, printing
+// only the lines it hasn't yet, until the transfer stops.
+func printTransferLog(
+	client *apiClient.Client,
+	id int64,
+	follow bool,
+	interval time.Duration,
+	out io.Writer,
+) error {
+	path := fmt.Sprintf("/api/v1/transfers/%d/logs?persisted=true", id)
+
+	// the number of the next line to print
+	var next int64
+	for {
+		var body struct {
+			Log data.TransferLog `json:"log"`
+		}
+		err := client.Get(path, &body)
+		if err != nil {
+			return err
+		}
+		log := body.Log
+
+		if log.Dropped > next {
+			fmt.Fprintf(out, "(%d lines rolled off)\n", log.Dropped-next)
+			next = log.Dropped
+		}
+		for i, line := range log.Lines {
+			if log.Dropped+int64(i) < next {
+				continue
+			}
+			fmt.Fprintln(out, line)
+			next++
+		}
+
+		if !follow || validator.In(log.Status, data.FinishedTransferStatuses...) {
+			return nil
+		}
+
+		time.Sleep(interval)
+	}
+}
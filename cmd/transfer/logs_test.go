@@ -0,0 +1,42 @@
+package transfer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestPrintTransferLogFollow(t *testing.T) {
+	polls := []data.TransferLog{
+		{Status: "active", Lines: []string{"a", "b"}},
+		{Status: "active", Lines: []string{"b", "c"}, Dropped: 1},
+		{Status: "complete", Lines: []string{"f", "g"}, Dropped: 5},
+	}
+	poll := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/transfers/3/logs" || r.URL.Query().Get("persisted") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"log": polls[poll]})
+		poll++
+	}))
+	t.Cleanup(srv.Close)
+
+	var out strings.Builder
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+	err := printTransferLog(client, 3, true, time.Millisecond, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "a\nb\nc\n(2 lines rolled off)\nf\ng\n"; out.String() != want {
+		t.Errorf("wanted each line printed once, got %q", out.String())
+	}
+}
@@ -0,0 +1,51 @@
+package transfer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+var RerunCmd = &cobra.Command{
+	Use:   "rerun <id>",
+	Short: "Queue a new transfer on a SQLpipe server with the same definition as an earlier one",
+	Args:  cobra.ExactArgs(1),
+	Run:   runRerun,
+}
+
+var rerunClient apiClient.Client
+
+func init() {
+	rerunClient.AddFlags(RerunCmd)
+
+	TransferCmd.AddCommand(RerunCmd)
+}
+
+func runRerun(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		globals.Errorf("transfer ID must be a positive integer\n")
+		os.Exit(1)
+	}
+
+	transfer, err := rerunTransfer(&rerunClient, id)
+	if err != nil {
+		globals.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+
+	globals.Infof("Transfer %d queued as a rerun of transfer %d. Follow it with: sqlpipe transfer get %d --watch\n", transfer.ID, transfer.RerunOf, transfer.ID)
+}
+
+func rerunTransfer(client *apiClient.Client, id int64) (data.Transfer, error) {
+	var body struct {
+		Transfer data.Transfer `json:"transfer"`
+	}
+	err := client.Post(fmt.Sprintf("/api/v1/rerun-transfer/%d", id), nil, &body)
+	return body.Transfer, err
+}
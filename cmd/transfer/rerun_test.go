@@ -0,0 +1,57 @@
+package transfer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+func runFakeRerun(t *testing.T, quiet bool, verbose bool) (stdout string, stderr string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/rerun-transfer/3" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"transfer":{"id":4,"rerunOf":3,"status":"queued"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	var out, errOut strings.Builder
+	oldClient, oldStdout, oldStderr := rerunClient, globals.Stdout, globals.Stderr
+	rerunClient = apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+	globals.Stdout, globals.Stderr = &out, &errOut
+	globals.Quiet, globals.Verbose = quiet, verbose
+	defer func() {
+		rerunClient, globals.Stdout, globals.Stderr = oldClient, oldStdout, oldStderr
+		globals.Quiet, globals.Verbose = false, false
+	}()
+
+	runRerun(RerunCmd, []string{"3"})
+	return out.String(), errOut.String()
+}
+
+func TestRerunOutputFlags(t *testing.T) {
+	stdout, stderr := runFakeRerun(t, false, false)
+	if !strings.HasPrefix(stdout, "Transfer 4 queued as a rerun of transfer 3") || stderr != "" {
+		t.Errorf("unexpected default output %q, %q", stdout, stderr)
+	}
+
+	stdout, stderr = runFakeRerun(t, true, false)
+	if stdout != "" || stderr != "" {
+		t.Errorf("wanted no output with --quiet, got %q, %q", stdout, stderr)
+	}
+
+	stdout, stderr = runFakeRerun(t, false, true)
+	if !strings.HasPrefix(stdout, "Transfer 4 queued") {
+		t.Errorf("wanted the usual output with --verbose, got %q", stdout)
+	}
+	if !strings.HasPrefix(stderr, "debug: POST /api/v1/rerun-transfer/3: 202 Accepted in ") {
+		t.Errorf("wanted a debug line for the request, got %q", stderr)
+	}
+}
@@ -2,11 +2,15 @@ package transfer
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sqlpipe/sqlpipe/internal/data"
 	"github.com/sqlpipe/sqlpipe/internal/engine"
 	"github.com/sqlpipe/sqlpipe/internal/globals"
+	"github.com/sqlpipe/sqlpipe/internal/validator"
 )
 
 var TransferCmd = &cobra.Command{
@@ -15,19 +19,54 @@ var TransferCmd = &cobra.Command{
 	Run:   runTransfer,
 }
 
-var transfer data.Transfer
+var (
+	transfer        data.Transfer
+	truncateTarget  bool
+	queryArgs       []string
+	connectAttempts int
+	connectBackoff  time.Duration
+)
 
 func init() {
 	TransferCmd.Flags().StringVar(&transfer.Query, "query", "", "Query to run on source system")
+	TransferCmd.Flags().StringArrayVar(&queryArgs, "query-arg", []string{}, "Value to bind to the query's next placeholder, as type:value, e.g. date:2022-01-31. Types are string, integer, decimal, boolean, date, timestamp and null. May be given more than once")
 	TransferCmd.Flags().StringVar(&transfer.TargetSchema, "target-schema", "", "Schema to write query results to")
 	TransferCmd.Flags().StringVar(&transfer.TargetTable, "target-table", "", "Table to write query results to")
-	TransferCmd.Flags().BoolVar(&transfer.Overwrite, "overwrite", false, "Overwrite target table")
+	TransferCmd.Flags().BoolVar(&transfer.Overwrite, "overwrite", false, "Empty the target table before loading. Same as --write-mode truncate")
+	TransferCmd.Flags().StringVar(&transfer.WriteMode, "write-mode", "", "What to do with rows already in the target table. Must be one of [append, truncate, recreate, upsert]. Defaults to append")
+	TransferCmd.Flags().BoolVar(&truncateTarget, "truncate-target", false, "Empty the target table before loading, keeping its definition. Same as --write-mode truncate")
+	TransferCmd.Flags().StringVar(&transfer.TargetFile, "target-file", "", "Write results to a .ndjson, .jsonl or .csv file instead of a target system, gzipped if the path ends in .gz, or zstd compressed if it ends in .zst. Use - for stdout")
+	TransferCmd.Flags().StringVar(&transfer.Compress, "compress", "", "Compress the target file with gzip or zstd, whatever its extension")
+	TransferCmd.Flags().BoolVar(&transfer.NoHeader, "no-header", false, "Leave the line of column names out of a .csv target file")
+	TransferCmd.Flags().StringVar(&transfer.BinaryEncoding, "binary-encoding", "", "How binary columns are written to a target file, base64 or hex. Defaults to base64")
+	TransferCmd.Flags().StringVar(&transfer.NullString, "null-string", "", "How NULLs are written to a .csv target file, e.g. \\N. Empty strings are always quoted")
+	TransferCmd.Flags().IntVar(&transfer.Parallelism, "parallelism", 0, "Split the source query into this many chunks, loaded concurrently. Requires --chunk-column")
+	TransferCmd.Flags().StringVar(&transfer.ChunkColumn, "chunk-column", "", "Numeric, ideally indexed, column of the query's result to split chunks on")
+	TransferCmd.Flags().StringVar(&transfer.TargetTablePattern, "target-table-pattern", "", "Route each row to a table named after one of its date columns, e.g. events_{created_at:YYYY_MM}. Used instead of --target-table")
+	TransferCmd.Flags().BoolVar(&transfer.CreateTargetTable, "create-target-table", false, "With --target-table-pattern, create tables that don't exist yet")
+	TransferCmd.Flags().BoolVar(&transfer.CreateTargetSchema, "create-target-schema", false, "Create --target-schema on the target if it doesn't exist yet")
+	TransferCmd.Flags().StringSliceVar(&transfer.SourceColumns, "source-columns", []string{}, "Only transfer these columns of the query's result, comma separated")
+	TransferCmd.Flags().StringSliceVar(&transfer.ExcludeColumns, "exclude-columns", []string{}, "Transfer every column of the query's result except these, comma separated")
+	TransferCmd.Flags().BoolVar(&transfer.VerifyChecksum, "verify-checksum", false, "Read the target table back after loading, and fail unless its rows hash the same as the source's. Needs --write-mode recreate or truncate")
+	TransferCmd.Flags().StringVar(&transfer.IsolationLevel, "isolation-level", "", "Read the source inside one transaction at this isolation level: read uncommitted, read committed, repeatable read, snapshot or serializable, as the source supports")
+	TransferCmd.Flags().IntVar(&transfer.MaxErrors, "max-errors", 0, "Skip rows that fail to insert, failing the transfer only once more than this many have been skipped. 0 fails at the first bad row")
+	TransferCmd.Flags().IntVar(&transfer.SourceLimit, "source-limit", 0, "Only copy the first this many rows of the query's result. 0 copies every row")
+	TransferCmd.Flags().Float64Var(&transfer.SampleRate, "sample-rate", 0, "Only copy a random sample of the query's rows, each kept with this chance, from 0 to 1. Can't be used with --source-limit")
+	TransferCmd.Flags().IntVar(&transfer.StatementTimeout, "statement-timeout", 0, "Seconds the source database lets each statement run before cancelling it. 0 means no limit. Not supported on SQL Server or Oracle")
+	TransferCmd.Flags().StringSliceVar(&transfer.TargetColumnOrder, "target-column-order", []string{}, "The order to create and insert the target's columns in, comma separated. Must list every source column")
+	TransferCmd.Flags().StringSliceVar(&transfer.ConflictColumns, "conflict-columns", []string{}, "With --write-mode upsert, the columns that identify a row already in the target, comma separated")
+	TransferCmd.Flags().IntVar(&transfer.MaxBufferedRows, "max-buffered-rows", 0, "Max rows to read from the source ahead of the target. 0 means no limit")
+	TransferCmd.Flags().Int64Var(&transfer.MaxBufferedBytes, "max-buffered-bytes", 0, "Max bytes of rows to read from the source ahead of the target. 0 means no limit")
+	TransferCmd.Flags().StringArrayVar(&transfer.PreLoadSQL, "pre-load-sql", []string{}, "Statement to run on the target before loading. May be given more than once")
 
 	TransferCmd.Flags().StringVar(&transfer.Source.DsType, "source-ds-type", "", "Source type. Must be one of [postgresql, mysql, mssql, oracle, redshift, snowflake]")
 	TransferCmd.Flags().StringVar(&transfer.Source.Hostname, "source-hostname", "", "Source system's hostname")
 	TransferCmd.Flags().IntVar(&transfer.Source.Port, "source-port", 0, "Source system's port")
 	TransferCmd.Flags().StringVar(&transfer.Source.AccountId, "source-account-id", "", "Source system's account ID (Snowflake only)")
 	TransferCmd.Flags().StringVar(&transfer.Source.DbName, "source-db-name", "", "Source system's DB name")
+	TransferCmd.Flags().StringVar(&transfer.Source.DefaultSchema, "source-default-schema", "", "Schema unqualified names resolve to on the source system")
+	TransferCmd.Flags().IntVar(&transfer.Source.Keepalive, "source-keepalive", 0, "Seconds an idle source connection waits before keepalives are sent. 0 leaves it to the driver")
+	TransferCmd.Flags().IntVar(&transfer.Source.ConnectTimeout, "source-connect-timeout", 0, "Seconds to wait for the source to accept a connection. 0 leaves it to the driver")
 	TransferCmd.Flags().StringVar(&transfer.Source.Username, "source-username", "", "Source username")
 	TransferCmd.Flags().StringVar(&transfer.Source.Password, "source-password", "", "Source password")
 
@@ -36,17 +75,89 @@ func init() {
 	TransferCmd.Flags().IntVar(&transfer.Target.Port, "target-port", 0, "Target system's port")
 	TransferCmd.Flags().StringVar(&transfer.Target.AccountId, "target-account-id", "", "Target system's account ID (Snowflake only)")
 	TransferCmd.Flags().StringVar(&transfer.Target.DbName, "target-db-name", "", "Target system's DB name")
+	TransferCmd.Flags().StringVar(&transfer.Target.DefaultSchema, "target-default-schema", "", "Schema unqualified names resolve to on the target system")
+	TransferCmd.Flags().IntVar(&transfer.Target.Keepalive, "target-keepalive", 0, "Seconds an idle target connection waits before keepalives are sent. 0 leaves it to the driver")
+	TransferCmd.Flags().IntVar(&transfer.Target.ConnectTimeout, "target-connect-timeout", 0, "Seconds to wait for the target to accept a connection. 0 leaves it to the driver")
 	TransferCmd.Flags().StringVar(&transfer.Target.Username, "target-username", "", "Target username")
 	TransferCmd.Flags().StringVar(&transfer.Target.Password, "target-password", "", "Target password")
+	TransferCmd.Flags().IntVar(&connectAttempts, "connect-attempts", 3, "How many times to try reaching the source and target before failing")
+	TransferCmd.Flags().DurationVar(&connectBackoff, "connect-backoff", time.Second, "How long to wait after the first failed try to reach the source or target. Each later wait doubles")
 	TransferCmd.Flags().BoolVar(&globals.Analytics, "analytics", true, "Send anonymized usage data to SQLpipe for product improvements")
 }
 
 func runTransfer(cmd *cobra.Command, args []string) {
+	if truncateTarget {
+		if transfer.WriteMode != "" && transfer.WriteMode != data.WriteModeTruncate {
+			globals.Errorf("--truncate-target can't be used with another --write-mode\n")
+			os.Exit(1)
+		}
+		transfer.WriteMode = data.WriteModeTruncate
+	}
+
+	transfer.QueryArgs = parseQueryArgs(queryArgs)
+	v := validator.New()
+	data.ValidateQueryArgs(v, transfer.Query, transfer.QueryArgs, transfer.Source.DsType)
+	data.ValidateIsolationLevel(v, &transfer, transfer.Source.DsType)
+	data.ValidateStatementTimeout(v, &transfer, transfer.Source.DsType)
+	data.ValidateSourceSampling(v, &transfer)
+	data.ValidateCompress(v, &transfer)
+	data.ValidateBinaryEncoding(v, &transfer)
+	data.ValidateKeepalive(v, "sourceKeepalive", &transfer.Source)
+	data.ValidateKeepalive(v, "targetKeepalive", &transfer.Target)
+	data.ValidateConnectTimeout(v, "sourceConnectTimeout", &transfer.Source)
+	data.ValidateConnectTimeout(v, "targetConnectTimeout", &transfer.Target)
+	if !v.Valid() {
+		for _, problem := range v.Errors {
+			globals.Errorf("%s\n", problem)
+		}
+		os.Exit(1)
+	}
+
+	globals.Debugf("transferring from %s to %s\n", describeConnection(transfer.Source), describeTarget(transfer))
+	engine.SetConnectRetry(connectAttempts, connectBackoff)
 	errProperties, err := engine.RunTransfer(&transfer)
 	if err != nil {
-		fmt.Println(errProperties, err)
-		return
+		globals.Errorf("%v %v\n", errProperties, err)
+		os.Exit(1)
 	}
 	globals.SendAnonymizedTransferAnalytics(transfer, false)
-	fmt.Println("Transfer complete. We make a good team!")
+	// don't mix the message in with rows written to stdout
+	if transfer.TargetFile != "-" {
+		if transfer.RejectedRows > 0 {
+			globals.Infof("Skipped %d rows that couldn't be inserted.\n", transfer.RejectedRows)
+		}
+		globals.Infof("Transfer complete. We make a good team!\n")
+	}
+}
+
+// Where a connection points, for debug output, without its credentials
+func describeConnection(connection data.Connection) string {
+	host := connection.Hostname
+	if connection.DsType == "snowflake" {
+		host = connection.AccountId
+	}
+	return fmt.Sprintf("%s at %s/%s", connection.DsType, host, connection.DbName)
+}
+
+func describeTarget(transfer data.Transfer) string {
+	if transfer.TargetFile != "" {
+		return "file " + transfer.TargetFile
+	}
+	return describeConnection(transfer.Target)
+}
+
+// Splits each type:value flag on its first colon. A flag with no colon is
+// just a type, which is how null is given.
+func parseQueryArgs(flags []string) data.QueryArgs {
+	args := data.QueryArgs{}
+	for _, flag := range flags {
+		var arg data.QueryArg
+		if i := strings.IndexByte(flag, ':'); i >= 0 {
+			arg = data.QueryArg{Type: flag[:i], Value: flag[i+1:]}
+		} else {
+			arg = data.QueryArg{Type: flag}
+		}
+		args = append(args, arg)
+	}
+	return args
 }
@@ -0,0 +1,76 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+var ValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a transfer definition against a SQLpipe server without creating it",
+	Run:   runValidate,
+}
+
+var (
+	validateClient apiClient.Client
+	definitionFile string
+)
+
+func init() {
+	validateClient.AddFlags(ValidateCmd)
+	ValidateCmd.Flags().StringVar(&definitionFile, "file", "-", "JSON transfer definition to check, in the same shape as the create transfer API. Use - for stdin")
+
+	TransferCmd.AddCommand(ValidateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) {
+	definition, err := readDefinition(definitionFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	problems, err := validateDefinition(&validateClient, definition)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if len(problems) > 0 {
+		fmt.Println("Transfer definition is invalid:")
+		for _, problem := range problems {
+			fmt.Println("  " + problem)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println("Transfer definition is valid.")
+}
+
+// Returns every problem the server found with the definition, sorted by
+// field. An error means the definition couldn't be checked at all.
+func validateDefinition(client *apiClient.Client, definition []byte) (problems []string, err error) {
+	if !json.Valid(definition) {
+		return nil, errors.New("transfer definition is not valid JSON")
+	}
+
+	err = client.Post("/api/v1/validate-transfer", json.RawMessage(definition), nil)
+
+	var validationErr *apiClient.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	for field, message := range validationErr.Errors {
+		problems = append(problems, fmt.Sprintf("%s: %s", field, message))
+	}
+	sort.Strings(problems)
+
+	return problems, nil
+}
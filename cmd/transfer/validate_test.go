@@ -0,0 +1,57 @@
+package transfer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestValidateDefinition(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			SourceID    int64  `json:"sourceID"`
+			TargetTable string `json:"targetTable"`
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+
+		if input.SourceID == 1 && input.TargetTable == "orders" {
+			w.Write([]byte(`{"valid":true}`))
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"error":{"targetTable":"A target table is required","sourceId":"Connection not found","query":"A query is required"}}`))
+	}))
+	defer srv.Close()
+
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	problems, err := validateDefinition(client, []byte(`{"sourceID":1,"targetID":2,"query":"select 1","targetTable":"orders"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("wanted a valid definition, got %q", problems)
+	}
+
+	problems, err = validateDefinition(client, []byte(`{"sourceID":9}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"query: A query is required",
+		"sourceId: Connection not found",
+		"targetTable: A target table is required",
+	}
+	if !reflect.DeepEqual(problems, want) {
+		t.Errorf("wanted %q, got %q", want, problems)
+	}
+
+	_, err = validateDefinition(client, []byte(`{"sourceID":`))
+	if err == nil {
+		t.Error("wanted an error for malformed JSON")
+	}
+}
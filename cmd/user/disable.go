@@ -0,0 +1,92 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+var DisableCmd = &cobra.Command{
+	Use:   "disable <id>",
+	Short: "Stop a user from authenticating, keeping their data and history",
+	Args:  cobra.ExactArgs(1),
+	Run:   runDisable,
+}
+
+var EnableCmd = &cobra.Command{
+	Use:   "enable <id>",
+	Short: "Let a disabled user authenticate again",
+	Args:  cobra.ExactArgs(1),
+	Run:   runEnable,
+}
+
+var disableClient apiClient.Client
+
+func init() {
+	disableClient.AddFlags(DisableCmd)
+	disableClient.AddFlags(EnableCmd)
+
+	UserCmd.AddCommand(DisableCmd)
+	UserCmd.AddCommand(EnableCmd)
+}
+
+func runDisable(cmd *cobra.Command, args []string) {
+	setDisabled(args[0], true)
+}
+
+func runEnable(cmd *cobra.Command, args []string) {
+	setDisabled(args[0], false)
+}
+
+func setDisabled(arg string, disable bool) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id < 1 {
+		globals.Errorf("user ID must be a positive integer\n")
+		os.Exit(1)
+	}
+
+	username, err := setUserDisabled(&disableClient, id, disable)
+	if err != nil {
+		switch {
+		case errors.Is(err, apiClient.ErrNotFound) && disable:
+			globals.Errorf("No enabled user with ID %d\n", id)
+		case errors.Is(err, apiClient.ErrNotFound):
+			globals.Errorf("No disabled user with ID %d\n", id)
+		default:
+			globals.Errorf("%v\n", err)
+		}
+		os.Exit(1)
+	}
+
+	if disable {
+		globals.Infof("User %d (%s) disabled\n", id, username)
+	} else {
+		globals.Infof("User %d (%s) enabled\n", id, username)
+	}
+}
+
+func setUserDisabled(client *apiClient.Client, id int64, disable bool) (string, error) {
+	path := fmt.Sprintf("/api/v1/enable-user/%d", id)
+	if disable {
+		path = fmt.Sprintf("/api/v1/disable-user/%d", id)
+	}
+
+	var body struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	}
+
+	err := client.Do(http.MethodPatch, path, nil, &body)
+	if err != nil {
+		return "", err
+	}
+
+	return body.User.Username, nil
+}
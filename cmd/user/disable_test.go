@@ -0,0 +1,43 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestSetUserDisabled(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/api/v1/enable-user/5" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"user":{"id":4,"username":"alice","admin":false}}`)
+	}))
+	defer srv.Close()
+
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	for _, disable := range []bool{true, false} {
+		username, err := setUserDisabled(client, 4, disable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if username != "alice" {
+			t.Errorf("wanted alice, got %q", username)
+		}
+	}
+	if want := []string{"PATCH /api/v1/disable-user/4", "PATCH /api/v1/enable-user/4"}; fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("wanted requests %v, got %v", want, paths)
+	}
+
+	if _, err := setUserDisabled(client, 5, false); !errors.Is(err, apiClient.ErrNotFound) {
+		t.Errorf("wanted ErrNotFound for a user that isn't disabled, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+var RestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Re-enable a user that was disabled by a soft delete",
+	Args:  cobra.ExactArgs(1),
+	Run:   runRestore,
+}
+
+var restoreClient apiClient.Client
+
+func init() {
+	restoreClient.AddFlags(RestoreCmd)
+
+	UserCmd.AddCommand(RestoreCmd)
+}
+
+func runRestore(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 1 {
+		globals.Errorf("user ID must be a positive integer\n")
+		os.Exit(1)
+	}
+
+	username, err := restoreUser(&restoreClient, id)
+	if err != nil {
+		if errors.Is(err, apiClient.ErrNotFound) {
+			globals.Errorf("No disabled user with ID %d\n", id)
+		} else {
+			globals.Errorf("%v\n", err)
+		}
+		os.Exit(1)
+	}
+
+	globals.Infof("User %d (%s) restored\n", id, username)
+}
+
+func restoreUser(client *apiClient.Client, id int64) (string, error) {
+	var body struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	}
+
+	err := client.Do(http.MethodPatch, fmt.Sprintf("/api/v1/restore-user/%d", id), nil, &body)
+	if err != nil {
+		return "", err
+	}
+
+	return body.User.Username, nil
+}
@@ -0,0 +1,36 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestRestoreUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/restore-user/4" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"user":{"id":4,"username":"alice","admin":false}}`)
+	}))
+	defer srv.Close()
+
+	client := &apiClient.Client{Server: srv.URL, HTTP: srv.Client()}
+
+	username, err := restoreUser(client, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "alice" {
+		t.Errorf("wanted alice restored, got %q", username)
+	}
+
+	if _, err := restoreUser(client, 5); !errors.Is(err, apiClient.ErrNotFound) {
+		t.Errorf("wanted ErrNotFound for a user that isn't disabled, got %v", err)
+	}
+}
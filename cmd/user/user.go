@@ -0,0 +1,10 @@
+package user
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var UserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage users on a SQLpipe server",
+}
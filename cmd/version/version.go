@@ -1,7 +1,11 @@
 package version
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"runtime"
 
 	"github.com/spf13/cobra"
 	"github.com/sqlpipe/sqlpipe/internal/globals"
@@ -13,9 +17,44 @@ var (
 		Short: "Show SQLpipe version",
 		Run:   showVersion,
 	}
+
+	asJSON bool
 )
 
+func init() {
+	VersionCmd.Flags().BoolVar(&asJSON, "json", false, "Print the version as a JSON object, for scripts")
+}
+
+type versionInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+}
+
 func showVersion(cmd *cobra.Command, args []string) {
-	fmt.Println("Git hash:", globals.GitHash)
-	fmt.Println("Human version:", globals.SqlpipeVersion)
+	err := printVersion(globals.Stdout, asJSON)
+	if err != nil {
+		globals.Errorf("%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printVersion(out io.Writer, asJSON bool) error {
+	info := versionInfo{
+		Version:   globals.SqlpipeVersion,
+		GoVersion: runtime.Version(),
+		Commit:    globals.GitHash,
+		BuildDate: globals.BuildDate,
+	}
+
+	if asJSON {
+		return json.NewEncoder(out).Encode(info)
+	}
+
+	fmt.Fprintln(out, "Git hash:", info.Commit)
+	fmt.Fprintln(out, "Human version:", info.Version)
+	fmt.Fprintln(out, "Built:", info.BuildDate)
+	fmt.Fprintln(out, "Go version:", info.GoVersion)
+	return nil
 }
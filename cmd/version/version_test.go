@@ -0,0 +1,39 @@
+package version
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+func TestPrintVersionJSON(t *testing.T) {
+	oldVersion, oldHash, oldDate := globals.SqlpipeVersion, globals.GitHash, globals.BuildDate
+	globals.SqlpipeVersion, globals.GitHash, globals.BuildDate = "1.0.1", "v1.0.1-0-gabc1234", "2022-01-03T10:00:00Z"
+	t.Cleanup(func() { globals.SqlpipeVersion, globals.GitHash, globals.BuildDate = oldVersion, oldHash, oldDate })
+
+	var out strings.Builder
+	err := printVersion(&out, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info map[string]string
+	if err := json.Unmarshal([]byte(out.String()), &info); err != nil {
+		t.Fatalf("wanted valid JSON, got %q: %v", out.String(), err)
+	}
+	want := map[string]string{"version": "1.0.1", "goVersion": runtime.Version(), "commit": "v1.0.1-0-gabc1234", "buildDate": "2022-01-03T10:00:00Z"}
+	for key, value := range want {
+		if info[key] != value {
+			t.Errorf("wanted %s %q, got %q", key, value, info[key])
+		}
+	}
+
+	out.Reset()
+	printVersion(&out, false)
+	if !strings.Contains(out.String(), "Human version: 1.0.1\n") {
+		t.Errorf("wanted the plain output kept, got %q", out.String())
+	}
+}
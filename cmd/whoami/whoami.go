@@ -0,0 +1,56 @@
+package whoami
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+var WhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show which SQLpipe user a set of credentials belongs to",
+	Run:   runWhoami,
+}
+
+var client apiClient.Client
+
+type profile struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+}
+
+func init() {
+	client.AddFlags(WhoamiCmd)
+}
+
+func runWhoami(cmd *cobra.Command, args []string) {
+	user, err := lookupUser(&client)
+	if err != nil {
+		if errors.Is(err, apiClient.ErrInvalidCredentials) {
+			fmt.Println("The server rejected these credentials. Check the username and password.")
+		} else {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println("Username:", user.Username)
+	fmt.Println("Admin:", user.Admin)
+}
+
+func lookupUser(client *apiClient.Client) (*profile, error) {
+	var body struct {
+		User profile `json:"user"`
+	}
+
+	err := client.Get("/api/v1/whoami", &body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &body.User, nil
+}
@@ -0,0 +1,43 @@
+package whoami
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/apiClient"
+)
+
+func TestLookupUser(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/whoami" {
+			http.NotFound(w, r)
+			return
+		}
+		username, password, _ := r.BasicAuth()
+		if username != "alice" || password != "correct horse" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid authentication credentials"}`)
+			return
+		}
+		fmt.Fprint(w, `{"user":{"id":7,"createdAt":"2022-01-03T10:00:00Z","username":"alice","admin":true}}`)
+	}))
+	defer srv.Close()
+
+	client := &apiClient.Client{Server: srv.URL + "/", Username: "alice", Password: "correct horse", HTTP: srv.Client()}
+	user, err := lookupUser(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "alice" || !user.Admin || user.ID != 7 {
+		t.Errorf("unexpected profile %+v", user)
+	}
+
+	client.Password = "stale password"
+	_, err = lookupUser(client)
+	if !errors.Is(err, apiClient.ErrInvalidCredentials) {
+		t.Errorf("wanted errInvalidCredentials, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package apiClient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/globals"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrNotFound           = errors.New("not found")
+)
+
+// Returned when the server rejects a request body, with the message for
+// each invalid field
+type ValidationError struct {
+	Errors map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d invalid fields", len(e.Errors))
+}
+
+// Client talks to a running SQLpipe server's JSON API with basic auth
+type Client struct {
+	Server   string
+	Username string
+	Password string
+	Insecure bool
+	HTTP     *http.Client
+}
+
+// Registers the flags every command that talks to a server needs
+func (c *Client) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&c.Server, "server", "https://localhost:9000", "Address of the SQLpipe server")
+	cmd.Flags().StringVar(&c.Username, "username", "", "SQLpipe username")
+	cmd.Flags().StringVar(&c.Password, "password", "", "SQLpipe password")
+	cmd.Flags().BoolVar(&c.Insecure, "insecure", false, "Skip TLS certificate verification, for servers using a self-signed certificate")
+}
+
+// Sends a request to path, and decodes a successful response into dst
+func (c *Client) Do(method string, path string, body interface{}, dst interface{}) error {
+	resp, err := c.send(c.httpClient(), method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if dst == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(dst)
+	if err != nil {
+		return fmt.Errorf("unable to read response from SQLpipe server: %w", err)
+	}
+
+	return nil
+}
+
+// Copies a successful response's body to w as it arrives. There is no
+// overall timeout, since the body may take a long time to stream.
+func (c *Client) Download(path string, w io.Writer) error {
+	client := *c.httpClient()
+	client.Timeout = 0
+
+	resp, err := c.send(&client, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response from SQLpipe server: %w", err)
+	}
+	return nil
+}
+
+// Sends a request, and turns unsuccessful responses into errors. The caller
+// must close a returned response's body.
+func (c *Client) send(client *http.Client, method string, path string, body interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Server, "/")+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach SQLpipe server: %w", err)
+	}
+	globals.Debugf("%s %s: %s in %s\n", method, path, resp.Status, time.Since(start).Round(time.Millisecond))
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, ErrInvalidCredentials
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode == http.StatusUnprocessableEntity:
+		var errBody struct {
+			Error map[string]string `json:"error"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&errBody)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read response from SQLpipe server: %w", err)
+		}
+		return nil, &ValidationError{Errors: errBody.Error}
+	default:
+		var errBody struct {
+			Error interface{} `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errBody) == nil && errBody.Error != nil {
+			return nil, fmt.Errorf("%s: %v", resp.Status, errBody.Error)
+		}
+		return nil, fmt.Errorf("unexpected response from SQLpipe server: %s", resp.Status)
+	}
+}
+
+func (c *Client) Get(path string, dst interface{}) error {
+	return c.Do(http.MethodGet, path, nil, dst)
+}
+
+func (c *Client) Post(path string, body interface{}, dst interface{}) error {
+	return c.Do(http.MethodPost, path, body, dst)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP == nil {
+		c.HTTP = &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}},
+		}
+	}
+	return c.HTTP
+}
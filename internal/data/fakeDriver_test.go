@@ -0,0 +1,18 @@
+package data
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+// Answers a query with rows, so model logic can be exercised without a live
+// database. Exec reports how many rows it returns as rows affected.
+type fakeHandler func(query string, args []driver.Value) (columns []string, rows [][]driver.Value, err error)
+
+func newFakeDB(t *testing.T, handler fakeHandler) *sql.DB {
+	t.Helper()
+	return testdb.Open(t, "data", &testdb.DB{Handler: testdb.Rows(handler)})
+}
@@ -1,8 +1,11 @@
 package data
 
 import (
+	"errors"
+	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/sqlpipe/sqlpipe/internal/validator"
 )
@@ -12,33 +15,125 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
-}
+	// The largest PageSize allowed. 0 means DefaultMaxPageSize.
+	MaxPageSize int
 
-func (f Filters) sortColumn() string {
-	for _, safeValue := range f.SortSafelist {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-")
-		}
-	}
+	// Optional bounds on created_at. The zero time means unbounded.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// A label selector. Only records with every one of these labels are
+	// listed.
+	Labels Labels
 
-	panic("unsafe sort parameter: " + f.Sort)
+	// Only records with this status. Empty means any.
+	Status string
+
+	// Only records created by the user with this ID. 0 means anyone's.
+	CreatedBy int64
 }
 
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+// The ORDER BY for the requested sort. The column is looked up in columns,
+// keyed by sort name, and the direction is ASC or DESC, so nothing from the
+// request is formatted into a query. Sorts that aren't in columns are
+// ErrInvalidSort.
+func (f Filters) orderBy(columns map[string]string) (string, error) {
+	column, ok := columns[strings.TrimPrefix(f.Sort, "-")]
+	if !ok {
+		return "", ErrInvalidSort
 	}
 
-	return "ASC"
+	if strings.HasPrefix(f.Sort, "-") {
+		return column + " DESC", nil
+	}
+	return column + " ASC", nil
 }
 
+var ErrInvalidSort = errors.New("invalid sort")
+
+const DefaultMaxPageSize = 100
+
 func ValidateFilters(v *validator.Validator, f Filters) {
+	maxPageSize := f.MaxPageSize
+	if maxPageSize == 0 {
+		maxPageSize = DefaultMaxPageSize
+	}
+
 	v.Check(f.Page > 0, "page", "must be greater than zero")
 	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
-	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+	v.Check(f.PageSize <= maxPageSize, "page_size", fmt.Sprintf("must be a maximum of %d", maxPageSize))
 
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	if !f.CreatedAfter.IsZero() && !f.CreatedBefore.IsZero() {
+		v.Check(f.CreatedAfter.Before(f.CreatedBefore), "since", "must be before until")
+	}
+}
+
+// Builds a WHERE clause for the created_at bounds on column, appending the
+// bound values to args so the placeholders follow on from the existing ones
+func (f Filters) createdAtWhere(column string, args []interface{}) (string, []interface{}) {
+	conditions, args := f.createdAtConditions(column, args)
+	return whereClause(conditions), args
+}
+
+func (f Filters) createdAtConditions(column string, args []interface{}) ([]string, []interface{}) {
+	var conditions []string
+
+	if !f.CreatedAfter.IsZero() {
+		args = append(args, f.CreatedAfter)
+		conditions = append(conditions, fmt.Sprintf("%s >= $%d::timestamptz", column, len(args)))
+	}
+
+	if !f.CreatedBefore.IsZero() {
+		args = append(args, f.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("%s < $%d::timestamptz", column, len(args)))
+	}
+
+	return conditions, args
+}
+
+func (f Filters) statusConditions(column string, args []interface{}) ([]string, []interface{}) {
+	if f.Status == "" {
+		return nil, args
+	}
+
+	args = append(args, f.Status)
+	return []string{fmt.Sprintf("%s = $%d", column, len(args))}, args
+}
+
+func (f Filters) createdByConditions(column string, args []interface{}) ([]string, []interface{}) {
+	if f.CreatedBy == 0 {
+		return nil, args
+	}
+
+	args = append(args, f.CreatedBy)
+	return []string{fmt.Sprintf("%s = $%d", column, len(args))}, args
+}
+
+// A condition that the label selector matches any of the jsonb label
+// columns, or none if there is no selector
+func (f Filters) labelConditions(columns []string, args []interface{}) ([]string, []interface{}) {
+	if len(f.Labels) == 0 {
+		return nil, args
+	}
+
+	args = append(args, f.Labels)
+	var matches []string
+	for _, column := range columns {
+		matches = append(matches, fmt.Sprintf("%s @> $%d::jsonb", column, len(args)))
+	}
+
+	return []string{"(" + strings.Join(matches, " or ") + ")"}, args
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return "where\n\t" + strings.Join(conditions, " and ")
 }
 
 func (f Filters) limit() int {
@@ -0,0 +1,147 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+func TestCreatedAtWhere(t *testing.T) {
+	monday := time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC)
+	wednesday := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		filters   Filters
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{"unbounded", Filters{}, "", []interface{}{10, 0}},
+		{"since", Filters{CreatedAfter: monday}, "where\n\ttransfers.created_at >= $3::timestamptz", []interface{}{10, 0, monday}},
+		{"until", Filters{CreatedBefore: wednesday}, "where\n\ttransfers.created_at < $3::timestamptz", []interface{}{10, 0, wednesday}},
+		{
+			"both",
+			Filters{CreatedAfter: monday, CreatedBefore: wednesday},
+			"where\n\ttransfers.created_at >= $3::timestamptz and transfers.created_at < $4::timestamptz",
+			[]interface{}{10, 0, monday, wednesday},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.filters.createdAtWhere("transfers.created_at", []interface{}{10, 0})
+			if where != tt.wantWhere {
+				t.Errorf("wanted where %q, got %q", tt.wantWhere, where)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("wanted args %v, got %v", tt.wantArgs, args)
+			}
+		})
+	}
+}
+
+func TestValidateFiltersCreatedAtBounds(t *testing.T) {
+	monday := time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC)
+	wednesday := time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	filters := Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}}
+
+	filters.CreatedAfter, filters.CreatedBefore = monday, wednesday
+	v := validator.New()
+	if ValidateFilters(v, filters); !v.Valid() {
+		t.Errorf("wanted valid bounds, got %v", v.Errors)
+	}
+
+	filters.CreatedAfter, filters.CreatedBefore = wednesday, monday
+	v = validator.New()
+	if ValidateFilters(v, filters); v.Valid() {
+		t.Error("wanted since after until to be rejected")
+	}
+}
+
+func TestValidateFiltersPageSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		page        int
+		pageSize    int
+		maxPageSize int
+		wantKey     string
+	}{
+		{"valid", 1, 100, 0, ""},
+		{"below min", 1, 0, 0, "page_size"},
+		{"above default max", 1, 101, 0, "page_size"},
+		{"above configured max", 1, 30, 25, "page_size"},
+		{"within configured max", 1, 500, 1000, ""},
+		{"page below min", 0, 10, 0, "page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateFilters(v, Filters{Page: tt.page, PageSize: tt.pageSize, MaxPageSize: tt.maxPageSize, Sort: "id", SortSafelist: []string{"id"}})
+			if tt.wantKey == "" && !v.Valid() {
+				t.Errorf("wanted valid filters, got %v", v.Errors)
+			}
+			if _, ok := v.Errors[tt.wantKey]; tt.wantKey != "" && (!ok || len(v.Errors) != 1) {
+				t.Errorf("wanted only a %s error, got %v", tt.wantKey, v.Errors)
+			}
+		})
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		sort    string
+		want    string
+		wantErr error
+	}{
+		{"id", "transfers.id ASC", nil},
+		{"-created_at", "transfers.created_at DESC", nil},
+		{"id; DROP TABLE transfers", "", ErrInvalidSort},
+		{"-id DESC, (select 1)", "", ErrInvalidSort},
+		{"status", "", ErrInvalidSort},
+		{"", "", ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			got, err := Filters{Sort: tt.sort}.orderBy(transferSortColumns)
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("wanted %q and %v, got %q and %v", tt.want, tt.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func TestTransferGetAllNeverFormatsTheSort(t *testing.T) {
+	var queries []string
+	db := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		queries = append(queries, query)
+		return make([]string, 39), nil, nil
+	})
+	transfers := TransferModel{DB: db}
+
+	// the safelist is the caller's, so it can't be trusted to keep the
+	// sort out of the query
+	crafted := "id; DROP TABLE transfers"
+	filters := Filters{Page: 1, PageSize: 10, Sort: crafted, SortSafelist: []string{crafted}}
+	if _, _, err := transfers.GetAll(filters); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("wanted ErrInvalidSort, got %v", err)
+	}
+	if len(queries) != 0 {
+		t.Fatalf("wanted no query run, got %v", queries)
+	}
+
+	filters.Sort = "-created_at"
+	if _, _, err := transfers.GetAll(filters); err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1 || !strings.Contains(queries[0], "order by\n\ttransfers.created_at DESC,\n\ttransfers.id asc") {
+		t.Errorf("wanted transfers ordered by created_at descending, got %v", queries)
+	}
+}
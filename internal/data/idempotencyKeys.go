@@ -0,0 +1,94 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyInUse = errors.New("a request with this idempotency key is still in progress")
+)
+
+// IdempotencyKeyModel remembers which transfer a client's Idempotency-Key
+// created, so a retried create returns that transfer instead of making
+// another. Keys belong to the user that sent them.
+type IdempotencyKeyModel struct {
+	DB *sql.DB
+}
+
+// Claims key for a new request. If the key was already used within ttl, the
+// transfer it created is returned with claimed false. A key whose request is
+// still running returns ErrIdempotencyKeyInUse. Expired keys are claimed
+// again as if they were new. Every claim also deletes the other keys that
+// have expired, so keys that are never sent again don't pile up.
+func (m IdempotencyKeyModel) Claim(userID int64, key string, ttl time.Duration) (transferID int64, claimed bool, err error) {
+	query := `
+        WITH expired AS (
+            DELETE FROM idempotency_keys
+            WHERE created_at < NOW() - $3 * interval '1 second'
+            AND NOT (user_id = $1 AND key = $2)
+        )
+        INSERT INTO idempotency_keys (user_id, key)
+        VALUES ($1, $2)
+        ON CONFLICT (user_id, key) DO UPDATE SET transfer_id = NULL, created_at = NOW()
+        WHERE idempotency_keys.created_at < NOW() - $3 * interval '1 second'
+        RETURNING user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var claimedBy int64
+	err = m.DB.QueryRowContext(ctx, query, userID, key, ttl.Seconds()).Scan(&claimedBy)
+	switch {
+	case err == nil:
+		return 0, true, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return 0, false, err
+	}
+
+	// the key is in use and hasn't expired
+	query = `
+        SELECT transfer_id
+        FROM idempotency_keys
+        WHERE user_id = $1 AND key = $2`
+
+	var existing sql.NullInt64
+	err = m.DB.QueryRowContext(ctx, query, userID, key).Scan(&existing)
+	if err != nil {
+		return 0, false, err
+	}
+	if !existing.Valid {
+		return 0, false, ErrIdempotencyKeyInUse
+	}
+
+	return existing.Int64, false, nil
+}
+
+// Records the transfer a claimed key's request created
+func (m IdempotencyKeyModel) Complete(userID int64, key string, transferID int64) error {
+	query := `
+        UPDATE idempotency_keys
+        SET transfer_id = $3
+        WHERE user_id = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, key, transferID)
+	return err
+}
+
+// Gives up a claimed key whose request failed, so it can be retried
+func (m IdempotencyKeyModel) Release(userID int64, key string) error {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE user_id = $1 AND key = $2 AND transfer_id IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, key)
+	return err
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClaimDeletesExpiredKeys(t *testing.T) {
+	// keys by user and key, with how old they are
+	ages := map[string]time.Duration{
+		"1/old":   48 * time.Hour,
+		"2/old":   30 * time.Hour,
+		"2/fresh": time.Hour,
+	}
+	db := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if !strings.Contains(query, "INSERT INTO idempotency_keys") {
+			return nil, nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		claimed := fmt.Sprint(args[0], "/", args[1])
+		ttl := time.Duration(args[2].(float64) * float64(time.Second))
+		if strings.Contains(query, "DELETE FROM idempotency_keys") {
+			for key, age := range ages {
+				if age > ttl && key != claimed {
+					delete(ages, key)
+				}
+			}
+		}
+		if age, ok := ages[claimed]; ok && age <= ttl {
+			return []string{"user_id"}, nil, nil
+		}
+		ages[claimed] = 0
+		return []string{"user_id"}, [][]driver.Value{{args[0]}}, nil
+	})
+	m := IdempotencyKeyModel{DB: db}
+
+	_, claimed, err := m.Claim(1, "new", 24*time.Hour)
+	if err != nil || !claimed {
+		t.Fatalf("wanted the new key claimed, got %v, %v", claimed, err)
+	}
+
+	want := map[string]bool{"1/new": true, "2/fresh": true}
+	if len(ages) != len(want) {
+		t.Errorf("wanted only %v kept, got %v", want, ages)
+	}
+	for key := range ages {
+		if !want[key] {
+			t.Errorf("wanted expired key %s deleted", key)
+		}
+	}
+}
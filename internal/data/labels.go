@@ -0,0 +1,94 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+var (
+	// Label keys and values are short names like env=prod or team=data-eng
+	labelKeyRX   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+	labelValueRX = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+// Key value pairs used to organize connections, by environment or team for
+// example. Stored as a JSON object in a single column.
+type Labels map[string]string
+
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		l = Labels{}
+	}
+	return json.Marshal(l)
+}
+
+func (l *Labels) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	case nil:
+		*l = nil
+		return nil
+	default:
+		return fmt.Errorf("can't scan labels from a %T", src)
+	}
+}
+
+// Formatted like a selector, key=value pairs separated by commas, sorted by
+// key
+func (l Labels) String() string {
+	pairs := make([]string, 0, len(l))
+	for key, value := range l {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func ValidateLabels(v *validator.Validator, key string, labels Labels) {
+	for name, value := range labels {
+		if !validator.Matches(name, labelKeyRX) {
+			v.AddError(key, fmt.Sprintf("Label key %q must be 1-63 letters, digits, dots, dashes, underscores or slashes, starting and ending with a letter or digit", name))
+			return
+		}
+		if !validator.Matches(value, labelValueRX) {
+			v.AddError(key, fmt.Sprintf("Label value %q must be at most 63 letters, digits, dots, dashes or underscores, starting and ending with a letter or digit", value))
+			return
+		}
+	}
+}
+
+// Parses label selectors like env=prod or env=prod,team=data. Every label
+// in every selector must match, so selectors that ask for two values of
+// the same key are malformed.
+func ParseLabelSelector(selectors []string) (Labels, error) {
+	labels := Labels{}
+	for _, selector := range selectors {
+		for _, pair := range strings.Split(selector, ",") {
+			pair = strings.TrimSpace(pair)
+			i := strings.IndexByte(pair, '=')
+			if i == -1 {
+				return nil, fmt.Errorf("label selector %q must be key=value", pair)
+			}
+			key, value := pair[:i], pair[i+1:]
+			if previous, ok := labels[key]; ok && previous != value {
+				return nil, fmt.Errorf("label selector asks for %s to be both %q and %q", key, previous, value)
+			}
+			labels[key] = value
+		}
+	}
+
+	v := validator.New()
+	if ValidateLabels(v, "label", labels); !v.Valid() {
+		return nil, fmt.Errorf("label selector is malformed: %s", v.Errors["label"])
+	}
+	return labels, nil
+}
@@ -0,0 +1,50 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		selectors []string
+		want      Labels
+	}{
+		{[]string{"env=prod"}, Labels{"env": "prod"}},
+		{[]string{"env=prod,team=data-eng", "region=us-east-1"}, Labels{"env": "prod", "team": "data-eng", "region": "us-east-1"}},
+		{[]string{"env=prod", "env=prod"}, Labels{"env": "prod"}},
+		{[]string{"example.com/owner="}, Labels{"example.com/owner": ""}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLabelSelector(tt.selectors)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.selectors, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: wanted %v, got %v", tt.selectors, tt.want, got)
+		}
+	}
+
+	for _, selector := range []string{"env", "=prod", "env=prod,", "env=prod,env=dev", "-env=prod", "env=pr od", "env=prod="} {
+		if _, err := ParseLabelSelector([]string{selector}); err == nil {
+			t.Errorf("%q: wanted a malformed selector rejected", selector)
+		}
+	}
+}
+
+func TestLabelConditions(t *testing.T) {
+	filters := Filters{Labels: Labels{"env": "prod"}}
+
+	conditions, args := filters.labelConditions([]string{"source.labels", "target.labels"}, []interface{}{10, 0})
+	if want := []string{"(source.labels @> $3::jsonb or target.labels @> $3::jsonb)"}; !reflect.DeepEqual(conditions, want) {
+		t.Errorf("wanted conditions %v, got %v", want, conditions)
+	}
+	if len(args) != 3 || !reflect.DeepEqual(args[2], filters.Labels) {
+		t.Errorf("wanted the selector appended to args, got %v", args)
+	}
+
+	if conditions, args := (Filters{}).labelConditions([]string{"labels"}, []interface{}{10, 0}); conditions != nil || len(args) != 2 {
+		t.Errorf("wanted no condition without a selector, got %v %v", conditions, args)
+	}
+}
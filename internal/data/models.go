@@ -15,6 +15,10 @@ type Models struct {
 	Connections ConnectionModel
 	Transfers   TransferModel
 	Queries     QueryModel
+	TargetLocks TargetLockModel
+
+	IdempotencyKeys   IdempotencyKeyModel
+	TransferTemplates TransferTemplateModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -23,5 +27,9 @@ func NewModels(db *sql.DB) Models {
 		Connections: ConnectionModel{DB: db},
 		Transfers:   TransferModel{DB: db},
 		Queries:     QueryModel{DB: db},
+		TargetLocks: TargetLockModel{DB: db},
+
+		IdempotencyKeys:   IdempotencyKeyModel{DB: db},
+		TransferTemplates: TransferTemplateModel{DB: db},
 	}
 }
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+// Observes a running transfer. The engine calls OnStatus when the transfer
+// starts and stops, with the status it's stored with (active, then complete
+// or error), OnBatch each time rows are committed to the target, and OnError
+// with the error a transfer failed with, just before its final status.
+// OnReject is called with each row skipped under MaxErrors, written as the
+// values of an insert, and the error inserting it gave. OnRetry is called
+// each time the source or target didn't answer as the transfer started,
+// with the data system's type, the attempt that failed, its error, and how
+// long until the next one.
+// Chunked transfers commit batches in parallel, so a reporter must be safe
+// to call from several goroutines at once.
+type ProgressReporter interface {
+	OnBatch(rows int64)
+	OnStatus(status string)
+	OnError(err error)
+	OnReject(row string, err error)
+	OnRetry(dsType string, attempt int, err error, wait time.Duration)
+}
+
+// Adds up the rows a transfer skipped, safely from several goroutines
+type RejectCounter struct {
+	mu    sync.Mutex
+	count int64
+}
+
+// Counts one more rejected row, and returns the count so far
+func (c *RejectCounter) Add() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count++
+	return c.count
+}
+
+func (c *RejectCounter) Count() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
@@ -20,7 +20,10 @@ type Query struct {
 	Error           string     `json:"error"`
 	ErrorProperties string     `json:"errorProperties"`
 	StoppedAt       time.Time  `json:"stoppedAt"`
-	Version         int        `json:"version"`
+	// How long the query may run before it's cancelled. 0 means no limit.
+	// Not stored; the query command sets it.
+	Timeout time.Duration `json:"-"`
+	Version int           `json:"version"`
 }
 
 type QueryModel struct {
@@ -55,7 +58,18 @@ func ValidateQuery(v *validator.Validator, query *Query) {
 	v.Check(query.Query != "", "query", "A query is required")
 }
 
+// The columns queries can be sorted by, keyed by sort name
+var querySortColumns = map[string]string{
+	"id":         "queries.id",
+	"created_at": "queries.created_at",
+}
+
 func (m QueryModel) GetAll(filters Filters) ([]*Query, Metadata, error) {
+	orderBy, err := filters.orderBy(querySortColumns)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
 	queryToRun := fmt.Sprintf(`
 	SELECT
 	count(*) OVER(),
@@ -80,13 +94,13 @@ left join
 on
 	queries.connection_id = connections.id
 order by
-	%s %s,
-	id asc
+	%s,
+	queries.id asc
 limit
 	$1
 offset
 	$2
-`, filters.sortColumn(), filters.sortDirection())
+`, orderBy)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -152,6 +166,10 @@ func (m QueryModel) GetQueued() ([]*Query, error) {
 	connections.Db_Name,
 	connections.Username,
 	connections.Password,
+	connections.Default_Schema,
+	connections.Keepalive,
+	connections.Connect_Timeout,
+	connections.Ssl_Mode,
 	queries.query,
 	queries.status,
 	queries.error,
@@ -196,6 +214,10 @@ order by
 			&query.Connection.DbName,
 			&query.Connection.Username,
 			&query.Connection.Password,
+			&query.Connection.DefaultSchema,
+			&query.Connection.Keepalive,
+			&query.Connection.ConnectTimeout,
+			&query.Connection.SSLMode,
 			&query.Query,
 			&query.Status,
 			&query.Error,
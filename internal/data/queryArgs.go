@@ -0,0 +1,217 @@
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+// A value bound to one of a transfer query's placeholders. Value is always
+// text, and Type says what it's converted to before binding.
+type QueryArg struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+const (
+	QueryArgString    = "string"
+	QueryArgInteger   = "integer"
+	QueryArgDecimal   = "decimal"
+	QueryArgBoolean   = "boolean"
+	QueryArgDate      = "date"
+	QueryArgTimestamp = "timestamp"
+	QueryArgNull      = "null"
+)
+
+var queryArgTypes = []string{
+	QueryArgString,
+	QueryArgInteger,
+	QueryArgDecimal,
+	QueryArgBoolean,
+	QueryArgDate,
+	QueryArgTimestamp,
+	QueryArgNull,
+}
+
+// Stored as a JSON array in a single column
+type QueryArgs []QueryArg
+
+func (a QueryArgs) Value() (driver.Value, error) {
+	if a == nil {
+		a = QueryArgs{}
+	}
+	return json.Marshal(a)
+}
+
+func (a *QueryArgs) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	case nil:
+		*a = nil
+		return nil
+	default:
+		return fmt.Errorf("can't scan query args from a %T", src)
+	}
+}
+
+// Converts each arg to the Go value it's bound as. Decimals stay text, so
+// they reach the source without being rounded through a float.
+func (a QueryArgs) Values() ([]interface{}, error) {
+	values := make([]interface{}, len(a))
+	for i, arg := range a {
+		value, err := arg.value()
+		if err != nil {
+			return nil, fmt.Errorf("query arg %d: %w", i+1, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (arg QueryArg) value() (interface{}, error) {
+	switch arg.Type {
+	case QueryArgString, QueryArgDecimal:
+		return arg.Value, nil
+	case QueryArgInteger:
+		return strconv.ParseInt(arg.Value, 10, 64)
+	case QueryArgBoolean:
+		return strconv.ParseBool(arg.Value)
+	case QueryArgDate:
+		return time.Parse("2006-01-02", arg.Value)
+	case QueryArgTimestamp:
+		return time.Parse(time.RFC3339Nano, arg.Value)
+	case QueryArgNull:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", arg.Type)
+	}
+}
+
+// Checks each arg converts to its type, and if the source's type is known,
+// that there is one arg per placeholder in the query
+func ValidateQueryArgs(v *validator.Validator, query string, args QueryArgs, dsType string) {
+	for i, arg := range args {
+		if !validator.In(arg.Type, queryArgTypes...) {
+			v.AddError("queryArgs", fmt.Sprintf("Query arg %d must have a type of string, integer, decimal, boolean, date, timestamp or null", i+1))
+			return
+		}
+		if _, err := arg.value(); err != nil {
+			v.AddError("queryArgs", fmt.Sprintf("Query arg %d isn't a valid %s", i+1, arg.Type))
+			return
+		}
+		if arg.Type == QueryArgDecimal && !decimalRX.MatchString(arg.Value) {
+			v.AddError("queryArgs", fmt.Sprintf("Query arg %d isn't a valid decimal", i+1))
+			return
+		}
+	}
+
+	if dsType == "" {
+		return
+	}
+	placeholders, err := CountPlaceholders(query, dsType)
+	if err != nil {
+		v.AddError("query", "Query has an unterminated quote or comment")
+		return
+	}
+	v.Check(placeholders == len(args), "queryArgs", fmt.Sprintf("Query has %d placeholders, but %d query args were given", placeholders, len(args)))
+}
+
+var (
+	decimalRX            = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	errUnterminatedQuery = errors.New("unterminated quote or comment")
+)
+
+// Counts the bind placeholders in query, using the style of dsType's driver:
+// $1 for PostgreSQL and Redshift, @p1 for SQL Server, :name for Oracle, and ?
+// for MySQL and Snowflake. Numbered placeholders count up to the highest
+// number used. Quoted strings, quoted identifiers and comments are skipped.
+func CountPlaceholders(query string, dsType string) (int, error) {
+	count := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"' || (c == '`' && dsType == "mysql"):
+			end := strings.IndexByte(query[i+1:], c)
+			if end == -1 {
+				return 0, errUnterminatedQuery
+			}
+			// a doubled quote is an escaped quote, which the next pass
+			// through the loop treats as the start of another quoted run
+			i += end + 1
+			continue
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end == -1 {
+				return count, nil
+			}
+			i += end
+			continue
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end == -1 {
+				return 0, errUnterminatedQuery
+			}
+			i += end + 3
+			continue
+		}
+
+		switch dsType {
+		case "postgresql", "redshift":
+			if c == '$' {
+				if n, width := leadingNumber(query[i+1:]); width > 0 {
+					if n > count {
+						count = n
+					}
+					i += width
+				}
+			}
+		case "mssql":
+			if c == '@' && i+1 < len(query) && (query[i+1] == 'p' || query[i+1] == 'P') {
+				if n, width := leadingNumber(query[i+2:]); width > 0 {
+					if n > count {
+						count = n
+					}
+					i += width + 1
+				}
+			}
+		case "oracle":
+			if c == ':' && i+1 < len(query) && isIdentifierByte(query[i+1]) {
+				count++
+				for i+1 < len(query) && isIdentifierByte(query[i+1]) {
+					i++
+				}
+			}
+		default:
+			if c == '?' {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func leadingNumber(s string) (n int, width int) {
+	for width < len(s) && s[width] >= '0' && s[width] <= '9' {
+		width++
+	}
+	if width == 0 {
+		return 0, 0
+	}
+	n, _ = strconv.Atoi(s[:width])
+	return n, width
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+func TestCountPlaceholders(t *testing.T) {
+	tests := []struct {
+		dsType string
+		query  string
+		want   int
+	}{
+		{"postgresql", "select * from t where a = $1 and b = $2 or c = $1", 2},
+		{"postgresql", "select '$3', \"$4\" from t where a::int = $1 -- $5\n and b = $2 /* $6 */", 2},
+		{"redshift", "select * from t", 0},
+		{"mysql", "select * from t where a = ? and b = '?' and `?` = ?", 2},
+		{"snowflake", "select * from t where a = ? and b = ?", 2},
+		{"mssql", "select * from t where a = @p1 and b = @p2 and c = '@p3'", 2},
+		{"oracle", "select * from t where a = :start_date and b < :2 and c = 'HH24:MI'", 2},
+	}
+	for _, tt := range tests {
+		got, err := CountPlaceholders(tt.query, tt.dsType)
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.dsType, tt.query, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: wanted %d placeholders, got %d", tt.dsType, tt.query, tt.want, got)
+		}
+	}
+
+	if _, err := CountPlaceholders("select 'unterminated from t", "postgresql"); err == nil {
+		t.Error("wanted an error for an unterminated quote")
+	}
+}
+
+func TestValidateQueryArgs(t *testing.T) {
+	query := "select * from events where created_at >= $1 and created_at < $2"
+	dates := QueryArgs{{Type: QueryArgDate, Value: "2022-01-01"}, {Type: QueryArgDate, Value: "2022-02-01"}}
+
+	v := validator.New()
+	ValidateQueryArgs(v, query, dates, "postgresql")
+	if !v.Valid() {
+		t.Errorf("wanted matching args to be valid, got %v", v.Errors)
+	}
+
+	for _, args := range []QueryArgs{
+		dates[:1],
+		append(dates, QueryArg{Type: QueryArgNull}),
+		{{Type: QueryArgDate, Value: "January 1st"}, dates[1]},
+		{{Type: "uuid", Value: "x"}, dates[1]},
+		{{Type: QueryArgDecimal, Value: "1e5"}, dates[1]},
+	} {
+		v := validator.New()
+		ValidateQueryArgs(v, query, args, "postgresql")
+		if _, ok := v.Errors["queryArgs"]; !ok {
+			t.Errorf("%v: wanted a queryArgs error, got %v", args, v.Errors)
+		}
+	}
+
+	// without the source's type, only the args themselves are checked
+	v = validator.New()
+	ValidateQueryArgs(v, query, dates[:1], "")
+	if !v.Valid() {
+		t.Errorf("wanted the count left unchecked, got %v", v.Errors)
+	}
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+var (
+	// Matches the {column:layout} placeholder in a target table pattern
+	tablePatternRX       = regexp.MustCompile(`\{([^{}:]*):([^{}]*)\}`)
+	tablePatternLayoutRX = regexp.MustCompile(`^(YYYY|MM|DD|HH|_)+$`)
+	tablePatternLayout   = strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02", "HH", "15")
+)
+
+// A target table name with one date placeholder, like
+// events_{created_at:YYYY_MM}, which routes each row to a table named after
+// the value of its created_at column
+type TablePattern struct {
+	Column string
+	prefix string
+	layout string
+	suffix string
+}
+
+func ParseTablePattern(pattern string) (TablePattern, error) {
+	matches := tablePatternRX.FindAllStringSubmatchIndex(pattern, -1)
+	if len(matches) != 1 {
+		return TablePattern{}, errors.New("must contain exactly one {column:layout} placeholder, like events_{created_at:YYYY_MM}")
+	}
+	match := matches[0]
+
+	column := pattern[match[2]:match[3]]
+	layout := pattern[match[4]:match[5]]
+
+	if !validator.Matches(column, validator.IdentifierRX) {
+		return TablePattern{}, errors.New("placeholder column must be a plain identifier of letters, digits and underscores")
+	}
+	if !tablePatternLayoutRX.MatchString(layout) {
+		return TablePattern{}, errors.New("placeholder layout may only use YYYY, MM, DD, HH and underscores")
+	}
+
+	tablePattern := TablePattern{
+		Column: column,
+		prefix: pattern[:match[0]],
+		layout: tablePatternLayout.Replace(layout),
+		suffix: pattern[match[1]:],
+	}
+
+	if !validator.Matches(tablePattern.Table(time.Now()), validator.IdentifierRX) {
+		return TablePattern{}, errors.New("must resolve to a plain identifier of letters, digits and underscores")
+	}
+
+	return tablePattern, nil
+}
+
+// The table that rows dated t belong in
+func (p TablePattern) Table(t time.Time) string {
+	return p.prefix + t.Format(p.layout) + p.suffix
+}
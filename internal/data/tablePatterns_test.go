@@ -0,0 +1,49 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTablePattern(t *testing.T) {
+	date := time.Date(2024, time.March, 7, 15, 0, 0, 0, time.UTC)
+
+	valid := []struct {
+		pattern string
+		column  string
+		table   string
+	}{
+		{"events_{created_at:YYYY_MM}", "created_at", "events_2024_03"},
+		{"events_{day:YYYYMMDD}", "day", "events_20240307"},
+		{"hits_{ts:YYYY_MM_DD_HH}", "ts", "hits_2024_03_07_15"},
+	}
+	for _, tt := range valid {
+		tablePattern, err := ParseTablePattern(tt.pattern)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.pattern, err)
+			continue
+		}
+		if tablePattern.Column != tt.column {
+			t.Errorf("%s: wanted column %q, got %q", tt.pattern, tt.column, tablePattern.Column)
+		}
+		if got := tablePattern.Table(date); got != tt.table {
+			t.Errorf("%s: wanted table %q, got %q", tt.pattern, tt.table, got)
+		}
+	}
+
+	invalid := []string{
+		"events",
+		"events_{created_at:YYYY}_{updated_at:MM}",
+		"events_{created-at:YYYY_MM}",
+		"events_{created_at:Jan}",
+		"events_{created_at:YYYY-MM}",
+		"events;drop_{created_at:YYYY}",
+		// tables can't start with a digit
+		"{day:YYYYMMDD}_events",
+	}
+	for _, pattern := range invalid {
+		if _, err := ParseTablePattern(pattern); err == nil {
+			t.Errorf("%s: wanted an error", pattern)
+		}
+	}
+}
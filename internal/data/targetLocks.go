@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+var (
+	ErrTargetBusy = errors.New("another transfer is writing to the target table")
+)
+
+// TargetLockModel keeps two transfers from writing to the same target table at
+// once. It uses postgres session level advisory locks on the backend DB, so a
+// lock is released when the connection holding it closes, even if the server
+// crashes mid transfer.
+type TargetLockModel struct {
+	DB *sql.DB
+}
+
+// Takes the lock for the transfer's target table, either waiting for it or
+// returning ErrTargetBusy if another transfer has it. The returned func
+// releases the lock.
+func (m TargetLockModel) Acquire(transfer *Transfer, wait bool) (release func(), err error) {
+	key := targetLockKey(transfer.Target.ID, transfer.TargetSchema, transfer.TargetTable)
+
+	// the lock belongs to the session, so hold one connection for its lifetime
+	conn, err := m.DB.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if wait {
+		_, err = conn.ExecContext(context.Background(), "select pg_advisory_lock($1)", key)
+	} else {
+		var locked bool
+		err = conn.QueryRowContext(context.Background(), "select pg_try_advisory_lock($1)", key).Scan(&locked)
+		if err == nil && !locked {
+			err = ErrTargetBusy
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	release = func() {
+		_, err := conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", key)
+		if err != nil {
+			// ErrBadConn makes the pool discard the connection instead of
+			// reusing it, and closing the session releases the lock
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+
+	return release, nil
+}
+
+// Identifiers are compared case insensitively, the way unquoted names are
+func targetLockKey(targetID int64, targetSchema string, targetTable string) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s", targetID, strings.ToLower(targetSchema), strings.ToLower(targetTable))
+	return int64(h.Sum64())
+}
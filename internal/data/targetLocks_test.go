@@ -0,0 +1,104 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Emulates postgres advisory locks, so each key can be held by one caller
+func fakeAdvisoryLocks() fakeHandler {
+	var mu sync.Mutex
+	locks := map[int64]chan struct{}{}
+	lockFor := func(key int64) chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if locks[key] == nil {
+			locks[key] = make(chan struct{}, 1)
+		}
+		return locks[key]
+	}
+
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		lock := lockFor(args[0].(int64))
+		switch query {
+		case "select pg_advisory_lock($1)":
+			lock <- struct{}{}
+			return nil, nil, nil
+		case "select pg_try_advisory_lock($1)":
+			select {
+			case lock <- struct{}{}:
+				return []string{"locked"}, [][]driver.Value{{true}}, nil
+			default:
+				return []string{"locked"}, [][]driver.Value{{false}}, nil
+			}
+		case "select pg_advisory_unlock($1)":
+			<-lock
+			return nil, nil, nil
+		}
+		return nil, nil, errors.New("unexpected query")
+	}
+}
+
+func TestTargetLocksSerializeTransfers(t *testing.T) {
+	locks := TargetLockModel{DB: newFakeDB(t, fakeAdvisoryLocks())}
+
+	first := &Transfer{Target: Connection{ID: 1}, TargetSchema: "public", TargetTable: "orders"}
+	second := &Transfer{Target: Connection{ID: 1}, TargetSchema: "PUBLIC", TargetTable: "Orders"}
+
+	release, err := locks.Acquire(first, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, err := locks.Acquire(second, true)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- release
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second transfer got the lock while the first still held it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+
+	select {
+	case releaseSecond := <-acquired:
+		releaseSecond()
+	case <-time.After(time.Second):
+		t.Fatal("second transfer never got the lock")
+	}
+}
+
+func TestTargetLocksFailFast(t *testing.T) {
+	locks := TargetLockModel{DB: newFakeDB(t, fakeAdvisoryLocks())}
+
+	orders := &Transfer{Target: Connection{ID: 1}, TargetSchema: "public", TargetTable: "orders"}
+
+	release, err := locks.Acquire(orders, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	_, err = locks.Acquire(orders, false)
+	if !errors.Is(err, ErrTargetBusy) {
+		t.Errorf("wanted ErrTargetBusy, got %v", err)
+	}
+
+	// the same table name on another target is a different table
+	otherTarget := &Transfer{Target: Connection{ID: 2}, TargetSchema: "public", TargetTable: "orders"}
+	releaseOther, err := locks.Acquire(otherTarget, false)
+	if err != nil {
+		t.Fatalf("wanted a lock on a different target, got %v", err)
+	}
+	releaseOther()
+}
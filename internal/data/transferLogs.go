@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Bounds on the log kept with each transfer. Once either is passed, the
+// oldest lines roll off.
+const (
+	maxLogLines = 500
+	maxLogBytes = 64 * 1024
+)
+
+// The newest lines of what happened while a transfer ran. Dropped counts the
+// lines that have rolled off, so Dropped+i numbers line i across reads.
+// Status is the transfer's, as of reading the log.
+type TransferLog struct {
+	Status  string   `json:"status"`
+	Lines   []string `json:"lines"`
+	Dropped int64    `json:"dropped"`
+	bytes   int
+}
+
+// Adds a timestamped line, rolling off old ones to stay within bounds
+func (l *TransferLog) Add(format string, args ...interface{}) {
+	line := fmt.Sprintf("%s %s", time.Now().UTC().Format(time.RFC3339), fmt.Sprintf(format, args...))
+	if len(line) > maxLogBytes {
+		line = line[:maxLogBytes]
+	}
+
+	l.Lines = append(l.Lines, line)
+	l.bytes += len(line)
+	for len(l.Lines) > maxLogLines || l.bytes > maxLogBytes {
+		l.bytes -= len(l.Lines[0])
+		l.Lines = l.Lines[1:]
+		l.Dropped++
+	}
+}
+
+func (m TransferModel) SaveLog(id int64, log *TransferLog) error {
+	query := `
+        UPDATE transfers 
+        SET log = $1, log_dropped = $2
+        WHERE id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(log.Lines), log.Dropped, id)
+	return err
+}
+
+// Saves a running transfer's log, and how many rows it has written so far
+func (m TransferModel) SaveProgress(id int64, log *TransferLog, rowsWritten int64) error {
+	query := `
+        UPDATE transfers 
+        SET log = $1, log_dropped = $2, rows_written = $3
+        WHERE id = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, pq.Array(log.Lines), log.Dropped, rowsWritten, id)
+	return err
+}
+
+func (m TransferModel) GetLog(id int64) (*TransferLog, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT status, log, log_dropped
+        FROM transfers
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var log TransferLog
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&log.Status, pq.Array(&log.Lines), &log.Dropped)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &log, nil
+}
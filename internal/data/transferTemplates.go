@@ -0,0 +1,278 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+var (
+	ErrDuplicateTemplateName = errors.New("duplicate transfer template name")
+
+	// A {{name}} placeholder, which may appear in any text field of a
+	// template's definition
+	templateVariableRX = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// A transfer definition with {{name}} placeholders. Templates aren't run
+// themselves; transfers are made from them by filling in every variable.
+type TransferTemplate struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Name       string    `json:"name"`
+	Definition Transfer  `json:"definition"`
+	Variables  []string  `json:"variables"`
+	Version    int       `json:"version"`
+}
+
+// Every variable the definition uses, sorted
+func (t TransferTemplate) variables() []string {
+	js, _ := json.Marshal(t.Definition)
+
+	seen := map[string]bool{}
+	var names []string
+	for _, match := range templateVariableRX.FindAllSubmatch(js, -1) {
+		name := string(match[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns a transfer made from the definition, with each placeholder
+// replaced by its variable's value. Every variable must be given, and no
+// others, or the errors are added to v and nil is returned.
+func (t TransferTemplate) Instantiate(v *validator.Validator, variables map[string]string) *Transfer {
+	var missing, unknown []string
+	used := map[string]bool{}
+	for _, name := range t.variables() {
+		used[name] = true
+		if _, ok := variables[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	for name := range variables {
+		if !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	v.Check(len(missing) == 0, "variables", fmt.Sprintf("Missing values for template variables: %s", strings.Join(missing, ", ")))
+	v.Check(len(unknown) == 0, "variables", fmt.Sprintf("The template has no variables named: %s", strings.Join(unknown, ", ")))
+	if !v.Valid() {
+		return nil
+	}
+
+	js, err := json.Marshal(t.Definition)
+	if err != nil {
+		v.AddError("definition", err.Error())
+		return nil
+	}
+
+	// values are escaped as JSON strings, so they can't change the
+	// definition's structure
+	js = templateVariableRX.ReplaceAllFunc(js, func(placeholder []byte) []byte {
+		name := templateVariableRX.FindSubmatch(placeholder)[1]
+		value, _ := json.Marshal(variables[string(name)])
+		return value[1 : len(value)-1]
+	})
+
+	var transfer Transfer
+	err = json.Unmarshal(js, &transfer)
+	if err != nil {
+		v.AddError("definition", err.Error())
+		return nil
+	}
+	return transfer.Rerun()
+}
+
+// Checks the name, and the definition as a transfer would be checked, with
+// every variable filled in with a plain identifier
+func ValidateTransferTemplate(v *validator.Validator, template *TransferTemplate) {
+	v.Check(template.Name != "", "name", "A template name is required")
+	v.Check(len(template.Name) <= 100, "name", "Template name must not be more than 100 characters")
+
+	variables := map[string]string{}
+	for _, name := range template.variables() {
+		variables[name] = "sqlpipe_" + name
+	}
+
+	definition := validator.New()
+	transfer := template.Instantiate(definition, variables)
+	if transfer != nil {
+		ValidateTransfer(definition, transfer)
+	}
+	for key, message := range definition.Errors {
+		v.AddError(key, message)
+	}
+}
+
+type TransferTemplateModel struct {
+	DB *sql.DB
+}
+
+func (m TransferTemplateModel) Insert(template *TransferTemplate) (*TransferTemplate, error) {
+	query := `
+        INSERT INTO transfer_templates (name, definition)
+        VALUES ($1, $2)
+        RETURNING id, created_at, version`
+
+	definition, err := json.Marshal(template.Definition)
+	if err != nil {
+		return template, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, template.Name, definition).Scan(&template.ID, &template.CreatedAt, &template.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "transfer_templates_name_key"`:
+			return template, ErrDuplicateTemplateName
+		default:
+			return template, err
+		}
+	}
+
+	template.Variables = template.variables()
+	return template, nil
+}
+
+// The columns transfer templates can be sorted by, keyed by sort name
+var transferTemplateSortColumns = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+	"name":       "name",
+}
+
+func (m TransferTemplateModel) GetAll(filters Filters) ([]*TransferTemplate, Metadata, error) {
+	orderBy, err := filters.orderBy(transferTemplateSortColumns)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, created_at, name, definition, version
+        FROM transfer_templates
+        ORDER BY %s, id ASC
+        LIMIT $1 OFFSET $2`, orderBy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	templates := []*TransferTemplate{}
+	for rows.Next() {
+		template, err := scanTransferTemplate(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		templates = append(templates, template)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return templates, metadata, nil
+}
+
+func (m TransferTemplateModel) GetById(id int64) (*TransferTemplate, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT id, created_at, name, definition, version
+        FROM transfer_templates
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	template, err := scanTransferTemplate(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return template, nil
+}
+
+func (m TransferTemplateModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        DELETE FROM transfer_templates
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Scans a template's columns, after any leading ones, like a listing's total
+func scanTransferTemplate(row rowScanner, leading ...interface{}) (*TransferTemplate, error) {
+	var template TransferTemplate
+	var definition []byte
+
+	err := row.Scan(append(leading, &template.ID, &template.CreatedAt, &template.Name, &definition, &template.Version)...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(definition, &template.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	template.Variables = template.variables()
+	return &template, nil
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+func testTemplate() TransferTemplate {
+	return TransferTemplate{
+		Name: "copy table",
+		Definition: Transfer{
+			SourceID:     1,
+			TargetID:     2,
+			Query:        "select * from {{schema}}.{{ table }}",
+			TargetSchema: "{{schema}}",
+			TargetTable:  "{{table}}_copy",
+			PreLoadSQL:   []string{"delete from {{table}}_copy where note = 'x'"},
+		},
+	}
+}
+
+func TestInstantiateTransferTemplate(t *testing.T) {
+	template := testTemplate()
+	if got := strings.Join(template.variables(), ","); got != "schema,table" {
+		t.Fatalf("wanted variables schema and table, got %s", got)
+	}
+
+	v := validator.New()
+	transfer := template.Instantiate(v, map[string]string{"schema": "public", "table": `us"ers`})
+	if !v.Valid() {
+		t.Fatal(v.Errors)
+	}
+	if transfer.Query != `select * from public.us"ers` || transfer.TargetSchema != "public" || transfer.TargetTable != `us"ers_copy` {
+		t.Errorf("placeholders weren't filled in: %+v", transfer)
+	}
+	if transfer.PreLoadSQL[0] != `delete from us"ers_copy where note = 'x'` || transfer.SourceID != 1 || transfer.TargetID != 2 {
+		t.Errorf("the rest of the definition wasn't kept: %+v", transfer)
+	}
+	if template.Definition.TargetTable != "{{table}}_copy" {
+		t.Error("wanted the template left untouched")
+	}
+}
+
+func TestInstantiateTransferTemplateVariables(t *testing.T) {
+	template := testTemplate()
+
+	v := validator.New()
+	if transfer := template.Instantiate(v, map[string]string{"table": "users"}); transfer != nil {
+		t.Errorf("wanted no transfer, got %+v", transfer)
+	}
+	if !strings.Contains(v.Errors["variables"], "schema") {
+		t.Errorf("wanted an error naming the missing variable, got %v", v.Errors)
+	}
+
+	v = validator.New()
+	template.Instantiate(v, map[string]string{"table": "users", "schema": "public", "tabel": "x"})
+	if !strings.Contains(v.Errors["variables"], "tabel") {
+		t.Errorf("wanted an error naming the unknown variable, got %v", v.Errors)
+	}
+}
+
+func TestValidateTransferTemplate(t *testing.T) {
+	template := testTemplate()
+	v := validator.New()
+	if ValidateTransferTemplate(v, &template); !v.Valid() {
+		t.Errorf("wanted a valid template, got %v", v.Errors)
+	}
+
+	template.Name = ""
+	template.Definition.TargetTable = "{{table}} copy"
+	v = validator.New()
+	ValidateTransferTemplate(v, &template)
+	if v.Errors["name"] == "" || v.Errors["targetTable"] == "" {
+		t.Errorf("wanted name and target table errors, got %v", v.Errors)
+	}
+}
@@ -5,27 +5,219 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/sqlpipe/sqlpipe/internal/validator"
 )
 
 type Transfer struct {
-	ID              int64      `json:"id"`
-	CreatedAt       time.Time  `json:"createdAt"`
-	SourceID        int64      `json:"sourceID"`
-	Source          Connection `json:"-"`
-	TargetID        int64      `json:"targetID"`
-	Target          Connection `json:"-"`
-	Query           string     `json:"query"`
-	TargetSchema    string     `json:"targetSchema"`
-	TargetTable     string     `json:"targetTable"`
-	Overwrite       bool       `json:"overwrite"`
-	Status          string     `json:"status"`
-	Error           string     `json:"error"`
-	ErrorProperties string     `json:"errorProperties"`
-	StoppedAt       time.Time  `json:"stoppedAt"`
-	Version         int        `json:"version"`
+	ID           int64      `json:"id"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	CreatedBy    int64      `json:"createdBy"`
+	SourceID     int64      `json:"sourceID"`
+	Source       Connection `json:"-"`
+	TargetID     int64      `json:"targetID"`
+	Target       Connection `json:"-"`
+	Query        string     `json:"query"`
+	QueryArgs    QueryArgs  `json:"queryArgs"`
+	TargetSchema string     `json:"targetSchema"`
+	TargetTable  string     `json:"targetTable"`
+	Overwrite    bool       `json:"overwrite"`
+	WriteMode    string     `json:"writeMode"`
+	PreLoadSQL   []string   `json:"preLoadSQL"`
+	Parallelism  int        `json:"parallelism"`
+	ChunkColumn  string     `json:"chunkColumn"`
+	// Used instead of TargetTable to split rows across tables by date
+	TargetTablePattern string   `json:"targetTablePattern"`
+	CreateTargetTable  bool     `json:"createTargetTable"`
+	CreateTargetSchema bool     `json:"createTargetSchema"`
+	SourceColumns      []string `json:"sourceColumns"`
+	ExcludeColumns     []string `json:"excludeColumns"`
+	ConflictColumns    []string `json:"conflictColumns"`
+	// The order the target's columns are created and inserted in, if not
+	// the source query's
+	TargetColumnOrder []string `json:"targetColumnOrder"`
+	// Reads the target back after loading, and fails the transfer unless
+	// its rows hash the same as the source's
+	VerifyChecksum bool `json:"verifyChecksum"`
+	// Reads the source inside one transaction at this isolation level, so
+	// the whole copy sees a consistent snapshot
+	IsolationLevel string `json:"isolationLevel"`
+	// Seconds the source database lets each statement run before cancelling
+	// it. 0 means no limit
+	StatementTimeout int `json:"statementTimeout"`
+	// Copies only the source query's first SourceLimit rows, or a random
+	// SampleRate fraction of them, from 0 to 1. 0 copies every row
+	SourceLimit int     `json:"sourceLimit"`
+	SampleRate  float64 `json:"sampleRate"`
+	// Rows that fail to insert are skipped, until more than this many have
+	// been. 0 fails the transfer at the first bad row
+	MaxErrors int `json:"maxErrors"`
+	// How many rows were skipped under MaxErrors
+	RejectedRows int64 `json:"rejectedRows"`
+	// How many rows have been written. It's saved with the transfer's log
+	// while it runs, so clients polling the transfer can tell its pace
+	RowsWritten int64 `json:"rowsWritten"`
+	// Counts rejected rows while the transfer runs. Every copy of the
+	// transfer shares it, so chunks loaded in parallel count toward the
+	// same MaxErrors
+	Rejects          *RejectCounter `json:"-"`
+	MaxBufferedRows  int            `json:"maxBufferedRows"`
+	MaxBufferedBytes int64          `json:"maxBufferedBytes"`
+	RerunOf          int64          `json:"rerunOf"`
+	// Transfers that must complete before this one starts. If one of them
+	// errors, is cancelled or is skipped, this one is skipped
+	DependsOn  []int64 `json:"dependsOn"`
+	TargetFile string  `json:"-"`
+	NullString string  `json:"-"`
+	// Leaves the header line out of a .csv TargetFile
+	NoHeader bool `json:"-"`
+	// Compresses TargetFile with gzip or zstd. Empty compresses files ending
+	// in .gz or .zst, and leaves others alone
+	Compress string `json:"-"`
+	// How binary columns are written to TargetFile, base64 or hex. Empty
+	// means base64
+	BinaryEncoding string `json:"-"`
+	// The X-Request-ID of the request that created the transfer, which the
+	// runner's log lines about it carry
+	RequestID string `json:"-"`
+	// Told about the transfer as it runs. nil reports to nobody
+	Progress        ProgressReporter `json:"-"`
+	Status          string           `json:"status"`
+	Error           string           `json:"error"`
+	ErrorProperties string           `json:"errorProperties"`
+	StoppedAt       time.Time        `json:"stoppedAt"`
+	Version         int              `json:"version"`
+}
+
+// How a transfer treats rows already in its target table
+const (
+	WriteModeAppend   = "append"
+	WriteModeTruncate = "truncate"
+	WriteModeRecreate = "recreate"
+	// inserts rows, updating the ones whose ConflictColumns match instead
+	WriteModeUpsert = "upsert"
+)
+
+var writeModes = []string{WriteModeAppend, WriteModeTruncate, WriteModeRecreate, WriteModeUpsert}
+
+// The columns transfers can be sorted by, keyed by sort name
+var transferSortColumns = map[string]string{
+	"id":         "transfers.id",
+	"created_at": "transfers.created_at",
+}
+
+// The statuses a transfer never leaves, so it can be deleted without
+// pulling it out from under a runner
+var FinishedTransferStatuses = []string{"complete", "error", "cancelled", "skipped"}
+
+var ErrTransferNotFinished = errors.New("transfer is still queued or active")
+
+// How a file target can be compressed
+const (
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// How binary columns are written to a file target
+const (
+	BinaryBase64 = "base64"
+	BinaryHex    = "hex"
+)
+
+// The isolation levels a transfer can read its source at
+const (
+	IsolationReadUncommitted = "read uncommitted"
+	IsolationReadCommitted   = "read committed"
+	IsolationRepeatableRead  = "repeatable read"
+	IsolationSnapshot        = "snapshot"
+	IsolationSerializable    = "serializable"
+)
+
+var isolationLevels = []string{IsolationReadUncommitted, IsolationReadCommitted, IsolationRepeatableRead, IsolationSnapshot, IsolationSerializable}
+
+// The levels each data system's driver can start a transaction at.
+// Snowflake only runs at its own default, so none can be asked for.
+var supportedIsolationLevels = map[string][]string{
+	"postgresql": {IsolationReadCommitted, IsolationRepeatableRead, IsolationSerializable},
+	"redshift":   {IsolationSerializable},
+	"mysql":      {IsolationReadUncommitted, IsolationReadCommitted, IsolationRepeatableRead, IsolationSerializable},
+	"mssql":      {IsolationReadUncommitted, IsolationReadCommitted, IsolationRepeatableRead, IsolationSnapshot, IsolationSerializable},
+	"oracle":     {IsolationReadCommitted, IsolationSerializable},
+	"snowflake":  {},
+}
+
+// Returns WriteMode, or for transfers that predate it, the mode Overwrite
+// maps to: emptying the target, which keeps its indexes and grants
+func (t Transfer) Mode() string {
+	switch {
+	case t.WriteMode != "":
+		return t.WriteMode
+	case t.Overwrite:
+		return WriteModeTruncate
+	default:
+		return WriteModeAppend
+	}
+}
+
+// Returns a new transfer with the same definition as t, for running it again.
+// The copy links back to t through RerunOf, and starts out queued.
+func (t Transfer) Rerun() *Transfer {
+	return &Transfer{
+		SourceID:           t.SourceID,
+		TargetID:           t.TargetID,
+		Query:              t.Query,
+		QueryArgs:          append(QueryArgs{}, t.QueryArgs...),
+		TargetSchema:       t.TargetSchema,
+		TargetTable:        t.TargetTable,
+		Overwrite:          t.Overwrite,
+		WriteMode:          t.WriteMode,
+		PreLoadSQL:         append([]string{}, t.PreLoadSQL...),
+		Parallelism:        t.Parallelism,
+		ChunkColumn:        t.ChunkColumn,
+		TargetTablePattern: t.TargetTablePattern,
+		CreateTargetTable:  t.CreateTargetTable,
+		CreateTargetSchema: t.CreateTargetSchema,
+		SourceColumns:      append([]string{}, t.SourceColumns...),
+		ExcludeColumns:     append([]string{}, t.ExcludeColumns...),
+		ConflictColumns:    append([]string{}, t.ConflictColumns...),
+		TargetColumnOrder:  append([]string{}, t.TargetColumnOrder...),
+		VerifyChecksum:     t.VerifyChecksum,
+		IsolationLevel:     t.IsolationLevel,
+		StatementTimeout:   t.StatementTimeout,
+		MaxErrors:          t.MaxErrors,
+		SourceLimit:        t.SourceLimit,
+		SampleRate:         t.SampleRate,
+		MaxBufferedRows:    t.MaxBufferedRows,
+		MaxBufferedBytes:   t.MaxBufferedBytes,
+		RerunOf:            t.ID,
+	}
+}
+
+// Shown in place of the name of a connection that no longer exists
+const deletedConnectionName = "(deleted)"
+
+// The connection columns of a transfer listing. They come from a left join, so
+// they are all NULL when the connection has been deleted.
+type joinedConnection struct {
+	name      sql.NullString
+	dsType    sql.NullString
+	accountId sql.NullString
+	dbName    sql.NullString
+}
+
+func (j joinedConnection) apply(connection *Connection) {
+	if !j.name.Valid {
+		connection.Name = deletedConnectionName
+		return
+	}
+
+	connection.Name = j.name.String
+	connection.DsType = j.dsType.String
+	connection.AccountId = j.accountId.String
+	connection.DbName = j.dbName.String
 }
 
 type TransferModel struct {
@@ -33,11 +225,65 @@ type TransferModel struct {
 }
 
 func (m TransferModel) Insert(transfer *Transfer) (*Transfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := insertTransfer(ctx, m.DB, transfer)
+	return transfer, err
+}
+
+// Inserts every transfer in one transaction, so either all of them are
+// created or, if any insert fails, none are
+func (m TransferModel) InsertAll(transfers []*Transfer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, transfer := range transfers {
+		err = insertTransfer(ctx, tx, transfer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// A *sql.DB, or a *sql.Tx to insert inside a transaction
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertTransfer(ctx context.Context, db rowQueryer, transfer *Transfer) error {
 	query := `
-        INSERT INTO transfers (source_id, target_id, query, target_schema, target_table, overwrite, stopped_at) 
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        INSERT INTO transfers (source_id, target_id, query, target_schema, target_table, overwrite, pre_load_sql, parallelism, chunk_column, target_table_pattern, create_target_table, source_columns, exclude_columns, write_mode, max_buffered_rows, max_buffered_bytes, rerun_of, query_args, conflict_columns, target_column_order, verify_checksum, isolation_level, statement_timeout, max_errors, source_limit, sample_rate, create_target_schema, created_by, depends_on, stopped_at, request_id) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31)
         RETURNING id, created_at, status, version`
 
+	if transfer.PreLoadSQL == nil {
+		transfer.PreLoadSQL = []string{}
+	}
+	if transfer.SourceColumns == nil {
+		transfer.SourceColumns = []string{}
+	}
+	if transfer.ExcludeColumns == nil {
+		transfer.ExcludeColumns = []string{}
+	}
+	if transfer.ConflictColumns == nil {
+		transfer.ConflictColumns = []string{}
+	}
+	if transfer.TargetColumnOrder == nil {
+		transfer.TargetColumnOrder = []string{}
+	}
+	if transfer.DependsOn == nil {
+		transfer.DependsOn = []int64{}
+	}
+
 	args := []interface{}{
 		transfer.SourceID,
 		transfer.TargetID,
@@ -45,25 +291,379 @@ func (m TransferModel) Insert(transfer *Transfer) (*Transfer, error) {
 		transfer.TargetSchema,
 		transfer.TargetTable,
 		transfer.Overwrite,
+		pq.Array(transfer.PreLoadSQL),
+		transfer.Parallelism,
+		transfer.ChunkColumn,
+		transfer.TargetTablePattern,
+		transfer.CreateTargetTable,
+		pq.Array(transfer.SourceColumns),
+		pq.Array(transfer.ExcludeColumns),
+		transfer.WriteMode,
+		transfer.MaxBufferedRows,
+		transfer.MaxBufferedBytes,
+		transfer.RerunOf,
+		transfer.QueryArgs,
+		pq.Array(transfer.ConflictColumns),
+		pq.Array(transfer.TargetColumnOrder),
+		transfer.VerifyChecksum,
+		transfer.IsolationLevel,
+		transfer.StatementTimeout,
+		transfer.MaxErrors,
+		transfer.SourceLimit,
+		transfer.SampleRate,
+		transfer.CreateTargetSchema,
+		transfer.CreatedBy,
+		pq.Array(transfer.DependsOn),
 		transfer.StoppedAt,
+		transfer.RequestID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	return db.QueryRowContext(ctx, query, args...).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.Status, &transfer.Version)
+}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.Status, &transfer.Version)
-	if err != nil {
-		return transfer, err
+// Checks the isolation level is one there is, and if the source's type is
+// known, that its driver supports it. Parallel chunks only share a
+// snapshot on PostgreSQL, which can hand one transaction's to another.
+func ValidateIsolationLevel(v *validator.Validator, transfer *Transfer, dsType string) {
+	if transfer.IsolationLevel == "" {
+		return
+	}
+	if !validator.In(transfer.IsolationLevel, isolationLevels...) {
+		v.AddError("isolationLevel", "Isolation level must be one of read uncommitted, read committed, repeatable read, snapshot or serializable")
+		return
 	}
 
-	return transfer, nil
+	if dsType == "" {
+		return
+	}
+	v.Check(validator.In(transfer.IsolationLevel, supportedIsolationLevels[dsType]...), "isolationLevel", fmt.Sprintf("The %s source doesn't support the %s isolation level", dsType, transfer.IsolationLevel))
+	if transfer.Parallelism > 1 {
+		v.Check(dsType == "postgresql" && (transfer.IsolationLevel == IsolationRepeatableRead || transfer.IsolationLevel == IsolationSerializable), "isolationLevel", "Parallel chunks can only share a snapshot on a PostgreSQL source, at the repeatable read or serializable isolation level")
+	}
+}
+
+// Checks a source limit or sample rate is in range, and isn't combined with
+// settings it would break
+func ValidateSourceSampling(v *validator.Validator, transfer *Transfer) {
+	v.Check(transfer.SourceLimit >= 0, "sourceLimit", "Source limit must not be negative")
+	v.Check(transfer.SampleRate >= 0 && transfer.SampleRate <= 1, "sampleRate", "Sample rate must be from 0 to 1")
+	if transfer.SourceLimit > 0 || transfer.SampleRate > 0 {
+		v.Check(transfer.SourceLimit == 0 || transfer.SampleRate == 0, "sampleRate", "Source limit and sample rate can't be used together")
+		v.Check(!transfer.VerifyChecksum, "sourceLimit", "A source limit or sample rate can't be used with verify checksum, since the source's rows would never match")
+		v.Check(transfer.SourceLimit == 0 || transfer.Parallelism <= 1, "sourceLimit", "Source limit can't be used with parallelism, since each chunk would be limited")
+	}
+}
+
+// The data systems that can cancel a session's long running statements
+// themselves
+var statementTimeoutDsTypes = []string{"postgresql", "redshift", "mysql", "snowflake"}
+
+// Checks the statement timeout isn't negative, and if the source's type is
+// known, that it can enforce one
+func ValidateStatementTimeout(v *validator.Validator, transfer *Transfer, dsType string) {
+	v.Check(transfer.StatementTimeout >= 0, "statementTimeout", "Statement timeout must not be negative")
+	if transfer.StatementTimeout > 0 && dsType != "" {
+		v.Check(validator.In(dsType, statementTimeoutDsTypes...), "statementTimeout", fmt.Sprintf("The %s source can't enforce a statement timeout", dsType))
+	}
+}
+
+// Checks a file target's compression is one sqlpipe can write, and doesn't
+// contradict the file's extension
+func ValidateCompress(v *validator.Validator, transfer *Transfer) {
+	if transfer.Compress == "" {
+		return
+	}
+	v.Check(validator.In(transfer.Compress, CompressGzip, CompressZstd), "compress", "Compress must be gzip or zstd")
+	v.Check(transfer.TargetFile != "", "compress", "Only file targets can be compressed")
+	v.Check(!strings.HasSuffix(transfer.TargetFile, ".gz") || transfer.Compress == CompressGzip, "compress", "Files ending in .gz can only be gzipped")
+	v.Check(!strings.HasSuffix(transfer.TargetFile, ".zst") || transfer.Compress == CompressZstd, "compress", "Files ending in .zst can only be compressed with zstd")
+}
+
+func ValidateBinaryEncoding(v *validator.Validator, transfer *Transfer) {
+	if transfer.BinaryEncoding == "" {
+		return
+	}
+	v.Check(validator.In(transfer.BinaryEncoding, BinaryBase64, BinaryHex), "binaryEncoding", "Binary encoding must be base64 or hex")
+	v.Check(transfer.TargetFile != "", "binaryEncoding", "Only file targets have a binary encoding")
 }
 
 func ValidateTransfer(v *validator.Validator, transfer *Transfer) {
 	v.Check(transfer.SourceID > 0, "sourceId", "Source ID is required and must be an integer greater than 0")
-	v.Check(transfer.TargetID > 0, "targetId", "Source ID is required and must be an integer greater than 0")
+	v.Check(transfer.TargetID > 0, "targetId", "Target ID is required and must be an integer greater than 0")
 	v.Check(transfer.Query != "", "query", "A query is required")
-	v.Check(transfer.TargetTable != "", "targetTable", "A target table is required")
+	if transfer.TargetTablePattern == "" {
+		v.Check(transfer.TargetTable != "", "targetTable", "A target table is required")
+	} else {
+		v.Check(transfer.TargetTable == "", "targetTable", "Target table must be empty when a target table pattern is given")
+		if _, err := ParseTablePattern(transfer.TargetTablePattern); err != nil {
+			v.AddError("targetTablePattern", "Target table pattern "+err.Error())
+		}
+		v.Check(transfer.Parallelism <= 1, "parallelism", "Parallelism can't be used with a target table pattern")
+	}
+
+	// target names are written into SQL unquoted
+	if transfer.TargetTable != "" {
+		v.Check(validator.Matches(transfer.TargetTable, validator.IdentifierRX), "targetTable", "Target table must be a plain identifier of letters, digits and underscores")
+	}
+	if transfer.TargetSchema != "" {
+		v.Check(validator.Matches(transfer.TargetSchema, validator.IdentifierRX), "targetSchema", "Target schema must be a plain identifier of letters, digits and underscores")
+	}
+	v.Check(!transfer.CreateTargetSchema || transfer.TargetSchema != "", "createTargetSchema", "Create target schema needs a target schema")
+
+	v.Check(transfer.Parallelism >= 0, "parallelism", "Parallelism must not be negative")
+	if transfer.Parallelism > 1 {
+		v.Check(transfer.ChunkColumn != "", "chunkColumn", "A chunk column is required when parallelism is more than 1")
+		v.Check(validator.Matches(transfer.ChunkColumn, validator.IdentifierRX), "chunkColumn", "Chunk column must be a plain identifier of letters, digits and underscores")
+	}
+
+	v.Check(len(transfer.SourceColumns) == 0 || len(transfer.ExcludeColumns) == 0, "excludeColumns", "Source columns and exclude columns can't both be given")
+	for _, column := range append(append([]string{}, transfer.SourceColumns...), transfer.ExcludeColumns...) {
+		if !validator.Matches(column, validator.IdentifierRX) {
+			v.AddError("sourceColumns", "Column names must be plain identifiers of letters, digits and underscores")
+			break
+		}
+	}
+	if transfer.ChunkColumn != "" {
+		v.Check(len(transfer.SourceColumns) == 0 || containsFold(transfer.SourceColumns, transfer.ChunkColumn), "chunkColumn", "Chunk column must be one of the source columns")
+		v.Check(!containsFold(transfer.ExcludeColumns, transfer.ChunkColumn), "chunkColumn", "Chunk column can't be excluded")
+	}
+
+	seen := map[string]bool{}
+	for _, column := range transfer.TargetColumnOrder {
+		if !validator.Matches(column, validator.IdentifierRX) {
+			v.AddError("targetColumnOrder", "Column names must be plain identifiers of letters, digits and underscores")
+			break
+		}
+		if seen[strings.ToLower(column)] {
+			v.AddError("targetColumnOrder", "Target column order must not repeat a column")
+			break
+		}
+		seen[strings.ToLower(column)] = true
+		if containsFold(transfer.ExcludeColumns, column) {
+			v.AddError("targetColumnOrder", "Target column order can't include an excluded column")
+			break
+		}
+	}
+	// the query's own columns are only known once it runs, so without a
+	// source column list, the rest is checked then
+	if len(transfer.TargetColumnOrder) > 0 && len(transfer.SourceColumns) > 0 {
+		permutation := len(transfer.TargetColumnOrder) == len(transfer.SourceColumns)
+		for _, column := range transfer.SourceColumns {
+			permutation = permutation && containsFold(transfer.TargetColumnOrder, column)
+		}
+		v.Check(permutation, "targetColumnOrder", "Target column order must list each source column once")
+	}
+
+	ValidateQueryArgs(v, transfer.Query, transfer.QueryArgs, transfer.Source.DsType)
+	ValidateIsolationLevel(v, transfer, transfer.Source.DsType)
+	ValidateStatementTimeout(v, transfer, transfer.Source.DsType)
+
+	v.Check(transfer.MaxErrors >= 0, "maxErrors", "Max errors must not be negative")
+	if transfer.MaxErrors > 0 {
+		v.Check(transfer.TargetTablePattern == "", "maxErrors", "Max errors can't be used with a target table pattern")
+		v.Check(!transfer.VerifyChecksum, "maxErrors", "Max errors can't be used with verify checksum, since rejected rows would never match")
+	}
+
+	ValidateSourceSampling(v, transfer)
+
+	v.Check(transfer.MaxBufferedRows >= 0, "maxBufferedRows", "Max buffered rows must not be negative")
+	v.Check(transfer.MaxBufferedBytes >= 0, "maxBufferedBytes", "Max buffered bytes must not be negative")
+
+	if transfer.WriteMode != "" {
+		v.Check(validator.In(transfer.WriteMode, writeModes...), "writeMode", "Write mode must be one of append, truncate, recreate or upsert")
+		v.Check(!transfer.Overwrite || transfer.WriteMode == WriteModeTruncate, "writeMode", "Overwrite means the truncate write mode, so it can't be used with another one")
+	}
+	v.Check(transfer.Mode() == WriteModeAppend || transfer.TargetFile != "-", "writeMode", "Stdout can only be appended to")
+
+	if transfer.Mode() == WriteModeUpsert {
+		v.Check(len(transfer.ConflictColumns) > 0, "conflictColumns", "Conflict columns are required with the upsert write mode")
+		v.Check(transfer.TargetFile == "", "writeMode", "Files can't be upserted into")
+		v.Check(transfer.TargetTablePattern == "", "writeMode", "Upsert can't be used with a target table pattern")
+		for _, col := range transfer.ConflictColumns {
+			if !validator.Matches(col, validator.IdentifierRX) {
+				v.AddError("conflictColumns", "Conflict columns must be plain identifiers of letters, digits and underscores")
+				break
+			}
+			if len(transfer.SourceColumns) > 0 && !containsFold(transfer.SourceColumns, col) {
+				v.AddError("conflictColumns", "Conflict columns must be among the source columns")
+				break
+			}
+			if containsFold(transfer.ExcludeColumns, col) {
+				v.AddError("conflictColumns", "Conflict columns can't be excluded")
+				break
+			}
+		}
+	} else {
+		v.Check(len(transfer.ConflictColumns) == 0, "conflictColumns", "Conflict columns can only be given with the upsert write mode")
+	}
+
+	if transfer.VerifyChecksum {
+		// the target must hold exactly the rows read from the source
+		v.Check(transfer.Mode() == WriteModeRecreate || transfer.Mode() == WriteModeTruncate, "verifyChecksum", "Checksums can only be verified with the recreate or truncate write modes")
+		v.Check(transfer.TargetFile == "" && transfer.TargetTablePattern == "", "verifyChecksum", "Checksums can only be verified on a single target table")
+		v.Check(transfer.Parallelism <= 1, "verifyChecksum", "Checksums can't be verified with parallelism")
+	}
+
+	for _, statement := range transfer.PreLoadSQL {
+		if strings.TrimSpace(statement) == "" {
+			v.AddError("preLoadSQL", "Pre-load SQL statements must not be empty")
+			break
+		}
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// The statuses that stop a transfer's dependents from ever running
+var failedDependencyStatuses = []string{"error", "cancelled", "skipped"}
+
+// Where a transfer another depends on has got to
+type DependencyState struct {
+	Status    string
+	DependsOn []int64
+}
+
+// Fetches the status and dependencies of each transfer in ids. Transfers that
+// don't exist are left out.
+func (m TransferModel) DependencyStates(ids []int64) (map[int64]DependencyState, error) {
+	states := map[int64]DependencyState{}
+	if len(ids) == 0 {
+		return states, nil
+	}
+
+	query := `
+        SELECT id, status, depends_on
+        FROM transfers
+        WHERE id = ANY($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var state DependencyState
+		err = rows.Scan(&id, &state.Status, pq.Array(&state.DependsOn))
+		if err != nil {
+			return nil, err
+		}
+		states[id] = state
+	}
+
+	return states, rows.Err()
+}
+
+// Fetches the states of ids, their dependencies, and theirs in turn, for
+// checking a new transfer's dependencies don't loop back on themselves
+func (m TransferModel) DependencyGraph(ids []int64) (map[int64]DependencyState, error) {
+	graph := map[int64]DependencyState{}
+	seen := map[int64]bool{}
+	for len(ids) > 0 {
+		states, err := m.DependencyStates(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int64
+		for _, id := range ids {
+			seen[id] = true
+		}
+		for id, state := range states {
+			graph[id] = state
+			for _, dep := range state.DependsOn {
+				if !seen[dep] {
+					seen[dep] = true
+					next = append(next, dep)
+				}
+			}
+		}
+		ids = next
+	}
+	return graph, nil
+}
+
+// Checks each transfer the transfer depends on is listed once, exists in
+// graph, and doesn't lead back to it or round in a cycle
+func ValidateDependencies(v *validator.Validator, transfer *Transfer, graph map[int64]DependencyState) {
+	seen := map[int64]bool{}
+	for _, id := range transfer.DependsOn {
+		switch {
+		case transfer.ID > 0 && id == transfer.ID:
+			v.AddError("dependsOn", "A transfer can't depend on itself")
+			return
+		case seen[id]:
+			v.AddError("dependsOn", "Depends on must not repeat a transfer")
+			return
+		}
+		seen[id] = true
+		if _, ok := graph[id]; !ok {
+			v.AddError("dependsOn", fmt.Sprintf("Transfer %d not found", id))
+			return
+		}
+	}
+
+	// the transfer itself is a node, so a dependency leading back to its ID
+	// is a cycle too
+	nodes := map[int64]DependencyState{}
+	for id, state := range graph {
+		nodes[id] = state
+	}
+	nodes[transfer.ID] = DependencyState{DependsOn: transfer.DependsOn}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := map[int64]int{}
+	var cyclic func(id int64) bool
+	cyclic = func(id int64) bool {
+		switch marks[id] {
+		case visiting:
+			return true
+		case done:
+			return false
+		}
+		marks[id] = visiting
+		for _, dep := range nodes[id].DependsOn {
+			if cyclic(dep) {
+				return true
+			}
+		}
+		marks[id] = done
+		return false
+	}
+	v.Check(!cyclic(transfer.ID), "dependsOn", "Dependencies must not form a cycle")
+}
+
+// Reports whether every transfer in dependsOn has completed, and if not,
+// the first one that failed, which means the transfer can never run.
+// Dependencies that have since been deleted don't hold it back.
+func CheckDependencies(dependsOn []int64, states map[int64]DependencyState) (ready bool, failed int64) {
+	ready = true
+	for _, id := range dependsOn {
+		state, ok := states[id]
+		switch {
+		case !ok:
+			continue
+		case validator.In(state.Status, failedDependencyStatuses...):
+			return false, id
+		case state.Status != "complete":
+			ready = false
+		}
+	}
+	return ready, 0
 }
 
 func (m TransferModel) CountTransfers() (int, error) {
@@ -83,6 +683,35 @@ func (m TransferModel) CountTransfers() (int, error) {
 }
 
 func (m TransferModel) GetAll(filters Filters) ([]*Transfer, Metadata, error) {
+	transfers := []*Transfer{}
+
+	metadata, err := m.Each(filters, func(transfer *Transfer) error {
+		transfers = append(transfers, transfer)
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return transfers, metadata, nil
+}
+
+// Like GetAll, but hands each transfer to fn as soon as it is scanned instead
+// of collecting them, stopping at the first error fn returns
+func (m TransferModel) Each(filters Filters, fn func(*Transfer) error) (Metadata, error) {
+	orderBy, err := filters.orderBy(transferSortColumns)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	args := []interface{}{filters.limit(), filters.offset()}
+	conditions, args := filters.createdAtConditions("transfers.created_at", args)
+	// a transfer has a connection's labels if its source or target does
+	labels, args := filters.labelConditions([]string{"source.labels", "target.labels"}, args)
+	conditions = append(conditions, labels...)
+	createdBy, args := filters.createdByConditions("transfers.created_by", args)
+	where := whereClause(append(conditions, createdBy...))
+
 	query := fmt.Sprintf(`
 	SELECT
 	count(*) OVER(),
@@ -102,6 +731,31 @@ func (m TransferModel) GetAll(filters Filters) ([]*Transfer, Metadata, error) {
 	transfers.target_schema,
 	transfers.target_table,
 	transfers.overwrite,
+	transfers.pre_load_sql,
+	transfers.parallelism,
+	transfers.chunk_column,
+	transfers.target_table_pattern,
+	transfers.create_target_table,
+	transfers.source_columns,
+	transfers.exclude_columns,
+	transfers.write_mode,
+	transfers.max_buffered_rows,
+	transfers.max_buffered_bytes,
+	transfers.rerun_of,
+	transfers.query_args,
+	transfers.conflict_columns,
+	transfers.target_column_order,
+	transfers.verify_checksum,
+	transfers.isolation_level,
+	transfers.statement_timeout,
+	transfers.max_errors,
+	transfers.rejected_rows,
+	transfers.rows_written,
+	transfers.source_limit,
+	transfers.sample_rate,
+	transfers.create_target_schema,
+	transfers.created_by,
+	transfers.depends_on,
 	transfers.status,
 	transfers.error,
 	transfers.error_properties,
@@ -117,51 +771,75 @@ left join
 	connections target
 on
 	transfers.target_id = target.id
+%s
 order by
-	%s %s,
-	id asc
+	%s,
+	transfers.id asc
 limit
 	$1
 offset
 	$2
-`, filters.sortColumn(), filters.sortDirection())
+`, where, orderBy)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{filters.limit(), filters.offset()}
-
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, Metadata{}, err
+		return Metadata{}, err
 	}
 
 	defer rows.Close()
 
 	totalRecords := 0
-	transfers := []*Transfer{}
 
 	for rows.Next() {
 		var transfer Transfer
+		var source, target joinedConnection
 
 		err := rows.Scan(
 			&totalRecords,
 			&transfer.ID,
 			&transfer.CreatedAt,
 			&transfer.SourceID,
-			&transfer.Source.Name,
-			&transfer.Source.DsType,
-			&transfer.Source.AccountId,
-			&transfer.Source.DbName,
+			&source.name,
+			&source.dsType,
+			&source.accountId,
+			&source.dbName,
 			&transfer.TargetID,
-			&transfer.Target.Name,
-			&transfer.Target.DsType,
-			&transfer.Target.AccountId,
-			&transfer.Target.DbName,
+			&target.name,
+			&target.dsType,
+			&target.accountId,
+			&target.dbName,
 			&transfer.Query,
 			&transfer.TargetSchema,
 			&transfer.TargetTable,
 			&transfer.Overwrite,
+			pq.Array(&transfer.PreLoadSQL),
+			&transfer.Parallelism,
+			&transfer.ChunkColumn,
+			&transfer.TargetTablePattern,
+			&transfer.CreateTargetTable,
+			pq.Array(&transfer.SourceColumns),
+			pq.Array(&transfer.ExcludeColumns),
+			&transfer.WriteMode,
+			&transfer.MaxBufferedRows,
+			&transfer.MaxBufferedBytes,
+			&transfer.RerunOf,
+			&transfer.QueryArgs,
+			pq.Array(&transfer.ConflictColumns),
+			pq.Array(&transfer.TargetColumnOrder),
+			&transfer.VerifyChecksum,
+			&transfer.IsolationLevel,
+			&transfer.StatementTimeout,
+			&transfer.MaxErrors,
+			&transfer.RejectedRows,
+			&transfer.RowsWritten,
+			&transfer.SourceLimit,
+			&transfer.SampleRate,
+			&transfer.CreateTargetSchema,
+			&transfer.CreatedBy,
+			pq.Array(&transfer.DependsOn),
 			&transfer.Status,
 			&transfer.Error,
 			&transfer.ErrorProperties,
@@ -169,19 +847,23 @@ offset
 			&transfer.Version,
 		)
 		if err != nil {
-			return nil, Metadata{}, err
+			return Metadata{}, err
 		}
 
-		transfers = append(transfers, &transfer)
+		source.apply(&transfer.Source)
+		target.apply(&transfer.Target)
+
+		err = fn(&transfer)
+		if err != nil {
+			return Metadata{}, err
+		}
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
+		return Metadata{}, err
 	}
 
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
-
-	return transfers, metadata, nil
+	return calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
 }
 
 func (m TransferModel) GetQueued() ([]*Transfer, error) {
@@ -197,6 +879,10 @@ func (m TransferModel) GetQueued() ([]*Transfer, error) {
 	source.Db_Name,
 	source.Username,
 	source.Password,
+	source.Default_Schema,
+	source.Keepalive,
+	source.Connect_Timeout,
+	source.Ssl_Mode,
 	target.ID,
 	target.Ds_Type,
 	target.Hostname,
@@ -205,10 +891,38 @@ func (m TransferModel) GetQueued() ([]*Transfer, error) {
 	target.Db_Name,
 	target.Username,
 	target.Password,
+	target.Default_Schema,
+	target.Keepalive,
+	target.Connect_Timeout,
+	target.Ssl_Mode,
 	transfers.query,
 	transfers.target_schema,
 	transfers.target_table,
 	transfers.overwrite,
+	transfers.pre_load_sql,
+	transfers.parallelism,
+	transfers.chunk_column,
+	transfers.target_table_pattern,
+	transfers.create_target_table,
+	transfers.source_columns,
+	transfers.exclude_columns,
+	transfers.write_mode,
+	transfers.max_buffered_rows,
+	transfers.max_buffered_bytes,
+	transfers.rerun_of,
+	transfers.query_args,
+	transfers.conflict_columns,
+	transfers.target_column_order,
+	transfers.verify_checksum,
+	transfers.isolation_level,
+	transfers.statement_timeout,
+	transfers.max_errors,
+	transfers.rejected_rows,
+	transfers.source_limit,
+	transfers.sample_rate,
+	transfers.create_target_schema,
+	transfers.depends_on,
+	transfers.request_id,
 	transfers.version
 FROM
 	transfers
@@ -252,6 +966,10 @@ order by
 			&transfer.Source.DbName,
 			&transfer.Source.Username,
 			&transfer.Source.Password,
+			&transfer.Source.DefaultSchema,
+			&transfer.Source.Keepalive,
+			&transfer.Source.ConnectTimeout,
+			&transfer.Source.SSLMode,
 			&transfer.Target.ID,
 			&transfer.Target.DsType,
 			&transfer.Target.Hostname,
@@ -260,10 +978,38 @@ order by
 			&transfer.Target.DbName,
 			&transfer.Target.Username,
 			&transfer.Target.Password,
+			&transfer.Target.DefaultSchema,
+			&transfer.Target.Keepalive,
+			&transfer.Target.ConnectTimeout,
+			&transfer.Target.SSLMode,
 			&transfer.Query,
 			&transfer.TargetSchema,
 			&transfer.TargetTable,
 			&transfer.Overwrite,
+			pq.Array(&transfer.PreLoadSQL),
+			&transfer.Parallelism,
+			&transfer.ChunkColumn,
+			&transfer.TargetTablePattern,
+			&transfer.CreateTargetTable,
+			pq.Array(&transfer.SourceColumns),
+			pq.Array(&transfer.ExcludeColumns),
+			&transfer.WriteMode,
+			&transfer.MaxBufferedRows,
+			&transfer.MaxBufferedBytes,
+			&transfer.RerunOf,
+			&transfer.QueryArgs,
+			pq.Array(&transfer.ConflictColumns),
+			pq.Array(&transfer.TargetColumnOrder),
+			&transfer.VerifyChecksum,
+			&transfer.IsolationLevel,
+			&transfer.StatementTimeout,
+			&transfer.MaxErrors,
+			&transfer.RejectedRows,
+			&transfer.SourceLimit,
+			&transfer.SampleRate,
+			&transfer.CreateTargetSchema,
+			pq.Array(&transfer.DependsOn),
+			&transfer.RequestID,
 			&transfer.Version,
 		)
 		if err != nil {
@@ -299,6 +1045,31 @@ func (m TransferModel) GetById(id int64) (*Transfer, error) {
 	transfers.target_schema,
 	transfers.target_table,
 	transfers.overwrite,
+	transfers.pre_load_sql,
+	transfers.parallelism,
+	transfers.chunk_column,
+	transfers.target_table_pattern,
+	transfers.create_target_table,
+	transfers.source_columns,
+	transfers.exclude_columns,
+	transfers.write_mode,
+	transfers.max_buffered_rows,
+	transfers.max_buffered_bytes,
+	transfers.rerun_of,
+	transfers.query_args,
+	transfers.conflict_columns,
+	transfers.target_column_order,
+	transfers.verify_checksum,
+	transfers.isolation_level,
+	transfers.statement_timeout,
+	transfers.max_errors,
+	transfers.rejected_rows,
+	transfers.rows_written,
+	transfers.source_limit,
+	transfers.sample_rate,
+	transfers.create_target_schema,
+	transfers.created_by,
+	transfers.depends_on,
 	transfers.status,
 	transfers.error,
 	transfers.error_properties,
@@ -318,6 +1089,7 @@ where transfers.id = $1
 `
 
 	var transfer Transfer
+	var source, target joinedConnection
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -326,19 +1098,44 @@ where transfers.id = $1
 		&transfer.ID,
 		&transfer.CreatedAt,
 		&transfer.SourceID,
-		&transfer.Source.Name,
-		&transfer.Source.DsType,
-		&transfer.Source.AccountId,
-		&transfer.Source.DbName,
+		&source.name,
+		&source.dsType,
+		&source.accountId,
+		&source.dbName,
 		&transfer.TargetID,
-		&transfer.Target.Name,
-		&transfer.Target.DsType,
-		&transfer.Target.AccountId,
-		&transfer.Target.DbName,
+		&target.name,
+		&target.dsType,
+		&target.accountId,
+		&target.dbName,
 		&transfer.Query,
 		&transfer.TargetSchema,
 		&transfer.TargetTable,
 		&transfer.Overwrite,
+		pq.Array(&transfer.PreLoadSQL),
+		&transfer.Parallelism,
+		&transfer.ChunkColumn,
+		&transfer.TargetTablePattern,
+		&transfer.CreateTargetTable,
+		pq.Array(&transfer.SourceColumns),
+		pq.Array(&transfer.ExcludeColumns),
+		&transfer.WriteMode,
+		&transfer.MaxBufferedRows,
+		&transfer.MaxBufferedBytes,
+		&transfer.RerunOf,
+		&transfer.QueryArgs,
+		pq.Array(&transfer.ConflictColumns),
+		pq.Array(&transfer.TargetColumnOrder),
+		&transfer.VerifyChecksum,
+		&transfer.IsolationLevel,
+		&transfer.StatementTimeout,
+		&transfer.MaxErrors,
+		&transfer.RejectedRows,
+		&transfer.RowsWritten,
+		&transfer.SourceLimit,
+		&transfer.SampleRate,
+		&transfer.CreateTargetSchema,
+		&transfer.CreatedBy,
+		pq.Array(&transfer.DependsOn),
 		&transfer.Status,
 		&transfer.Error,
 		&transfer.ErrorProperties,
@@ -355,14 +1152,17 @@ where transfers.id = $1
 		}
 	}
 
+	source.apply(&transfer.Source)
+	target.apply(&transfer.Target)
+
 	return &transfer, nil
 }
 
 func (m TransferModel) Update(transfer *Transfer) error {
 	query := `
         UPDATE transfers 
-        SET status = $1, error = $2, error_properties = $3, stopped_at = $4, version = version + 1
-        WHERE id = $5 AND version = $6
+        SET status = $1, error = $2, error_properties = $3, stopped_at = $4, rejected_rows = $5, version = version + 1
+        WHERE id = $6 AND version = $7
         RETURNING version`
 
 	args := []interface{}{
@@ -370,6 +1170,7 @@ func (m TransferModel) Update(transfer *Transfer) error {
 		&transfer.Error,
 		&transfer.ErrorProperties,
 		&transfer.StoppedAt,
+		&transfer.RejectedRows,
 		&transfer.ID,
 		&transfer.Version,
 	}
@@ -390,6 +1191,46 @@ func (m TransferModel) Update(transfer *Transfer) error {
 	return nil
 }
 
+// How many times UpdateWithRetry re-reads a transfer after an edit conflict
+const maxUpdateAttempts = 5
+
+// Fetches the transfer, applies mutate, and saves it, starting over with a
+// fresh read whenever another writer got there first
+func (m TransferModel) UpdateWithRetry(id int64, mutate func(*Transfer) error) (*Transfer, error) {
+	return updateWithRetry(m.GetById, m.Update, id, mutate)
+}
+
+func updateWithRetry(
+	get func(int64) (*Transfer, error),
+	update func(*Transfer) error,
+	id int64,
+	mutate func(*Transfer) error,
+) (*Transfer, error) {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		transfer, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		err = mutate(transfer)
+		if err != nil {
+			return nil, err
+		}
+
+		err = update(transfer)
+		switch {
+		case err == nil:
+			return transfer, nil
+		case errors.Is(err, ErrEditConflict):
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, ErrEditConflict
+}
+
 func (m TransferModel) Delete(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
@@ -418,3 +1259,79 @@ func (m TransferModel) Delete(id int64) error {
 
 	return nil
 }
+
+// Checks a bulk delete names its transfers either by id, or by a finished
+// status and created_at bounds, but not both. Naming none would delete every
+// finished transfer, so it's refused.
+func ValidateDeleteMany(v *validator.Validator, ids []int64, filters Filters) {
+	byFilter := filters.Status != "" || !filters.CreatedAfter.IsZero() || !filters.CreatedBefore.IsZero()
+	v.Check(len(ids) > 0 || byFilter, "ids", "Transfer ids, or a status or date to delete by, are required")
+	v.Check(len(ids) == 0 || !byFilter, "ids", "Transfer ids can't be given with a status or date")
+	for _, id := range ids {
+		if id < 1 {
+			v.AddError("ids", "Transfer ids must be integers greater than 0")
+			break
+		}
+	}
+	if filters.Status != "" {
+		v.Check(validator.In(filters.Status, FinishedTransferStatuses...), "status", "Only complete, error, cancelled or skipped transfers can be deleted")
+	}
+}
+
+// Deletes the transfers with ids, or if there are none, every finished
+// transfer matching the filters' status and created_at bounds, in a single
+// transaction. Returns how many were deleted. Only finished transfers are
+// deleted: if an id names a queued or active one, nothing is, and the error
+// is ErrTransferNotFinished.
+func (m TransferModel) DeleteMany(ids []int64, filters Filters) (int64, error) {
+	args := []interface{}{pq.Array(FinishedTransferStatuses)}
+	conditions := []string{"status = any($1)"}
+	if len(ids) > 0 {
+		args = append(args, pq.Array(ids))
+		conditions = append(conditions, "id = any($2)")
+	} else {
+		var more []string
+		more, args = filters.statusConditions("status", args)
+		conditions = append(conditions, more...)
+		more, args = filters.createdAtConditions("created_at", args)
+		conditions = append(conditions, more...)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM transfers "+whereClause(conditions), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// listed transfers that are left weren't finished
+	if len(ids) > 0 {
+		var unfinished int
+		err = tx.QueryRowContext(ctx, "SELECT count(*) FROM transfers WHERE id = any($1)", pq.Array(ids)).Scan(&unfinished)
+		if err != nil {
+			return 0, err
+		}
+		if unfinished > 0 {
+			return 0, ErrTransferNotFinished
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,455 @@
+package data
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/validator"
+)
+
+func TestUpdateWithRetry(t *testing.T) {
+	stored := Transfer{ID: 1, Status: "queued", Version: 1}
+
+	get := func(id int64) (*Transfer, error) {
+		transfer := stored
+		return &transfer, nil
+	}
+
+	// another writer bumps the version between our first read and update
+	conflicts := 1
+	update := func(transfer *Transfer) error {
+		if conflicts > 0 {
+			conflicts--
+			stored.Version++
+			return ErrEditConflict
+		}
+		if transfer.Version != stored.Version {
+			return ErrEditConflict
+		}
+		transfer.Version++
+		stored = *transfer
+		return nil
+	}
+
+	mutations := 0
+	transfer, err := updateWithRetry(get, update, 1, func(transfer *Transfer) error {
+		mutations++
+		transfer.Status = "cancelled"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mutations != 2 {
+		t.Errorf("wanted the mutation applied to a fresh read after the conflict, got %d applications", mutations)
+	}
+	if transfer.Status != "cancelled" || stored.Status != "cancelled" {
+		t.Errorf("wanted status cancelled, got %q", stored.Status)
+	}
+	if stored.Version != 3 {
+		t.Errorf("wanted version 3, got %d", stored.Version)
+	}
+}
+
+func TestUpdateWithRetryGivesUp(t *testing.T) {
+	get := func(id int64) (*Transfer, error) { return &Transfer{ID: id}, nil }
+	update := func(transfer *Transfer) error { return ErrEditConflict }
+
+	_, err := updateWithRetry(get, update, 1, func(*Transfer) error { return nil })
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("wanted ErrEditConflict, got %v", err)
+	}
+}
+
+func TestJoinedConnectionApply(t *testing.T) {
+	var deleted Connection
+	joinedConnection{}.apply(&deleted)
+	if deleted.Name != "(deleted)" {
+		t.Errorf("wanted deleted marker, got %q", deleted.Name)
+	}
+
+	var live Connection
+	joinedConnection{
+		name:   sql.NullString{String: "warehouse", Valid: true},
+		dsType: sql.NullString{String: "postgresql", Valid: true},
+		dbName: sql.NullString{String: "analytics", Valid: true},
+	}.apply(&live)
+	if live.Name != "warehouse" || live.DsType != "postgresql" || live.DbName != "analytics" || live.AccountId != "" {
+		t.Errorf("unexpected connection %+v", live)
+	}
+}
+
+func TestValidateTransferCollectsAllErrors(t *testing.T) {
+	valid := &Transfer{SourceID: 1, TargetID: 2, Query: "select 1", TargetSchema: "public", TargetTable: "orders_2022"}
+	v := validator.New()
+	if ValidateTransfer(v, valid); !v.Valid() {
+		t.Errorf("wanted a valid transfer, got %v", v.Errors)
+	}
+
+	invalid := &Transfer{TargetSchema: "public; drop table users", TargetTable: "orders"}
+	v = validator.New()
+	ValidateTransfer(v, invalid)
+	for _, key := range []string{"sourceId", "targetId", "query", "targetSchema"} {
+		if _, ok := v.Errors[key]; !ok {
+			t.Errorf("wanted an error for %s, got %v", key, v.Errors)
+		}
+	}
+}
+
+func TestValidateTransferColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer Transfer
+		wantKey  string
+	}{
+		{"include and exclude", Transfer{SourceColumns: []string{"id"}, ExcludeColumns: []string{"body"}}, "excludeColumns"},
+		{"not an identifier", Transfer{SourceColumns: []string{"id, (select 1)"}}, "sourceColumns"},
+		{"dollar sign", Transfer{SourceColumns: []string{"price$"}}, "sourceColumns"},
+		{"target table with a hash", Transfer{TargetTable: "orders#2022"}, "targetTable"},
+		{"chunk column not included", Transfer{SourceColumns: []string{"name"}, Parallelism: 4, ChunkColumn: "id"}, "chunkColumn"},
+		{"chunk column excluded", Transfer{ExcludeColumns: []string{"ID"}, Parallelism: 4, ChunkColumn: "id"}, "chunkColumn"},
+		{"order not a permutation", Transfer{SourceColumns: []string{"id", "name"}, TargetColumnOrder: []string{"name"}}, "targetColumnOrder"},
+		{"order repeats a column", Transfer{TargetColumnOrder: []string{"id", "ID"}}, "targetColumnOrder"},
+		{"order includes an excluded column", Transfer{ExcludeColumns: []string{"body"}, TargetColumnOrder: []string{"body", "id"}}, "targetColumnOrder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTransfer(v, &tt.transfer)
+			if _, ok := v.Errors[tt.wantKey]; !ok {
+				t.Errorf("wanted an error for %s, got %v", tt.wantKey, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateSourceSampling(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer Transfer
+		wantKey  string
+	}{
+		{"negative limit", Transfer{SourceLimit: -1}, "sourceLimit"},
+		{"rate above 1", Transfer{SampleRate: 1.5}, "sampleRate"},
+		{"limit and rate", Transfer{SourceLimit: 100, SampleRate: 0.5}, "sampleRate"},
+		{"limit with parallelism", Transfer{SourceLimit: 100, Parallelism: 4, ChunkColumn: "id"}, "sourceLimit"},
+		{"rate with checksum", Transfer{SampleRate: 0.5, VerifyChecksum: true}, "sourceLimit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateTransfer(v, &tt.transfer)
+			if _, ok := v.Errors[tt.wantKey]; !ok {
+				t.Errorf("wanted an error for %s, got %v", tt.wantKey, v.Errors)
+			}
+		})
+	}
+}
+
+func TestTransferMode(t *testing.T) {
+	tests := []struct {
+		transfer Transfer
+		want     string
+	}{
+		{Transfer{}, WriteModeAppend},
+		{Transfer{Overwrite: true}, WriteModeTruncate},
+		{Transfer{WriteMode: WriteModeTruncate}, WriteModeTruncate},
+		{Transfer{Overwrite: true, WriteMode: WriteModeTruncate}, WriteModeTruncate},
+	}
+	for _, tt := range tests {
+		if got := tt.transfer.Mode(); got != tt.want {
+			t.Errorf("%+v: wanted %s, got %s", tt.transfer, tt.want, got)
+		}
+	}
+
+	for _, transfer := range []Transfer{
+		{WriteMode: "merge"},
+		{Overwrite: true, WriteMode: WriteModeRecreate},
+		{WriteMode: WriteModeTruncate, TargetFile: "-"},
+	} {
+		v := validator.New()
+		ValidateTransfer(v, &transfer)
+		if _, ok := v.Errors["writeMode"]; !ok {
+			t.Errorf("%+v: wanted a writeMode error, got %v", transfer, v.Errors)
+		}
+	}
+}
+
+func TestValidateUpsert(t *testing.T) {
+	valid := Transfer{SourceID: 1, TargetID: 2, Query: "select * from t", TargetTable: "t", WriteMode: WriteModeUpsert, ConflictColumns: []string{"id"}}
+	v := validator.New()
+	ValidateTransfer(v, &valid)
+	if !v.Valid() {
+		t.Fatalf("wanted a valid upsert, got %v", v.Errors)
+	}
+
+	for _, tt := range []struct {
+		change func(*Transfer)
+		key    string
+	}{
+		{func(t *Transfer) { t.ConflictColumns = nil }, "conflictColumns"},
+		{func(t *Transfer) { t.ConflictColumns = []string{"id; drop table t"} }, "conflictColumns"},
+		{func(t *Transfer) { t.SourceColumns = []string{"name"} }, "conflictColumns"},
+		{func(t *Transfer) { t.ExcludeColumns = []string{"ID"} }, "conflictColumns"},
+		{func(t *Transfer) { t.WriteMode = WriteModeAppend }, "conflictColumns"},
+		{func(t *Transfer) { t.TargetTable, t.TargetFile = "", "out.csv" }, "writeMode"},
+	} {
+		transfer := valid
+		tt.change(&transfer)
+		v := validator.New()
+		ValidateTransfer(v, &transfer)
+		if _, ok := v.Errors[tt.key]; !ok {
+			t.Errorf("%+v: wanted a %s error, got %v", transfer, tt.key, v.Errors)
+		}
+	}
+}
+
+func TestTransferRerun(t *testing.T) {
+	original := Transfer{
+		ID:            4,
+		SourceID:      1,
+		TargetID:      2,
+		Query:         "select * from t",
+		QueryArgs:     QueryArgs{{Type: QueryArgDate, Value: "2022-01-01"}},
+		TargetTable:   "t",
+		WriteMode:     WriteModeTruncate,
+		PreLoadSQL:    []string{"delete from t"},
+		SourceColumns: []string{"id"},
+		Status:        "error",
+		Error:         "target unreachable",
+		StoppedAt:     time.Now(),
+		Version:       3,
+	}
+
+	rerun := original.Rerun()
+	if rerun.RerunOf != 4 || rerun.ID != 0 || rerun.Status != "" || rerun.Error != "" || rerun.Version != 0 {
+		t.Errorf("wanted a new transfer linked to the original, got %+v", rerun)
+	}
+
+	rerun.RerunOf = 0
+	want := Transfer{
+		SourceID:        1,
+		TargetID:        2,
+		Query:           "select * from t",
+		QueryArgs:       QueryArgs{{Type: QueryArgDate, Value: "2022-01-01"}},
+		TargetTable:     "t",
+		WriteMode:       WriteModeTruncate,
+		PreLoadSQL:      []string{"delete from t"},
+		SourceColumns:   []string{"id"},
+		ExcludeColumns:  []string{},
+		ConflictColumns: []string{},
+
+		TargetColumnOrder: []string{},
+	}
+	if !reflect.DeepEqual(*rerun, want) {
+		t.Errorf("wanted definition %+v, got %+v", want, *rerun)
+	}
+
+	rerun.PreLoadSQL[0] = "truncate t"
+	if original.PreLoadSQL[0] != "delete from t" {
+		t.Error("wanted the original left untouched")
+	}
+}
+
+func TestTransferLogRollsOff(t *testing.T) {
+	var log TransferLog
+	for i := 0; i < maxLogLines+10; i++ {
+		log.Add("batch %d", i)
+	}
+	if len(log.Lines) != maxLogLines || log.Dropped != 10 {
+		t.Fatalf("wanted %d lines and 10 dropped, got %d and %d", maxLogLines, len(log.Lines), log.Dropped)
+	}
+	if !strings.HasSuffix(log.Lines[0], " batch 10") {
+		t.Errorf("wanted the oldest lines rolled off, got %q first", log.Lines[0])
+	}
+
+	log = TransferLog{}
+	long := strings.Repeat("x", maxLogBytes/3)
+	for i := 0; i < 4; i++ {
+		log.Add("%s", long)
+	}
+	log.Add("complete")
+	size := 0
+	for _, line := range log.Lines {
+		size += len(line)
+	}
+	if size > maxLogBytes || log.Dropped != 2 || !strings.HasSuffix(log.Lines[len(log.Lines)-1], " complete") {
+		t.Errorf("wanted the log kept within %d bytes, got %d bytes, %d dropped", maxLogBytes, size, log.Dropped)
+	}
+}
+
+func TestValidateIsolationLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer Transfer
+		dsType   string
+		valid    bool
+	}{
+		{"none", Transfer{}, "snowflake", true},
+		{"supported", Transfer{IsolationLevel: IsolationRepeatableRead}, "postgresql", true},
+		{"snapshot on sql server", Transfer{IsolationLevel: IsolationSnapshot}, "mssql", true},
+		{"source not known yet", Transfer{IsolationLevel: IsolationSnapshot}, "", true},
+		{"unknown", Transfer{IsolationLevel: "chaos"}, "", false},
+		{"unsupported by the driver", Transfer{IsolationLevel: IsolationSnapshot}, "postgresql", false},
+		{"snowflake", Transfer{IsolationLevel: IsolationReadCommitted}, "snowflake", false},
+		{"parallel chunks on postgresql", Transfer{IsolationLevel: IsolationSerializable, Parallelism: 4}, "postgresql", true},
+		{"parallel chunks read committed", Transfer{IsolationLevel: IsolationReadCommitted, Parallelism: 4}, "postgresql", false},
+		{"parallel chunks elsewhere", Transfer{IsolationLevel: IsolationSerializable, Parallelism: 4}, "mysql", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateIsolationLevel(v, &tt.transfer, tt.dsType)
+			if v.Valid() != tt.valid {
+				t.Errorf("wanted valid %t, got errors %v", tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateStatementTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer Transfer
+		dsType   string
+		valid    bool
+	}{
+		{"none", Transfer{}, "oracle", true},
+		{"postgresql", Transfer{StatementTimeout: 60}, "postgresql", true},
+		{"mysql", Transfer{StatementTimeout: 60}, "mysql", true},
+		{"source not known yet", Transfer{StatementTimeout: 60}, "", true},
+		{"negative", Transfer{StatementTimeout: -1}, "", false},
+		{"sql server", Transfer{StatementTimeout: 60}, "mssql", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateStatementTimeout(v, &tt.transfer, tt.dsType)
+			if v.Valid() != tt.valid {
+				t.Errorf("wanted valid %t, got errors %v", tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateCompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer Transfer
+		valid    bool
+	}{
+		{"none", Transfer{TargetFile: "out.csv"}, true},
+		{"zstd", Transfer{TargetFile: "out.csv", Compress: CompressZstd}, true},
+		{"gzip matching extension", Transfer{TargetFile: "out.csv.gz", Compress: CompressGzip}, true},
+		{"zstd into a .gz file", Transfer{TargetFile: "out.csv.gz", Compress: CompressZstd}, false},
+		{"unknown", Transfer{TargetFile: "out.csv", Compress: "bzip2"}, false},
+		{"table target", Transfer{TargetTable: "events", Compress: CompressGzip}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateCompress(v, &tt.transfer)
+			if v.Valid() != tt.valid {
+				t.Errorf("wanted valid %t, got errors %v", tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestValidateDependencies(t *testing.T) {
+	// 3 depends on 2, which depends on 1, and 5 and 6 depend on each other,
+	// as no transfer created through the API could
+	graph := map[int64]DependencyState{
+		1: {Status: "complete"},
+		2: {Status: "queued", DependsOn: []int64{1}},
+		3: {Status: "queued", DependsOn: []int64{2}},
+		5: {Status: "queued", DependsOn: []int64{6}},
+		6: {Status: "queued", DependsOn: []int64{5}},
+	}
+
+	tests := []struct {
+		name     string
+		transfer Transfer
+		valid    bool
+	}{
+		{"none", Transfer{}, true},
+		{"chain", Transfer{DependsOn: []int64{3}}, true},
+		{"several", Transfer{DependsOn: []int64{1, 3}}, true},
+		{"missing", Transfer{DependsOn: []int64{4}}, false},
+		{"repeated", Transfer{DependsOn: []int64{1, 1}}, false},
+		{"itself", Transfer{ID: 2, DependsOn: []int64{2}}, false},
+		{"back to itself", Transfer{ID: 1, DependsOn: []int64{3}}, false},
+		{"existing cycle", Transfer{DependsOn: []int64{5}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateDependencies(v, &tt.transfer, graph)
+			if v.Valid() != tt.valid {
+				t.Errorf("wanted valid %t, got errors %v", tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	states := map[int64]DependencyState{
+		1: {Status: "complete"},
+		2: {Status: "active"},
+		3: {Status: "cancelled"},
+	}
+
+	tests := []struct {
+		name      string
+		dependsOn []int64
+		ready     bool
+		failed    int64
+	}{
+		{"none", nil, true, 0},
+		{"complete", []int64{1}, true, 0},
+		{"deleted", []int64{1, 4}, true, 0},
+		{"running", []int64{1, 2}, false, 0},
+		{"failed", []int64{2, 3}, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, failed := CheckDependencies(tt.dependsOn, states)
+			if ready != tt.ready || failed != tt.failed {
+				t.Errorf("wanted ready %t and failed %d, got %t and %d", tt.ready, tt.failed, ready, failed)
+			}
+		})
+	}
+}
+
+func TestGetQueuedLoadsSSLModes(t *testing.T) {
+	connection := func(id int64, dsType string, sslMode string) []driver.Value {
+		return []driver.Value{id, dsType, "db.internal", int64(5432), "", "app", "user", "pass", "", int64(0), int64(0), sslMode}
+	}
+	db := newFakeDB(t, func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		row := []driver.Value{int64(1), time.Now()}
+		row = append(row, connection(1, "postgresql", "verify-full")...)
+		row = append(row, connection(2, "mysql", "require")...)
+		row = append(row,
+			"select 1", "public", "t", false, []byte("{}"), int64(0), "", "", false, []byte("{}"), []byte("{}"), "",
+			int64(0), int64(0), int64(0), []byte("[]"), []byte("{}"), []byte("{}"), false, "", int64(0), int64(0), int64(0),
+			int64(0), float64(0), false, []byte("{}"), "", int64(1),
+		)
+		return make([]string, len(row)), [][]driver.Value{row}, nil
+	})
+
+	transfers, err := TransferModel{DB: db}.GetQueued()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 1 || transfers[0].Source.SSLMode != "verify-full" || transfers[0].Target.SSLMode != "require" {
+		t.Errorf("wanted the connections' SSL modes loaded with the queued transfer, got %+v", transfers)
+	}
+}
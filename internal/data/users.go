@@ -8,6 +8,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/lib/pq"
 	"github.com/sqlpipe/sqlpipe/internal/validator"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -15,16 +16,20 @@ import (
 var (
 	ErrDuplicateUsername  = errors.New("duplicate username")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
+	ErrPasswordReused     = errors.New("password was used recently")
 	AnonymousUser         = &User{}
 )
 
 type User struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"createdAt"`
-	Username  string    `json:"username"`
-	Password  password  `json:"-"`
-	Admin     bool      `json:"admin"`
-	Version   int       `json:"-"`
+	ID              int64     `json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Username        string    `json:"username"`
+	Password        password  `json:"-"`
+	PasswordHistory [][]byte  `json:"-"`
+	Admin           bool      `json:"admin"`
+	// Set when the user was disabled instead of deleted
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	Version   int        `json:"-"`
 }
 
 type password struct {
@@ -36,6 +41,12 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+// Disabled users keep their row, so their history stays intact, but can't
+// authenticate
+func (u *User) IsDisabled() bool {
+	return u.DeletedAt != nil
+}
+
 func (p *password) Set(plaintextPassword string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
 	if err != nil {
@@ -62,6 +73,44 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
+// Changes the user's password, refusing any of their last historySize
+// passwords (including the current one). The replaced hash is kept so it can
+// be checked against on the next change. A historySize of 0 disables the check.
+func (u *User) SetPassword(plaintextPassword string, historySize int) error {
+	var recent [][]byte
+	if historySize > 0 && u.Password.hash != nil {
+		recent = append([][]byte{u.Password.hash}, u.PasswordHistory...)
+		if len(recent) > historySize {
+			recent = recent[:historySize]
+		}
+	}
+
+	for _, hash := range recent {
+		err := bcrypt.CompareHashAndPassword(hash, []byte(plaintextPassword))
+		switch {
+		case err == nil:
+			return ErrPasswordReused
+		case !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return err
+		}
+	}
+
+	err := u.Password.Set(plaintextPassword)
+	if err != nil {
+		return err
+	}
+
+	if historySize > 0 {
+		// the new password takes one of the historySize slots
+		if len(recent) > historySize-1 {
+			recent = recent[:historySize-1]
+		}
+		u.PasswordHistory = recent
+	}
+
+	return nil
+}
+
 func ValidateUsername(v *validator.Validator, username string) {
 	if username != "" {
 		v.Check(validator.Matches(username, validator.UsernameRX), "username", "Username must be 5-30 characters, contain alphanumeric characters or underscores, and first letter must be a letter")
@@ -119,7 +168,7 @@ func (m UserModel) Insert(user *User) (*User, error) {
 
 func (m UserModel) GetByUsername(username string) (*User, error) {
 	query := `
-        SELECT id, created_at, username, password_hash, admin, version
+        SELECT id, created_at, username, password_hash, password_history, admin, deleted_at, version
         FROM users
         WHERE username = $1`
 
@@ -133,7 +182,9 @@ func (m UserModel) GetByUsername(username string) (*User, error) {
 		&user.CreatedAt,
 		&user.Username,
 		&user.Password.hash,
+		pq.Array(&user.PasswordHistory),
 		&user.Admin,
+		&user.DeletedAt,
 		&user.Version,
 	)
 
@@ -151,7 +202,7 @@ func (m UserModel) GetByUsername(username string) (*User, error) {
 
 func (m UserModel) GetById(id int64) (*User, error) {
 	query := `
-        SELECT id, created_at, username, password_hash, admin, version
+        SELECT id, created_at, username, password_hash, password_history, admin, deleted_at, version
         FROM users
         WHERE id = $1`
 
@@ -165,7 +216,9 @@ func (m UserModel) GetById(id int64) (*User, error) {
 		&user.CreatedAt,
 		&user.Username,
 		&user.Password.hash,
+		pq.Array(&user.PasswordHistory),
 		&user.Admin,
+		&user.DeletedAt,
 		&user.Version,
 	)
 
@@ -184,13 +237,18 @@ func (m UserModel) GetById(id int64) (*User, error) {
 func (m UserModel) Update(user *User) error {
 	query := `
         UPDATE users 
-        SET username = $1, password_hash = $2, admin = $3, version = version + 1
-        WHERE id = $4 AND version = $5
+        SET username = $1, password_hash = $2, password_history = $3, admin = $4, version = version + 1
+        WHERE id = $5 AND version = $6
         RETURNING version`
 
+	if user.PasswordHistory == nil {
+		user.PasswordHistory = [][]byte{}
+	}
+
 	args := []interface{}{
 		user.Username,
 		user.Password.hash,
+		pq.Array(user.PasswordHistory),
 		user.Admin,
 		user.ID,
 		user.Version,
@@ -214,17 +272,30 @@ func (m UserModel) Update(user *User) error {
 	return nil
 }
 
-func (m UserModel) GetAll(filters Filters) ([]*User, Metadata, error) {
+// The columns users can be sorted by, keyed by sort name
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"created_at": "created_at",
+}
+
+// Disabled users are only included if includeDisabled is set
+func (m UserModel) GetAll(filters Filters, includeDisabled bool) ([]*User, Metadata, error) {
+	orderBy, err := filters.orderBy(userSortColumns)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
 	query := fmt.Sprintf(`
-        SELECT count(*) OVER(), id, created_at, username, admin, version
+        SELECT count(*) OVER(), id, created_at, username, admin, deleted_at, version
         FROM users
-        ORDER BY %s %s, id ASC
-        LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+        WHERE ($3 OR deleted_at IS NULL)
+        ORDER BY %s, id ASC
+        LIMIT $1 OFFSET $2`, orderBy)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{filters.limit(), filters.offset()}
+	args := []interface{}{filters.limit(), filters.offset(), includeDisabled}
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -245,6 +316,7 @@ func (m UserModel) GetAll(filters Filters) ([]*User, Metadata, error) {
 			&user.CreatedAt,
 			&user.Username,
 			&user.Admin,
+			&user.DeletedAt,
 			&user.Version,
 		)
 		if err != nil {
@@ -258,11 +330,53 @@ func (m UserModel) GetAll(filters Filters) ([]*User, Metadata, error) {
 		return nil, Metadata{}, err
 	}
 
+	// a page past the end has no rows to carry count(*) OVER(), so count
+	// separately to still report the total
+	if len(users) == 0 && filters.offset() > 0 {
+		totalRecords, err = m.CountUsers(includeDisabled)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 
 	return users, metadata, nil
 }
 
+func (m UserModel) CountUsers(includeDisabled bool) (int, error) {
+	query := `select count(*) from users where ($1 or deleted_at is null)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var numUsers int
+
+	err := m.DB.QueryRowContext(ctx, query, includeDisabled).Scan(&numUsers)
+	if err != nil {
+		return 0, err
+	}
+
+	return numUsers, nil
+}
+
+// Counts the admins who aren't disabled
+func (m UserModel) CountAdmins() (int, error) {
+	query := `select count(*) from users where admin and deleted_at is null`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var numAdmins int
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&numAdmins)
+	if err != nil {
+		return 0, err
+	}
+
+	return numAdmins, nil
+}
+
 func (m UserModel) Delete(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
@@ -292,11 +406,53 @@ func (m UserModel) Delete(id int64) error {
 	return nil
 }
 
+// Disables a user instead of deleting it. Disabling a user that is already
+// disabled returns ErrRecordNotFound.
+func (m UserModel) SoftDelete(id int64) error {
+	return m.setDeletedAt(id, `
+			UPDATE users
+			SET deleted_at = NOW(), version = version + 1
+			WHERE id = $1 AND deleted_at IS NULL`)
+}
+
+// Re-enables a disabled user
+func (m UserModel) Restore(id int64) error {
+	return m.setDeletedAt(id, `
+			UPDATE users
+			SET deleted_at = NULL, version = version + 1
+			WHERE id = $1 AND deleted_at IS NOT NULL`)
+}
+
+func (m UserModel) setDeletedAt(id int64, query string) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
 func (m *UserModel) Authenticate(username, password string) (int, error) {
 	var id int
 	var hashedPassword []byte
 
-	stmt := "SELECT id, password_hash FROM users WHERE username = $1"
+	stmt := "SELECT id, password_hash FROM users WHERE username = $1 AND deleted_at IS NULL"
 	row := m.DB.QueryRow(stmt, username)
 	err := row.Scan(&id, &hashedPassword)
 	if err != nil {
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSetPasswordHistory(t *testing.T) {
+	user := &User{}
+	err := user.Password.Set("first-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		password string
+		wantErr  error
+	}{
+		{"second-password", nil},
+		{"second-password", ErrPasswordReused},
+		{"first-password", ErrPasswordReused},
+		{"third-password", nil},
+		// first-password has rolled off a history of 2
+		{"first-password", nil},
+	}
+
+	for _, step := range steps {
+		err := user.SetPassword(step.password, 2)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("setting %q: wanted %v, got %v", step.password, step.wantErr, err)
+		}
+	}
+
+	if len(user.PasswordHistory) != 1 {
+		t.Errorf("wanted history trimmed to 1 previous hash, got %d", len(user.PasswordHistory))
+	}
+
+	matches, err := user.Password.Matches("first-password")
+	if err != nil || !matches {
+		t.Errorf("wanted the current password to be first-password")
+	}
+}
+
+func TestSetPasswordHistoryDisabled(t *testing.T) {
+	user := &User{}
+	err := user.Password.Set("first-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = user.SetPassword("first-password", 0)
+	if err != nil {
+		t.Fatalf("wanted reuse to be allowed with no history, got %v", err)
+	}
+}
+
+// Serves a users table of numUsers rows, paging it the way postgres would
+func fakeUsersTable(numUsers int) fakeHandler {
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		if strings.Contains(query, "select count(*) from users") {
+			return []string{"count"}, [][]driver.Value{{int64(numUsers)}}, nil
+		}
+
+		limit, offset := int(args[0].(int64)), int(args[1].(int64))
+		var rows [][]driver.Value
+		for id := offset + 1; id <= numUsers && id <= offset+limit; id++ {
+			rows = append(rows, []driver.Value{int64(numUsers), int64(id), time.Now(), fmt.Sprintf("user%d", id), false, nil, int64(1)})
+		}
+		return []string{"count", "id", "created_at", "username", "admin", "deleted_at", "version"}, rows, nil
+	}
+}
+
+func TestUserGetAllPagination(t *testing.T) {
+	users := UserModel{DB: newFakeDB(t, fakeUsersTable(25))}
+	filters := Filters{PageSize: 10, Sort: "id", SortSafelist: []string{"id"}}
+
+	filters.Page = 2
+	page, metadata, err := users.GetAll(filters, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 10 || page[0].Username != "user11" {
+		t.Errorf("wanted users 11 through 20, got %d users", len(page))
+	}
+	want := Metadata{CurrentPage: 2, PageSize: 10, FirstPage: 1, LastPage: 3, TotalRecords: 25}
+	if metadata != want {
+		t.Errorf("wanted metadata %+v, got %+v", want, metadata)
+	}
+
+	filters.Page = 5
+	page, metadata, err = users.GetAll(filters, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page == nil || len(page) != 0 {
+		t.Errorf("wanted an empty, non-nil page, got %v", page)
+	}
+	want = Metadata{CurrentPage: 5, PageSize: 10, FirstPage: 1, LastPage: 3, TotalRecords: 25}
+	if metadata != want {
+		t.Errorf("wanted metadata %+v, got %+v", want, metadata)
+	}
+}
+
+// Serves a users table holding just alice, who can be disabled and restored
+func fakeDisableableUser(t *testing.T, password string) (fakeHandler, *bool) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled := false
+	affected := [][]driver.Value{{}}
+
+	return func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+		switch {
+		case strings.Contains(query, "SET deleted_at = NOW()"):
+			if disabled {
+				return nil, nil, nil
+			}
+			disabled = true
+			return nil, affected, nil
+		case strings.Contains(query, "SET deleted_at = NULL"):
+			if !disabled {
+				return nil, nil, nil
+			}
+			disabled = false
+			return nil, affected, nil
+		case strings.Contains(query, "SELECT id, password_hash FROM users WHERE username = $1 AND deleted_at IS NULL"):
+			if disabled || args[0] != "alice" {
+				return []string{"id", "password_hash"}, nil, nil
+			}
+			return []string{"id", "password_hash"}, [][]driver.Value{{int64(1), hash}}, nil
+		case strings.Contains(query, "count(*) OVER()"):
+			columns := []string{"count", "id", "created_at", "username", "admin", "deleted_at", "version"}
+			if disabled && !args[2].(bool) {
+				return columns, nil, nil
+			}
+			var deletedAt driver.Value
+			if disabled {
+				deletedAt = time.Now()
+			}
+			return columns, [][]driver.Value{{int64(1), int64(1), time.Now(), "alice", false, deletedAt, int64(1)}}, nil
+		}
+		return nil, nil, fmt.Errorf("unexpected query %q", query)
+	}, &disabled
+}
+
+func TestUserSoftDelete(t *testing.T) {
+	handler, disabled := fakeDisableableUser(t, "alice-password")
+	users := UserModel{DB: newFakeDB(t, handler)}
+	filters := Filters{Page: 1, PageSize: 10, Sort: "id", SortSafelist: []string{"id"}}
+
+	if _, err := users.Authenticate("alice", "alice-password"); err != nil {
+		t.Fatalf("wanted alice to authenticate before being disabled, got %v", err)
+	}
+
+	if err := users.SoftDelete(1); err != nil {
+		t.Fatal(err)
+	}
+	if !*disabled {
+		t.Fatal("wanted alice disabled")
+	}
+	if err := users.SoftDelete(1); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("wanted disabling twice to return ErrRecordNotFound, got %v", err)
+	}
+
+	if _, err := users.Authenticate("alice", "alice-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wanted a disabled user rejected, got %v", err)
+	}
+
+	page, _, err := users.GetAll(filters, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 0 {
+		t.Errorf("wanted disabled users hidden by default, got %d users", len(page))
+	}
+
+	page, _, err = users.GetAll(filters, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || !page[0].IsDisabled() {
+		t.Errorf("wanted alice listed as disabled with includeDisabled, got %v", page)
+	}
+
+	if err := users.Restore(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Authenticate("alice", "alice-password"); err != nil {
+		t.Errorf("wanted a restored user to authenticate, got %v", err)
+	}
+	if err := users.Restore(1); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("wanted restoring an enabled user to return ErrRecordNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The driver names PostgreSQL array types after their element type with a
+// leading underscore, _INT4 for int[] and _TEXT for text[], and returns
+// their values in PostgreSQL's text form, like {1,2,3}
+func isPostgreSQLArray(dbType string) bool {
+	return len(dbType) > 1 && strings.HasPrefix(dbType, "_")
+}
+
+// The column type for an array of the same elements
+func postgresqlArrayCreateType(dbType string) string {
+	element := strings.TrimPrefix(dbType, "_")
+	// like the scalar type, bpchar loses its length, so it would be char(1)
+	if element == "BPCHAR" {
+		element = "VARCHAR"
+	}
+	return element + "[]"
+}
+
+// Targets other than PostgreSQL have no array columns, so arrays go to them
+// as JSON arrays, which every target already knows how to store
+func arraysAsJSON(
+	dsConn DsConnection,
+	rows sourceRows,
+	columnInfo ResultSetColumnInfo,
+) (
+	sourceRows,
+	ResultSetColumnInfo,
+) {
+	if dsType, _, _ := dsConn.getConnectionInfo(); dsType == "postgresql" {
+		return rows, columnInfo
+	}
+
+	elementTypes := map[int]string{}
+	for i, intermediateType := range columnInfo.ColumnIntermediateTypes {
+		if intermediateType == "PostgreSQL_ARRAY" {
+			elementTypes[i] = strings.TrimPrefix(columnInfo.ColumnDbTypes[i], "_")
+		}
+	}
+	if len(elementTypes) == 0 {
+		return rows, columnInfo
+	}
+
+	converted := columnInfo
+	converted.ColumnDbTypes = append([]string{}, columnInfo.ColumnDbTypes...)
+	converted.ColumnIntermediateTypes = append([]string{}, columnInfo.ColumnIntermediateTypes...)
+	converted.ColumnNamesToTypes = map[string]string{}
+	for name, dbType := range columnInfo.ColumnNamesToTypes {
+		converted.ColumnNamesToTypes[name] = dbType
+	}
+	for i := range elementTypes {
+		converted.ColumnDbTypes[i] = "JSON"
+		converted.ColumnIntermediateTypes[i] = "PostgreSQL_JSON"
+		converted.ColumnNamesToTypes[columnInfo.ColumnNames[i]] = "JSON"
+	}
+
+	return &jsonArrayRows{sourceRows: rows, elementTypes: elementTypes}, converted
+}
+
+// Converts the array columns of each row to JSON as it's scanned. dest must
+// be *interface{} like every insert uses.
+type jsonArrayRows struct {
+	sourceRows
+	elementTypes map[int]string
+}
+
+func (r *jsonArrayRows) Scan(dest ...interface{}) error {
+	err := r.sourceRows.Scan(dest...)
+	if err != nil {
+		return err
+	}
+
+	for i, elementType := range r.elementTypes {
+		ptr, ok := dest[i].(*interface{})
+		if !ok {
+			return fmt.Errorf("can't scan into a %T", dest[i])
+		}
+		if *ptr == nil {
+			continue
+		}
+		js, err := postgresqlArrayJSON(fmt.Sprintf("%s", *ptr), elementType)
+		if err != nil {
+			return err
+		}
+		*ptr = js
+	}
+	return nil
+}
+
+// Converts an array in PostgreSQL's text form to a JSON array, nesting
+// multidimensional arrays. Numeric and boolean elements become JSON numbers
+// and booleans, and JSON elements are kept as they are.
+func postgresqlArrayJSON(text string, elementType string) (string, error) {
+	parser := arrayParser{text: text, elementType: strings.ToUpper(elementType)}
+
+	// arrays that don't start at 1 are prefixed with their bounds, like
+	// [0:2]={1,2,3}
+	if strings.HasPrefix(parser.text, "[") {
+		i := strings.Index(parser.text, "=")
+		if i == -1 {
+			return "", fmt.Errorf("malformed array %q", text)
+		}
+		parser.pos = i + 1
+	}
+
+	array, err := parser.parseArray()
+	if err != nil {
+		return "", err
+	}
+	if parser.pos != len(parser.text) {
+		return "", fmt.Errorf("malformed array %q", text)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(array); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+type arrayParser struct {
+	text        string
+	pos         int
+	elementType string
+}
+
+func (p *arrayParser) parseArray() ([]interface{}, error) {
+	if p.pos >= len(p.text) || p.text[p.pos] != '{' {
+		return nil, fmt.Errorf("malformed array %q", p.text)
+	}
+	p.pos++
+
+	elements := []interface{}{}
+	if p.pos < len(p.text) && p.text[p.pos] == '}' {
+		p.pos++
+		return elements, nil
+	}
+
+	for {
+		element, err := p.parseElement()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		if p.pos >= len(p.text) {
+			return nil, fmt.Errorf("malformed array %q", p.text)
+		}
+		switch p.text[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return elements, nil
+		default:
+			return nil, fmt.Errorf("malformed array %q", p.text)
+		}
+	}
+}
+
+func (p *arrayParser) parseElement() (interface{}, error) {
+	if p.pos >= len(p.text) {
+		return nil, fmt.Errorf("malformed array %q", p.text)
+	}
+
+	switch p.text[p.pos] {
+	case '{':
+		return p.parseArray()
+	case '"':
+		p.pos++
+		var element strings.Builder
+		for p.pos < len(p.text) {
+			c := p.text[p.pos]
+			p.pos++
+			switch c {
+			case '\\':
+				if p.pos < len(p.text) {
+					element.WriteByte(p.text[p.pos])
+					p.pos++
+				}
+			case '"':
+				// quoted elements are never NULL, even "NULL"
+				return p.element(element.String()), nil
+			default:
+				element.WriteByte(c)
+			}
+		}
+		return nil, fmt.Errorf("malformed array %q", p.text)
+	default:
+		end := strings.IndexAny(p.text[p.pos:], ",}")
+		if end == -1 {
+			return nil, fmt.Errorf("malformed array %q", p.text)
+		}
+		element := strings.TrimSpace(p.text[p.pos : p.pos+end])
+		p.pos += end
+		if strings.EqualFold(element, "NULL") {
+			return nil, nil
+		}
+		return p.element(element), nil
+	}
+}
+
+func (p *arrayParser) element(text string) interface{} {
+	switch {
+	case numericDbTypeRX.MatchString(p.elementType) && jsonNumberRX.MatchString(text):
+		return json.Number(text)
+	case p.elementType == "BOOL":
+		return boolText(text)
+	case (p.elementType == "JSON" || p.elementType == "JSONB") && json.Valid([]byte(text)):
+		return json.RawMessage(text)
+	default:
+		return text
+	}
+}
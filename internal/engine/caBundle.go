@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	mssqlDriver "github.com/denisenkom/go-mssqldb"
+	"github.com/denisenkom/go-mssqldb/msdsn"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+// What MySQL connection strings call the TLS config trusting the bundle
+const mysqlCABundleConfig = "sqlpipe-ca-bundle"
+
+var (
+	// The system's certificate authorities, plus the extra ones database
+	// servers may be verified against. nil verifies nothing beyond what each
+	// driver does by default.
+	caBundlePool *x509.CertPool
+
+	// The pgx config registered for each PostgreSQL connection string, so
+	// opening the same one again doesn't register another
+	caBundlePgxMu      sync.Mutex
+	caBundlePgxConfigs = map[string]string{}
+)
+
+// Adds the certificate authorities in the PEM file at path, e.g. internal
+// CAs that aren't in the system trust store, to the ones PostgreSQL,
+// Redshift, MySQL and SQL Server servers are verified against. It only adds
+// trust: whether a connection uses TLS, and whether it verifies the server,
+// is left to its connection string, and CAs a connection names itself are
+// used instead. An empty path turns it off.
+func SetCABundle(path string) error {
+	if path == "" {
+		caBundlePool = nil
+		return nil
+	}
+
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("CA bundle has no PEM certificates in it")
+	}
+
+	err = mysqlDriver.RegisterTLSConfig(mysqlCABundleConfig, &tls.Config{RootCAs: pool})
+	if err != nil {
+		return err
+	}
+
+	caBundlePgxMu.Lock()
+	for _, name := range caBundlePgxConfigs {
+		stdlib.UnregisterConnConfig(name)
+	}
+	caBundlePgxConfigs = map[string]string{}
+	caBundlePgxMu.Unlock()
+
+	caBundlePool = pool
+	return nil
+}
+
+// Returns a connector for connString whose TLS config trusts the CA bundle,
+// or nil to open it as usual, without a bundle or for drivers that take none
+func caBundleConnector(driverName string, connString string) (driver.Connector, error) {
+	if caBundlePool == nil {
+		return nil, nil
+	}
+
+	switch driverName {
+	case "pgx":
+		name, err := caBundlePgxConfig(connString)
+		if err != nil {
+			return nil, err
+		}
+		return stdlib.GetDefaultDriver().(driver.DriverContext).OpenConnector(name)
+	case "mysql":
+		config, err := mysqlDriver.ParseDSN(connString)
+		if err != nil {
+			return nil, err
+		}
+		// tls=true verifies against the system's CAs. Other settings don't
+		// verify, or name their own config.
+		if config.TLSConfig != "true" {
+			return nil, nil
+		}
+		config.TLSConfig = mysqlCABundleConfig
+		return mysqlDriver.NewConnector(config)
+	case "mssql":
+		config, _, err := msdsn.Parse(connString)
+		if err != nil {
+			return nil, err
+		}
+		trustCABundle(config.TLSConfig)
+		// the connector doesn't rewrite ? placeholders, which sqlpipe's SQL
+		// Server queries don't use
+		return mssqlDriver.NewConnectorConfig(config), nil
+	default:
+		return nil, nil
+	}
+}
+
+// Registers connString's pgx config, trusting the CA bundle, and returns the
+// name it can be opened by
+func caBundlePgxConfig(connString string) (string, error) {
+	caBundlePgxMu.Lock()
+	defer caBundlePgxMu.Unlock()
+
+	if name, ok := caBundlePgxConfigs[connString]; ok {
+		return name, nil
+	}
+
+	config, err := pgx.ParseConfig(connString)
+	if err != nil {
+		return "", err
+	}
+	trustCABundle(config.TLSConfig)
+	for _, fallback := range config.Fallbacks {
+		trustCABundle(fallback.TLSConfig)
+	}
+
+	name := stdlib.RegisterConnConfig(config)
+	caBundlePgxConfigs[connString] = name
+	return name, nil
+}
+
+// Has config verify servers against the CA bundle, unless it doesn't use TLS
+// or already names the CAs it trusts
+func trustCABundle(config *tls.Config) {
+	if config != nil && config.RootCAs == nil {
+		config.RootCAs = caBundlePool
+	}
+}
@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// A certificate for 127.0.0.1 signed by the CA
+func (ca testCA) serverCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// Listens like a PostgreSQL server that only gets as far as the TLS
+// handshake, sending the handshake's result on the returned channel
+func listenTLSPostgreSQL(t *testing.T, cert tls.Certificate) (string, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	handshakes := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// the SSLRequest message, answered with S to start TLS
+		if _, err = io.ReadFull(conn, make([]byte, 8)); err != nil {
+			handshakes <- err
+			return
+		}
+		if _, err = conn.Write([]byte("S")); err != nil {
+			handshakes <- err
+			return
+		}
+		handshakes <- tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	}()
+
+	return listener.Addr().String(), handshakes
+}
+
+func TestCABundleVerifiesPostgreSQLServers(t *testing.T) {
+	bundled := newTestCA(t, "bundled")
+	unknown := newTestCA(t, "unknown")
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, bundled.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCABundle(path); err != nil {
+		t.Fatal(err)
+	}
+	defer SetCABundle("")
+
+	tests := []struct {
+		name     string
+		ca       testCA
+		sslmode  string
+		verifies bool
+	}{
+		{"signed by the bundled CA", bundled, "verify-full", true},
+		{"signed by an unknown CA", unknown, "verify-full", false},
+		{"chain signed by the bundled CA", bundled, "verify-ca", true},
+		{"chain signed by an unknown CA", unknown, "verify-ca", false},
+		// the bundle only adds trust, so a connection that doesn't verify
+		// its server still doesn't
+		{"not verifying", unknown, "require", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, handshakes := listenTLSPostgreSQL(t, tt.ca.serverCert(t))
+
+			db, err := sqlOpen("pgx", "postgres://user:pass@"+addr+"/db?sslmode="+tt.sslmode, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			// the fake server hangs up after the handshake, so pinging
+			// always fails, but only an unknown CA fails verification
+			err = db.Ping()
+			handshake := <-handshakes
+			if tt.verifies && (handshake != nil || strings.Contains(err.Error(), "certificate")) {
+				t.Errorf("wanted the server verified, got handshake error %v and ping error %v", handshake, err)
+			}
+			if !tt.verifies && (handshake == nil || !strings.Contains(err.Error(), "certificate signed by unknown authority")) {
+				t.Errorf("wanted the server's certificate rejected, got handshake error %v and ping error %v", handshake, err)
+			}
+		})
+	}
+}
+
+func TestCABundleLeavesTLSSettingsAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, newTestCA(t, "bundled").pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCABundle(path); err != nil {
+		t.Fatal(err)
+	}
+	defer SetCABundle("")
+
+	// a server that turns down TLS, which sslmode=prefer, the default,
+	// falls back from
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	plaintext := make(chan bool, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			message := make([]byte, 8)
+			_, err = io.ReadFull(conn, message)
+			switch {
+			case err != nil:
+			case message[4] == 0x04 && message[5] == 0xd2:
+				// an SSLRequest, turned down
+				conn.Write([]byte("N"))
+			default:
+				// a startup message, protocol 3.0
+				plaintext <- message[4] == 0 && message[5] == 3
+			}
+			conn.Close()
+		}
+	}()
+
+	db, err := sqlOpen("pgx", "postgres://user:pass@"+listener.Addr().String()+"/db", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	db.PingContext(ctx)
+	select {
+	case ok := <-plaintext:
+		if !ok {
+			t.Error("wanted a plaintext startup message")
+		}
+	default:
+		t.Error("wanted the connection to fall back to plaintext, not insist on TLS")
+	}
+
+	for connString, asksForTLS := range map[string]bool{
+		"u:p@tcp(db:3306)/app":                 false,
+		"u:p@tcp(db:3306)/app?tls=skip-verify": false,
+		"u:p@tcp(db:3306)/app?tls=true":        true,
+	} {
+		connector, err := caBundleConnector("mysql", connString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (connector != nil) != asksForTLS {
+			t.Errorf("%s: wanted the bundle used %t", connString, asksForTLS)
+		}
+	}
+}
+
+func TestSetCABundleRejectsFilesWithoutCertificates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCABundle(path); err == nil {
+		defer SetCABundle("")
+		t.Error("wanted an error for a bundle without certificates")
+	}
+	if connector, _ := caBundleConnector("pgx", "postgres://u:p@db/app"); connector != nil {
+		t.Error("wanted connections opened as usual without a bundle")
+	}
+}
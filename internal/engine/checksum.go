@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// An order independent hash of a set of rows. Each row is hashed on its own
+// and the hashes are summed, so rows can be read back from the target in
+// any order and still match.
+type rowChecksum struct {
+	rows uint64
+	sum  uint64
+}
+
+func (c *rowChecksum) add(values []interface{}) {
+	h := sha256.New()
+	for _, value := range values {
+		text := checksumValue(value)
+		binary.Write(h, binary.BigEndian, int64(len(text)))
+		h.Write([]byte(text))
+	}
+	c.rows++
+	c.sum += binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+func (c rowChecksum) String() string {
+	return fmt.Sprintf("%d rows, %016x", c.rows, c.sum)
+}
+
+// Writes a value out the same way whichever driver scanned it. Booleans
+// come out as 1 and 0, since some systems keep them as numbers, times are
+// written in UTC, and decimals lose their trailing zeros, since scales
+// differ between systems. NULL can't be confused with any text, because
+// text is always prefixed.
+func checksumValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "n"
+	case bool:
+		if v {
+			return "v1"
+		}
+		return "v0"
+	case time.Time:
+		return "v" + v.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return "v" + checksumText(string(v))
+	case string:
+		return "v" + checksumText(v)
+	case float64, float32, int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint:
+		return "v" + diffNumber(v)
+	default:
+		return "v" + fmt.Sprint(v)
+	}
+}
+
+func checksumText(text string) string {
+	if _, err := strconv.ParseFloat(text, 64); err == nil && strings.Contains(text, ".") {
+		return diffNumber(text)
+	}
+	return text
+}
+
+// Hashes each row as it's scanned, on its way to the target
+type checksumRows struct {
+	sourceRows
+	checksum rowChecksum
+}
+
+func (r *checksumRows) Scan(dest ...interface{}) error {
+	err := r.sourceRows.Scan(dest...)
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(dest))
+	for i, ptr := range dest {
+		if value, ok := ptr.(*interface{}); ok {
+			values[i] = *value
+		}
+	}
+	r.checksum.add(values)
+	return nil
+}
+
+// Reads back every row of the target table, and checks they hash the same
+// as the rows read from the source
+func verifyChecksum(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	columnInfo ResultSetColumnInfo,
+	source rowChecksum,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	table := transfer.TargetTable
+	if transfer.TargetSchema != "" {
+		table = transfer.TargetSchema + "." + table
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columnInfo.ColumnNames, ", "), table)
+
+	rows, errProperties, err := dsConn.execute(query)
+	if err != nil {
+		return errProperties, err
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, columnInfo.NumCols)
+	valuePtrs := make([]interface{}, columnInfo.NumCols)
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var target rowChecksum
+	for rows.Next() {
+		err = rows.Scan(valuePtrs...)
+		if err != nil {
+			return map[string]string{"error": err.Error(), "query": query}, errors.New("unable to read back the target's rows")
+		}
+		target.add(values)
+	}
+	if err = rows.Err(); err != nil {
+		return map[string]string{"error": err.Error(), "query": query}, errors.New("unable to read back the target's rows")
+	}
+
+	if target != source {
+		return map[string]string{
+			"sourceChecksum": source.String(),
+			"targetChecksum": target.String(),
+		}, errors.New("the target's rows don't match the rows read from the source")
+	}
+	return nil, nil
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	transfer := data.Transfer{
+		Query:          "select id, name from users",
+		TargetSchema:   "dbo",
+		TargetTable:    "users_copy",
+		WriteMode:      data.WriteModeTruncate,
+		VerifyChecksum: true,
+	}
+	readBack := "SELECT id, name FROM dbo.users_copy"
+
+	tests := []struct {
+		name    string
+		target  [][]driver.Value
+		wantErr bool
+	}{
+		// read back in another order, and as another driver would scan it
+		{"matched", [][]driver.Value{{"2", []byte("b")}, {int64(1), "a"}}, false},
+		{"corrupted row", [][]driver.Value{{int64(1), "a"}, {int64(2), "x"}}, true},
+		{"missing row", [][]driver.Value{{int64(1), "a"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, fake := newFakeMSSQL(t, "target")
+			fake.results[readBack] = fakeResult{
+				columns: []string{"id", "name"},
+				types:   []string{"BIGINT", "NVARCHAR"},
+				rows:    tt.target,
+			}
+
+			errProperties, err := runFakeInsert(t, target, transfer)
+			if tt.wantErr {
+				if err == nil || errProperties["targetChecksum"] == "" {
+					t.Errorf("wanted a checksum mismatch, got %v %v", err, errProperties)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v, %v", err, errProperties)
+			}
+		})
+	}
+}
+
+func TestChecksumValue(t *testing.T) {
+	when := time.Date(2022, 1, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600))
+	for _, same := range [][]interface{}{
+		{true, int64(1), "1"},
+		{"12.50", []byte("12.5"), float64(12.5)},
+		{when, when.UTC()},
+	} {
+		for _, value := range same[1:] {
+			if checksumValue(value) != checksumValue(same[0]) {
+				t.Errorf("wanted %#v to hash like %#v", value, same[0])
+			}
+		}
+	}
+	if checksumValue(nil) == checksumValue("") || checksumValue(nil) == checksumValue("n") {
+		t.Error("wanted NULL to hash differently from any text")
+	}
+}
@@ -0,0 +1,212 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Splits the source query into transfer.Parallelism ranges of
+// transfer.ChunkColumn, and loads them into the target concurrently. The
+// target table is prepared once up front, so each chunk only appends.
+func chunkedTransfer(
+	sourceSystem DsConnection,
+	targetSystem DsConnection,
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	// chunks after the first read in transactions of their own, which share
+	// the snapshot of the one sourceSystem reads in
+	var snapshot string
+	if transfer.IsolationLevel != "" {
+		snapshot, errProperties, err = exportSnapshot(sourceSystem)
+		if err != nil {
+			return errProperties, err
+		}
+	}
+
+	min, max, empty, errProperties, err := chunkBounds(sourceSystem, transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	// nothing to split, so load the whole query the usual way
+	if empty {
+		rows, resultSetColumnInfo, errProperties, err := sourceSystem.getRows(transfer)
+		if err != nil {
+			return errProperties, err
+		}
+		defer rows.Close()
+		return Insert(targetSystem, rows, transfer, resultSetColumnInfo)
+	}
+
+	queries := chunkQueries(transfer.Query, transfer.ChunkColumn, min, max, transfer.Parallelism)
+
+	chunks := make([]data.Transfer, len(queries))
+	for i, query := range queries {
+		chunks[i] = transfer
+		chunks[i].Query = query
+		chunks[i].Overwrite = false
+		// the target is prepared once, before any chunk is inserted
+		if transfer.Mode() != data.WriteModeUpsert {
+			chunks[i].WriteMode = data.WriteModeAppend
+		}
+		chunks[i].PreLoadSQL = nil
+	}
+
+	// the first chunk's result set decides the target table's columns
+	firstRows, resultSetColumnInfo, errProperties, err := sourceSystem.getRows(chunks[0])
+	if err != nil {
+		return errProperties, err
+	}
+
+	errProperties, err = prepareTarget(targetSystem, transfer, resultSetColumnInfo)
+	if err != nil {
+		firstRows.Close()
+		return errProperties, err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for i := range chunks {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rows, columnInfo := firstRows, resultSetColumnInfo
+			var chunkErrProperties map[string]string
+			var chunkErr error
+			finishRead := func() {}
+			if i > 0 {
+				chunkSource := sourceSystem
+				if snapshot != "" {
+					chunkSource, finishRead, chunkErrProperties, chunkErr = beginSnapshotRead(sourceSystem, transfer, snapshot)
+				}
+				if chunkErr == nil {
+					rows, columnInfo, chunkErrProperties, chunkErr = chunkSource.getRows(chunks[i])
+				}
+			}
+			if chunkErr == nil {
+				chunkErrProperties, chunkErr = Insert(targetSystem, rows, chunks[i], columnInfo)
+				rows.Close()
+			}
+			finishRead()
+
+			if chunkErr != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					errProperties, err = chunkErrProperties, chunkErr
+					if errProperties == nil {
+						errProperties = map[string]string{}
+					}
+					errProperties["chunkQuery"] = chunks[i].Query
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errProperties, err
+}
+
+// Finds the smallest and largest value of the chunk column, rounded out to
+// whole numbers. empty is true if the query returns no non-NULL values.
+func chunkBounds(
+	dsConn DsConnection,
+	transfer data.Transfer,
+) (
+	min int64,
+	max int64,
+	empty bool,
+	errProperties map[string]string,
+	err error,
+) {
+	query := fmt.Sprintf(
+		"SELECT MIN(%s), MAX(%s) FROM (%s) sqlpipe_chunk_bounds",
+		transfer.ChunkColumn,
+		transfer.ChunkColumn,
+		transfer.Query,
+	)
+
+	args, errProperties, err := queryArgValues(transfer)
+	if err != nil {
+		return min, max, empty, errProperties, err
+	}
+
+	rows, errProperties, err := dsConn.execute(query, args...)
+	if err != nil {
+		return min, max, empty, errProperties, err
+	}
+	defer rows.Close()
+
+	var minValue, maxValue interface{}
+	if rows.Next() {
+		err = rows.Scan(&minValue, &maxValue)
+		if err != nil {
+			return min, max, empty, map[string]string{"error": err.Error(), "query": query}, errors.New("unable to read chunk column bounds")
+		}
+	}
+	if minValue == nil || maxValue == nil {
+		return min, max, true, nil, nil
+	}
+
+	low, err := chunkNumber(minValue)
+	if err == nil {
+		var high float64
+		high, err = chunkNumber(maxValue)
+		min, max = int64(math.Floor(low)), int64(math.Ceil(high))
+	}
+	if err != nil {
+		return min, max, empty, map[string]string{"error": err.Error(), "chunkColumn": transfer.ChunkColumn}, errors.New("chunk column must be numeric")
+	}
+
+	return min, max, false, nil, nil
+}
+
+func chunkNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("unsupported chunk column value of type %T", value)
+	}
+}
+
+// Builds up to n queries that between them return every row of query
+// exactly once. Rows where the chunk column is NULL go in the first chunk.
+func chunkQueries(query string, column string, min int64, max int64, n int) []string {
+	width := (max-min)/int64(n) + 1
+
+	var queries []string
+	for low := min; low <= max; low += width {
+		high := low + width
+
+		condition := fmt.Sprintf("%s >= %d AND %s < %d", column, low, column, high)
+		if low == min {
+			condition = fmt.Sprintf("(%s OR %s IS NULL)", condition, column)
+		}
+
+		queries = append(queries, fmt.Sprintf("SELECT * FROM (%s) sqlpipe_chunk WHERE %s", query, condition))
+	}
+
+	return queries
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+var chunkConditionRX = regexp.MustCompile(`id >= (-?\d+) AND id < (-?\d+)`)
+
+// A source holding ids 1 to 100 plus one row with a NULL id, which answers
+// the bounds and chunk queries the way a real data system would
+func newFakeChunkedSource(t *testing.T) (PostgreSQL, *fakeDb) {
+	source, fake := newFakePostgreSQL(t, "source")
+
+	var all [][]driver.Value
+	for id := int64(1); id <= 100; id++ {
+		all = append(all, []driver.Value{id, "row-" + strconv.FormatInt(id, 10)})
+	}
+	all = append(all, []driver.Value{nil, "no id"})
+
+	fake.resolve = func(q string) fakeResult {
+		switch {
+		case q == "SELECT pg_export_snapshot()":
+			return fakeResult{columns: []string{"pg_export_snapshot"}, types: []string{"TEXT"}, rows: [][]driver.Value{{"00000003-0000001B-1"}}}
+		case strings.HasPrefix(q, "SET TRANSACTION SNAPSHOT"):
+			return fakeResult{}
+		}
+		if strings.HasPrefix(q, "SELECT MIN(id), MAX(id)") {
+			return fakeResult{
+				columns: []string{"min", "max"},
+				types:   []string{"INT8", "INT8"},
+				rows:    [][]driver.Value{{int64(1), int64(100)}},
+			}
+		}
+
+		match := chunkConditionRX.FindStringSubmatch(q)
+		if match == nil {
+			t.Errorf("unexpected query %q", q)
+			return fakeResult{}
+		}
+		low, _ := strconv.ParseInt(match[1], 10, 64)
+		high, _ := strconv.ParseInt(match[2], 10, 64)
+		withNulls := strings.Contains(q, "id IS NULL")
+
+		result := fakeResult{columns: []string{"id", "name"}, types: []string{"INT8", "TEXT"}}
+		for _, row := range all {
+			id, ok := row[0].(int64)
+			if (ok && id >= low && id < high) || (!ok && withNulls) {
+				result.rows = append(result.rows, row)
+			}
+		}
+		return result
+	}
+
+	return source, fake
+}
+
+func TestChunkedTransferLoadsEveryRowOnce(t *testing.T) {
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_copy",
+		WriteMode:    data.WriteModeRecreate,
+		Parallelism:  4,
+		ChunkColumn:  "id",
+	}
+
+	source, _ := newFakeChunkedSource(t)
+	target, fake := newFakePostgreSQL(t, "target")
+
+	_, err := chunkedTransfer(source, target, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	nulls := 0
+	for _, row := range fake.copiedRows() {
+		if row[0] == nil {
+			nulls++
+			continue
+		}
+		id, err := strconv.Atoi(row[0].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	if nulls != 1 {
+		t.Errorf("wanted the NULL id row once, got %d", nulls)
+	}
+	if len(ids) != 100 {
+		t.Fatalf("wanted 100 rows, got %d", len(ids))
+	}
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("wanted ids 1 to 100 exactly once, got %v", ids)
+		}
+	}
+
+	copies := 0
+	for _, statement := range fake.executed() {
+		if strings.HasPrefix(statement, "COPY") {
+			copies++
+		}
+	}
+	if copies != 4 {
+		t.Errorf("wanted 4 chunks loaded, got %d", copies)
+	}
+	if executed := fake.executed(); !strings.HasPrefix(executed[2], "DROP TABLE") {
+		t.Errorf("wanted the table recreated before loading, got %q", executed[2])
+	}
+}
+
+func TestChunkQueries(t *testing.T) {
+	queries := chunkQueries("select * from t", "id", 1, 10, 4)
+	want := []string{
+		"SELECT * FROM (select * from t) sqlpipe_chunk WHERE (id >= 1 AND id < 4 OR id IS NULL)",
+		"SELECT * FROM (select * from t) sqlpipe_chunk WHERE id >= 4 AND id < 7",
+		"SELECT * FROM (select * from t) sqlpipe_chunk WHERE id >= 7 AND id < 10",
+		"SELECT * FROM (select * from t) sqlpipe_chunk WHERE id >= 10 AND id < 13",
+	}
+	if strings.Join(queries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wanted:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(queries, "\n"))
+	}
+
+	// fewer values than workers
+	if queries := chunkQueries("select * from t", "id", 5, 6, 4); len(queries) != 2 {
+		t.Errorf("wanted 2 chunks, got %q", queries)
+	}
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Rewrites transfer.Query to select only transfer.SourceColumns, or every
+// column but transfer.ExcludeColumns, in transfer.TargetColumnOrder if it's
+// given. The query's columns are looked up by running it for zero rows, so
+// each named column can be checked, and an exclude list can be turned into
+// a select list. Names match case insensitively, and are written as the
+// source returns them, quoted, so mixed case names and expressions' aliases
+// still resolve.
+func projectColumns(
+	dsConn DsConnection,
+	transfer data.Transfer,
+) (
+	query string,
+	errProperties map[string]string,
+	err error,
+) {
+	if !projectsColumns(transfer) {
+		return transfer.Query, nil, nil
+	}
+
+	args, errProperties, err := queryArgValues(transfer)
+	if err != nil {
+		return "", errProperties, err
+	}
+
+	rows, errProperties, err := dsConn.execute(fmt.Sprintf("SELECT * FROM (%s) sqlpipe_columns WHERE 1 = 0", transfer.Query), args...)
+	if err != nil {
+		return "", errProperties, err
+	}
+	sourceColumns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return "", map[string]string{"error": err.Error()}, errors.New("unable to read the source query's columns")
+	}
+
+	findColumn := func(name string) (string, bool) {
+		for _, sourceColumn := range sourceColumns {
+			if strings.EqualFold(sourceColumn, name) {
+				return sourceColumn, true
+			}
+		}
+		return "", false
+	}
+
+	var selected []string
+	if len(transfer.SourceColumns) > 0 {
+		for _, name := range transfer.SourceColumns {
+			sourceColumn, ok := findColumn(name)
+			if !ok {
+				return "", map[string]string{"column": name}, errors.New("source column is not in the query's result")
+			}
+			selected = append(selected, sourceColumn)
+		}
+	} else if len(transfer.ExcludeColumns) > 0 {
+		excluded := map[string]bool{}
+		for _, name := range transfer.ExcludeColumns {
+			sourceColumn, ok := findColumn(name)
+			if !ok {
+				return "", map[string]string{"column": name}, errors.New("excluded column is not in the query's result")
+			}
+			excluded[sourceColumn] = true
+		}
+		for _, sourceColumn := range sourceColumns {
+			if !excluded[sourceColumn] {
+				selected = append(selected, sourceColumn)
+			}
+		}
+		if len(selected) == 0 {
+			return "", nil, errors.New("every column of the query's result is excluded")
+		}
+	} else {
+		selected = sourceColumns
+	}
+
+	if len(transfer.TargetColumnOrder) > 0 {
+		selected, errProperties, err = orderColumns(selected, transfer.TargetColumnOrder)
+		if err != nil {
+			return "", errProperties, err
+		}
+	}
+
+	dsType, _, _ := dsConn.getConnectionInfo()
+	quoted := make([]string, len(selected))
+	for i, column := range selected {
+		quoted[i] = quoteIdentifier(dsType, column)
+	}
+
+	return fmt.Sprintf("SELECT %s FROM (%s) sqlpipe_source", strings.Join(quoted, ", "), transfer.Query), nil, nil
+}
+
+// Quotes a column name the way dsType's SQL dialect does, so it's matched
+// exactly, whatever case or characters it has
+func quoteIdentifier(dsType string, name string) string {
+	switch dsType {
+	case "mysql":
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	case "mssql":
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	default:
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+}
+
+func projectsColumns(transfer data.Transfer) bool {
+	return len(transfer.SourceColumns) > 0 || len(transfer.ExcludeColumns) > 0 || len(transfer.TargetColumnOrder) > 0
+}
+
+// Puts the transferred columns in order, which must name each of them once
+func orderColumns(columns []string, order []string) (
+	ordered []string,
+	errProperties map[string]string,
+	err error,
+) {
+	for _, name := range order {
+		found := false
+		for _, column := range columns {
+			if strings.EqualFold(column, name) {
+				ordered = append(ordered, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, map[string]string{"column": name}, errors.New("target column order names a column that isn't transferred")
+		}
+	}
+
+	for _, column := range columns {
+		found := false
+		for _, name := range order {
+			found = found || strings.EqualFold(column, name)
+		}
+		if !found {
+			return nil, map[string]string{"column": column}, errors.New("target column order is missing a transferred column")
+		}
+	}
+
+	return ordered, nil, nil
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestProjectColumns(t *testing.T) {
+	const query = "select * from documents"
+
+	tests := []struct {
+		name           string
+		sourceColumns  []string
+		excludeColumns []string
+		wantQuery      string
+		wantColumns    []string
+	}{
+		{
+			name:          "include list",
+			sourceColumns: []string{"ID", "title"},
+			wantQuery:     `SELECT "id", "title" FROM (select * from documents) sqlpipe_source`,
+			wantColumns:   []string{"id", "title"},
+		},
+		{
+			name:           "exclude list",
+			excludeColumns: []string{"body"},
+			wantQuery:      `SELECT "id", "title", "updated_at" FROM (select * from documents) sqlpipe_source`,
+			wantColumns:    []string{"id", "title", "updated_at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, sourceFake := newFakePostgreSQL(t, "source")
+			sourceFake.results["SELECT * FROM (select * from documents) sqlpipe_columns WHERE 1 = 0"] = fakeResult{
+				columns: []string{"id", "title", "body", "updated_at"},
+				types:   []string{"INT8", "TEXT", "BYTEA", "TIMESTAMP"},
+			}
+
+			transfer := data.Transfer{
+				Query:          query,
+				TargetSchema:   "public",
+				TargetTable:    "documents_copy",
+				WriteMode:      data.WriteModeRecreate,
+				SourceColumns:  tt.sourceColumns,
+				ExcludeColumns: tt.excludeColumns,
+			}
+
+			projected, errProperties, err := projectColumns(source, transfer)
+			if err != nil {
+				t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+			}
+			if projected != tt.wantQuery {
+				t.Fatalf("wanted query %q, got %q", tt.wantQuery, projected)
+			}
+
+			result := fakeResult{columns: tt.wantColumns, rows: [][]driver.Value{make([]driver.Value, len(tt.wantColumns))}}
+			for range tt.wantColumns {
+				result.types = append(result.types, "TEXT")
+			}
+			sourceFake.results[projected] = result
+
+			target, targetFake := newFakePostgreSQL(t, "target")
+			transfer.Query = projected
+			_, err = runFakeInsertFrom(t, source, target, transfer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var created string
+			for _, statement := range targetFake.executed() {
+				if strings.HasPrefix(statement, "CREATE TABLE") {
+					created = statement
+				}
+			}
+			var definitions []string
+			for _, column := range tt.wantColumns {
+				definitions = append(definitions, column+" TEXT")
+			}
+			wantTable := "CREATE TABLE public.documents_copy (" + strings.Join(definitions, ", ") + ")"
+			if strings.Join(strings.Fields(created), " ") != wantTable {
+				t.Errorf("wanted %q, got %q", wantTable, created)
+			}
+		})
+	}
+}
+
+func TestProjectColumnsQuotesNames(t *testing.T) {
+	source, sourceFake := newFakePostgreSQL(t, "source")
+	sourceFake.results[`SELECT * FROM (select id, id AS "userId", count(*) from events group by id) sqlpipe_columns WHERE 1 = 0`] = fakeResult{
+		columns: []string{"id", "userId", "count"},
+		types:   []string{"INT8", "INT8", "INT8"},
+	}
+
+	transfer := data.Transfer{Query: `select id, id AS "userId", count(*) from events group by id`, ExcludeColumns: []string{"id"}}
+	projected, errProperties, err := projectColumns(source, transfer)
+	if err != nil {
+		t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+	}
+	if want := `SELECT "userId", "count" FROM (` + transfer.Query + `) sqlpipe_source`; projected != want {
+		t.Errorf("wanted query %q, got %q", want, projected)
+	}
+
+	for dsType, want := range map[string]string{
+		"postgresql": `"user""Id"`,
+		"snowflake":  `"user""Id"`,
+		"mysql":      "`user\"Id`",
+		"mssql":      `[user"Id]`,
+	} {
+		if got := quoteIdentifier(dsType, `user"Id`); got != want {
+			t.Errorf("%s: wanted %s, got %s", dsType, want, got)
+		}
+	}
+	if got := quoteIdentifier("mssql", "a]b"); got != "[a]]b]" {
+		t.Errorf("wanted ] doubled, got %s", got)
+	}
+}
+
+func TestProjectColumnsRejectsUnknownColumns(t *testing.T) {
+	source, sourceFake := newFakePostgreSQL(t, "source")
+	sourceFake.results["SELECT * FROM (select * from documents) sqlpipe_columns WHERE 1 = 0"] = fakeResult{
+		columns: []string{"id", "title"},
+		types:   []string{"INT8", "TEXT"},
+	}
+
+	for _, transfer := range []data.Transfer{
+		{Query: "select * from documents", SourceColumns: []string{"id", "summary"}},
+		{Query: "select * from documents", ExcludeColumns: []string{"summary"}},
+	} {
+		_, errProperties, err := projectColumns(source, transfer)
+		if err == nil || errProperties["column"] != "summary" {
+			t.Errorf("wanted an error naming summary, got %v %v", err, errProperties)
+		}
+	}
+
+	_, _, err := projectColumns(source, data.Transfer{Query: "select * from documents", ExcludeColumns: []string{"id", "title"}})
+	if err == nil {
+		t.Error("wanted an error when every column is excluded")
+	}
+}
+
+func TestTargetColumnOrder(t *testing.T) {
+	source, sourceFake := newFakePostgreSQL(t, "source")
+	sourceFake.results["SELECT * FROM (select * from documents) sqlpipe_columns WHERE 1 = 0"] = fakeResult{
+		columns: []string{"id", "title", "updated_at"},
+		types:   []string{"INT8", "TEXT", "TEXT"},
+	}
+
+	transfer := data.Transfer{
+		Query:             "select * from documents",
+		TargetSchema:      "dbo",
+		TargetTable:       "documents_copy",
+		WriteMode:         data.WriteModeRecreate,
+		TargetColumnOrder: []string{"updated_at", "ID", "title"},
+	}
+	projected, errProperties, err := projectColumns(source, transfer)
+	if err != nil {
+		t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+	}
+	if want := `SELECT "updated_at", "id", "title" FROM (select * from documents) sqlpipe_source`; projected != want {
+		t.Fatalf("wanted query %q, got %q", want, projected)
+	}
+
+	sourceFake.results[projected] = fakeResult{
+		columns: []string{"updated_at", "id", "title"},
+		types:   []string{"TEXT", "INT8", "TEXT"},
+		rows:    [][]driver.Value{{"2022-01-01", int64(1), "a"}},
+	}
+	target, targetFake := newFakeMSSQL(t, "target")
+	transfer.Query = projected
+	_, err = runFakeInsertFrom(t, source, target, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created, inserted string
+	for _, statement := range targetFake.executed() {
+		switch {
+		case strings.HasPrefix(statement, "CREATE TABLE"):
+			created = statement
+		case strings.HasPrefix(statement, "INSERT INTO"):
+			inserted = statement
+		}
+	}
+	if !strings.Contains(created, "(updated_at NTEXT, id NTEXT, title NTEXT)") {
+		t.Errorf("table wasn't created in the given order: %q", created)
+	}
+	if !strings.HasPrefix(inserted, "INSERT INTO dbo.documents_copy (updated_at, id, title)") {
+		t.Errorf("rows weren't inserted in the given order: %q", inserted)
+	}
+
+	for _, order := range [][]string{{"id", "title"}, {"id", "title", "updated_at", "body"}} {
+		transfer.Query = "select * from documents"
+		transfer.TargetColumnOrder = order
+		if _, _, err := projectColumns(source, transfer); err == nil {
+			t.Errorf("wanted an error for order %v", order)
+		}
+	}
+}
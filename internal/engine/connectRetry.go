@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// How many times a transfer tries to reach its source and target before
+// failing, and how long it waits after the first failed try. Each wait after
+// that is twice as long as the last.
+var connectRetry = struct {
+	attempts int
+	backoff  time.Duration
+}{1, time.Second}
+
+// How long each try may take
+const pingTimeout = 5 * time.Second
+
+// Replaced in tests
+var sleep = time.Sleep
+
+// Sets how transfers retry reaching their source and target when they start,
+// e.g. to ride out a rolling restart. attempts of 1 or less doesn't retry.
+func SetConnectRetry(attempts int, backoff time.Duration) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	connectRetry.attempts = attempts
+	connectRetry.backoff = backoff
+}
+
+// Pings the data system until it answers or the attempts run out, telling
+// reporter about each retry
+func waitForDs(dsConn DsConnection, reporter data.ProgressReporter) (errProperties map[string]string, err error) {
+	backoff := connectRetry.backoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err = dsConn.ping(ctx)
+		cancel()
+		if err == nil {
+			return nil, nil
+		}
+
+		dsType, debugConnString := dsConn.GetDebugInfo()
+		if attempt >= connectRetry.attempts {
+			return map[string]string{
+				"error":      err.Error(),
+				"dsType":     dsType,
+				"connString": debugConnString,
+				"attempts":   fmt.Sprint(attempt),
+			}, errors.New("couldn't connect to DB")
+		}
+
+		reporter.OnRetry(dsType, attempt, err, backoff)
+		sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Records the waits between tries instead of sleeping, and puts the retry
+// settings back afterwards
+func recordSleeps(t *testing.T, attempts int, backoff time.Duration) *[]time.Duration {
+	oldRetry, oldSleep := connectRetry, sleep
+	t.Cleanup(func() { connectRetry, sleep = oldRetry, oldSleep })
+
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	SetConnectRetry(attempts, backoff)
+	return &slept
+}
+
+func TestWaitForDsRetries(t *testing.T) {
+	slept := recordSleeps(t, 3, 10*time.Millisecond)
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.pingFailures = 2
+
+	reporter := &fakeReporter{}
+	errProperties, err := waitForDs(target, reporter)
+	if err != nil {
+		t.Fatalf("wanted the third ping to get through, got %v %v", err, errProperties)
+	}
+	if want := []string{"retry postgresql 1 in 10ms", "retry postgresql 2 in 20ms"}; !reflect.DeepEqual(reporter.calls, want) {
+		t.Errorf("wanted each retry reported, got %q", reporter.calls)
+	}
+	if fake.pings != 3 {
+		t.Errorf("wanted 3 pings, got %d", fake.pings)
+	}
+	if want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}; !reflect.DeepEqual(*slept, want) {
+		t.Errorf("wanted waits of %v, got %v", want, *slept)
+	}
+
+	// and the transfer goes on to load the target
+	transfer := data.Transfer{Query: "select id, name from users", TargetSchema: "public", TargetTable: "users_copy", WriteMode: data.WriteModeAppend}
+	errProperties, err = runFakeInsert(t, target, transfer)
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	if rows := len(fake.copiedRows()); rows != 2 {
+		t.Errorf("wanted 2 rows loaded, got %d", rows)
+	}
+}
+
+func TestWaitForDsGivesUp(t *testing.T) {
+	recordSleeps(t, 2, time.Millisecond)
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.pingFailures = 5
+
+	errProperties, err := waitForDs(target, noProgress{})
+	if err == nil {
+		t.Fatal("wanted an error once the attempts ran out")
+	}
+	if fake.pings != 2 || errProperties["attempts"] != "2" {
+		t.Errorf("wanted 2 attempts, got %d pings and %v", fake.pings, errProperties)
+	}
+}
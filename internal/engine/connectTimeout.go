@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// The connection string parameter that bounds how long the driver waits to
+// connect, starting with ? or & depending on whether connString already has
+// parameters. Empty without a connect timeout, and for drivers that can't
+// set one.
+func connectTimeoutParams(connection data.Connection, connString string) string {
+	if connection.ConnectTimeout <= 0 {
+		return ""
+	}
+
+	sep := "?"
+	if strings.Contains(connString, "?") {
+		sep = "&"
+	}
+
+	switch connection.DsType {
+	case "postgresql", "redshift":
+		return fmt.Sprintf("%sconnect_timeout=%d", sep, connection.ConnectTimeout)
+	case "mysql":
+		return fmt.Sprintf("%stimeout=%ds", sep, connection.ConnectTimeout)
+	case "mssql":
+		return fmt.Sprintf("%sdial+timeout=%d", sep, connection.ConnectTimeout)
+	case "snowflake":
+		return fmt.Sprintf("%sloginTimeout=%d", sep, connection.ConnectTimeout)
+	default:
+		return ""
+	}
+}
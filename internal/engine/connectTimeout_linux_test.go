@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// A local port that drops connection attempts, like a dead host. Linux drops
+// SYNs to a listener whose accept queue is full, so it listens with the
+// smallest queue, never accepts, and fills the queue.
+func blackholedPort(t *testing.T) int {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+
+	err = syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}})
+	if err == nil {
+		err = syscall.Listen(fd, 0)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	sockaddr, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := sockaddr.(*syscall.SockaddrInet4).Port
+	addr := (&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}).String()
+
+	for i := 0; i < 16; i++ {
+		conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return port
+			}
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+	}
+	t.Skip("the listener kept accepting connections")
+	return 0
+}
+
+func TestConnectTimeoutGivesUpOnBlackholedHosts(t *testing.T) {
+	for _, dsType := range []string{"postgresql", "mysql"} {
+		t.Run(dsType, func(t *testing.T) {
+			dsConn, _, err := GetDs(data.Connection{DsType: dsType, Hostname: "127.0.0.1", Port: blackholedPort(t), DbName: "app", Username: "etl", Password: "pass", ConnectTimeout: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dsConn.closeDb()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			err = dsConn.ping(ctx)
+			elapsed := time.Since(start)
+			if err == nil {
+				t.Fatal("wanted connecting to a blackholed host to fail")
+			}
+			if elapsed > 5*time.Second {
+				t.Errorf("wanted the 1s connect timeout to give up quickly, took %s: %v", elapsed, err)
+			}
+		})
+	}
+}
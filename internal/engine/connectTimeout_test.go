@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestConnectTimeoutParams(t *testing.T) {
+	tests := []struct {
+		dsType string
+		want   string
+	}{
+		{"postgresql", "?connect_timeout=7"},
+		{"redshift", "?connect_timeout=7"},
+		{"mysql", "?timeout=7s"},
+		{"mssql", "?dial+timeout=7"},
+		{"snowflake", "?loginTimeout=7"},
+		{"oracle", ""},
+	}
+	for _, tt := range tests {
+		connection := data.Connection{DsType: tt.dsType, ConnectTimeout: 7}
+		if got := connectTimeoutParams(connection, "db"); got != tt.want {
+			t.Errorf("%s: wanted %q, got %q", tt.dsType, tt.want, got)
+		}
+	}
+
+	if got := connectTimeoutParams(data.Connection{DsType: "mssql", ConnectTimeout: 7}, "sqlserver://h?database=app"); got != "&dial+timeout=7" {
+		t.Errorf("wanted & after existing parameters, got %q", got)
+	}
+	if got := connectTimeoutParams(data.Connection{DsType: "postgresql"}, "db"); got != "" {
+		t.Errorf("wanted nothing without a connect timeout, got %q", got)
+	}
+}
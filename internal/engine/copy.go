@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// How many rows are streamed into a copy between progress reports
+const copyProgressRows = 10000
+
+// What a COPY FROM STDIN needs from the driver connection a transaction runs
+// on. pgx's *pgconn.PgConn has it.
+type copyConn interface {
+	CopyFrom(ctx context.Context, r io.Reader, sql string) (pgconn.CommandTag, error)
+}
+
+// Begins a transaction like standardBegin, and returns the driver connection
+// it runs on so rows can be copied inside it. database/sql has no way back
+// from a transaction to its connection, so one is taken out of the pool
+// first, and handed back once the transaction ends.
+func copyBegin(dsType string, db *sql.DB, opts *sql.TxOptions) (tx *sql.Tx, copier copyConn, errProperties map[string]string, err error) {
+	ctx := context.Background()
+	errProperties = map[string]string{"dsType": dsType}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		errProperties["error"] = err.Error()
+		return nil, nil, errProperties, errors.New("db.Begin() threw an error")
+	}
+
+	conn.Raw(func(driverConn interface{}) error {
+		switch c := driverConn.(type) {
+		case *stdlib.Conn:
+			copier = c.Conn().PgConn()
+		case copyConn:
+			copier = c
+		}
+		return nil
+	})
+
+	tx, err = conn.BeginTx(ctx, opts)
+	if err != nil {
+		conn.Close()
+		errProperties["error"] = err.Error()
+		return nil, nil, errProperties, errors.New("db.Begin() threw an error")
+	}
+
+	// Close waits for the transaction to commit or roll back before putting
+	// the connection back. Until then only the transaction, and copies in
+	// it, use the connection.
+	go conn.Close()
+
+	return tx, copier, nil, nil
+}
+
+// Streams rows into the target with COPY FROM STDIN, which is much faster than
+// batched inserts. All rows are copied inside one transaction, so the target
+// gets either every row or none of them. If tx is not nil, the copy joins it.
+func copyInsert(
+	dsConn DsConnection,
+	rows sourceRows,
+	transfer data.Transfer,
+	resultSetColumnInfo ResultSetColumnInfo,
+	txConn DsConnection,
+	tx *sql.Tx,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	dsType, _ := dsConn.GetDebugInfo()
+
+	if tx == nil {
+		txConn, tx, errProperties, err = dsConn.begin(nil)
+		if err != nil {
+			return errProperties, err
+		}
+	}
+	defer tx.Rollback()
+
+	// identifiers are left unquoted to match the table created by createTable
+	targetTable := transfer.TargetTable
+	if transfer.TargetSchema != "" {
+		targetTable = fmt.Sprintf("%s.%s", transfer.TargetSchema, transfer.TargetTable)
+	}
+	query := fmt.Sprintf("COPY %s (%s) FROM STDIN", targetTable, strings.Join(resultSetColumnInfo.ColumnNames, ", "))
+
+	errProperties = map[string]string{
+		"dsType": dsType,
+		"query":  query,
+	}
+
+	var copier copyConn
+	if pgConn, ok := txConn.(PostgreSQL); ok {
+		copier = pgConn.copier
+	}
+	if copier == nil {
+		errProperties["error"] = "the connection can't copy rows"
+		return errProperties, errors.New("unable to start copy")
+	}
+
+	numCols := resultSetColumnInfo.NumCols
+	createTypes := make([]string, numCols)
+	for i := 0; i < numCols; i++ {
+		createTypes[i] = dsConn.getCreateTableType(resultSetColumnInfo, i)
+	}
+
+	// rows are encoded as the copy reads them, so only one is held at a time
+	reader, writer := io.Pipe()
+	var copied int64
+	var sourceErr error
+	done := make(chan struct{})
+	var reported int64
+	go func() {
+		defer close(done)
+		copied, sourceErr = writeCopyRows(writer, rows, numCols, createTypes, func(streamed int64) {
+			progress(transfer).OnBatch(streamed - reported)
+			reported = streamed
+		})
+		writer.CloseWithError(sourceErr)
+	}()
+
+	_, err = copier.CopyFrom(context.Background(), reader, query)
+	// unblocks the writer if the copy stopped before reading every row
+	reader.CloseWithError(errors.New("copy ended"))
+	<-done
+
+	if sourceErr != nil {
+		errProperties["error"] = sourceErr.Error()
+		return errProperties, errors.New("error reading source rows")
+	}
+	if err != nil {
+		errProperties["error"] = err.Error()
+		return errProperties, errors.New("unable to copy rows")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		errProperties["error"] = err.Error()
+		return errProperties, errors.New("unable to commit copy")
+	}
+
+	// the rows streamed since the last report
+	if copied > reported {
+		progress(transfer).OnBatch(copied - reported)
+	}
+
+	return nil, nil
+}
+
+// Writes rows to w in COPY's text format, one line per row, and returns how
+// many were written. onProgress is given the running count every
+// copyProgressRows rows.
+func writeCopyRows(w io.Writer, rows sourceRows, numCols int, createTypes []string, onProgress func(written int64)) (int64, error) {
+	values := make([]interface{}, numCols)
+	valuePtrs := make([]interface{}, numCols)
+	for i := 0; i < numCols; i++ {
+		valuePtrs[i] = &values[i]
+	}
+
+	buffered := bufio.NewWriter(w)
+	var written int64
+	for rows.Next() {
+		err := rows.Scan(valuePtrs...)
+		if err != nil {
+			return written, err
+		}
+
+		for i, value := range values {
+			if i > 0 {
+				buffered.WriteByte('\t')
+			}
+			buffered.WriteString(copyText(createTypes[i], value))
+		}
+		// writes only fail once the copy has stopped, which reports why
+		if err = buffered.WriteByte('\n'); err != nil {
+			return written, nil
+		}
+		written++
+		if written%copyProgressRows == 0 {
+			onProgress(written)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return written, err
+	}
+
+	buffered.Flush()
+	return written, nil
+}
+
+// Escapes the characters COPY's text format gives meaning to
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// Formats a value as a field of COPY's text format, which the server parses
+// as the column's type. Most drivers scan text into []byte, so bytes are only
+// sent as bytea's hex for bytea columns. JSON is sent as its text.
+func copyText(createType string, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return `\N`
+	case []byte:
+		if createType == "BYTEA" {
+			return `\\x` + hex.EncodeToString(v)
+		}
+		return copyEscaper.Replace(string(v))
+	case string:
+		return copyEscaper.Replace(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999Z07:00")
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	if createType == "JSON" || createType == "JSONB" {
+		return copyEscaper.Replace(jsonText(value))
+	}
+	return copyEscaper.Replace(fmt.Sprint(value))
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// fakePostgreSQLServer speaks just enough of PostgreSQL's wire protocol for
+// pgx to begin, copy into, and commit or roll back a transaction
+type fakePostgreSQLServer struct {
+	mu      sync.Mutex
+	queries []string
+	copied  string
+}
+
+func (s *fakePostgreSQLServer) received() ([]string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.queries...), s.copied
+}
+
+// Starts a fake server and returns a PostgreSQL target connected to it
+// through pgx
+func newFakePostgreSQLServer(t *testing.T) (PostgreSQL, *fakePostgreSQLServer) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakePostgreSQLServer{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	db, err := sqlOpen("pgx", "postgres://user:password@"+listener.Addr().String()+"/db?sslmode=disable", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return PostgreSQL{dsType: "postgresql", driverName: "pgx", db: db}, server
+}
+
+func (s *fakePostgreSQLServer) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+	for {
+		message, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		switch message := message.(type) {
+		case *pgproto3.Query:
+			s.mu.Lock()
+			s.queries = append(s.queries, message.String)
+			s.mu.Unlock()
+
+			switch message.String {
+			case "begin":
+				backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("BEGIN")})
+				backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'T'})
+			case "commit", "rollback":
+				backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("COMMIT")})
+				backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			default:
+				backend.Send(&pgproto3.CopyInResponse{ColumnFormatCodes: []uint16{0, 0}})
+				s.receiveCopy(backend)
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+func (s *fakePostgreSQLServer) receiveCopy(backend *pgproto3.Backend) {
+	for {
+		message, err := backend.Receive()
+		if err != nil {
+			return
+		}
+
+		switch message := message.(type) {
+		case *pgproto3.CopyData:
+			s.mu.Lock()
+			s.copied += string(message.Data)
+			s.mu.Unlock()
+		case *pgproto3.CopyDone:
+			backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("COPY")})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'T'})
+			return
+		case *pgproto3.CopyFail:
+			backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "57014", Message: message.Message})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'E'})
+			return
+		}
+	}
+}
+
+func TestCopyInsertThroughPgx(t *testing.T) {
+	transfer := data.Transfer{Query: "select id, name from users", TargetSchema: "public", TargetTable: "users_copy"}
+
+	for _, joined := range []bool{false, true} {
+		source, fake := newFakePostgreSQL(t, "source")
+		fake.results[transfer.Query] = fakeResult{
+			columns: []string{"id", "name"},
+			types:   []string{"INT8", "TEXT"},
+			rows:    [][]driver.Value{{int64(1), "plain"}, {int64(2), nil}, {int64(3), "tab\tand back\\slash"}},
+		}
+		rows, columnInfo, errProperties, err := source.getRows(transfer)
+		if err != nil {
+			t.Fatalf("unable to get rows. err: %v, errProperties: %v", err, errProperties)
+		}
+
+		target, server := newFakePostgreSQLServer(t)
+
+		// the copy either begins its own transaction or joins the one the
+		// write mode's DDL ran in
+		var txConn DsConnection
+		var tx *sql.Tx
+		if joined {
+			txConn, tx, errProperties, err = target.begin(nil)
+			if err != nil {
+				t.Fatalf("unable to begin. err: %v, errProperties: %v", err, errProperties)
+			}
+		}
+		errProperties, err = copyInsert(target, rows, transfer, columnInfo, txConn, tx)
+		rows.Close()
+		if err != nil {
+			t.Fatalf("joined %v: %v, %v", joined, err, errProperties)
+		}
+
+		queries, copied := server.received()
+		want := []string{"begin", "COPY public.users_copy (id, name) FROM STDIN", "commit"}
+		if len(queries) != len(want) {
+			t.Fatalf("joined %v: wanted %q, got %q", joined, want, queries)
+		}
+		for i := range want {
+			if queries[i] != want[i] {
+				t.Errorf("joined %v: wanted %q, got %q", joined, want, queries)
+			}
+		}
+		if wantCopied := "1\tplain\n2\t\\N\n3\ttab\\tand back\\\\slash\n"; copied != wantCopied {
+			t.Errorf("joined %v: wanted %q copied, got %q", joined, wantCopied, copied)
+		}
+
+		// the connection the transaction was pinned to goes back to the pool
+		deadline := time.Now().Add(time.Second)
+		for target.db.Stats().InUse != 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if inUse := target.db.Stats().InUse; inUse != 0 {
+			t.Errorf("joined %v: wanted the connection released, %d still in use", joined, inUse)
+		}
+	}
+}
+
+func TestCopyText(t *testing.T) {
+	tests := []struct {
+		createType string
+		value      interface{}
+		want       string
+	}{
+		{"TEXT", nil, `\N`},
+		{"TEXT", []byte("a\tb\nc\\d"), `a\tb\nc\\d`},
+		{"BYTEA", []byte{0x00, 0xff}, `\\x00ff`},
+		{"JSONB", map[string]interface{}{"a": 1}, `{"a":1}`},
+		{"BIGINT", int64(-7), "-7"},
+		{"DOUBLE PRECISION", 1.5, "1.5"},
+		{"BOOLEAN", true, "true"},
+		{"TIMESTAMPTZ", time.Date(2021, 3, 4, 5, 6, 7, 800, time.UTC), "2021-03-04 05:06:07.0000008Z"},
+	}
+	for _, test := range tests {
+		if got := copyText(test.createType, test.value); got != test.want {
+			t.Errorf("%v as %s: wanted %q, got %q", test.value, test.createType, test.want, got)
+		}
+	}
+}
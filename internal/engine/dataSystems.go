@@ -6,11 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/secrets"
 	"github.com/sqlpipe/sqlpipe/pkg"
 )
 
@@ -28,6 +31,22 @@ type DsConnection interface {
 	// Drops <transfer.TargetTable>
 	dropTable(transferInfo data.Transfer) (errProperties map[string]string, err error)
 
+	// Runs "truncate table <transfer.TargetTable>"
+	truncateTable(transfer data.Transfer) (errProperties map[string]string, err error)
+
+	// Returns true if <transfer.TargetTable> is a view rather than a table
+	isView(transfer data.Transfer) (isView bool, errProperties map[string]string, err error)
+
+	// Returns true if <transfer.TargetTable> exists and the connection is
+	// known not to have permission to drop it. Systems where that can't be
+	// reliably checked return false.
+	dropDenied(transfer data.Transfer) (denied bool, errProperties map[string]string, err error)
+
+	// Returns the columns of <transfer.TargetTable> that the database
+	// computes itself, and so refuses values for. Systems without generated
+	// columns return none.
+	generatedColumns(transfer data.Transfer) (columns []string, errProperties map[string]string, err error)
+
 	// Creates a table to match the result set of <transfer.Query>
 	createTable(transfer data.Transfer, columnInfo ResultSetColumnInfo) (errProperties map[string]string, err error)
 
@@ -65,6 +84,14 @@ type DsConnection interface {
 	// Generates the end of an insertion query for a given data system type
 	getQueryEnder(targetTable string) (queryEnder string)
 
+	// Generates the start and end of an insertion query that updates the rows
+	// whose conflict columns match ones already in the target, instead of
+	// inserting them again
+	getUpsertQuery(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo, conflictColumns []string) (queryStarter string, queryEnder string, errProperties map[string]string, err error)
+
+	// Wraps a query in the data system's syntax for showing its execution plan
+	getExplainQuery(query string, analyze bool) (explainQuery string, errProperties map[string]string, err error)
+
 	// Returns the data system type, and a debug conn string, which has
 	// the username and password scrubbed
 	GetDebugInfo() (dsType string, debugConnString string)
@@ -83,7 +110,30 @@ type DsConnection interface {
 	turboTransfer(rows *sql.Rows, transferInfo data.Transfer, resultSetColumnInfo ResultSetColumnInfo) (errProperties map[string]string, err error)
 
 	// Bottom level func where queries actually get run
-	execute(query string) (rows *sql.Rows, errProperties map[string]string, err error)
+	execute(query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error)
+
+	// Like execute, but the query is cancelled on the data system when ctx
+	// is done, where its driver supports that
+	executeContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error)
+
+	// Runs a statement that doesn't return rows, and returns how many rows
+	// it changed
+	exec(query string, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error)
+
+	// Starts a transaction, and returns a copy of the DsConnection that runs
+	// its queries inside it. opts may be nil for the driver's defaults.
+	begin(opts *sql.TxOptions) (txConn DsConnection, tx *sql.Tx, errProperties map[string]string, err error)
+
+	// Returns true if DROP and CREATE TABLE can be rolled back as part of a
+	// transaction
+	transactionalDDL() bool
+
+	// Returns true if rows can be loaded with COPY FROM STDIN instead of
+	// batched inserts
+	supportsCopy() bool
+
+	// Checks the data system can be reached
+	ping(ctx context.Context) error
 
 	closeDb()
 }
@@ -103,7 +153,7 @@ func TestConnection(
 
 	_, driverName, connString := dsConn.getConnectionInfo()
 
-	db, err := sql.Open(driverName, connString)
+	db, err := sqlOpen(driverName, connString, nil)
 	if err != nil {
 		return connection, errProperties, err
 	}
@@ -156,12 +206,34 @@ func TestConnections(connections []*data.Connection) (
 	return connections, errProperties, err
 }
 
+// Looks up connection passwords that are secret references. nil unless
+// SetSecretProvider is called.
+var secretProvider secrets.SecretProvider
+
+func SetSecretProvider(provider secrets.SecretProvider) {
+	secretProvider = provider
+}
+
+// Stands in for a data system that couldn't be set up, so callers can still
+// defer closeDb
+type unopenedDs struct {
+	DsConnection
+}
+
+func (unopenedDs) closeDb() {}
+
 func GetDs(connection data.Connection) (
 	dsConn DsConnection,
 	errProperties map[string]string,
 	err error,
 ) {
 
+	// the resolved secret only lives in this copy of the connection
+	connection.Password, err = secrets.Resolve(secretProvider, connection.Password)
+	if err != nil {
+		return unopenedDs{}, map[string]string{"connection": connection.Name, "error": err.Error()}, errors.New("unable to resolve connection password")
+	}
+
 	switch connection.DsType {
 	case "postgresql":
 		dsConn, errProperties, err = getNewPostgreSQL(connection)
@@ -182,38 +254,114 @@ func GetDs(connection data.Connection) (
 	return dsConn, errProperties, err
 }
 
+// Opens transfers' sources and targets. Replaced in tests.
+var getDs = GetDs
+
+// Runs the transfer, telling its ProgressReporter how it goes
 func RunTransfer(
 	transfer *data.Transfer,
 ) (
 	errProperties map[string]string,
 	err error,
 ) {
+	if transfer.Rejects == nil {
+		transfer.Rejects = &data.RejectCounter{}
+	}
+	defer func() {
+		transfer.RejectedRows = transfer.Rejects.Count()
+	}()
+
+	return reportProgress(progress(*transfer), func() (map[string]string, error) {
+		return runTransfer(transfer)
+	})
+}
 
+func runTransfer(
+	transfer *data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
 	sourceConnection := transfer.Source
+	// the source itself cancels statements that run too long, even if
+	// sqlpipe goes away
+	sourceConnection.StatementTimeout = transfer.StatementTimeout
 
 	targetConnection := transfer.Target
 
-	sourceSystem, errProperties, err := GetDs(sourceConnection)
+	sourceSystem, errProperties, err := getDs(sourceConnection)
 	defer sourceSystem.closeDb()
 	if err != nil {
 		return errProperties, err
 	}
+	errProperties, err = waitForDs(sourceSystem, progress(*transfer))
+	if err != nil {
+		return errProperties, err
+	}
+
+	// with an isolation level, every read of the source sees one snapshot
+	sourceSystem, finishRead, errProperties, err := beginSourceRead(sourceSystem, *transfer)
+	if err != nil {
+		return errProperties, err
+	}
+	defer finishRead()
+
+	if projectsColumns(*transfer) {
+		query, errProperties, err := projectColumns(sourceSystem, *transfer)
+		if err != nil {
+			return errProperties, err
+		}
+		projected := *transfer
+		projected.Query = query
+		transfer = &projected
+	}
+
+	if transfer.SourceLimit > 0 || transfer.SampleRate > 0 {
+		sampled := *transfer
+		sampled.Query = sampleSource(sourceSystem, *transfer)
+		transfer = &sampled
+	}
+
+	if transfer.Parallelism > 1 && transfer.TargetFile == "" {
+		targetSystem, errProperties, err := getDs(targetConnection)
+		defer targetSystem.closeDb()
+		if err != nil {
+			return errProperties, err
+		}
+		errProperties, err = waitForDs(targetSystem, progress(*transfer))
+		if err != nil {
+			return errProperties, err
+		}
+		return chunkedTransfer(sourceSystem, targetSystem, *transfer)
+	}
 
 	rows, resultSetColumnInfo, errProperties, err := sourceSystem.getRows(*transfer)
 	if err != nil {
 		return errProperties, err
 	}
+	defer rows.Close()
+
+	if transfer.TargetFile != "" {
+		return fileInsert(rows, *transfer, resultSetColumnInfo)
+	}
 
-	targetSystem, errProperties, err := GetDs(targetConnection)
+	targetSystem, errProperties, err := getDs(targetConnection)
 	defer targetSystem.closeDb()
 	if err != nil {
 		return errProperties, err
 	}
+	errProperties, err = waitForDs(targetSystem, progress(*transfer))
+	if err != nil {
+		return errProperties, err
+	}
 	errProperties, err = Insert(targetSystem, rows, *transfer, resultSetColumnInfo)
 
 	return errProperties, err
 }
 
+// Returned by RunQuery when the query ran past its timeout
+var ErrQueryTimedOut = errors.New("query timed out")
+
 func RunQuery(query *data.Query) (
 	errProperties map[string]string,
 	err error,
@@ -223,14 +371,162 @@ func RunQuery(query *data.Query) (
 	if err != nil {
 		return errProperties, err
 	}
-	rows, errProperties, err := dsConn.execute(query.Query)
+	return runQuery(dsConn, query.Query, query.Timeout)
+}
+
+// Runs the query, cancelling it once timeout has passed, unless timeout is 0
+func runQuery(dsConn DsConnection, query string, timeout time.Duration) (
+	errProperties map[string]string,
+	err error,
+) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	rows, errProperties, err := dsConn.executeContext(ctx, query)
 	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return map[string]string{"timeout": timeout.String(), "query": query}, ErrQueryTimedOut
+		}
 		return errProperties, err
 	}
 	defer rows.Close()
 	return errProperties, err
 }
 
+// Runs the query wrapped in an EXPLAIN, and returns the plan one line per row
+func ExplainQuery(query *data.Query, analyze bool) (
+	plan []string,
+	errProperties map[string]string,
+	err error,
+) {
+	dsConn, errProperties, err := GetDs(query.Connection)
+	defer dsConn.closeDb()
+	if err != nil {
+		return plan, errProperties, err
+	}
+	return explain(dsConn, query.Query, analyze)
+}
+
+func explain(
+	dsConn DsConnection,
+	query string,
+	analyze bool,
+) (
+	plan []string,
+	errProperties map[string]string,
+	err error,
+) {
+	// analyze actually runs the query, so only allow it for reads
+	if analyze && !isSelectQuery(query) {
+		return plan, map[string]string{"query": query}, errors.New("explain analyze is only allowed on SELECT queries")
+	}
+
+	explainQuery, errProperties, err := dsConn.getExplainQuery(query, analyze)
+	if err != nil {
+		return plan, errProperties, err
+	}
+
+	rows, errProperties, err := dsConn.execute(explainQuery)
+	if err != nil {
+		return plan, errProperties, err
+	}
+	defer rows.Close()
+
+	colNames, err := rows.Columns()
+	if err != nil {
+		return plan, errProperties, err
+	}
+
+	numCols := len(colNames)
+	values := make([]interface{}, numCols)
+	valuePtrs := make([]interface{}, numCols)
+	for i := 0; i < numCols; i++ {
+		valuePtrs[i] = &values[i]
+	}
+
+	// plans spread over several columns get a header row
+	if numCols > 1 {
+		plan = append(plan, strings.Join(colNames, "\t"))
+	}
+
+	for rows.Next() {
+		err = rows.Scan(valuePtrs...)
+		if err != nil {
+			return plan, errProperties, err
+		}
+
+		line := make([]string, numCols)
+		for i, value := range values {
+			switch v := value.(type) {
+			case nil:
+				line[i] = "NULL"
+			case []byte:
+				line[i] = string(v)
+			default:
+				line[i] = fmt.Sprint(v)
+			}
+		}
+		plan = append(plan, strings.Join(line, "\t"))
+	}
+
+	return plan, errProperties, rows.Err()
+}
+
+var (
+	leadingCommentRX = regexp.MustCompile(`^(\s+|--[^\n]*\n?|/\*(?s:.*?)\*/|\()+`)
+	firstWordRX      = regexp.MustCompile(`^[a-zA-Z]+`)
+	modifyingRX      = regexp.MustCompile(`(?i)\b(insert|update|delete|merge|truncate|drop|alter|create)\b`)
+)
+
+// Reports whether a query only reads data. CTEs are allowed as long as they
+// don't contain a data modifying statement.
+func isSelectQuery(query string) bool {
+	query = leadingCommentRX.ReplaceAllString(query, "")
+
+	switch strings.ToUpper(firstWordRX.FindString(query)) {
+	case "SELECT":
+		return true
+	case "WITH":
+		return !modifyingRX.MatchString(query)
+	default:
+		return false
+	}
+}
+
+func standardGetExplainQuery(
+	dsType string,
+	query string,
+	analyze bool,
+	supportsAnalyze bool,
+) (
+	explainQuery string,
+	errProperties map[string]string,
+	err error,
+) {
+	if !analyze {
+		return fmt.Sprintf("EXPLAIN %s", query), errProperties, err
+	}
+
+	if !supportsAnalyze {
+		return "", map[string]string{"dsType": dsType}, fmt.Errorf("explain analyze is not supported for %s", dsType)
+	}
+
+	return fmt.Sprintf("EXPLAIN ANALYZE %s", query), errProperties, err
+}
+
+// The transfer's query args, converted to the values bound to its query
+func queryArgValues(transfer data.Transfer) (args []interface{}, errProperties map[string]string, err error) {
+	args, err = transfer.QueryArgs.Values()
+	if err != nil {
+		return nil, map[string]string{"error": err.Error()}, errors.New("unable to read query args")
+	}
+	return args, nil, nil
+}
+
 func standardGetRows(
 	dsConn DsConnection,
 	transferInfo data.Transfer,
@@ -241,7 +537,12 @@ func standardGetRows(
 	err error,
 ) {
 
-	rows, errProperties, err = dsConn.execute(transferInfo.Query)
+	args, errProperties, err := queryArgValues(transferInfo)
+	if err != nil {
+		return rows, resultSetColumnInfo, errProperties, err
+	}
+
+	rows, errProperties, err = dsConn.execute(transferInfo.Query, args...)
 	if err != nil {
 		return rows, resultSetColumnInfo, errProperties, err
 	}
@@ -249,11 +550,19 @@ func standardGetRows(
 	return rows, resultSetColumnInfo, errProperties, err
 }
 
+// Inserts rows in batches. Each batch is one statement run in its own
+// transaction, so a failed batch leaves none of its rows behind, and the
+// rowsCommitted error property says how many rows made it into the target
+// before the failure. If tx is not nil, the first batch joins it, and it is
+// committed with that batch. With MaxErrors, a failed batch is retried a row
+// at a time, skipping the rows that fail.
 func sqlInsert(
 	dsConn DsConnection,
-	rows *sql.Rows,
+	rows sourceRows,
 	transfer data.Transfer,
 	resultSetColumnInfo ResultSetColumnInfo,
+	txConn DsConnection,
+	tx *sql.Tx,
 ) (
 	errProperties map[string]string,
 	err error,
@@ -275,20 +584,73 @@ func sqlInsert(
 		valuePtrs[i] = &values[i]
 	}
 
+	queryStarter := dsConn.getQueryStarter(targetTable, transfer.TargetSchema, resultSetColumnInfo)
+	queryEnder := dsConn.getQueryEnder(targetTable)
+	if transfer.Mode() == data.WriteModeUpsert {
+		queryStarter, queryEnder, errProperties, err = upsertQuery(dsConn, transfer, resultSetColumnInfo)
+		if err != nil {
+			if tx != nil {
+				tx.Rollback()
+			}
+			return errProperties, err
+		}
+	}
+
 	var insertError error
 	var insertErrProperties map[string]string
-	var insertRows *sql.Rows
+
+	// rows in the current batch, rows in the batch being inserted, and rows
+	// already committed to the target
+	batchRows, pendingRows, rowsCommitted := 0, 0, 0
+
+	// with MaxErrors, the values of each row in the current batch are kept
+	// too, in case the batch has to be retried row by row
+	lenient := transfer.MaxErrors > 0
+	var batchValues []string
+	pendingRejected := 0
+
+	runBatch := func(queryString string, rowValues []string) {
+		batchTxConn, batchTx := txConn, tx
+		txConn, tx = nil, nil
+		wg.Add(1)
+		pkg.Background(func() {
+			defer wg.Done()
+			insertErrProperties, insertError = insertBatch(dsConn, batchTxConn, batchTx, queryString)
+			pendingRejected = 0
+			if insertError != nil && lenient {
+				pendingRejected, insertErrProperties, insertError = insertRowsSkippingRejects(dsConn, transfer, queryStarter, queryEnder, rowValues)
+			}
+		})
+	}
+
+	waitForBatch := func() (map[string]string, error) {
+		wg.Wait()
+		if insertError != nil {
+			if insertErrProperties == nil {
+				insertErrProperties = map[string]string{}
+			}
+			insertErrProperties["rowsCommitted"] = strconv.Itoa(rowsCommitted)
+			return insertErrProperties, insertError
+		}
+		rowsCommitted += pendingRows - pendingRejected
+		if pendingRows > pendingRejected {
+			progress(transfer).OnBatch(int64(pendingRows - pendingRejected))
+		}
+		pendingRows, pendingRejected = 0, 0
+		return nil, nil
+	}
 
 	for i := 1; rows.Next(); i++ {
 		// scan incoming values into valueptrs, which in turn points to values
 		rows.Scan(valuePtrs...)
 
 		if isFirst {
-			queryBuilder.WriteString(dsConn.getQueryStarter(targetTable, transfer.TargetSchema, resultSetColumnInfo))
+			queryBuilder.WriteString(queryStarter)
 			isFirst = false
 		} else {
 			queryBuilder.WriteString(dsConn.getRowStarter())
 		}
+		rowStart := queryBuilder.Len()
 
 		// while in the middle of insert row, add commas at end of values
 		for j := 0; j < zeroIndexedNumCols; j++ {
@@ -297,52 +659,124 @@ func sqlInsert(
 
 		// end of row doesn't need a comma at the end
 		queryBuilder.WriteString(dsConn.getValToWriteRowEnd(colTypes[zeroIndexedNumCols], values[zeroIndexedNumCols]))
+		batchRows++
+		if lenient {
+			batchValues = append(batchValues, queryBuilder.String()[rowStart:])
+		}
 
 		// each dsConn has its own limits on insert statements (either on total
 		// length or number of rows)
 		if dsConn.insertChecker(queryBuilder.Len(), i) {
 			noUnionAll := strings.TrimSuffix(queryBuilder.String(), " UNION ALL ")
 			queryBuilder.Reset()
-			withQueryEnder := fmt.Sprintf("%s%s", noUnionAll, dsConn.getQueryEnder(targetTable))
+			withQueryEnder := fmt.Sprintf("%s%s", noUnionAll, queryEnder)
 			queryString := sqlEndStringNilReplacer.Replace(withQueryEnder)
-			wg.Wait()
-			if insertError != nil {
-				return insertErrProperties, insertError
+			errProperties, err = waitForBatch()
+			if err != nil {
+				return errProperties, err
 			}
-			wg.Add(1)
-			pkg.Background(func() {
-				defer wg.Done()
-				insertRows, insertErrProperties, insertError = dsConn.execute(queryString)
-				if insertError != nil {
-					return
-				}
-				defer insertRows.Close()
-			})
+			pendingRows, batchRows = batchRows, 0
+			runBatch(queryString, batchValues)
+			batchValues = nil
 			isFirst = true
 		}
 	}
 	// if we still have some leftovers, add those too.
 	if !isFirst {
 		noUnionAll := strings.TrimSuffix(queryBuilder.String(), " UNION ALL ")
-		withQueryEnder := fmt.Sprintf("%s%s", noUnionAll, dsConn.getQueryEnder(targetTable))
+		withQueryEnder := fmt.Sprintf("%s%s", noUnionAll, queryEnder)
 		queryString := sqlEndStringNilReplacer.Replace(withQueryEnder)
-		wg.Wait()
-		if insertError != nil {
-			return insertErrProperties, insertError
+		errProperties, err = waitForBatch()
+		if err != nil {
+			return errProperties, err
 		}
-		wg.Add(1)
-		pkg.Background(func() {
-			defer wg.Done()
-			insertRows, insertErrProperties, insertError = dsConn.execute(queryString)
-			if insertError != nil {
-				return
-			}
-			defer insertRows.Close()
-		})
+		pendingRows, batchRows = batchRows, 0
+		runBatch(queryString, batchValues)
 	}
-	wg.Wait()
-	if insertError != nil {
-		return insertErrProperties, insertError
+	errProperties, err = waitForBatch()
+	if err != nil {
+		return errProperties, err
+	}
+
+	// no rows came back, so nothing used the open transaction
+	if tx != nil {
+		err = tx.Commit()
+		if err != nil {
+			return map[string]string{"error": err.Error()}, errors.New("unable to commit transaction")
+		}
+	}
+
+	return nil, nil
+}
+
+// Inserts each row of a failed batch on its own, skipping and reporting the
+// ones that fail. Fails once the transfer has rejected more than MaxErrors
+// rows in all.
+func insertRowsSkippingRejects(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	queryStarter string,
+	queryEnder string,
+	rowValues []string,
+) (
+	rejected int,
+	errProperties map[string]string,
+	err error,
+) {
+	rejects := transfer.Rejects
+	if rejects == nil {
+		rejects = &data.RejectCounter{}
+	}
+
+	for _, values := range rowValues {
+		query := sqlEndStringNilReplacer.Replace(strings.TrimSuffix(queryStarter+values, " UNION ALL ") + queryEnder)
+		rowErrProperties, rowErr := insertBatch(dsConn, nil, nil, query)
+		if rowErr == nil {
+			continue
+		}
+
+		rejected++
+		if detail, ok := rowErrProperties["error"]; ok {
+			rowErr = errors.New(detail)
+		}
+		progress(transfer).OnReject(strings.TrimSuffix(values, " UNION ALL "), rowErr)
+
+		if rejects.Add() > int64(transfer.MaxErrors) {
+			return rejected, map[string]string{"maxErrors": strconv.Itoa(transfer.MaxErrors), "error": rowErr.Error()}, fmt.Errorf("more than %d rows were rejected", transfer.MaxErrors)
+		}
+	}
+
+	return rejected, nil, nil
+}
+
+// Runs a single batch insert and commits it. If tx is nil, the batch gets a
+// transaction of its own. Otherwise it runs on txConn, inside tx.
+func insertBatch(
+	dsConn DsConnection,
+	txConn DsConnection,
+	tx *sql.Tx,
+	query string,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	if tx == nil {
+		txConn, tx, errProperties, err = dsConn.begin(nil)
+		if err != nil {
+			return errProperties, err
+		}
+	}
+
+	rows, errProperties, err := txConn.execute(query)
+	if err != nil {
+		tx.Rollback()
+		return errProperties, err
+	}
+	rows.Close()
+
+	err = tx.Commit()
+	if err != nil {
+		return map[string]string{"error": err.Error()}, errors.New("unable to commit batch")
 	}
 
 	return nil, nil
@@ -362,7 +796,7 @@ func turboTransfer(
 
 func Insert(
 	dsConn DsConnection,
-	rows *sql.Rows,
+	sqlRows *sql.Rows,
 	transfer data.Transfer,
 	resultSetColumnInfo ResultSetColumnInfo,
 ) (
@@ -370,18 +804,171 @@ func Insert(
 	err error,
 ) {
 
-	if transfer.Overwrite {
-		errProperties, err = dsConn.dropTable(transfer)
+	var rows sourceRows
+	rows, stop := bufferRows(sqlRows, transfer, resultSetColumnInfo.NumCols)
+	defer stop()
+
+	rows, resultSetColumnInfo = arraysAsJSON(dsConn, rows, resultSetColumnInfo)
+
+	if transfer.TargetTablePattern != "" {
+		return partitionedInsert(dsConn, rows, transfer, resultSetColumnInfo)
+	}
+
+	errProperties, err = runPreLoadSQL(dsConn, transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	errProperties, err = ensureTargetSchema(dsConn, transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	var txConn DsConnection
+	var tx *sql.Tx
+
+	if overwritesTarget(transfer) {
+		errProperties, err = checkOverwriteTarget(dsConn, transfer)
 		if err != nil {
 			return errProperties, err
 		}
-		errProperties, err = dsConn.createTable(transfer, resultSetColumnInfo)
+
+		// where the target allows it, emptying or recreating the table
+		// commits along with the first batch, so a failure early on leaves
+		// the old rows in place
+		ddlConn := dsConn
+		if dsConn.transactionalDDL() {
+			txConn, tx, errProperties, err = dsConn.begin(nil)
+			if err != nil {
+				return errProperties, err
+			}
+			ddlConn = txConn
+		}
+
+		errProperties, err = runWriteModeDDL(ddlConn, transfer, resultSetColumnInfo)
+		if err != nil {
+			if tx != nil {
+				tx.Rollback()
+			}
+			return errProperties, err
+		}
+	}
+
+	// rejected rows are retried one at a time, each committing on its own,
+	// so the DDL can't wait to commit with the first batch
+	if tx != nil && transfer.MaxErrors > 0 {
+		err = tx.Commit()
+		if err != nil {
+			return map[string]string{"error": err.Error()}, errors.New("unable to commit transaction")
+		}
+		txConn, tx = nil, nil
+	}
+
+	// looked up once the pre-load SQL and write mode DDL have run, since
+	// they may change the target's columns
+	rows, resultSetColumnInfo, errProperties, err = skipGeneratedColumns(dsConn, rows, transfer, resultSetColumnInfo)
+	if err != nil {
+		if tx != nil {
+			tx.Rollback()
+		}
+		return errProperties, err
+	}
+
+	var checksum *checksumRows
+	if transfer.VerifyChecksum {
+		checksum = &checksumRows{sourceRows: rows}
+		rows = checksum
+	}
+
+	// COPY can only append, so upserts are always batched. It also fails
+	// as a whole, so loads that skip bad rows are batched too.
+	if dsConn.supportsCopy() && transfer.Mode() != data.WriteModeUpsert && transfer.MaxErrors == 0 {
+		errProperties, err = copyInsert(dsConn, rows, transfer, resultSetColumnInfo, txConn, tx)
+	} else {
+		errProperties, err = sqlInsert(dsConn, rows, transfer, resultSetColumnInfo, txConn, tx)
+	}
+	if err != nil || checksum == nil {
+		return errProperties, err
+	}
+
+	return verifyChecksum(dsConn, transfer, resultSetColumnInfo, checksum.checksum)
+}
+
+// Runs the pre-load SQL, checks the target schema, and then runs the write
+// mode's DDL, each statement committing on its own
+func prepareTarget(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	resultSetColumnInfo ResultSetColumnInfo,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	errProperties, err = runPreLoadSQL(dsConn, transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	errProperties, err = ensureTargetSchema(dsConn, transfer)
+	if err != nil || !overwritesTarget(transfer) {
+		return errProperties, err
+	}
+
+	errProperties, err = checkOverwriteTarget(dsConn, transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	return runWriteModeDDL(dsConn, transfer, resultSetColumnInfo)
+}
+
+// Returns true if the transfer's write mode empties or recreates the target
+// before loading it
+func overwritesTarget(transfer data.Transfer) bool {
+	mode := transfer.Mode()
+	return mode == data.WriteModeTruncate || mode == data.WriteModeRecreate
+}
+
+// Truncates the target table, or drops and recreates it from the result
+// set's columns, as the transfer's write mode says
+func runWriteModeDDL(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	resultSetColumnInfo ResultSetColumnInfo,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	switch transfer.Mode() {
+	case data.WriteModeTruncate:
+		return dsConn.truncateTable(transfer)
+	case data.WriteModeRecreate:
+		errProperties, err = dsConn.dropTable(transfer)
 		if err != nil {
 			return errProperties, err
 		}
+		return dsConn.createTable(transfer, resultSetColumnInfo)
 	}
+	return nil, nil
+}
 
-	return sqlInsert(dsConn, rows, transfer, resultSetColumnInfo)
+// Runs each of the transfer's pre-load statements on the target, in order,
+// stopping at the first one that fails
+func runPreLoadSQL(
+	dsConn DsConnection,
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	for _, statement := range transfer.PreLoadSQL {
+		rows, errProperties, err := dsConn.execute(statement)
+		if err != nil {
+			return errProperties, err
+		}
+		rows.Close()
+	}
+	return nil, nil
 }
 
 func standardGetFormattedResults(
@@ -429,8 +1016,13 @@ func standardGetFormattedResults(
 	return queryResult, errProperties, err
 }
 
-func standardExecute(query string, dsType string, db *sql.DB) (rows *sql.Rows, errProperties map[string]string, err error) {
-	rows, err = db.Query(query)
+// Satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func standardExecute(ctx context.Context, query string, dsType string, db queryer, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	rows, err = db.QueryContext(ctx, query, args...)
 	if err != nil {
 		if len(query) > 1000 {
 			query = fmt.Sprintf("%v ... (Rest of query truncated)", query[:1000])
@@ -449,6 +1041,47 @@ func standardExecute(query string, dsType string, db *sql.DB) (rows *sql.Rows, e
 	return rows, nil, nil
 }
 
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func standardExec(query string, dsType string, db execer, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error) {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		if len(query) > 1000 {
+			query = fmt.Sprintf("%v ... (Rest of query truncated)", query[:1000])
+		}
+
+		errProperties := map[string]string{
+			"error":  err.Error(),
+			"dsType": dsType,
+			"query":  query,
+		}
+
+		return 0, errProperties, errors.New("db.Exec() threw an error")
+	}
+
+	// some drivers can't count affected rows
+	rowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return -1, nil, nil
+	}
+	return rowsAffected, nil, nil
+}
+
+func standardBegin(dsType string, db *sql.DB, opts *sql.TxOptions) (tx *sql.Tx, errProperties map[string]string, err error) {
+	tx, err = db.BeginTx(context.Background(), opts)
+	if err != nil {
+		errProperties = map[string]string{
+			"error":  err.Error(),
+			"dsType": dsType,
+		}
+		return tx, errProperties, errors.New("db.Begin() threw an error")
+	}
+
+	return tx, nil, nil
+}
+
 func getResultSetColumnInfo(
 	dsConn DsConnection,
 	rows *sql.Rows,
@@ -524,6 +1157,93 @@ func standardGetRowStarter() string {
 	return ",("
 }
 
+// Finds the starter and ender for an upsert into the transfer's target. The
+// conflict columns are matched to the result set's columns without regard to
+// case, and passed on in the result set's spelling.
+func upsertQuery(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	columnInfo ResultSetColumnInfo,
+) (
+	queryStarter string,
+	queryEnder string,
+	errProperties map[string]string,
+	err error,
+) {
+	conflictColumns := make([]string, len(transfer.ConflictColumns))
+	for i, conflictColumn := range transfer.ConflictColumns {
+		for _, name := range columnInfo.ColumnNames {
+			if strings.EqualFold(name, conflictColumn) {
+				conflictColumns[i] = name
+			}
+		}
+		if conflictColumns[i] == "" {
+			errProperties = map[string]string{"conflictColumn": conflictColumn}
+			return "", "", errProperties, errors.New("conflict column isn't in the query's result set")
+		}
+	}
+
+	return dsConn.getUpsertQuery(transfer.TargetTable, transfer.TargetSchema, columnInfo, conflictColumns)
+}
+
+// The columns an upsert updates when a row's conflict columns match one
+// already in the target, which is every column but the conflict columns
+func upsertUpdateColumns(columnInfo ResultSetColumnInfo, conflictColumns []string) []string {
+	updateColumns := []string{}
+	for _, name := range columnInfo.ColumnNames {
+		isConflictColumn := false
+		for _, conflictColumn := range conflictColumns {
+			if name == conflictColumn {
+				isConflictColumn = true
+			}
+		}
+		if !isConflictColumn {
+			updateColumns = append(updateColumns, name)
+		}
+	}
+	return updateColumns
+}
+
+// Generates everything in a MERGE after its source, which must be aliased as
+// source, into a target aliased as target
+func standardMergeClauses(columnInfo ResultSetColumnInfo, conflictColumns []string) string {
+	var builder strings.Builder
+
+	builder.WriteString(" ON (")
+	for i, conflictColumn := range conflictColumns {
+		if i > 0 {
+			builder.WriteString(" AND ")
+		}
+		fmt.Fprintf(&builder, "target.%s = source.%s", conflictColumn, conflictColumn)
+	}
+	builder.WriteString(")")
+
+	// with nothing but conflict columns, a matching row is already up to date
+	updateColumns := upsertUpdateColumns(columnInfo, conflictColumns)
+	if len(updateColumns) > 0 {
+		builder.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		for i, name := range updateColumns {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			fmt.Fprintf(&builder, "%s = source.%s", name, name)
+		}
+	}
+
+	sourceColumns := make([]string, len(columnInfo.ColumnNames))
+	for i, name := range columnInfo.ColumnNames {
+		sourceColumns[i] = "source." + name
+	}
+	fmt.Fprintf(
+		&builder,
+		" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+		strings.Join(columnInfo.ColumnNames, ", "),
+		strings.Join(sourceColumns, ", "),
+	)
+
+	return builder.String()
+}
+
 func standardGetQueryStarter(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo) string {
 	switch targetSchema {
 	case "":
@@ -533,6 +1253,77 @@ func standardGetQueryStarter(targetTable string, targetSchema string, columnInfo
 	}
 }
 
+// Looks the target up in information_schema. currentSchema is the SQL
+// expression for the schema that unqualified names resolve to.
+func standardIsView(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	currentSchema string,
+) (
+	isView bool,
+	errProperties map[string]string,
+	err error,
+) {
+	schema := currentSchema
+	if transfer.TargetSchema != "" {
+		schema = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+
+	return queryCountsAny(dsConn, fmt.Sprintf(
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_type = 'VIEW' AND LOWER(table_schema) = LOWER(%s) AND LOWER(table_name) = LOWER('%s')",
+		schema,
+		transfer.TargetTable,
+	))
+}
+
+// Runs a SELECT COUNT(*) query, and returns true if the count is above 0
+func queryCountsAny(dsConn DsConnection, query string) (bool, map[string]string, error) {
+	rows, errProperties, err := dsConn.execute(query)
+	if err != nil {
+		return false, errProperties, err
+	}
+	defer rows.Close()
+
+	count := 0
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return false, map[string]string{"error": err.Error(), "query": query}, errors.New("unable to read count")
+		}
+	}
+
+	return count > 0, nil, nil
+}
+
+// Refuses to overwrite a target that is a view, since dropping it would
+// throw away the view definition rather than a copy of the data. Recreating
+// also needs permission to drop the table, which is checked up front so the
+// transfer fails before touching anything.
+func checkOverwriteTarget(dsConn DsConnection, transfer data.Transfer) (map[string]string, error) {
+	isView, errProperties, err := dsConn.isView(transfer)
+	if err != nil {
+		return errProperties, err
+	}
+	if isView {
+		errProperties = map[string]string{"targetSchema": transfer.TargetSchema, "targetTable": transfer.TargetTable}
+		return errProperties, errors.New("target is a view, not a table, so it can't be overwritten")
+	}
+
+	if transfer.Mode() != data.WriteModeRecreate {
+		return nil, nil
+	}
+
+	denied, errProperties, err := dsConn.dropDenied(transfer)
+	if err != nil {
+		return errProperties, err
+	}
+	if denied {
+		errProperties = map[string]string{"targetSchema": transfer.TargetSchema, "targetTable": transfer.TargetTable}
+		return errProperties, errors.New("target connection isn't allowed to drop the target table, which the recreate write mode needs. Use truncate instead, or grant the permission")
+	}
+	return nil, nil
+}
+
 func dropTableIfExistsWithSchema(
 	dsConn DsConnection,
 	transferInfo data.Transfer,
@@ -615,6 +1406,41 @@ func deleteFromTableNoSchema(dsConn DsConnection, transferInfo data.Transfer) (
 	return errProperties, err
 }
 
+func truncateTableWithSchema(dsConn DsConnection, transferInfo data.Transfer) (
+	errProperties map[string]string,
+	err error,
+) {
+	query := fmt.Sprintf(
+		"TRUNCATE TABLE %v.%v",
+		transferInfo.TargetSchema,
+		transferInfo.TargetTable,
+	)
+	rows, errProperties, err := dsConn.execute(query)
+	if err != nil {
+		return errProperties, err
+	}
+	defer rows.Close()
+
+	return errProperties, err
+}
+
+func truncateTableNoSchema(dsConn DsConnection, transferInfo data.Transfer) (
+	errProperties map[string]string,
+	err error,
+) {
+	query := fmt.Sprintf(
+		"TRUNCATE TABLE %v",
+		transferInfo.TargetTable,
+	)
+	rows, errProperties, err := dsConn.execute(query)
+	if err != nil {
+		return errProperties, err
+	}
+	defer rows.Close()
+
+	return errProperties, err
+}
+
 func standardCreateTable(
 	dsConn DsConnection,
 	transferInfo data.Transfer,
@@ -1,11 +1,15 @@
 package engine
 
 import (
+	"database/sql/driver"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/secrets"
 )
 
 // Test connections
@@ -253,7 +257,7 @@ var mssqlSetupTests = []queryTest{
 		connection:  mssqlTestConnection,
 		testQuery:   `insert into wide_table (mybigint, mybit, mydecimal, myint, mymoney, mynumeric, mysmallint, mysmallmoney, mytinyint, myfloat, myreal, mydate, mydatetime2, mydatetime, mydatetimeoffset, mysmalldatetime, mytime, mychar, myvarchar, mytext, mynchar, mynvarchar, myntext, mybinary, myvarbinary, myuniqueidentifier, myxml) values(435345, 1, 324.43, 54, 43.21, 54.33, 12, 22.10, 4, 45.5, 47.7, '2013-10-12', CAST('2005-06-12 11:40:17.632' AS datetime2), CAST('2005-06-12 11:40:17.632' AS datetime), CAST('2005-06-12 11:40:17.632 +01:00' AS datetimeoffset), CAST('2005-06-12 11:40:00' AS smalldatetime), CAST('11:40:12.543654' AS time), 'yoo', 'gday guvna', 'omg have you hea''rd" a,bout the latest craze that the people are talking about?', 'yoo', 'gday guvna', 'omg have you heard about the latest craze that the people are talking about?', 101, 100001, N'6F9619FF-8B86-D011-B42D-00C04FC964FF','<foo>bar</foo>'),(null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null, null);`,
 		checkQuery:  "select * from wide_table",
-		checkResult: QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybinary": "BINARY", "mybit": "BIT", "mychar": "CHAR", "mydate": "DATE", "mydatetime": "DATETIME", "mydatetime2": "DATETIME2", "mydatetimeoffset": "DATETIMEOFFSET", "mydecimal": "DECIMAL", "myfloat": "FLOAT", "myint": "INT", "mymoney": "MONEY", "mynchar": "NCHAR", "myntext": "NTEXT", "mynumeric": "DECIMAL", "mynvarchar": "NVARCHAR", "myreal": "REAL", "mysmalldatetime": "SMALLDATETIME", "mysmallint": "SMALLINT", "mysmallmoney": "SMALLMONEY", "mytext": "TEXT", "mytime": "TIME", "mytinyint": "TINYINT", "myuniqueidentifier": "UNIQUEIDENTIFIER", "myvarbinary": "VARBINARY", "myvarchar": "VARCHAR", "myxml": "XML"}, Rows: []interface{}{"435345", "1", "324.43000", "54", "'43.2100'", "54.3300000", "12", "'22.1000'", "4", "45.5", "47.70000076293945", "CONVERT(DATETIME2, '2013-10-12 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6330000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 11:40:12.5436540', 121)", "'yoo'", "'gday guvna'", "'omg have you hea''rd\" a,bout the latest craze that the people are talking about?'", "'yoo'", "'gday guvna'", "'omg have you heard about the latest craze that the people are talking about?'", "CONVERT(VARBINARY(8000), '0x000065', 1)", "CONVERT(VARBINARY(8000), '0x000186a1', 1)", "N'6F9619FF-8B86-D011-B42D-00C04FC964FF'", "'<foo>bar</foo>'", "%!d(<nil>)", "null", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!d(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "null", "'%!s(<nil>)'"}},
+		checkResult: QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybinary": "BINARY", "mybit": "BIT", "mychar": "CHAR", "mydate": "DATE", "mydatetime": "DATETIME", "mydatetime2": "DATETIME2", "mydatetimeoffset": "DATETIMEOFFSET", "mydecimal": "DECIMAL", "myfloat": "FLOAT", "myint": "INT", "mymoney": "MONEY", "mynchar": "NCHAR", "myntext": "NTEXT", "mynumeric": "DECIMAL", "mynvarchar": "NVARCHAR", "myreal": "REAL", "mysmalldatetime": "SMALLDATETIME", "mysmallint": "SMALLINT", "mysmallmoney": "SMALLMONEY", "mytext": "TEXT", "mytime": "TIME", "mytinyint": "TINYINT", "myuniqueidentifier": "UNIQUEIDENTIFIER", "myvarbinary": "VARBINARY", "myvarchar": "VARCHAR", "myxml": "XML"}, Rows: []interface{}{"435345", "1", "324.43000", "54", "'43.2100'", "54.3300000", "12", "'22.1000'", "4", "45.5", "47.70000076293945", "CONVERT(DATETIME2, '2013-10-12 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6330000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 11:40:12.5436540', 121)", "'yoo'", "'gday guvna'", "'omg have you hea''rd\" a,bout the latest craze that the people are talking about?'", "'yoo'", "'gday guvna'", "'omg have you heard about the latest craze that the people are talking about?'", "CONVERT(VARBINARY(MAX), '0x000065', 1)", "CONVERT(VARBINARY(MAX), '0x000186a1', 1)", "N'6F9619FF-8B86-D011-B42D-00C04FC964FF'", "'<foo>bar</foo>'", "%!d(<nil>)", "null", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!d(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "null", "'%!s(<nil>)'"}},
 	},
 	{
 		name:                  "mssqlLoadTableDrop",
@@ -394,7 +398,7 @@ var postgresqlTransferTests = []transferTest{
 		targetTable:   "postgresql_wide_table",
 		transferQuery: "select * from wide_table",
 		checkQuery:    "select * from postgresql_wide_table",
-		checkResult:   QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybit": "VARCHAR", "mybitvarying": "VARCHAR", "myboolean": "BIT", "mybox": "VARCHAR", "mybytea": "VARBINARY", "mychar": "NVARCHAR", "mycidr": "VARCHAR", "mycircle": "VARCHAR", "mydate": "DATETIME2", "mydoubleprecision": "FLOAT", "myinet": "VARCHAR", "myinteger": "INT", "myinterval": "VARCHAR", "myjson": "NVARCHAR", "myjsonb": "NVARCHAR", "myline": "VARCHAR", "mylseg": "VARCHAR", "mymacaddr": "VARCHAR", "mymoney": "VARCHAR", "mynumeric": "FLOAT", "mypath": "VARCHAR", "mypg_lsn": "VARCHAR", "mypoint": "VARCHAR", "mypolygon": "VARCHAR", "myreal": "REAL", "mysmallint": "INT", "mytext": "NTEXT", "mytime": "TIME", "mytimestamp": "DATETIME2", "mytimestamptz": "DATETIME2", "mytimetz": "VARCHAR", "mytsquery": "NVARCHAR", "mytsvector": "NVARCHAR", "myuuid": "UNIQUEIDENTIFIER", "myvarchar": "NVARCHAR", "myxml": "XML"}, Rows: []interface{}{"6514798382812790784", "'10001'", "'1001'", "1", "'(8,9),(1,3)'", "CONVERT(VARBINARY(8000), '0xaaaabbbb', 1)", "'abc'", "'\"my\"varch''ar,123@gmail.com'", "'192.168.100.128/25'", "'<(1,5),5>'", "CONVERT(DATETIME2, '2014-01-10 00:00:00.0000000', 121)", "529.5621898337544", "'192.168.100.128'", "745910651", "'10 days 10:00:00'", "'{\"mykey\": \"this\\\"  ''is'' m,y val\"}'", "'{\"mykey\": \"this is my val\"}'", "'{1,5,20}'", "'[(5,4),(2,1)]'", "'08:00:2b:01:02:03'", "'$35,244.33'", "449.82115", "'[(1,4),(8,7)]'", "'16/B374D848'", "'(5,7)'", "'((5,8),(6,10),(7,20))'", "9673.109375", "24345", "'myte\",xt123@gmail.com'", "CONVERT(DATETIME2, '0001-01-01 03:46:38.7655940', 121)", "'03:46:38.765594+05'", "CONVERT(DATETIME2, '2014-01-10 10:05:04.0000000', 121)", "CONVERT(DATETIME2, '2014-01-10 18:05:04.0000000', 121)", "'''fat'' & ''rat'''", "'''a'' ''and'' ''ate'' ''cat'' ''fat'' ''mat'' ''on'' ''rat'' ''sat'''", "N'A0EEBC99-9CB-4EF8-BB6D-6BB9BD380A11'", "'<foo>bar</foo>'", "%!d(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "null", "'%!s(<nil>)'", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "null", "%!g(<nil>)", "'%!s(<nil>)'", "%!d(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!g(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!g(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "null", "'%!s(<nil>)'", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "null", "'%!s(<nil>)'"}},
+		checkResult:   QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybit": "VARCHAR", "mybitvarying": "VARCHAR", "myboolean": "BIT", "mybox": "VARCHAR", "mybytea": "VARBINARY", "mychar": "NVARCHAR", "mycidr": "VARCHAR", "mycircle": "VARCHAR", "mydate": "DATETIME2", "mydoubleprecision": "FLOAT", "myinet": "VARCHAR", "myinteger": "INT", "myinterval": "VARCHAR", "myjson": "NVARCHAR", "myjsonb": "NVARCHAR", "myline": "VARCHAR", "mylseg": "VARCHAR", "mymacaddr": "VARCHAR", "mymoney": "VARCHAR", "mynumeric": "FLOAT", "mypath": "VARCHAR", "mypg_lsn": "VARCHAR", "mypoint": "VARCHAR", "mypolygon": "VARCHAR", "myreal": "REAL", "mysmallint": "INT", "mytext": "NTEXT", "mytime": "TIME", "mytimestamp": "DATETIME2", "mytimestamptz": "DATETIME2", "mytimetz": "VARCHAR", "mytsquery": "NVARCHAR", "mytsvector": "NVARCHAR", "myuuid": "UNIQUEIDENTIFIER", "myvarchar": "NVARCHAR", "myxml": "XML"}, Rows: []interface{}{"6514798382812790784", "'10001'", "'1001'", "1", "'(8,9),(1,3)'", "CONVERT(VARBINARY(MAX), '0xaaaabbbb', 1)", "'abc'", "'\"my\"varch''ar,123@gmail.com'", "'192.168.100.128/25'", "'<(1,5),5>'", "CONVERT(DATETIME2, '2014-01-10 00:00:00.0000000', 121)", "529.5621898337544", "'192.168.100.128'", "745910651", "'10 days 10:00:00'", "'{\"mykey\": \"this\\\"  ''is'' m,y val\"}'", "'{\"mykey\": \"this is my val\"}'", "'{1,5,20}'", "'[(5,4),(2,1)]'", "'08:00:2b:01:02:03'", "'$35,244.33'", "449.82115", "'[(1,4),(8,7)]'", "'16/B374D848'", "'(5,7)'", "'((5,8),(6,10),(7,20))'", "9673.109375", "24345", "'myte\",xt123@gmail.com'", "CONVERT(DATETIME2, '0001-01-01 03:46:38.7655940', 121)", "'03:46:38.765594+05'", "CONVERT(DATETIME2, '2014-01-10 10:05:04.0000000', 121)", "CONVERT(DATETIME2, '2014-01-10 18:05:04.0000000', 121)", "'''fat'' & ''rat'''", "'''a'' ''and'' ''ate'' ''cat'' ''fat'' ''mat'' ''on'' ''rat'' ''sat'''", "N'A0EEBC99-9CB-4EF8-BB6D-6BB9BD380A11'", "'<foo>bar</foo>'", "%!d(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "null", "'%!s(<nil>)'", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "null", "%!g(<nil>)", "'%!s(<nil>)'", "%!d(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!g(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!g(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "null", "'%!s(<nil>)'", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "null", "'%!s(<nil>)'"}},
 	},
 	{
 		name:          "postgresql2oracle_wide",
@@ -535,7 +539,7 @@ var mysqlTransferTests = []transferTest{
 		targetTable:   "mysql_wide_table",
 		transferQuery: "select * from wide_table",
 		checkQuery:    "select * from mysql_wide_table",
-		checkResult:   QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybinary": "VARBINARY", "mybit": "VARCHAR", "mybit5": "VARCHAR", "mybit64": "VARCHAR", "myblob": "VARBINARY", "mychar": "NVARCHAR", "mydate": "DATE", "mydatetime": "DATETIME2", "mydecimal": "DECIMAL", "mydouble": "FLOAT", "myenum": "NVARCHAR", "myfloat": "REAL", "mygeometry": "VARBINARY", "mygeometrycollection": "VARBINARY", "myint": "INT", "myjson": "NVARCHAR", "mylinestring": "VARBINARY", "mylongblob": "VARBINARY", "mylongtext": "NTEXT", "mymediumblob": "VARBINARY", "mymediumint": "INT", "mymediumtext": "NTEXT", "mymultilinestring": "VARBINARY", "mymultipoint": "VARBINARY", "mymultipolygon": "VARBINARY", "mynchar": "NVARCHAR", "mynvarchar": "NVARCHAR", "mypoint": "VARBINARY", "mypolygon": "VARBINARY", "myserial": "BIGINT", "myset": "NVARCHAR", "mysmallint": "SMALLINT", "mytext": "NTEXT", "mytime": "TIME", "mytimestamp": "DATETIME2", "mytinyblob": "VARBINARY", "mytinyint": "TINYINT", "mytinytext": "NTEXT", "myvarbinary": "VARBINARY", "myvarchar": "NVARCHAR", "myyear": "INT"}, Rows: []interface{}{"1", "'[1]'", "'[1010]'", "'[11111111 11111111 11111111 11111111 11111111 11111111 11111111 11111111]'", "2", "5", "50", "4595435", "392809438543", "30.50000", "45.900001525878906", "54.3", "CONVERT(DATETIME2, '2009-05-28 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 14:23:54.0000000', 121)", "CONVERT(DATETIME2, '2010-10-24 20:52:52.0000000', 121)", "CONVERT(DATETIME2, '1989-02-22 03:17:21.0000000', 121)", "1905", "'chr'", "'my varchar ''st\"ri,ng wheeeee'", "'ncr'", "'my nvarchar string wheeeee'", "CONVERT(VARBINARY(8000), '0x626e72', 1)", "CONVERT(VARBINARY(8000), '0x6d792062696e61727920737472696e67207761686f6f6f6f6f', 1)", "CONVERT(VARBINARY(8000), '0x626c6f622063697479206262', 1)", "CONVERT(VARBINARY(8000), '0x626c6f622063697479206262', 1)", "CONVERT(VARBINARY(8000), '0x626c6f622063697479206262', 1)", "CONVERT(VARBINARY(8000), '0x626c6f622063697479206262', 1)", "'text city bb'", "'text city bb'", "'text city bb'", "'text city bb'", "'enumval1'", "'setval1'", "CONVERT(VARBINARY(8000), '0x000000000101000000000000000000f03f000000000000f03f', 1)", "CONVERT(VARBINARY(8000), '0x000000000101000000000000000000f03f000000000000f03f', 1)", "CONVERT(VARBINARY(8000), '0x0000000001020000000300000000000000000000000000000000000000000000000000f03f000000000000f03f00000000000000400000000000000040', 1)", "CONVERT(VARBINARY(8000), '0x0000000001030000000200000005000000000000000000000000000000000000000000000000002440000000000000000000000000000024400000000000002440000000000000000000000000000024400000000000000000000000000000000005000000000000000000144000000000000014400000000000001c4000000000000014400000000000001c400000000000001c4000000000000014400000000000001c4000000000000014400000000000001440', 1)", "CONVERT(VARBINARY(8000), '0x0000000001040000000a0000000101000000000000000000f03f000000000000f03f01010000000000000000000040000000000000004001010000000000000000001440000000000000084001010000000000000000001c4000000000000000400101000000000000000000224000000000000008400101000000000000000000204000000000000010400101000000000000000000184000000000000018400101000000000000000000184000000000000022400101000000000000000000104000000000000022400101000000000000000000f03f0000000000001440', 1)", "CONVERT(VARBINARY(8000), '0x00000000010500000002000000010200000003000000000000000000f03f000000000000f03f00000000000000400000000000000040000000000000084000000000000008400102000000020000000000000000001040000000000000104000000000000014400000000000001440', 1)", "CONVERT(VARBINARY(8000), '0x0000000001060000000100000001030000000200000005000000000000000000000000000000000000000000000000000000000000000000084000000000000008400000000000000840000000000000084000000000000000000000000000000000000000000000000005000000000000000000f03f000000000000f03f000000000000f03f0000000000000040000000000000004000000000000000400000000000000040000000000000f03f000000000000f03f000000000000f03f', 1)", "CONVERT(VARBINARY(8000), '0x0000000001060000000100000001030000000200000005000000000000000000000000000000000000000000000000000000000000000000084000000000000008400000000000000840000000000000084000000000000000000000000000000000000000000000000005000000000000000000f03f000000000000f03f000000000000f03f0000000000000040000000000000004000000000000000400000000000000040000000000000f03f000000000000f03f000000000000f03f', 1)", "'{\"mykey\": \"this is\\\" m\\\"y, ''val''\"}'", "2", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!d(<nil>)", "%!d(<nil>)", "%!d(<nil>)", "%!d(<nil>)", "%!d(<nil>)", "%!s(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "%!d(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "'%!s(<nil>)'"}},
+		checkResult:   QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybinary": "VARBINARY", "mybit": "VARCHAR", "mybit5": "VARCHAR", "mybit64": "VARCHAR", "myblob": "VARBINARY", "mychar": "NVARCHAR", "mydate": "DATE", "mydatetime": "DATETIME2", "mydecimal": "DECIMAL", "mydouble": "FLOAT", "myenum": "NVARCHAR", "myfloat": "REAL", "mygeometry": "VARBINARY", "mygeometrycollection": "VARBINARY", "myint": "INT", "myjson": "NVARCHAR", "mylinestring": "VARBINARY", "mylongblob": "VARBINARY", "mylongtext": "NTEXT", "mymediumblob": "VARBINARY", "mymediumint": "INT", "mymediumtext": "NTEXT", "mymultilinestring": "VARBINARY", "mymultipoint": "VARBINARY", "mymultipolygon": "VARBINARY", "mynchar": "NVARCHAR", "mynvarchar": "NVARCHAR", "mypoint": "VARBINARY", "mypolygon": "VARBINARY", "myserial": "BIGINT", "myset": "NVARCHAR", "mysmallint": "SMALLINT", "mytext": "NTEXT", "mytime": "TIME", "mytimestamp": "DATETIME2", "mytinyblob": "VARBINARY", "mytinyint": "TINYINT", "mytinytext": "NTEXT", "myvarbinary": "VARBINARY", "myvarchar": "NVARCHAR", "myyear": "INT"}, Rows: []interface{}{"1", "'[1]'", "'[1010]'", "'[11111111 11111111 11111111 11111111 11111111 11111111 11111111 11111111]'", "2", "5", "50", "4595435", "392809438543", "30.50000", "45.900001525878906", "54.3", "CONVERT(DATETIME2, '2009-05-28 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 14:23:54.0000000', 121)", "CONVERT(DATETIME2, '2010-10-24 20:52:52.0000000', 121)", "CONVERT(DATETIME2, '1989-02-22 03:17:21.0000000', 121)", "1905", "'chr'", "'my varchar ''st\"ri,ng wheeeee'", "'ncr'", "'my nvarchar string wheeeee'", "CONVERT(VARBINARY(MAX), '0x626e72', 1)", "CONVERT(VARBINARY(MAX), '0x6d792062696e61727920737472696e67207761686f6f6f6f6f', 1)", "CONVERT(VARBINARY(MAX), '0x626c6f622063697479206262', 1)", "CONVERT(VARBINARY(MAX), '0x626c6f622063697479206262', 1)", "CONVERT(VARBINARY(MAX), '0x626c6f622063697479206262', 1)", "CONVERT(VARBINARY(MAX), '0x626c6f622063697479206262', 1)", "'text city bb'", "'text city bb'", "'text city bb'", "'text city bb'", "'enumval1'", "'setval1'", "CONVERT(VARBINARY(MAX), '0x000000000101000000000000000000f03f000000000000f03f', 1)", "CONVERT(VARBINARY(MAX), '0x000000000101000000000000000000f03f000000000000f03f', 1)", "CONVERT(VARBINARY(MAX), '0x0000000001020000000300000000000000000000000000000000000000000000000000f03f000000000000f03f00000000000000400000000000000040', 1)", "CONVERT(VARBINARY(MAX), '0x0000000001030000000200000005000000000000000000000000000000000000000000000000002440000000000000000000000000000024400000000000002440000000000000000000000000000024400000000000000000000000000000000005000000000000000000144000000000000014400000000000001c4000000000000014400000000000001c400000000000001c4000000000000014400000000000001c4000000000000014400000000000001440', 1)", "CONVERT(VARBINARY(MAX), '0x0000000001040000000a0000000101000000000000000000f03f000000000000f03f01010000000000000000000040000000000000004001010000000000000000001440000000000000084001010000000000000000001c4000000000000000400101000000000000000000224000000000000008400101000000000000000000204000000000000010400101000000000000000000184000000000000018400101000000000000000000184000000000000022400101000000000000000000104000000000000022400101000000000000000000f03f0000000000001440', 1)", "CONVERT(VARBINARY(MAX), '0x00000000010500000002000000010200000003000000000000000000f03f000000000000f03f00000000000000400000000000000040000000000000084000000000000008400102000000020000000000000000001040000000000000104000000000000014400000000000001440', 1)", "CONVERT(VARBINARY(MAX), '0x0000000001060000000100000001030000000200000005000000000000000000000000000000000000000000000000000000000000000000084000000000000008400000000000000840000000000000084000000000000000000000000000000000000000000000000005000000000000000000f03f000000000000f03f000000000000f03f0000000000000040000000000000004000000000000000400000000000000040000000000000f03f000000000000f03f000000000000f03f', 1)", "CONVERT(VARBINARY(MAX), '0x0000000001060000000100000001030000000200000005000000000000000000000000000000000000000000000000000000000000000000084000000000000008400000000000000840000000000000084000000000000000000000000000000000000000000000000005000000000000000000f03f000000000000f03f000000000000f03f0000000000000040000000000000004000000000000000400000000000000040000000000000f03f000000000000f03f000000000000f03f', 1)", "'{\"mykey\": \"this is\\\" m\\\"y, ''val''\"}'", "2", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!d(<nil>)", "%!d(<nil>)", "%!d(<nil>)", "%!d(<nil>)", "%!d(<nil>)", "%!s(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "%!d(<nil>)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "'%!s(<nil>)'"}},
 	},
 	{
 		name:          "mysql2oracle_wide",
@@ -665,7 +669,7 @@ var mssqlTransferTests = []transferTest{
 		targetTable:   "mssql_wide_table",
 		transferQuery: "select * from wide_table",
 		checkQuery:    "select * from mssql_wide_table",
-		checkResult:   QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybinary": "VARBINARY", "mybit": "BIT", "mychar": "CHAR", "mydate": "DATETIME2", "mydatetime": "DATETIME2", "mydatetime2": "DATETIME2", "mydatetimeoffset": "DATETIME2", "mydecimal": "DECIMAL", "myfloat": "FLOAT", "myint": "BIGINT", "mymoney": "VARCHAR", "mynchar": "NCHAR", "myntext": "NTEXT", "mynumeric": "DECIMAL", "mynvarchar": "NVARCHAR", "myreal": "FLOAT", "mysmalldatetime": "DATETIME2", "mysmallint": "BIGINT", "mysmallmoney": "VARCHAR", "mytext": "TEXT", "mytime": "DATETIME2", "mytinyint": "BIGINT", "myuniqueidentifier": "UNIQUEIDENTIFIER", "myvarbinary": "VARBINARY", "myvarchar": "VARCHAR", "myxml": "XML"}, Rows: []interface{}{"435345", "1", "324.43000", "54", "'43.2100'", "54.3300000", "12", "'22.1000'", "4", "45.5", "47.70000076293945", "CONVERT(DATETIME2, '2013-10-12 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6330000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 11:40:12.5436540', 121)", "'yoo'", "'gday guvna'", "'omg have you hea''rd\" a,bout the latest craze that the people are talking about?'", "'yoo'", "'gday guvna'", "'omg have you heard about the latest craze that the people are talking about?'", "CONVERT(VARBINARY(8000), '0x000065', 1)", "CONVERT(VARBINARY(8000), '0x000186a1', 1)", "N'6F9619FF-8B86-D011-B42D-00C04FC964FF'", "'<foo>bar</foo>'", "%!d(<nil>)", "null", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!d(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "null", "'%!s(<nil>)'"}},
+		checkResult:   QueryResult{ColumnTypes: map[string]string{"mybigint": "BIGINT", "mybinary": "VARBINARY", "mybit": "BIT", "mychar": "CHAR", "mydate": "DATETIME2", "mydatetime": "DATETIME2", "mydatetime2": "DATETIME2", "mydatetimeoffset": "DATETIME2", "mydecimal": "DECIMAL", "myfloat": "FLOAT", "myint": "BIGINT", "mymoney": "VARCHAR", "mynchar": "NCHAR", "myntext": "NTEXT", "mynumeric": "DECIMAL", "mynvarchar": "NVARCHAR", "myreal": "FLOAT", "mysmalldatetime": "DATETIME2", "mysmallint": "BIGINT", "mysmallmoney": "VARCHAR", "mytext": "TEXT", "mytime": "DATETIME2", "mytinyint": "BIGINT", "myuniqueidentifier": "UNIQUEIDENTIFIER", "myvarbinary": "VARBINARY", "myvarchar": "VARCHAR", "myxml": "XML"}, Rows: []interface{}{"435345", "1", "324.43000", "54", "'43.2100'", "54.3300000", "12", "'22.1000'", "4", "45.5", "47.70000076293945", "CONVERT(DATETIME2, '2013-10-12 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6330000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:17.6320000', 121)", "CONVERT(DATETIME2, '2005-06-12 11:40:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 11:40:12.5436540', 121)", "'yoo'", "'gday guvna'", "'omg have you hea''rd\" a,bout the latest craze that the people are talking about?'", "'yoo'", "'gday guvna'", "'omg have you heard about the latest craze that the people are talking about?'", "CONVERT(VARBINARY(MAX), '0x000065', 1)", "CONVERT(VARBINARY(MAX), '0x000186a1', 1)", "N'6F9619FF-8B86-D011-B42D-00C04FC964FF'", "'<foo>bar</foo>'", "%!d(<nil>)", "null", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!s(<nil>)", "%!d(<nil>)", "'%!s(<nil>)'", "%!d(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "null", "'%!s(<nil>)'"}},
 	},
 	{
 		name:          "mssql2oracle_wide",
@@ -795,7 +799,7 @@ var oracleTransferTests = []transferTest{
 		targetTable:   "oracle_wide_table",
 		transferQuery: "select * from wide_table",
 		checkQuery:    "select * from oracle_wide_table",
-		checkResult:   QueryResult{ColumnTypes: map[string]string{"MYBINARY_DOUBLE": "FLOAT", "MYBINARY_FLOAT": "FLOAT", "MYBLOB": "VARBINARY", "MYCHAR": "NTEXT", "MYCLOB": "NVARCHAR", "MYDATE": "DATE", "MYLONG": "NTEXT", "MYNCHAR": "NTEXT", "MYNUMBER": "FLOAT", "MYNVARCHAR2": "NTEXT", "MYTIMESTAMP": "DATETIME2", "MYTIMESTAMPTZ": "DATETIME2", "MYTIMESTAMPWITHLOCALTZ": "DATETIME2", "MYVARCHAR": "NTEXT", "MYVARCHAR2": "NTEXT"}, Rows: []interface{}{"'chr'", "'my vr''c\",hr'", "'my vrchr2'", "'ncr'", "'mynvarch2'", "'myclob'", "'wow such long text wow'", "12.5", "47.5", "900.2", "CONVERT(DATETIME2, '2005-09-16 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '2021-07-22 10:18:59.1946810', 121)", "CONVERT(DATETIME2, '2021-07-22 10:18:59.1946810', 121)", "CONVERT(DATETIME2, '2021-07-22 09:18:59.1946810', 121)", "CONVERT(VARBINARY(8000), '0x111a', 1)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!g(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)"}},
+		checkResult:   QueryResult{ColumnTypes: map[string]string{"MYBINARY_DOUBLE": "FLOAT", "MYBINARY_FLOAT": "FLOAT", "MYBLOB": "VARBINARY", "MYCHAR": "NTEXT", "MYCLOB": "NVARCHAR", "MYDATE": "DATE", "MYLONG": "NTEXT", "MYNCHAR": "NTEXT", "MYNUMBER": "FLOAT", "MYNVARCHAR2": "NTEXT", "MYTIMESTAMP": "DATETIME2", "MYTIMESTAMPTZ": "DATETIME2", "MYTIMESTAMPWITHLOCALTZ": "DATETIME2", "MYVARCHAR": "NTEXT", "MYVARCHAR2": "NTEXT"}, Rows: []interface{}{"'chr'", "'my vr''c\",hr'", "'my vrchr2'", "'ncr'", "'mynvarch2'", "'myclob'", "'wow such long text wow'", "12.5", "47.5", "900.2", "CONVERT(DATETIME2, '2005-09-16 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '2021-07-22 10:18:59.1946810', 121)", "CONVERT(DATETIME2, '2021-07-22 10:18:59.1946810', 121)", "CONVERT(DATETIME2, '2021-07-22 09:18:59.1946810', 121)", "CONVERT(VARBINARY(MAX), '0x111a', 1)", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "%!g(<nil>)", "%!g(<nil>)", "%!g(<nil>)", "null", "null", "null", "null", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)"}},
 	},
 	{
 		name:          "oracle2oracle_wide",
@@ -929,7 +933,7 @@ var snowflakeTransferTests = []transferTest{
 		targetTable:   "snowflake_wide_table",
 		transferQuery: "select * from wide_table",
 		checkQuery:    "select * from snowflake_wide_table",
-		checkResult:   QueryResult{ColumnTypes: map[string]string{"MYARRAY": "NVARCHAR", "MYBINARY": "VARBINARY", "MYBOOLEAN": "BIT", "MYDATE": "DATETIME2", "MYFLOAT": "FLOAT", "MYGEOGRAPHY": "NVARCHAR", "MYINT": "BIGINT", "MYNUMBER": "FLOAT", "MYOBJECT": "NVARCHAR", "MYTIME": "DATETIME2", "MYTIMESTAMP_LTZ": "DATETIME2", "MYTIMESTAMP_NTZ": "DATETIME2", "MYTIMESTAMP_TZ": "DATETIME2", "MYVARCHAR": "NVARCHAR", "MYVARIANT": "NVARCHAR"}, Rows: []interface{}{"25.5", "22", "42.5", "'hellooooo h''er\"es ,my varchar value'", "CONVERT(VARBINARY(8000), '0x0011', 1)", "1", "CONVERT(DATETIME2, '2000-10-15 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 23:54:01.0000000', 121)", "CONVERT(DATETIME2, '2000-10-16 07:54:01.3456730', 121)", "CONVERT(DATETIME2, '2000-10-15 23:54:01.3456730', 121)", "CONVERT(DATETIME2, '2000-10-15 23:54:01.3456730', 121)", "'{  \"mykey\": \"this is \\\"my'' v,al\"}'", "'{  \"key3\": \"value3\",  \"key4\": \"value4\"}'", "'[  true,  1,  -1.200000000000000e-03,  \"Abc\",  [    \"x\",    \"y\"  ],  {    \"a\": 1  }]'", "'{  \"coordinates\": [    -122.35,    37.55  ],  \"type\": \"Point\"}'", "%!g(<nil>)", "%!d(<nil>)", "%!g(<nil>)", "'%!s(<nil>)'", "CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)", "null", "null", "null", "null", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'"}},
+		checkResult:   QueryResult{ColumnTypes: map[string]string{"MYARRAY": "NVARCHAR", "MYBINARY": "VARBINARY", "MYBOOLEAN": "BIT", "MYDATE": "DATETIME2", "MYFLOAT": "FLOAT", "MYGEOGRAPHY": "NVARCHAR", "MYINT": "BIGINT", "MYNUMBER": "FLOAT", "MYOBJECT": "NVARCHAR", "MYTIME": "DATETIME2", "MYTIMESTAMP_LTZ": "DATETIME2", "MYTIMESTAMP_NTZ": "DATETIME2", "MYTIMESTAMP_TZ": "DATETIME2", "MYVARCHAR": "NVARCHAR", "MYVARIANT": "NVARCHAR"}, Rows: []interface{}{"25.5", "22", "42.5", "'hellooooo h''er\"es ,my varchar value'", "CONVERT(VARBINARY(MAX), '0x0011', 1)", "1", "CONVERT(DATETIME2, '2000-10-15 00:00:00.0000000', 121)", "CONVERT(DATETIME2, '0001-01-01 23:54:01.0000000', 121)", "CONVERT(DATETIME2, '2000-10-16 07:54:01.3456730', 121)", "CONVERT(DATETIME2, '2000-10-15 23:54:01.3456730', 121)", "CONVERT(DATETIME2, '2000-10-15 23:54:01.3456730', 121)", "'{  \"mykey\": \"this is \\\"my'' v,al\"}'", "'{  \"key3\": \"value3\",  \"key4\": \"value4\"}'", "'[  true,  1,  -1.200000000000000e-03,  \"Abc\",  [    \"x\",    \"y\"  ],  {    \"a\": 1  }]'", "'{  \"coordinates\": [    -122.35,    37.55  ],  \"type\": \"Point\"}'", "%!g(<nil>)", "%!d(<nil>)", "%!g(<nil>)", "'%!s(<nil>)'", "CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)", "null", "null", "null", "null", "null", "null", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'", "'%!s(<nil>)'"}},
 	},
 	{
 		name:          "snowflake2oracle_wide",
@@ -1504,3 +1508,74 @@ func TestSnowflakeTransfers(t *testing.T) {
 		})
 	}
 }
+
+type mapSecretProvider map[string]string
+
+func (p mapSecretProvider) Resolve(ref string) (string, error) {
+	secret, ok := p[ref]
+	if !ok {
+		return "", secrets.ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func TestGetDsResolvesSecretReferences(t *testing.T) {
+	SetSecretProvider(mapSecretProvider{"kv/warehouse#password": "hunter2"})
+	defer SetSecretProvider(nil)
+
+	connection := data.Connection{Name: "warehouse", DsType: "postgresql", Username: "etl", Password: "secret:kv/warehouse#password", Hostname: "db.internal", Port: 5432, DbName: "dw"}
+
+	dsConn, errProperties, err := GetDs(connection)
+	defer dsConn.closeDb()
+	if err != nil {
+		t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+	}
+	if _, _, connString := dsConn.getConnectionInfo(); !strings.Contains(connString, ":hunter2@") {
+		t.Errorf("wanted the resolved password in the connection string, got %q", connString)
+	}
+
+	connection.Password = "secret:kv/warehouse#token"
+	dsConn, errProperties, err = GetDs(connection)
+	defer dsConn.closeDb()
+	if err == nil || !strings.Contains(errProperties["error"], "secret not found") || errProperties["connection"] != "warehouse" {
+		t.Errorf("wanted a missing secret error for warehouse, got %v %v", err, errProperties)
+	}
+}
+
+func TestGetRowsBindsQueryArgs(t *testing.T) {
+	query := "select id, name from events where created_at >= $1 and created_at < $2 and kind = $3"
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results[query] = fakeResult{
+		columns: []string{"id", "name"},
+		types:   []string{"INT8", "TEXT"},
+		rows:    [][]driver.Value{{int64(1), "signup"}},
+	}
+	target, targetFake := newFakeMSSQL(t, "target")
+
+	transfer := data.Transfer{
+		Query: query,
+		QueryArgs: data.QueryArgs{
+			{Type: data.QueryArgDate, Value: "2022-01-01"},
+			{Type: data.QueryArgTimestamp, Value: "2022-02-01T00:00:00Z"},
+			{Type: data.QueryArgString, Value: "o'brien"},
+		},
+		TargetTable: "events_copy",
+	}
+	errProperties, err := runFakeInsertFrom(t, source, target, transfer)
+	if err != nil {
+		t.Fatalf("insert failed. err: %v, errProperties: %v", err, errProperties)
+	}
+
+	// the query runs unchanged, with the args bound rather than spliced in
+	want := [][]driver.Value{{
+		time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+		"o'brien",
+	}}
+	if got := fake.boundArgs(query); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted args %v bound, got %v", want, got)
+	}
+	if !strings.Contains(targetFake.committedStatements()[0], "'signup'") {
+		t.Errorf("wanted the source row inserted, got %v", targetFake.committedStatements())
+	}
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Keeps *sql.DB handles open between transfers, keyed by connection ID, so
+// repeated runs against the same connection reuse a warm pool. Handles that
+// go unused for longer than ttl are closed.
+type dbCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	handles map[int64]*cachedDb
+	// handles dropped from the cache while transfers were still using them,
+	// closed once the last of those releases it
+	retired map[*sql.DB]*cachedDb
+}
+
+type cachedDb struct {
+	db         *sql.DB
+	version    int
+	connString string
+	initSQL    string
+	users      int
+	lastUsed   time.Time
+}
+
+// nil until EnableDbCache is called, in which case every transfer opens and
+// closes its own handles
+var dbs *dbCache
+
+func newDbCache(ttl time.Duration) *dbCache {
+	return &dbCache{ttl: ttl, handles: map[int64]*cachedDb{}, retired: map[*sql.DB]*cachedDb{}}
+}
+
+// Turns on the handle cache for the rest of the process. A ttl of 0 leaves
+// it off.
+func EnableDbCache(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	cache := newDbCache(ttl)
+	dbs = cache
+
+	go func() {
+		for range time.Tick(ttl / 2) {
+			cache.evictIdle(time.Now())
+		}
+	}()
+}
+
+// Closes the cached handle for a connection, if any. Should be called when
+// the connection is updated or deleted.
+func EvictDb(connectionID int64) {
+	if dbs != nil {
+		dbs.evict(connectionID)
+	}
+}
+
+func openDb(connection data.Connection, driverName string, connString string) (*sql.DB, error) {
+	initSQL := sessionInitSQL(connection)
+	if dbs == nil || connection.ID == 0 {
+		return sqlOpen(driverName, connString, initSQL)
+	}
+	return dbs.open(connection, driverName, connString, initSQL)
+}
+
+func releaseDb(db *sql.DB) {
+	if db == nil {
+		return
+	}
+	if dbs == nil || !dbs.release(db) {
+		db.Close()
+	}
+}
+
+func (c *dbCache) open(connection data.Connection, driverName string, connString string, initSQL []string) (*sql.DB, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// transfers may set the session up differently on the same connection
+	session := strings.Join(initSQL, ";\n")
+
+	cached, ok := c.handles[connection.ID]
+	if ok && cached.version == connection.Version && cached.connString == connString && cached.initSQL == session {
+		cached.users++
+		cached.lastUsed = time.Now()
+		return cached.db, nil
+	}
+
+	// the connection changed since the handle was opened
+	if ok {
+		c.remove(connection.ID)
+	}
+
+	db, err := sqlOpen(driverName, connString, initSQL)
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxIdleTime(c.ttl)
+
+	c.handles[connection.ID] = &cachedDb{
+		db:         db,
+		version:    connection.Version,
+		connString: connString,
+		initSQL:    session,
+		users:      1,
+		lastUsed:   time.Now(),
+	}
+
+	return db, nil
+}
+
+// Returns false if db isn't in the cache, and should be closed by the caller
+func (c *dbCache) release(db *sql.DB) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, cached := range c.handles {
+		if cached.db == db {
+			cached.users--
+			cached.lastUsed = time.Now()
+			return true
+		}
+	}
+
+	if cached, ok := c.retired[db]; ok {
+		cached.users--
+		if cached.users == 0 {
+			delete(c.retired, db)
+			db.Close()
+		}
+		return true
+	}
+	return false
+}
+
+func (c *dbCache) evict(connectionID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.handles[connectionID]; ok {
+		c.remove(connectionID)
+	}
+}
+
+func (c *dbCache) evictIdle(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, cached := range c.handles {
+		if cached.users == 0 && now.Sub(cached.lastUsed) > c.ttl {
+			c.remove(id)
+		}
+	}
+}
+
+// Drops a handle from the cache. Handles still in use by transfers are
+// retired instead, and closed by releaseDb once the last of them finishes.
+// Callers must hold mu.
+func (c *dbCache) remove(connectionID int64) {
+	cached := c.handles[connectionID]
+	delete(c.handles, connectionID)
+	if cached.users == 0 {
+		cached.db.Close()
+		return
+	}
+	c.retired[cached.db] = cached
+}
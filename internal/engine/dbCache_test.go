@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func useDbCache(t *testing.T, ttl time.Duration) *dbCache {
+	cache := newDbCache(ttl)
+	dbs = cache
+	t.Cleanup(func() { dbs = nil })
+	return cache
+}
+
+// Opens a handle for connection the way a transfer does, and releases it
+func openCachedPostgreSQL(t *testing.T, connection data.Connection) PostgreSQL {
+	t.Helper()
+
+	dsConn, _, err := GetDs(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsConn.closeDb()
+	return dsConn.(PostgreSQL)
+}
+
+func TestDbCacheReusesHandles(t *testing.T) {
+	useDbCache(t, time.Minute)
+
+	connection := data.Connection{
+		ID:       7,
+		DsType:   "postgresql",
+		Username: "sqlpipe",
+		Password: "secret",
+		Hostname: "localhost",
+		Port:     5432,
+		DbName:   "sqlpipe",
+		Version:  1,
+	}
+
+	first := openCachedPostgreSQL(t, connection)
+	second := openCachedPostgreSQL(t, connection)
+	if first.db != second.db {
+		t.Fatal("wanted both transfers to share one *sql.DB")
+	}
+
+	// an update bumps the version, which the next transfer sees
+	connection.Version++
+	updated := openCachedPostgreSQL(t, connection)
+	if updated.db == first.db {
+		t.Fatal("wanted a new *sql.DB after the connection was updated")
+	}
+	if err := first.db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Errorf("wanted the stale handle closed, got %v", err)
+	}
+
+	EvictDb(connection.ID)
+	if err := updated.db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Errorf("wanted the evicted handle closed, got %v", err)
+	}
+	if again := openCachedPostgreSQL(t, connection); again.db == updated.db {
+		t.Error("wanted a new *sql.DB after the connection was evicted")
+	}
+}
+
+func TestDbCacheKeepsHandlesInUse(t *testing.T) {
+	cache := useDbCache(t, time.Minute)
+
+	connection := data.Connection{ID: 3, DsType: "postgresql", Hostname: "localhost", Port: 5432}
+	dsConn, _, err := GetDs(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := dsConn.(PostgreSQL).db
+
+	EvictDb(connection.ID)
+	cache.evictIdle(time.Now().Add(time.Hour))
+	if err := db.Ping(); err != nil && err.Error() == "sql: database is closed" {
+		t.Fatal("wanted a handle in use to stay open after eviction")
+	}
+
+	// the transfer finishing closes it, since it's no longer cached
+	dsConn.closeDb()
+	if err := db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Errorf("wanted the handle closed once released, got %v", err)
+	}
+}
+
+func TestDbCacheKeepsEvictedHandlesUntilLastRelease(t *testing.T) {
+	cache := useDbCache(t, time.Minute)
+
+	connection := data.Connection{ID: 4, DsType: "postgresql", Hostname: "localhost", Port: 5432}
+	first, _, err := GetDs(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := GetDs(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := first.(PostgreSQL).db
+	if second.(PostgreSQL).db != db {
+		t.Fatal("wanted both transfers to share one *sql.DB")
+	}
+
+	// a transfer that sets its session up differently replaces the handle
+	// while both are still running on it
+	connection.StatementTimeout = 30
+	replaced, _, err := GetDs(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replaced.closeDb()
+	if replaced.(PostgreSQL).db == db {
+		t.Fatal("wanted a new *sql.DB for the new session setup")
+	}
+
+	first.closeDb()
+	if err := db.Ping(); err != nil && err.Error() == "sql: database is closed" {
+		t.Fatal("wanted the handle kept open while another transfer uses it")
+	}
+
+	second.closeDb()
+	if err := db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Errorf("wanted the handle closed once its last user released it, got %v", err)
+	}
+	if len(cache.retired) != 0 {
+		t.Errorf("wanted no retired handles left, got %d", len(cache.retired))
+	}
+}
+
+func TestDbCacheEvictsIdleHandles(t *testing.T) {
+	cache := useDbCache(t, time.Minute)
+
+	connection := data.Connection{ID: 5, DsType: "postgresql", Hostname: "localhost", Port: 5432}
+	db := openCachedPostgreSQL(t, connection).db
+
+	cache.evictIdle(time.Now())
+	if _, ok := cache.handles[connection.ID]; !ok {
+		t.Fatal("wanted a recently used handle kept")
+	}
+
+	cache.evictIdle(time.Now().Add(2 * time.Minute))
+	if _, ok := cache.handles[connection.ID]; ok {
+		t.Fatal("wanted an idle handle evicted")
+	}
+	if err := db.Ping(); err == nil || err.Error() != "sql: database is closed" {
+		t.Errorf("wanted the idle handle closed, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Returned by DiffTransfer for transfers that copied a random sample of
+// their source, whose rows would never match it
+var ErrSampledDiff = errors.New("a sampled transfer can't be compared with its source")
+
+// How a transfer's source query compares to the table it loaded. Delta is
+// the target's rows less the source's, so a partial load is negative.
+type TransferDiff struct {
+	SourceRows     int64  `json:"sourceRows"`
+	TargetRows     int64  `json:"targetRows"`
+	Delta          int64  `json:"delta"`
+	ChecksumColumn string `json:"checksumColumn,omitempty"`
+	SourceChecksum string `json:"sourceChecksum,omitempty"`
+	TargetChecksum string `json:"targetChecksum,omitempty"`
+}
+
+// Counts the rows the transfer's query returns on its source, and the rows
+// in its target table. If checksumColumn isn't empty, the column is summed
+// on both sides too. Hash functions differ from system to system, but a sum
+// of a numeric key comes out the same everywhere.
+func DiffTransfer(transfer data.Transfer, checksumColumn string) (
+	diff TransferDiff,
+	errProperties map[string]string,
+	err error,
+) {
+	sourceSystem, errProperties, err := GetDs(transfer.Source)
+	defer sourceSystem.closeDb()
+	if err != nil {
+		return diff, errProperties, err
+	}
+
+	targetSystem, errProperties, err := GetDs(transfer.Target)
+	defer targetSystem.closeDb()
+	if err != nil {
+		return diff, errProperties, err
+	}
+
+	return diffTransfer(sourceSystem, targetSystem, transfer, checksumColumn)
+}
+
+func diffTransfer(
+	sourceSystem DsConnection,
+	targetSystem DsConnection,
+	transfer data.Transfer,
+	checksumColumn string,
+) (
+	diff TransferDiff,
+	errProperties map[string]string,
+	err error,
+) {
+	diff.ChecksumColumn = checksumColumn
+
+	// which rows a sample copied can't be read back
+	if transfer.SampleRate > 0 {
+		return diff, map[string]string{"sampleRate": strconv.FormatFloat(transfer.SampleRate, 'f', -1, 64)}, ErrSampledDiff
+	}
+
+	args, errProperties, err := queryArgValues(transfer)
+	if err != nil {
+		return diff, errProperties, err
+	}
+
+	// the source query keeps its own WHERE, and its args. A source limit
+	// limits the rows counted the same way it limited the rows copied.
+	sourceQuery := fmt.Sprintf("SELECT %s FROM (%s) sqlpipe_diff", diffAggregates(checksumColumn), sampleSource(sourceSystem, transfer))
+	diff.SourceRows, diff.SourceChecksum, errProperties, err = countRows(sourceSystem, sourceQuery, args...)
+	if err != nil {
+		return diff, errProperties, err
+	}
+
+	table := transfer.TargetTable
+	if transfer.TargetSchema != "" {
+		table = transfer.TargetSchema + "." + table
+	}
+	targetQuery := fmt.Sprintf("SELECT %s FROM %s", diffAggregates(checksumColumn), table)
+	diff.TargetRows, diff.TargetChecksum, errProperties, err = countRows(targetSystem, targetQuery)
+	if err != nil {
+		return diff, errProperties, err
+	}
+
+	diff.Delta = diff.TargetRows - diff.SourceRows
+	return diff, nil, nil
+}
+
+func diffAggregates(checksumColumn string) string {
+	if checksumColumn == "" {
+		return "COUNT(*)"
+	}
+	return fmt.Sprintf("COUNT(*), SUM(%s)", checksumColumn)
+}
+
+// Runs a query made by diffAggregates, and reads back its count and, if it
+// has one, its checksum
+func countRows(dsConn DsConnection, query string, args ...interface{}) (
+	count int64,
+	checksum string,
+	errProperties map[string]string,
+	err error,
+) {
+	rows, errProperties, err := dsConn.execute(query, args...)
+	if err != nil {
+		return count, checksum, errProperties, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return count, checksum, map[string]string{"error": err.Error(), "query": query}, errors.New("unable to read row count")
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	if rows.Next() {
+		err = rows.Scan(valuePtrs...)
+		if err != nil {
+			return count, checksum, map[string]string{"error": err.Error(), "query": query}, errors.New("unable to read row count")
+		}
+	}
+
+	countText := diffNumber(values[0])
+	count, err = strconv.ParseInt(countText, 10, 64)
+	if err != nil {
+		return count, checksum, map[string]string{"count": countText, "query": query}, errors.New("row count isn't a whole number")
+	}
+	if len(values) > 1 {
+		checksum = diffNumber(values[1])
+	}
+
+	return count, checksum, nil, nil
+}
+
+// Writes out a number the same way whichever driver returned it, so sums
+// from different systems can be compared as text. A sum of no rows is
+// NULL, which comes out empty.
+func diffNumber(value interface{}) string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		text = string(v)
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		text = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	return text
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestDiffTransfer(t *testing.T) {
+	transfer := data.Transfer{
+		Query:        "select id from users where created_at > $1",
+		QueryArgs:    data.QueryArgs{{Type: data.QueryArgDate, Value: "2022-01-01"}},
+		TargetSchema: "dbo",
+		TargetTable:  "users",
+	}
+	sourceQuery := "SELECT COUNT(*), SUM(id) FROM (select id from users where created_at > $1) sqlpipe_diff"
+	targetQuery := "SELECT COUNT(*), SUM(id) FROM dbo.users"
+
+	tests := []struct {
+		name      string
+		target    []driver.Value
+		wantDelta int64
+		wantSum   string
+	}{
+		{"matched", []driver.Value{int64(3), int64(6)}, 0, "6"},
+		{"short load", []driver.Value{int64(2), int64(3)}, -1, "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, sourceFake := newFakePostgreSQL(t, "source")
+			// pgx hands numeric sums over as text
+			sourceFake.results[sourceQuery] = fakeResult{
+				columns: []string{"count", "sum"},
+				types:   []string{"INT8", "NUMERIC"},
+				rows:    [][]driver.Value{{int64(3), "6.000"}},
+			}
+			target, targetFake := newFakeMSSQL(t, "target")
+			targetFake.results[targetQuery] = fakeResult{
+				columns: []string{"", ""},
+				types:   []string{"INT", "BIGINT"},
+				rows:    [][]driver.Value{tt.target},
+			}
+
+			diff, errProperties, err := diffTransfer(source, target, transfer, "id")
+			if err != nil {
+				t.Fatalf("%v, %v", err, errProperties)
+			}
+			if diff.SourceRows != 3 || diff.Delta != tt.wantDelta {
+				t.Errorf("wanted 3 source rows and a delta of %d, got %+v", tt.wantDelta, diff)
+			}
+			if diff.SourceChecksum != "6" || diff.TargetChecksum != tt.wantSum {
+				t.Errorf("wanted checksums 6 and %s, got %+v", tt.wantSum, diff)
+			}
+			if bound := sourceFake.boundArgs(sourceQuery); len(bound) != 1 || len(bound[0]) != 1 {
+				t.Errorf("wanted the source query's arg bound, got %v", bound)
+			}
+		})
+	}
+}
+
+func TestDiffTransferSampledSource(t *testing.T) {
+	transfer := data.Transfer{Query: "select id from users", TargetSchema: "dbo", TargetTable: "users", SourceLimit: 10}
+
+	source, sourceFake := newFakePostgreSQL(t, "source")
+	sourceFake.results["SELECT COUNT(*) FROM (SELECT * FROM (select id from users) sqlpipe_sample LIMIT 10) sqlpipe_diff"] = fakeResult{
+		columns: []string{"count"},
+		rows:    [][]driver.Value{{int64(10)}},
+	}
+	target, targetFake := newFakeMSSQL(t, "target")
+	targetFake.results["SELECT COUNT(*) FROM dbo.users"] = fakeResult{
+		columns: []string{""},
+		rows:    [][]driver.Value{{int64(10)}},
+	}
+
+	diff, errProperties, err := diffTransfer(source, target, transfer, "")
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	if diff.SourceRows != 10 || diff.Delta != 0 {
+		t.Errorf("wanted only the limited rows counted, got %+v", diff)
+	}
+
+	transfer.SourceLimit = 0
+	transfer.SampleRate = 0.1
+	_, _, err = diffTransfer(source, target, transfer, "")
+	if !errors.Is(err, ErrSampledDiff) {
+		t.Errorf("wanted a sampled transfer refused, got %v", err)
+	}
+}
+
+func TestDiffNumber(t *testing.T) {
+	for value, want := range map[interface{}]string{
+		nil:           "",
+		int64(12):     "12",
+		"120":         "120",
+		"12.500":      "12.5",
+		"12.00":       "12",
+		float64(1e21): "1000000000000000000000",
+	} {
+		if got := diffNumber(value); got != want {
+			t.Errorf("%#v: wanted %q, got %q", value, want, got)
+		}
+	}
+}
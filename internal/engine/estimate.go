@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// The top line of a PostgreSQL or Redshift plan, like
+// Seq Scan on users  (cost=0.00..22.70 rows=1270 width=36)
+var planEstimateRX = regexp.MustCompile(`rows=([0-9]+) width=([0-9]+)`)
+
+// Roughly how much a transfer's query would move. Method is explain when
+// the rows and their width came from the planner, or count when only the
+// rows could be counted, in which case the size is unknown and left at 0.
+type TransferEstimate struct {
+	Rows        int64  `json:"rows"`
+	AvgRowBytes int64  `json:"avgRowBytes"`
+	Bytes       int64  `json:"bytes"`
+	Method      string `json:"method"`
+}
+
+// Estimates the rows and bytes the transfer's query returns on its source,
+// without running it. Systems whose plans give row estimates and widths
+// are only asked for a plan. Others get a COUNT(*) of the query, which
+// reads but never writes.
+func EstimateTransfer(transfer data.Transfer) (
+	estimate TransferEstimate,
+	errProperties map[string]string,
+	err error,
+) {
+	sourceSystem, errProperties, err := GetDs(transfer.Source)
+	defer sourceSystem.closeDb()
+	if err != nil {
+		return estimate, errProperties, err
+	}
+
+	return estimateTransfer(sourceSystem, transfer)
+}
+
+func estimateTransfer(
+	dsConn DsConnection,
+	transfer data.Transfer,
+) (
+	estimate TransferEstimate,
+	errProperties map[string]string,
+	err error,
+) {
+	if !isSelectQuery(transfer.Query) {
+		return estimate, map[string]string{"query": transfer.Query}, errors.New("only SELECT queries can be estimated")
+	}
+
+	args, errProperties, err := queryArgValues(transfer)
+	if err != nil {
+		return estimate, errProperties, err
+	}
+
+	switch dsType, _, _ := dsConn.getConnectionInfo(); dsType {
+	case "postgresql", "redshift":
+		return explainEstimate(dsConn, transfer.Query, args...)
+	}
+
+	estimate.Method = "count"
+	estimate.Rows, _, errProperties, err = countRows(dsConn, fmt.Sprintf("SELECT COUNT(*) FROM (%s) sqlpipe_estimate", transfer.Query), args...)
+	return estimate, errProperties, err
+}
+
+// Reads the planner's row and width estimates off the top of the plan
+func explainEstimate(dsConn DsConnection, query string, args ...interface{}) (
+	estimate TransferEstimate,
+	errProperties map[string]string,
+	err error,
+) {
+	explainQuery, errProperties, err := dsConn.getExplainQuery(query, false)
+	if err != nil {
+		return estimate, errProperties, err
+	}
+
+	rows, errProperties, err := dsConn.execute(explainQuery, args...)
+	if err != nil {
+		return estimate, errProperties, err
+	}
+	defer rows.Close()
+
+	var topLine string
+	if rows.Next() {
+		err = rows.Scan(&topLine)
+		if err != nil {
+			return estimate, map[string]string{"error": err.Error(), "query": explainQuery}, errors.New("unable to read query plan")
+		}
+	}
+
+	match := planEstimateRX.FindStringSubmatch(topLine)
+	if match == nil {
+		return estimate, map[string]string{"plan": topLine, "query": explainQuery}, errors.New("query plan has no row estimate")
+	}
+
+	estimate.Method = "explain"
+	estimate.Rows, _ = strconv.ParseInt(match[1], 10, 64)
+	estimate.AvgRowBytes, _ = strconv.ParseInt(match[2], 10, 64)
+	estimate.Bytes = estimate.Rows * estimate.AvgRowBytes
+	return estimate, nil, nil
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestEstimateTransfer(t *testing.T) {
+	const realRows = 1000
+	transfer := data.Transfer{Query: "select id, name from users"}
+
+	// the plan PostgreSQL gives for a freshly analyzed table of 1000 rows
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results["EXPLAIN select id, name from users"] = fakeResult{
+		columns: []string{"QUERY PLAN"},
+		types:   []string{"TEXT"},
+		rows:    [][]driver.Value{{"Seq Scan on users  (cost=0.00..18.80 rows=986 width=36)"}},
+	}
+
+	estimate, errProperties, err := estimateTransfer(source, transfer)
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	if estimate.Method != "explain" || estimate.Rows < realRows*9/10 || estimate.Rows > realRows*11/10 {
+		t.Errorf("wanted a planner estimate within 10%% of %d rows, got %+v", realRows, estimate)
+	}
+	if estimate.AvgRowBytes != 36 || estimate.Bytes != estimate.Rows*36 {
+		t.Errorf("wanted 36 byte rows, got %+v", estimate)
+	}
+	for _, statement := range fake.executed() {
+		if statement != "EXPLAIN select id, name from users" {
+			t.Errorf("wanted only the plan asked for, got %q", statement)
+		}
+	}
+
+	counted, countFake := newFakeMSSQL(t, "counted")
+	countFake.results["SELECT COUNT(*) FROM (select id, name from users) sqlpipe_estimate"] = fakeResult{
+		columns: []string{""},
+		types:   []string{"INT"},
+		rows:    [][]driver.Value{{int64(realRows)}},
+	}
+	estimate, errProperties, err = estimateTransfer(counted, transfer)
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	if estimate.Method != "count" || estimate.Rows != realRows || estimate.Bytes != 0 {
+		t.Errorf("wanted %d counted rows of unknown size, got %+v", realRows, estimate)
+	}
+
+	transfer.Query = "delete from users"
+	if _, _, err := estimateTransfer(source, transfer); err == nil {
+		t.Error("wanted a query that writes refused")
+	}
+}
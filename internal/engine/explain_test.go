@@ -0,0 +1,52 @@
+package engine
+
+import "testing"
+
+func TestPostgreSQLExplainQuery(t *testing.T) {
+	dsConn := PostgreSQL{dsType: "postgresql"}
+
+	tests := []struct {
+		analyze bool
+		want    string
+	}{
+		{false, "EXPLAIN select * from users where id = 1"},
+		{true, "EXPLAIN ANALYZE select * from users where id = 1"},
+	}
+
+	for _, tt := range tests {
+		got, _, err := dsConn.getExplainQuery("select * from users where id = 1", tt.analyze)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("wanted %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestExplainAnalyzeRejectsDML(t *testing.T) {
+	dsConn, fake := newFakePostgreSQL(t, "source")
+
+	for _, query := range []string{
+		"DELETE FROM users",
+		"  -- clean up\nupdate users set admin = true",
+		"with gone as (delete from users returning id) select * from gone",
+	} {
+		_, _, err := explain(dsConn, query, true)
+		if err == nil {
+			t.Errorf("wanted analyze to be rejected for %q", query)
+		}
+	}
+
+	if executed := fake.executed(); len(executed) != 0 {
+		t.Fatalf("wanted nothing to run, got %q", executed)
+	}
+
+	_, _, err := explain(dsConn, "DELETE FROM users", false)
+	if err != nil {
+		t.Fatalf("wanted plain explain to be allowed on DML, got %v", err)
+	}
+	if executed := fake.executed(); len(executed) != 1 || executed[0] != "EXPLAIN DELETE FROM users" {
+		t.Fatalf("wanted the wrapped query to run, got %q", executed)
+	}
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+type fakeResult struct {
+	columns []string
+	types   []string
+	rows    [][]driver.Value
+	// returned by Exec
+	rowsAffected int64
+}
+
+// fakeDb records every statement a testdb connection is given, so engine
+// logic can be exercised without a live data system. Statements run inside a
+// transaction only count as committed once the transaction commits.
+type fakeDb struct {
+	mu         sync.Mutex
+	statements []string
+	args       [][]driver.Value
+	committed  []string
+	// statements run in each connection's open transaction
+	pending map[*testdb.Conn][]string
+	copied  [][]driver.Value
+	// the isolation level of each transaction begun
+	isolations []sql.IsolationLevel
+	// how many pings to fail before answering, and how many were made
+	pingFailures int
+	pings        int
+	results      map[string]fakeResult
+	failOn       string
+	// queries containing slowOn don't return until they're cancelled, and
+	// cancelled counts them
+	slowOn    string
+	cancelled int
+	// answers queries that aren't in results
+	resolve func(query string) fakeResult
+	// every insert first looks up the target's generated columns, so those
+	// lookups are only recorded if a test asks for them
+	recordLookups bool
+}
+
+func isSchemaLookup(query string) bool {
+	return strings.Contains(query, "FROM information_schema.schemata")
+}
+
+func isGeneratedColumnsLookup(query string) bool {
+	for _, marker := range []string{"is_generated = 'ALWAYS'", "extra IN ('VIRTUAL GENERATED', 'STORED GENERATED')", "c.is_computed = 1", "virtual_column = 'YES'"} {
+		if strings.Contains(query, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// newFakeDb registers a fresh recorder and returns a *sql.DB backed by it.
+// Connections can also be opened with openDb, using testdb.DriverName and
+// the test's name and name as the connection string.
+func newFakeDb(t testing.TB, name string) (*sql.DB, *fakeDb) {
+	t.Helper()
+
+	fake := &fakeDb{results: map[string]fakeResult{}, pending: map[*testdb.Conn][]string{}}
+	db := testdb.Open(t, name, &testdb.DB{
+		Handler:    fake.handle,
+		OnBegin:    fake.begin,
+		OnCommit:   fake.commit,
+		OnRollback: fake.rollback,
+		OnPing:     fake.ping,
+		WrapConn:   func(conn *testdb.Conn) driver.Conn { return fakeConn{conn, fake} },
+	})
+
+	return db, fake
+}
+
+func (f *fakeDb) executed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.statements...)
+}
+
+// Statements that were run outside a transaction, or inside one that committed
+func (f *fakeDb) committedStatements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.committed...)
+}
+
+// The values bound to each run of query, in order
+func (f *fakeDb) boundArgs(query string) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var bound [][]driver.Value
+	for i, statement := range f.statements {
+		if statement == query {
+			bound = append(bound, f.args[i])
+		}
+	}
+	return bound
+}
+
+// Like drivers that cancel queries on the server, a slow query stops as soon
+// as its context is done
+func (f *fakeDb) handle(ctx context.Context, c *testdb.Conn, query string, args []driver.Value) (testdb.Result, error) {
+	f.mu.Lock()
+	slow := f.slowOn != "" && strings.Contains(query, f.slowOn)
+	f.mu.Unlock()
+	if slow {
+		<-ctx.Done()
+		f.mu.Lock()
+		f.cancelled++
+		f.mu.Unlock()
+		return testdb.Result{}, ctx.Err()
+	}
+
+	result, err := f.run(c, query, args)
+	return testdb.Result{
+		Columns:      result.columns,
+		Types:        result.types,
+		Rows:         result.rows,
+		RowsAffected: result.rowsAffected,
+	}, err
+}
+
+func (f *fakeDb) run(c *testdb.Conn, query string, args []driver.Value) (fakeResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if isGeneratedColumnsLookup(query) && !f.recordLookups {
+		if result, ok := f.results[query]; ok || f.resolve == nil {
+			return result, nil
+		}
+		return f.resolve(query), nil
+	}
+	// nor are lookups of the target schema, which exists unless results
+	// says otherwise
+	if isSchemaLookup(query) {
+		if result, ok := f.results[query]; ok {
+			return result, nil
+		}
+		return fakeResult{columns: []string{"count"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(1)}}}, nil
+	}
+
+	f.statements = append(f.statements, query)
+	f.args = append(f.args, append([]driver.Value{}, args...))
+	if f.failOn != "" && strings.Contains(query, f.failOn) {
+		return fakeResult{}, errors.New("fake failure")
+	}
+	if c.InTx {
+		f.pending[c] = append(f.pending[c], query)
+	} else {
+		f.committed = append(f.committed, query)
+	}
+	if result, ok := f.results[query]; ok || f.resolve == nil {
+		return result, nil
+	}
+	return f.resolve(query), nil
+}
+
+func (f *fakeDb) begin(c *testdb.Conn, opts driver.TxOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.isolations = append(f.isolations, sql.IsolationLevel(opts.Isolation))
+	return nil
+}
+
+func (f *fakeDb) commit(c *testdb.Conn) error {
+	f.mu.Lock()
+	f.committed = append(f.committed, f.pending[c]...)
+	f.mu.Unlock()
+	return f.rollback(c)
+}
+
+func (f *fakeDb) rollback(c *testdb.Conn) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.pending, c)
+	return nil
+}
+
+func (f *fakeDb) ping(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pings++
+	if f.pingFailures > 0 {
+		f.pingFailures--
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (f *fakeDb) beginLevels() []sql.IsolationLevel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sql.IsolationLevel{}, f.isolations...)
+}
+
+// A testdb connection that can copy rows, like pgx's
+type fakeConn struct {
+	*testdb.Conn
+	db *fakeDb
+}
+
+// Like pgx's connection, rows are copied as COPY's text format. Each field
+// is recorded as its unescaped text, or nil for NULL.
+func (c fakeConn) CopyFrom(ctx context.Context, r io.Reader, query string) (pgconn.CommandTag, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.db.run(c.Conn, query, nil); err != nil {
+		return nil, err
+	}
+
+	unescaper := strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+	lines := strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+	if len(text) == 0 {
+		lines = nil
+	}
+
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for _, line := range lines {
+		var row []driver.Value
+		for _, field := range strings.Split(line, "\t") {
+			if field == `\N` {
+				row = append(row, nil)
+			} else {
+				row = append(row, unescaper.Replace(field))
+			}
+		}
+		c.db.copied = append(c.db.copied, row)
+	}
+	return pgconn.CommandTag(fmt.Sprintf("COPY %d", len(lines))), nil
+}
+
+// Rows sent to COPY statements, in order
+func (f *fakeDb) copiedRows() [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]driver.Value{}, f.copied...)
+}
@@ -0,0 +1,615 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+var (
+	numericDbTypeRX = regexp.MustCompile(`(?i)^(UNSIGNED )?(TINYINT|SMALLINT|MEDIUMINT|INT|INTEGER|BIGINT|INT2|INT4|INT8|FLOAT|FLOAT4|FLOAT8|DOUBLE|REAL|NUMERIC|DECIMAL|NUMBER|FIXED)$`)
+	jsonNumberRX    = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+	binaryDbTypeRX  = regexp.MustCompile(`(?i)^(BYTEA|TINYBLOB|BLOB|MEDIUMBLOB|LONGBLOB|BINARY|VARBINARY|IMAGE|RAW|LONG RAW)$`)
+)
+
+// Writes the result set to transfer.TargetFile instead of a data system. The
+// format comes from the file extension, and the output is compressed as
+// transfer.Compress says, or as a trailing .gz or .zst says. A TargetFile of
+// "-" writes ndjson to stdout.
+func fileInsert(
+	sqlRows *sql.Rows,
+	transfer data.Transfer,
+	resultSetColumnInfo ResultSetColumnInfo,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	errProperties = map[string]string{"targetFile": transfer.TargetFile}
+
+	buffered, stop := bufferRows(sqlRows, transfer, resultSetColumnInfo.NumCols)
+	defer stop()
+	rows := &countingRows{sourceRows: buffered}
+
+	compression := fileCompression(transfer)
+
+	writeRow, writeHeader, err := fileWriters(transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	// truncating and recreating a file are the same thing
+	overwrite := transfer.Mode() != data.WriteModeAppend
+
+	if overwrite {
+		err = checkFileOverwrite(transfer.TargetFile)
+		if err != nil {
+			return errProperties, err
+		}
+	}
+
+	// appending to a file that already has rows shouldn't repeat the header
+	isEmpty := true
+
+	var out io.Writer
+	if transfer.TargetFile == "-" {
+		out = os.Stdout
+	} else {
+		flags := os.O_CREATE | os.O_WRONLY
+		if overwrite {
+			flags |= os.O_TRUNC
+		} else {
+			flags |= os.O_APPEND
+		}
+
+		file, err := os.OpenFile(transfer.TargetFile, flags, 0644)
+		if err != nil {
+			errProperties["error"] = err.Error()
+			return errProperties, errors.New("unable to open target file")
+		}
+		defer file.Close()
+		out = file
+
+		info, err := file.Stat()
+		if err == nil && info.Size() > 0 {
+			isEmpty = false
+		}
+	}
+
+	bufferedOut := bufio.NewWriter(out)
+	out = bufferedOut
+
+	// appending to a compressed file adds a new gzip member or zstd frame,
+	// which readers treat as a continuation of the same stream
+	var compressor io.WriteCloser
+	switch compression {
+	case data.CompressGzip:
+		compressor = gzip.NewWriter(out)
+	case data.CompressZstd:
+		compressor, err = zstd.NewWriter(out)
+		if err != nil {
+			errProperties["error"] = err.Error()
+			return errProperties, errors.New("unable to start compressing target file")
+		}
+	}
+	if compressor != nil {
+		out = compressor
+	}
+
+	if writeHeader != nil && isEmpty {
+		err = writeHeader(out, resultSetColumnInfo)
+		if err != nil {
+			errProperties["error"] = err.Error()
+			return errProperties, errors.New("unable to write header to target file")
+		}
+	}
+
+	errProperties, err = writeRows(out, rows, resultSetColumnInfo, writeRow, transfer.BinaryEncoding, errProperties)
+	if err != nil {
+		if compressor != nil {
+			compressor.Close()
+		}
+		return errProperties, err
+	}
+
+	// closing the compressor writes its last block, before the buffer is
+	// flushed to the file
+	if compressor != nil {
+		if err = compressor.Close(); err != nil {
+			errProperties["error"] = err.Error()
+			return errProperties, errors.New("unable to write row to target file")
+		}
+	}
+
+	if err = bufferedOut.Flush(); err != nil {
+		errProperties["error"] = err.Error()
+		return errProperties, errors.New("unable to write row to target file")
+	}
+
+	// files are written in one go, so they're reported as one batch
+	if rows.count > 0 {
+		progress(transfer).OnBatch(rows.count)
+	}
+
+	return nil, nil
+}
+
+// Writes every row to out, adding the error to errProperties on failure.
+// Binary columns are written as text in binaryEncoding, base64 if it's empty.
+func writeRows(
+	out io.Writer,
+	rows sourceRows,
+	resultSetColumnInfo ResultSetColumnInfo,
+	writeRow func(w io.Writer, columnInfo ResultSetColumnInfo, values []interface{}) error,
+	binaryEncoding string,
+	errProperties map[string]string,
+) (map[string]string, error) {
+	numCols := resultSetColumnInfo.NumCols
+	values := make([]interface{}, numCols)
+	valuePtrs := make([]interface{}, numCols)
+	for i := 0; i < numCols; i++ {
+		valuePtrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		err := rows.Scan(valuePtrs...)
+		if err != nil {
+			errProperties["error"] = err.Error()
+			return errProperties, errors.New("unable to scan source row")
+		}
+
+		for i, value := range values {
+			values[i] = normalizeValue(resultSetColumnInfo.ColumnDbTypes[i], value)
+			if values[i] != nil && binaryDbTypeRX.MatchString(resultSetColumnInfo.ColumnDbTypes[i]) {
+				values[i] = encodeBinary(values[i], binaryEncoding)
+			}
+		}
+
+		err = writeRow(out, resultSetColumnInfo, values)
+		if err != nil {
+			errProperties["error"] = err.Error()
+			return errProperties, errors.New("unable to write row to target file")
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		errProperties["error"] = err.Error()
+		return errProperties, errors.New("error reading source rows")
+	}
+
+	return nil, nil
+}
+
+// Picks the row and header writers for transfer.TargetFile's format. Formats
+// without a header, and csv with NoHeader, return a nil writeHeader.
+func fileWriters(transfer data.Transfer) (
+	writeRow func(w io.Writer, columnInfo ResultSetColumnInfo, values []interface{}) error,
+	writeHeader func(w io.Writer, columnInfo ResultSetColumnInfo) error,
+	err error,
+) {
+	path := strings.TrimSuffix(strings.TrimSuffix(transfer.TargetFile, ".gz"), ".zst")
+
+	switch {
+	case transfer.TargetFile == "-", strings.HasSuffix(path, ".ndjson"), strings.HasSuffix(path, ".jsonl"):
+		writeRow = writeNDJSONRow
+	case strings.HasSuffix(path, ".csv"):
+		writeRow, writeHeader = csvRowWriter(transfer.NullString), writeCSVHeader
+	default:
+		return nil, nil, errors.New("unsupported target file type, must end in .ndjson, .jsonl or .csv")
+	}
+
+	if transfer.NoHeader {
+		if writeHeader == nil {
+			return nil, nil, errors.New("only .csv files have a header to leave out")
+		}
+		writeHeader = nil
+	}
+	return writeRow, writeHeader, nil
+}
+
+// The compression transfer.Compress asks for, or else the one the target
+// file's extension implies. "" means none
+func fileCompression(transfer data.Transfer) string {
+	switch {
+	case transfer.Compress != "":
+		return transfer.Compress
+	case strings.HasSuffix(transfer.TargetFile, ".gz"):
+		return data.CompressGzip
+	case strings.HasSuffix(transfer.TargetFile, ".zst"):
+		return data.CompressZstd
+	default:
+		return ""
+	}
+}
+
+// Overwriting truncates the file, which can't be done to stdout, pipes and
+// devices, or files marked append-only
+func checkFileOverwrite(path string) error {
+	if path == "-" {
+		return errors.New("stdout can't be overwritten")
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errors.New("target file isn't a regular file, so it can't be overwritten")
+	}
+
+	// append-only files refuse to open for writing without O_APPEND
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrPermission) {
+		return errors.New("target file is append-only or read-only, so it can't be overwritten")
+	}
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	return nil
+}
+
+// Runs query and writes its result set to path, in the format given by the
+// extension. Parent directories are created as needed, and the file only
+// replaces what was at path once every row is written. noHeader leaves a
+// .csv file's header line out.
+func ExportQuery(query *data.Query, path string, noHeader bool) (
+	bytesWritten int64,
+	errProperties map[string]string,
+	err error,
+) {
+	dsConn, errProperties, err := GetDs(query.Connection)
+	defer dsConn.closeDb()
+	if err != nil {
+		return 0, errProperties, err
+	}
+	return exportQuery(dsConn, query.Query, path, noHeader)
+}
+
+func exportQuery(dsConn DsConnection, query string, path string, noHeader bool) (
+	bytesWritten int64,
+	errProperties map[string]string,
+	err error,
+) {
+	errProperties = map[string]string{"outputFile": path}
+	transfer := data.Transfer{TargetFile: path, Overwrite: true, NoHeader: noHeader}
+
+	if path == "-" {
+		return 0, errProperties, errors.New("output file must be a path, not stdout")
+	}
+	if _, _, err = fileWriters(transfer); err != nil {
+		return 0, errProperties, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		errProperties["error"] = err.Error()
+		return 0, errProperties, errors.New("unable to create output directory")
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return 0, errProperties, errors.New("output file is a directory")
+	}
+	// the file is only replaced once it's complete, by renaming
+	err = checkFileOverwrite(path)
+	if err != nil {
+		return 0, errProperties, err
+	}
+
+	rows, errProperties, err := dsConn.execute(query)
+	if err != nil {
+		return 0, errProperties, err
+	}
+	defer rows.Close()
+
+	columnInfo, errProperties, err := getResultSetColumnInfo(dsConn, rows)
+	if err != nil {
+		return 0, errProperties, err
+	}
+	if mysqlConn, ok := dsConn.(MySQL); ok {
+		columnInfo = mysqlConn.describeBooleans(query, nil, columnInfo)
+	}
+
+	// rows are written next to path, under a name that keeps its
+	// extensions, so a failed export leaves any file already at path alone
+	temp, err := os.CreateTemp(filepath.Dir(path), ".sqlpipe-*-"+filepath.Base(path))
+	if err != nil {
+		return 0, map[string]string{"outputFile": path, "error": err.Error()}, errors.New("unable to create output file")
+	}
+	temp.Chmod(0644)
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	transfer.TargetFile = temp.Name()
+	errProperties, err = fileInsert(rows, transfer, columnInfo)
+	if err != nil {
+		if errProperties == nil {
+			errProperties = map[string]string{}
+		}
+		errProperties["targetFile"] = path
+		return 0, errProperties, err
+	}
+
+	err = os.Rename(temp.Name(), path)
+	if err != nil {
+		return 0, map[string]string{"outputFile": path, "error": err.Error()}, errors.New("unable to move output file into place")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, map[string]string{"outputFile": path, "error": err.Error()}, errors.New("unable to read size of output file")
+	}
+
+	return info.Size(), nil, nil
+}
+
+// Reads back every row of the table a finished transfer loaded, and writes
+// them to w as csv or ndjson
+func ExportTransferResult(transfer data.Transfer, format string, w io.Writer) (
+	errProperties map[string]string,
+	err error,
+) {
+	dsConn, errProperties, err := GetDs(transfer.Target)
+	defer dsConn.closeDb()
+	if err != nil {
+		return errProperties, err
+	}
+	return exportTable(dsConn, transfer, format, w)
+}
+
+func exportTable(dsConn DsConnection, transfer data.Transfer, format string, w io.Writer) (
+	errProperties map[string]string,
+	err error,
+) {
+	var writeRow func(w io.Writer, columnInfo ResultSetColumnInfo, values []interface{}) error
+	var writeHeader func(w io.Writer, columnInfo ResultSetColumnInfo) error
+	switch format {
+	case "csv":
+		writeRow, writeHeader = csvRowWriter(""), writeCSVHeader
+	case "json":
+		writeRow = writeNDJSONRow
+	default:
+		return map[string]string{"format": format}, errors.New("unsupported export format, must be csv or json")
+	}
+
+	table := transfer.TargetTable
+	if transfer.TargetSchema != "" {
+		table = transfer.TargetSchema + "." + table
+	}
+
+	rows, errProperties, err := dsConn.execute(fmt.Sprintf("SELECT * FROM %s", table))
+	if err != nil {
+		return errProperties, err
+	}
+	defer rows.Close()
+
+	columnInfo, errProperties, err := getResultSetColumnInfo(dsConn, rows)
+	if err != nil {
+		return errProperties, err
+	}
+
+	buffered := bufio.NewWriter(w)
+	errProperties = map[string]string{"targetTable": table}
+
+	if writeHeader != nil {
+		err = writeHeader(buffered, columnInfo)
+		if err != nil {
+			errProperties["error"] = err.Error()
+			return errProperties, errors.New("unable to write header")
+		}
+	}
+
+	errProperties, err = writeRows(buffered, rows, columnInfo, writeRow, data.BinaryBase64, errProperties)
+	if err != nil {
+		return errProperties, err
+	}
+
+	if err = buffered.Flush(); err != nil {
+		return map[string]string{"targetTable": table, "error": err.Error()}, errors.New("unable to write rows")
+	}
+
+	return nil, nil
+}
+
+// Writes one row as a JSON object keyed by column name, in column order
+func writeNDJSONRow(w io.Writer, columnInfo ResultSetColumnInfo, values []interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	buf.WriteByte('{')
+	for i, colName := range columnInfo.ColumnNames {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := encoder.Encode(colName); err != nil {
+			return err
+		}
+		// Encode terminates each value with a newline
+		buf.Truncate(buf.Len() - 1)
+		buf.WriteByte(':')
+		if err := encoder.Encode(ndjsonValue(columnInfo.ColumnDbTypes[i], values[i])); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Gives booleans and NULLs the same Go type whichever driver scanned them.
+// Drivers return booleans as bool, as text like "t", or, for MySQL BIT
+// columns, as a single byte. MySQL describes its BOOLEAN, really
+// TINYINT(1), as BOOLEAN once describeBooleans has looked it up.
+func normalizeValue(dbType string, value interface{}) interface{} {
+	if v, ok := value.([]byte); ok && v == nil {
+		return nil
+	}
+
+	switch strings.ToUpper(dbType) {
+	case "BOOL", "BOOLEAN":
+		switch v := value.(type) {
+		case []byte:
+			return boolText(string(v))
+		case string:
+			return boolText(v)
+		case int64:
+			return v != 0
+		}
+	case "BIT":
+		if v, ok := value.([]byte); ok && len(v) == 1 && v[0] <= 1 {
+			return v[0] == 1
+		}
+	}
+	return value
+}
+
+// Raw bytes aren't valid text in csv or JSON, so they're written as hex or
+// base64
+func encodeBinary(value interface{}, encoding string) string {
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		raw = []byte(fmt.Sprint(v))
+	}
+
+	if encoding == data.BinaryHex {
+		return hex.EncodeToString(raw)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// Text that isn't a boolean is left as it is
+func boolText(text string) interface{} {
+	switch strings.ToLower(text) {
+	case "t", "true", "1", "y", "yes", "on":
+		return true
+	case "f", "false", "0", "n", "no", "off":
+		return false
+	default:
+		return text
+	}
+}
+
+// Converts a scanned value into something that marshals to the matching JSON
+// type. Drivers often hand numbers back as text, so the column's database type
+// decides whether a value is written as a number.
+func ndjsonValue(dbType string, value interface{}) interface{} {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+
+	switch {
+	case numericDbTypeRX.MatchString(dbType) && jsonNumberRX.MatchString(text):
+		return json.Number(text)
+	case (dbType == "JSON" || dbType == "JSONB") && json.Valid([]byte(text)):
+		return json.RawMessage(text)
+	case isPostgreSQLArray(dbType):
+		if js, err := postgresqlArrayJSON(text, strings.TrimPrefix(dbType, "_")); err == nil {
+			return json.RawMessage(js)
+		}
+		return text
+	default:
+		return text
+	}
+}
+
+func writeCSVHeader(w io.Writer, columnInfo ResultSetColumnInfo) error {
+	var buf bytes.Buffer
+	for i, colName := range columnInfo.ColumnNames {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeCSVField(&buf, colName, "")
+	}
+	buf.WriteString("\r\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Writes NULLs as nullString. Empty strings, and values that happen to equal
+// nullString, are quoted so they can't be mistaken for NULL.
+func csvRowWriter(nullString string) func(w io.Writer, columnInfo ResultSetColumnInfo, values []interface{}) error {
+	return func(w io.Writer, columnInfo ResultSetColumnInfo, values []interface{}) error {
+		var buf bytes.Buffer
+		for i, value := range values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if value == nil {
+				buf.WriteString(nullString)
+				continue
+			}
+			writeCSVField(&buf, csvText(value), nullString)
+		}
+		buf.WriteString("\r\n")
+
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+}
+
+// Quotes a field when RFC 4180 requires it, when it's empty, or when it
+// matches nullString
+func writeCSVField(buf *bytes.Buffer, field string, nullString string) {
+	needsQuotes := field == "" ||
+		field == nullString ||
+		strings.ContainsAny(field, ",\"\r\n") ||
+		field[0] == ' ' || field[len(field)-1] == ' '
+
+	if !needsQuotes {
+		buf.WriteString(field)
+		return
+	}
+
+	buf.WriteByte('"')
+	buf.WriteString(strings.ReplaceAll(field, `"`, `""`))
+	buf.WriteByte('"')
+}
+
+func csvText(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
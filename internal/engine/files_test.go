@@ -0,0 +1,506 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+func runFakeFileInsert(t *testing.T, transfer data.Transfer) {
+	t.Helper()
+	runFakeFileInsertRows(t, transfer, fakeResult{
+		columns: []string{"id", "amount", "note", "payload"},
+		types:   []string{"INT8", "NUMERIC", "TEXT", "JSONB"},
+		rows: [][]driver.Value{
+			{int64(1), "12.50", "first", `{"a": 1}`},
+			{int64(2), nil, `quote " and <tag>`, nil},
+		},
+	})
+}
+
+func runFakeFileInsertRows(t *testing.T, transfer data.Transfer, result fakeResult) {
+	t.Helper()
+
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results[transfer.Query] = result
+
+	rows, columnInfo, errProperties, err := source.getRows(transfer)
+	if err != nil {
+		t.Fatalf("unable to get rows. err: %v, errProperties: %v", err, errProperties)
+	}
+	defer rows.Close()
+
+	errProperties, err = fileInsert(rows, transfer, columnInfo)
+	if err != nil {
+		t.Fatalf("unable to write file. err: %v, errProperties: %v", err, errProperties)
+	}
+}
+
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+	golden, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return golden
+}
+
+func TestNDJSONFileTarget(t *testing.T) {
+	golden := readGolden(t, "transfer.ndjson")
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+
+	transfer := data.Transfer{Query: "select * from events", TargetFile: path, Overwrite: true}
+
+	runFakeFileInsert(t, transfer)
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, golden) {
+		t.Fatalf("\nwanted:\n%s\ngot:\n%s", golden, got)
+	}
+
+	// appending keeps the existing rows
+	transfer.Overwrite = false
+	runFakeFileInsert(t, transfer)
+	got, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append([]byte{}, golden...), golden...); !bytes.Equal(got, want) {
+		t.Fatalf("\nwanted:\n%s\ngot:\n%s", want, got)
+	}
+
+	// overwriting truncates
+	transfer.Overwrite = true
+	runFakeFileInsert(t, transfer)
+	got, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, golden) {
+		t.Fatalf("\nwanted:\n%s\ngot:\n%s", golden, got)
+	}
+}
+
+func TestGzippedNDJSONFileTarget(t *testing.T) {
+	golden := readGolden(t, "transfer.ndjson")
+	path := filepath.Join(t.TempDir(), "out.ndjson.gz")
+
+	runFakeFileInsert(t, data.Transfer{Query: "select * from events", TargetFile: path, Overwrite: true})
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, golden) {
+		t.Fatalf("\nwanted:\n%s\ngot:\n%s", golden, got)
+	}
+}
+
+func TestCompressedCSVFileTarget(t *testing.T) {
+	want := "id,amount,note,payload\r\n1,12.50,first,\"{\"\"a\"\": 1}\"\r\n2,,\"quote \"\" and <tag>\",\r\n"
+
+	tests := []struct {
+		name       string
+		file       string
+		compress   string
+		decompress func(io.Reader) (io.Reader, error)
+	}{
+		{"gz extension", "out.csv.gz", "", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"zstd flag", "out.csv", data.CompressZstd, func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			runFakeFileInsert(t, data.Transfer{Query: "select * from events", TargetFile: path, Overwrite: true, Compress: tt.compress})
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			reader, err := tt.decompress(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("wanted csv:\n%q\ngot:\n%q", want, got)
+			}
+		})
+	}
+}
+
+func TestCSVFileTargetNullString(t *testing.T) {
+	result := fakeResult{
+		columns: []string{"id", "nickname", "note"},
+		types:   []string{"INT8", "TEXT", "TEXT"},
+		rows: [][]driver.Value{
+			{int64(1), nil, ""},
+			{int64(2), `\N`, `says "hi", twice`},
+		},
+	}
+
+	tests := []struct {
+		nullString string
+		want       string
+	}{
+		{`\N`, "id,nickname,note\r\n" + `1,\N,""` + "\r\n" + `2,"\N","says ""hi"", twice"` + "\r\n"},
+		{"", "id,nickname,note\r\n" + `1,,""` + "\r\n" + `2,\N,"says ""hi"", twice"` + "\r\n"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "out.csv")
+		runFakeFileInsertRows(t, data.Transfer{Query: "select * from people", TargetFile: path, NullString: tt.nullString}, result)
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("null string %q:\nwanted:\n%s\ngot:\n%s", tt.nullString, tt.want, got)
+		}
+	}
+}
+
+func TestExportQuery(t *testing.T) {
+	query := "select id, name from users"
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results[query] = fakeResult{
+		columns: []string{"id", "name"},
+		types:   []string{"INT8", "TEXT"},
+		rows:    [][]driver.Value{{int64(1), "a"}, {int64(2), "b, c"}},
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "dir", "users.csv")
+
+	bytesWritten, errProperties, err := exportQuery(source, query, path, false)
+	if err != nil {
+		t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+	}
+
+	want := "id,name\r\n1,a\r\n2,\"b, c\"\r\n"
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("wanted:\n%s\ngot:\n%s", want, got)
+	}
+	if bytesWritten != int64(len(want)) {
+		t.Errorf("wanted %d bytes written, got %d", len(want), bytesWritten)
+	}
+
+	// a file where a parent directory should be
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = exportQuery(source, query, filepath.Join(blocker, "users.csv"), false)
+	if err == nil {
+		t.Fatal("wanted an error writing under a regular file")
+	}
+
+	// a failing query leaves no partial file behind
+	fake.failOn = "from users"
+	failed := filepath.Join(dir, "failed.csv")
+	if _, _, err = exportQuery(source, query, failed, false); err == nil {
+		t.Fatal("wanted an error from the failing query")
+	}
+	if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Errorf("wanted no output file, got %v", err)
+	}
+
+	// rows that fail part way leave the file that was there alone
+	fake.failOn = ""
+	fake.results["select amount from ledger"] = fakeResult{
+		columns: []string{"amount"},
+		types:   []string{"FLOAT8"},
+		rows:    [][]driver.Value{{1.5}, {math.NaN()}},
+	}
+	existing := filepath.Join(dir, "ledger.ndjson")
+	if err := os.WriteFile(existing, []byte("{\"amount\":1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = exportQuery(source, "select amount from ledger", existing, false); err == nil {
+		t.Fatal("wanted an error writing a NaN as JSON")
+	}
+	if got, _ := os.ReadFile(existing); string(got) != "{\"amount\":1}\n" {
+		t.Errorf("wanted the existing file kept, got %q", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("wanted nothing left behind, got %v", entries)
+	}
+}
+
+func TestExportQueryNoHeader(t *testing.T) {
+	query := "select id, name from users"
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results[query] = fakeResult{
+		columns: []string{"id", "name"},
+		types:   []string{"INT8", "TEXT"},
+		rows:    [][]driver.Value{{int64(1), "a"}, {int64(2), "b, c"}},
+	}
+
+	dir := t.TempDir()
+	rows := "1,a\r\n2,\"b, c\"\r\n"
+	for noHeader, want := range map[bool]string{false: "id,name\r\n" + rows, true: rows} {
+		path := filepath.Join(dir, fmt.Sprintf("users_%t.csv", noHeader))
+		if _, errProperties, err := exportQuery(source, query, path, noHeader); err != nil {
+			t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("noHeader %t: wanted:\n%s\ngot:\n%s", noHeader, want, got)
+		}
+	}
+
+	if _, _, err := exportQuery(source, query, filepath.Join(dir, "users.ndjson"), true); err == nil {
+		t.Error("wanted an error leaving the header out of a file without one")
+	}
+}
+
+func TestExportTransferResult(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.results["SELECT * FROM public.users"] = fakeResult{
+		columns: []string{"id", "name", "note"},
+		types:   []string{"INT8", "TEXT", "TEXT"},
+		rows:    [][]driver.Value{{int64(1), "a", nil}, {int64(2), "b, c", ""}},
+	}
+	transfer := data.Transfer{ID: 7, TargetSchema: "public", TargetTable: "users", Status: "complete"}
+
+	var csv bytes.Buffer
+	errProperties, err := exportTable(target, transfer, "csv", &csv)
+	if err != nil {
+		t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+	}
+	want := "id,name,note\r\n1,a,\r\n2,\"b, c\",\"\"\r\n"
+	if csv.String() != want {
+		t.Errorf("wanted csv:\n%q\ngot:\n%q", want, csv.String())
+	}
+
+	var ndjson bytes.Buffer
+	errProperties, err = exportTable(target, transfer, "json", &ndjson)
+	if err != nil {
+		t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+	}
+	want = `{"id":1,"name":"a","note":null}` + "\n" + `{"id":2,"name":"b, c","note":""}` + "\n"
+	if ndjson.String() != want {
+		t.Errorf("wanted ndjson:\n%q\ngot:\n%q", want, ndjson.String())
+	}
+
+	if _, err = exportTable(target, transfer, "xml", io.Discard); err == nil {
+		t.Error("wanted an error for an unsupported format")
+	}
+}
+
+func TestFileTargetOverwriteRejectsAppendOnlyTargets(t *testing.T) {
+	transfer := data.Transfer{Query: "select * from events", TargetFile: "-", Overwrite: true}
+
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results[transfer.Query] = fakeResult{columns: []string{"n"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(1)}}}
+	rows, columnInfo, _, err := source.getRows(transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if _, err = fileInsert(rows, transfer, columnInfo); err == nil {
+		t.Error("wanted overwriting stdout rejected")
+	}
+
+	// devices can't be truncated either
+	if _, err := os.Stat(os.DevNull); err == nil {
+		if err := checkFileOverwrite(os.DevNull); err == nil {
+			t.Errorf("wanted overwriting %s rejected", os.DevNull)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFileOverwrite(path); err != nil {
+		t.Errorf("wanted a regular file accepted, got %v", err)
+	}
+}
+
+func TestExportQueryNormalizesBooleans(t *testing.T) {
+	query := "select id, active from users"
+	postgresql, postgresqlFake := newFakePostgreSQL(t, "postgresql")
+	postgresqlFake.results[query] = fakeResult{
+		columns: []string{"id", "active"},
+		types:   []string{"INT8", "BOOL"},
+		rows:    [][]driver.Value{{int64(1), true}, {int64(2), false}, {int64(3), nil}},
+	}
+	db, mysqlFake := newFakeDb(t, "mysql")
+	mysql := MySQL{dsType: "mysql", driverName: testdb.DriverName, db: db}
+	mysqlFake.results[query] = fakeResult{
+		columns: []string{"id", "active"},
+		types:   []string{"BIGINT", "BIT"},
+		rows:    [][]driver.Value{{int64(1), []byte{1}}, {int64(2), []byte{0}}, {int64(3), nil}},
+	}
+
+	want := "{\"id\":1,\"active\":true}\n{\"id\":2,\"active\":false}\n{\"id\":3,\"active\":null}\n"
+	for name, dsConn := range map[string]DsConnection{"postgresql": postgresql, "mysql": mysql} {
+		path := filepath.Join(t.TempDir(), "users.ndjson")
+		if _, errProperties, err := exportQuery(dsConn, query, path, false); err != nil {
+			t.Fatalf("%s: %v %v", name, err, errProperties)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: wanted:\n%s\ngot:\n%s", name, want, got)
+		}
+	}
+}
+
+func TestExportQueryDescribesMySQLBooleans(t *testing.T) {
+	query := "select id, active, level from users"
+	db, fake := newFakeDb(t, "mysql")
+	mysql := MySQL{dsType: "mysql", driverName: testdb.DriverName, db: db}
+	// BOOLEAN and TINYINT(4) columns both come back as TINYINT, as text
+	fake.results[query] = fakeResult{
+		columns: []string{"id", "active", "level"},
+		types:   []string{"BIGINT", "TINYINT", "TINYINT"},
+		rows:    [][]driver.Value{{int64(1), []byte("1"), []byte("1")}, {int64(2), []byte("0"), []byte("3")}},
+	}
+	fake.results["SHOW COLUMNS FROM sqlpipe_column_types"] = fakeResult{
+		columns: []string{"Field", "Type", "Null", "Key", "Default", "Extra"},
+		rows: [][]driver.Value{
+			{"id", "bigint", "NO", "", nil, ""},
+			{"active", "tinyint(1)", "YES", "", nil, ""},
+			{"level", "tinyint", "YES", "", nil, ""},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "users.ndjson")
+	if _, errProperties, err := exportQuery(mysql, query, path, false); err != nil {
+		t.Fatalf("%v %v", err, errProperties)
+	}
+	want := "{\"id\":1,\"active\":true,\"level\":1}\n{\"id\":2,\"active\":false,\"level\":3}\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("wanted:\n%s\ngot:\n%s", want, got)
+	}
+	if executed := fake.executed(); len(executed) != 4 ||
+		executed[1] != "CREATE TEMPORARY TABLE sqlpipe_column_types AS SELECT * FROM ("+query+") sqlpipe_source LIMIT 0" ||
+		executed[3] != "DROP TEMPORARY TABLE sqlpipe_column_types" {
+		t.Errorf("wanted the widths looked up in a temporary table, got %q", executed)
+	}
+
+	// without the privilege to make the table, the columns stay numbers
+	fake.failOn = "CREATE TEMPORARY TABLE"
+	if _, errProperties, err := exportQuery(mysql, query, path, false); err != nil {
+		t.Fatalf("%v %v", err, errProperties)
+	}
+	want = "{\"id\":1,\"active\":1,\"level\":1}\n{\"id\":2,\"active\":0,\"level\":3}\n"
+	if got, _ := os.ReadFile(path); string(got) != want {
+		t.Errorf("wanted:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	for _, tt := range []struct {
+		dbType string
+		value  interface{}
+		want   interface{}
+	}{
+		{"BOOL", []byte("t"), true},
+		{"BOOLEAN", "false", false},
+		{"boolean", int64(1), true},
+		{"BIT", true, true},
+		{"BIT", []byte{0x05}, []byte{0x05}},
+		{"TEXT", []byte(nil), nil},
+		{"TEXT", "t", "t"},
+	} {
+		got := normalizeValue(tt.dbType, tt.value)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %#v: wanted %#v, got %#v", tt.dbType, tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestBinaryFileTarget(t *testing.T) {
+	payload := []byte{0x00, 0xff, '"', ',', '\n', 0x80, 'a'}
+	result := fakeResult{
+		columns: []string{"id", "payload"},
+		types:   []string{"INT8", "BYTEA"},
+		rows:    [][]driver.Value{{int64(1), payload}, {int64(2), nil}},
+	}
+
+	tests := []struct {
+		file     string
+		encoding string
+		want     string
+	}{
+		{"out.csv", "", "id,payload\r\n1," + base64.StdEncoding.EncodeToString(payload) + "\r\n2,\r\n"},
+		{"out.csv", data.BinaryHex, "id,payload\r\n1,00ff222c0a8061\r\n2,\r\n"},
+		{"out.ndjson", "", `{"id":1,"payload":"` + base64.StdEncoding.EncodeToString(payload) + `"}` + "\n" + `{"id":2,"payload":null}` + "\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.file)
+		runFakeFileInsertRows(t, data.Transfer{Query: "select * from blobs", TargetFile: path, BinaryEncoding: tt.encoding}, result)
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s %q:\nwanted:\n%q\ngot:\n%q", tt.file, tt.encoding, tt.want, got)
+		}
+	}
+
+	// the base64 column decodes back to the same bytes
+	path := filepath.Join(t.TempDir(), "out.csv")
+	runFakeFileInsertRows(t, data.Transfer{Query: "select * from blobs", TargetFile: path, NoHeader: true}, result)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(records[0][1])
+	if err != nil {
+		t.Fatalf("wanted valid base64, got %q: %v", records[0][1], err)
+	}
+	if !bytes.Equal(decoded, payload) {
+		t.Errorf("wanted %x back, got %x", payload, decoded)
+	}
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Looks up the target's generated columns in information_schema.columns.
+// currentSchema is the SQL expression for the schema that unqualified names
+// resolve to, and condition picks out the generated columns.
+func standardGeneratedColumns(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	currentSchema string,
+	condition string,
+) (
+	columns []string,
+	errProperties map[string]string,
+	err error,
+) {
+	schema := currentSchema
+	if transfer.TargetSchema != "" {
+		schema = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+
+	return queryColumnNames(dsConn, fmt.Sprintf(
+		"SELECT column_name FROM information_schema.columns WHERE LOWER(table_schema) = LOWER(%s) AND LOWER(table_name) = LOWER('%s') AND %s",
+		schema,
+		transfer.TargetTable,
+		condition,
+	))
+}
+
+// Runs a query that returns a single column of names
+func queryColumnNames(dsConn DsConnection, query string) ([]string, map[string]string, error) {
+	rows, errProperties, err := dsConn.execute(query)
+	if err != nil {
+		return nil, errProperties, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, map[string]string{"error": err.Error(), "query": query}, errors.New("unable to read column name")
+		}
+		names = append(names, name)
+	}
+
+	return names, nil, nil
+}
+
+// Leaves the columns the target generates itself out of the rows, since
+// databases refuse values for them. The target computes them from the
+// columns that are inserted. Recreated targets, and ones made from a table
+// pattern, are created by sqlpipe with plain columns, so they're left alone.
+func skipGeneratedColumns(
+	dsConn DsConnection,
+	rows sourceRows,
+	transfer data.Transfer,
+	columnInfo ResultSetColumnInfo,
+) (
+	sourceRows,
+	ResultSetColumnInfo,
+	map[string]string,
+	error,
+) {
+	if transfer.Mode() == data.WriteModeRecreate || transfer.TargetTablePattern != "" {
+		return rows, columnInfo, nil, nil
+	}
+
+	generated, errProperties, err := dsConn.generatedColumns(transfer)
+	if err != nil || len(generated) == 0 {
+		return rows, columnInfo, errProperties, err
+	}
+
+	var keep []int
+	for i, name := range columnInfo.ColumnNames {
+		isGenerated := false
+		for _, column := range generated {
+			isGenerated = isGenerated || strings.EqualFold(name, column)
+		}
+		if !isGenerated {
+			keep = append(keep, i)
+		}
+	}
+
+	if len(keep) == columnInfo.NumCols {
+		return rows, columnInfo, nil, nil
+	}
+	if len(keep) == 0 {
+		return rows, columnInfo, map[string]string{"generated": strings.Join(generated, ", ")}, errors.New("every transferred column is generated by the target")
+	}
+
+	return newProjectedRows(rows, columnInfo.NumCols, keep), keepColumns(columnInfo, keep), nil, nil
+}
+
+// The column info for just the columns at the keep indexes
+func keepColumns(columnInfo ResultSetColumnInfo, keep []int) ResultSetColumnInfo {
+	kept := ResultSetColumnInfo{
+		NumCols:            len(keep),
+		ColumnNamesToTypes: map[string]string{},
+	}
+	for _, i := range keep {
+		kept.ColumnNames = append(kept.ColumnNames, columnInfo.ColumnNames[i])
+		kept.ColumnDbTypes = append(kept.ColumnDbTypes, columnInfo.ColumnDbTypes[i])
+		kept.ColumnIntermediateTypes = append(kept.ColumnIntermediateTypes, columnInfo.ColumnIntermediateTypes[i])
+		kept.ColumnScanTypes = append(kept.ColumnScanTypes, columnInfo.ColumnScanTypes[i])
+		kept.ColumnLengths = append(kept.ColumnLengths, columnInfo.ColumnLengths[i])
+		kept.LengthOks = append(kept.LengthOks, columnInfo.LengthOks[i])
+		kept.ColumnPrecisions = append(kept.ColumnPrecisions, columnInfo.ColumnPrecisions[i])
+		kept.ColumnScales = append(kept.ColumnScales, columnInfo.ColumnScales[i])
+		kept.PrecisionScaleOks = append(kept.PrecisionScaleOks, columnInfo.PrecisionScaleOks[i])
+		kept.ColumnNullables = append(kept.ColumnNullables, columnInfo.ColumnNullables[i])
+		kept.NullableOks = append(kept.NullableOks, columnInfo.NullableOks[i])
+
+		name := columnInfo.ColumnNames[i]
+		kept.ColumnNamesToTypes[name] = columnInfo.ColumnNamesToTypes[name]
+	}
+	return kept
+}
+
+// Reads every column of the underlying rows, and scans only the kept ones
+// into dest, which must be *interface{} like every insert uses
+type projectedRows struct {
+	sourceRows
+	keep      []int
+	values    []interface{}
+	valuePtrs []interface{}
+}
+
+func newProjectedRows(rows sourceRows, numCols int, keep []int) *projectedRows {
+	projected := &projectedRows{
+		sourceRows: rows,
+		keep:       keep,
+		values:     make([]interface{}, numCols),
+		valuePtrs:  make([]interface{}, numCols),
+	}
+	for i := range projected.values {
+		projected.valuePtrs[i] = &projected.values[i]
+	}
+	return projected
+}
+
+func (r *projectedRows) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.keep) {
+		return fmt.Errorf("wanted %d scan destinations, got %d", len(r.keep), len(dest))
+	}
+
+	err := r.sourceRows.Scan(r.valuePtrs...)
+	if err != nil {
+		return err
+	}
+
+	for i, column := range r.keep {
+		ptr, ok := dest[i].(*interface{})
+		if !ok {
+			return fmt.Errorf("can't scan into a %T", dest[i])
+		}
+		*ptr = r.values[column]
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestInsertSkipsGeneratedColumns(t *testing.T) {
+	transfer := data.Transfer{
+		Query:        "select id, first_name, last_name, full_name from people",
+		TargetSchema: "dbo",
+		TargetTable:  "people",
+	}
+
+	newSource := func(name string) PostgreSQL {
+		source, fake := newFakePostgreSQL(t, name)
+		fake.results[transfer.Query] = fakeResult{
+			columns: []string{"id", "first_name", "last_name", "full_name"},
+			types:   []string{"INT8", "TEXT", "TEXT", "TEXT"},
+			rows:    [][]driver.Value{{int64(1), "Ada", "Lovelace", "Ada Lovelace"}},
+		}
+		return source
+	}
+
+	// the target computes full_name, and refuses any value for it
+	newTarget := func(name string, generated []string) *fakeDb {
+		target, fake := newFakeMSSQL(t, name)
+		fake.failOn = "full_name"
+		fake.resolve = func(query string) fakeResult {
+			result := fakeResult{columns: []string{"name"}, types: []string{"NVARCHAR"}}
+			if isGeneratedColumnsLookup(query) {
+				for _, column := range generated {
+					result.rows = append(result.rows, []driver.Value{column})
+				}
+			}
+			return result
+		}
+		_, err := runFakeInsertFrom(t, newSource(name+"-source"), target, transfer)
+		if (err != nil) != (len(generated) == 0) {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+		return fake
+	}
+
+	// without the lookup, inserting every column fails
+	newTarget("naive", nil)
+
+	fake := newTarget("generated", []string{"FULL_NAME"})
+	committed := fake.committedStatements()
+	if len(committed) != 1 || !strings.Contains(committed[0], "INSERT INTO dbo.people (id, first_name, last_name) VALUES (1,'Ada','Lovelace')") {
+		t.Errorf("wanted full_name left out of the insert, got %v", committed)
+	}
+}
+
+func TestSkipGeneratedColumnsKeepsRecreatedTargets(t *testing.T) {
+	target, fake := newFakeMSSQL(t, "target")
+	fake.recordLookups = true
+
+	transfer := data.Transfer{TargetTable: "people", WriteMode: data.WriteModeRecreate}
+	columnInfo := ResultSetColumnInfo{ColumnNames: []string{"id"}, NumCols: 1}
+	_, kept, _, err := skipGeneratedColumns(target, nil, transfer, columnInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.NumCols != 1 || len(fake.executed()) != 0 {
+		t.Errorf("wanted a recreated target's columns left alone without a lookup, got %+v and %v", kept, fake.executed())
+	}
+}
+
+func TestMySQLGeneratedColumnsLeaveDefaultExpressions(t *testing.T) {
+	target, fake := newFakeMySQL(t, "target")
+	fake.recordLookups = true
+
+	// created_at has DEFAULT (NOW()), which gives it a generation expression
+	// but an EXTRA of DEFAULT_GENERATED
+	columns := map[string]string{"id": "", "created_at": "DEFAULT_GENERATED", "full_name": "VIRTUAL GENERATED", "total": "STORED GENERATED"}
+	fake.resolve = func(query string) fakeResult {
+		result := fakeResult{columns: []string{"column_name"}}
+		for _, name := range []string{"id", "created_at", "full_name", "total"} {
+			if extra := columns[name]; extra != "" && strings.Contains(query, "'"+extra+"'") {
+				result.rows = append(result.rows, []driver.Value{name})
+			}
+		}
+		return result
+	}
+
+	generated, errProperties, err := target.generatedColumns(data.Transfer{TargetTable: "orders"})
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	if strings.Join(generated, ",") != "full_name,total" {
+		t.Errorf("wanted only the virtual and stored generated columns, got %v", generated)
+	}
+}
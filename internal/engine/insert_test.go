@@ -0,0 +1,901 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+func newFakePostgreSQL(t testing.TB, name string) (PostgreSQL, *fakeDb) {
+	db, fake := newFakeDb(t, name)
+	return PostgreSQL{dsType: "postgresql", driverName: testdb.DriverName, db: db}, fake
+}
+
+func newFakeMSSQL(t testing.TB, name string) (MSSQL, *fakeDb) {
+	db, fake := newFakeDb(t, name)
+	return MSSQL{dsType: "mssql", driverName: testdb.DriverName, db: db}, fake
+}
+
+func newFakeMySQL(t testing.TB, name string) (MySQL, *fakeDb) {
+	db, fake := newFakeDb(t, name)
+	return MySQL{dsType: "mysql", driverName: testdb.DriverName, db: db}, fake
+}
+
+func newFakeSource(t *testing.T, query string) PostgreSQL {
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results[query] = fakeResult{
+		columns: []string{"id", "name"},
+		types:   []string{"INT8", "TEXT"},
+		rows:    [][]driver.Value{{int64(1), "a"}, {int64(2), "b"}},
+	}
+	return source
+}
+
+// Like newFakeSource, but returns numRows rows named row-1, row-2, ...
+func newFakeSourceRows(t testing.TB, query string, numRows int) PostgreSQL {
+	source, fake := newFakePostgreSQL(t, "source")
+	result := fakeResult{
+		columns: []string{"id", "name"},
+		types:   []string{"INT8", "TEXT"},
+	}
+	for i := 1; i <= numRows; i++ {
+		result.rows = append(result.rows, []driver.Value{int64(i), fmt.Sprintf("row-%d", i)})
+	}
+	fake.results[query] = result
+	return source
+}
+
+func runFakeInsert(t *testing.T, target DsConnection, transfer data.Transfer) (map[string]string, error) {
+	t.Helper()
+	return runFakeInsertFrom(t, newFakeSource(t, transfer.Query), target, transfer)
+}
+
+func runFakeInsertFrom(t *testing.T, source PostgreSQL, target DsConnection, transfer data.Transfer) (map[string]string, error) {
+	t.Helper()
+
+	rows, columnInfo, errProperties, err := source.getRows(transfer)
+	if err != nil {
+		t.Fatalf("unable to get rows. err: %v, errProperties: %v", err, errProperties)
+	}
+	defer rows.Close()
+
+	return Insert(target, rows, transfer, columnInfo)
+}
+
+// Has runTransfer open each connection as the fake of the same name, until
+// the test ends
+func useFakeDs(t *testing.T, fakes map[string]DsConnection) {
+	t.Helper()
+
+	oldGetDs := getDs
+	t.Cleanup(func() { getDs = oldGetDs })
+	getDs = func(connection data.Connection) (DsConnection, map[string]string, error) {
+		dsConn, ok := fakes[connection.Name]
+		if !ok {
+			t.Fatalf("no fake data system named %q", connection.Name)
+		}
+		return dsConn, nil, nil
+	}
+}
+
+func TestInsertRunsPreLoadSQLFirst(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_copy",
+		PreLoadSQL:   []string{"SET lock_timeout = '5s'", "DELETE FROM public.users_copy WHERE id > 100"},
+	}
+
+	_, err := runFakeInsert(t, target, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executed := fake.executed()
+	if len(executed) != 3 {
+		t.Fatalf("wanted 3 statements, got %d: %q", len(executed), executed)
+	}
+	for i, statement := range transfer.PreLoadSQL {
+		if executed[i] != statement {
+			t.Errorf("statement %d: wanted %q, got %q", i, statement, executed[i])
+		}
+	}
+	if !strings.HasPrefix(executed[2], "COPY public.users_copy") {
+		t.Errorf("wanted copy after pre-load statements, got %q", executed[2])
+	}
+}
+
+func TestInsertAbortsOnFailedPreLoadSQL(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.failOn = "LOCK TABLE"
+
+	transfer := data.Transfer{
+		Query:       "select id, name from users",
+		TargetTable: "users_copy",
+		Overwrite:   true,
+		PreLoadSQL:  []string{"LOCK TABLE users_copy", "SET lock_timeout = '5s'"},
+	}
+
+	_, err := runFakeInsert(t, target, transfer)
+	if err == nil {
+		t.Fatal("wanted an error from the failing pre-load statement")
+	}
+
+	executed := fake.executed()
+	if len(executed) != 1 {
+		t.Fatalf("wanted the transfer to stop after the failing statement, got %q", executed)
+	}
+}
+
+func TestInsertRollsBackFailedBatch(t *testing.T) {
+	// SQL Server targets get a new batch every 1000 rows
+	target, fake := newFakeMSSQL(t, "target")
+	fake.failOn = "'row-1500'"
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "dbo",
+		TargetTable:  "users_copy",
+	}
+	source := newFakeSourceRows(t, transfer.Query, 2500)
+
+	errProperties, err := runFakeInsertFrom(t, source, target, transfer)
+	if err == nil {
+		t.Fatal("wanted an error from the failing batch")
+	}
+	if errProperties["rowsCommitted"] != "1000" {
+		t.Errorf("wanted 1000 rows committed, got %q", errProperties["rowsCommitted"])
+	}
+
+	committed := fake.committedStatements()
+	if len(committed) != 1 {
+		t.Fatalf("wanted only the first batch committed, got %d statements", len(committed))
+	}
+	if !strings.Contains(committed[0], "'row-1000'") || strings.Contains(committed[0], "'row-1001'") {
+		t.Error("wanted the committed batch to hold exactly rows 1 through 1000")
+	}
+}
+
+func TestInsertOverwriteRollsBackDDLWithFirstBatch(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.failOn = "COPY"
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_copy",
+		WriteMode:    data.WriteModeRecreate,
+	}
+
+	_, err := runFakeInsert(t, target, transfer)
+	if err == nil {
+		t.Fatal("wanted an error from the failing batch")
+	}
+
+	// the view and permission checks run outside the transaction
+	if len(fake.executed()) != 5 {
+		t.Fatalf("wanted the view check, permission check, drop, create and copy to run, got %q", fake.executed())
+	}
+	if committed := fake.committedStatements(); len(committed) != 2 {
+		t.Errorf("wanted the drop and create rolled back, got %q committed", committed)
+	}
+}
+
+func TestInsertOverwriteCommitsDDLWithoutRows(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_copy",
+		WriteMode:    data.WriteModeRecreate,
+	}
+
+	_, err := runFakeInsertFrom(t, newFakeSourceRows(t, transfer.Query, 0), target, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	committed := fake.committedStatements()[2:]
+	if len(committed) != 3 || !strings.HasPrefix(committed[0], "DROP TABLE") || !strings.HasPrefix(committed[1], "CREATE TABLE") {
+		t.Errorf("wanted the drop and create committed, got %q", committed)
+	}
+}
+
+func TestInsertOverwriteRejectsViews(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.resolve = func(query string) fakeResult {
+		if strings.Contains(query, "information_schema.tables") && strings.Contains(query, "'users_view'") {
+			return fakeResult{columns: []string{"count"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(1)}}}
+		}
+		return fakeResult{}
+	}
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_view",
+		Overwrite:    true,
+	}
+
+	_, err := runFakeInsert(t, target, transfer)
+	if err == nil || !strings.Contains(err.Error(), "view") {
+		t.Fatalf("wanted an error saying the target is a view, got %v", err)
+	}
+	for _, statement := range fake.executed() {
+		if strings.HasPrefix(statement, "DROP") || strings.HasPrefix(statement, "COPY") {
+			t.Errorf("wanted nothing dropped or loaded, got %q", statement)
+		}
+	}
+
+	// a base table of the same shape is overwritten as usual
+	transfer.TargetTable = "users_copy"
+	if _, err := runFakeInsert(t, target, transfer); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyInsertMatchesBatchInsert(t *testing.T) {
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_copy",
+	}
+	rows := [][]driver.Value{
+		{int64(1), []byte("plain")},
+		{int64(2), nil},
+		{int64(3), []byte("it's\ttabbed")},
+	}
+
+	newSource := func(name string) PostgreSQL {
+		source, fake := newFakePostgreSQL(t, name)
+		fake.results[transfer.Query] = fakeResult{
+			columns: []string{"id", "name"},
+			types:   []string{"INT8", "TEXT"},
+			rows:    rows,
+		}
+		return source
+	}
+
+	copyTarget, copyFake := newFakePostgreSQL(t, "copy")
+	_, err := runFakeInsertFrom(t, newSource("copy-source"), copyTarget, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]driver.Value{{"1", "plain"}, {"2", nil}, {"3", "it's\ttabbed"}}
+	got := copyFake.copiedRows()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("wanted copied rows %v, got %v", want, got)
+	}
+
+	// the batch insert path writes the same values as SQL literals
+	insertTarget, insertFake := newFakeMSSQL(t, "insert")
+	_, err = runFakeInsertFrom(t, newSource("insert-source"), insertTarget, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert := insertFake.committedStatements()[0]
+	for _, literal := range []string{"(1,'plain')", "(2,null)", "(3,'it''s\ttabbed')"} {
+		if !strings.Contains(insert, literal) {
+			t.Errorf("wanted %s in %q", literal, insert)
+		}
+	}
+}
+
+func benchmarkInsert(b *testing.B, newTarget func(tb testing.TB) DsConnection) {
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_copy",
+	}
+	source := newFakeSourceRows(b, transfer.Query, 10000)
+	target := newTarget(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, columnInfo, _, err := source.getRows(transfer)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = Insert(target, rows, transfer, columnInfo)
+		rows.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Measures the client side cost of each load path, since the fake driver
+// does no work of its own
+func BenchmarkCopyInsert(b *testing.B) {
+	benchmarkInsert(b, func(tb testing.TB) DsConnection {
+		target, _ := newFakePostgreSQL(tb, "target")
+		return target
+	})
+}
+
+func BenchmarkBatchInsert(b *testing.B) {
+	benchmarkInsert(b, func(tb testing.TB) DsConnection {
+		target, _ := newFakeMSSQL(tb, "target")
+		return target
+	})
+}
+
+func TestInsertWriteModes(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{data.WriteModeAppend, []string{"COPY"}},
+		{data.WriteModeTruncate, []string{"SELECT COUNT(*) FROM information_schema", "TRUNCATE TABLE public.users_copy", "COPY"}},
+		{data.WriteModeRecreate, []string{"SELECT COUNT(*) FROM information_schema", "SELECT COUNT(*) FROM pg_tables", "DROP TABLE IF EXISTS public.users_copy", "CREATE TABLE public.users_copy", "COPY"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			target, fake := newFakePostgreSQL(t, "target")
+
+			transfer := data.Transfer{
+				Query:        "select id, name from users",
+				TargetSchema: "public",
+				TargetTable:  "users_copy",
+				WriteMode:    tt.mode,
+			}
+
+			_, err := runFakeInsert(t, target, transfer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			executed := fake.executed()
+			if len(executed) != len(tt.want) {
+				t.Fatalf("wanted %d statements, got %q", len(tt.want), executed)
+			}
+			for i, prefix := range tt.want {
+				if !strings.HasPrefix(executed[i], prefix) {
+					t.Errorf("statement %d: wanted %q, got %q", i, prefix, executed[i])
+				}
+			}
+
+			// whatever happened to the old rows, the new ones are all loaded
+			if copied := fake.copiedRows(); len(copied) != 2 {
+				t.Errorf("wanted 2 rows loaded, got %v", copied)
+			}
+		})
+	}
+}
+
+// MySQL writes to the connection's database, so its DDL names no schema
+func TestInsertTruncateMySQL(t *testing.T) {
+	target, fake := newFakeMySQL(t, "target")
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "analytics",
+		TargetTable:  "users_copy",
+		WriteMode:    data.WriteModeTruncate,
+	}
+
+	_, err := runFakeInsert(t, target, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var truncate string
+	for _, statement := range fake.executed() {
+		if strings.HasPrefix(statement, "TRUNCATE") {
+			truncate = statement
+		}
+	}
+	if truncate != "TRUNCATE TABLE users_copy" {
+		t.Errorf("wanted the table truncated without a schema, got %q in %q", truncate, fake.executed())
+	}
+	if inserts := fake.committedStatements(); !strings.HasPrefix(inserts[len(inserts)-1], "INSERT INTO users_copy") {
+		t.Errorf("wanted the rows inserted after truncating, got %q", inserts)
+	}
+}
+
+func TestInsertRecreateNeedsDropPermission(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.resolve = func(query string) fakeResult {
+		if strings.Contains(query, "pg_has_role") {
+			return fakeResult{columns: []string{"count"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(1)}}}
+		}
+		return fakeResult{}
+	}
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "public",
+		TargetTable:  "users_copy",
+		WriteMode:    data.WriteModeRecreate,
+	}
+
+	_, err := runFakeInsert(t, target, transfer)
+	if err == nil || !strings.Contains(err.Error(), "drop") {
+		t.Fatalf("wanted a drop permission error, got %v", err)
+	}
+	for _, statement := range fake.executed() {
+		if strings.HasPrefix(statement, "DROP") || strings.HasPrefix(statement, "COPY") {
+			t.Errorf("wanted nothing changed on the target, got %q", statement)
+		}
+	}
+}
+
+func TestInsertKeepsNumericPrecision(t *testing.T) {
+	exact := []string{"12345678901234567890.123456789", "-0.123456789012345678901234567", "99999999999999999999999999999999999999"}
+	transfer := data.Transfer{Query: "select amount from ledger", TargetSchema: "public", TargetTable: "ledger_copy"}
+
+	// going through a float64 would have rounded every one of them
+	for _, value := range exact {
+		f, _ := strconv.ParseFloat(value, 64)
+		if strconv.FormatFloat(f, 'f', -1, 64) == value {
+			t.Fatalf("%s survives a float64, so it doesn't test anything", value)
+		}
+	}
+
+	newSource := func(name string) PostgreSQL {
+		source, fake := newFakePostgreSQL(t, name)
+		result := fakeResult{columns: []string{"amount"}, types: []string{"NUMERIC"}}
+		for _, value := range exact {
+			// pgx hands numerics over as text
+			result.rows = append(result.rows, []driver.Value{value})
+		}
+		fake.results[transfer.Query] = result
+		return source
+	}
+
+	copyTarget, copyFake := newFakePostgreSQL(t, "copy")
+	_, err := runFakeInsertFrom(t, newSource("copy-source"), copyTarget, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range copyFake.copiedRows() {
+		if row[0] != exact[i] {
+			t.Errorf("wanted %s copied, got %v", exact[i], row[0])
+		}
+	}
+
+	insertTarget, insertFake := newFakePostgreSQL(t, "insert")
+	source := newSource("insert-source")
+	rows, columnInfo, _, err := source.getRows(transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	_, err = sqlInsert(insertTarget, rows, transfer, columnInfo, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert := insertFake.committedStatements()[0]
+	for _, value := range exact {
+		if !strings.Contains(insert, "("+value+")") {
+			t.Errorf("wanted %s in %q", value, insert)
+		}
+	}
+}
+
+func TestInsertUpsertUpdatesExistingRows(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+
+	transfer := data.Transfer{
+		Query:           "select id, name from users",
+		TargetSchema:    "public",
+		TargetTable:     "users_copy",
+		WriteMode:       data.WriteModeUpsert,
+		ConflictColumns: []string{"ID"},
+	}
+
+	// a rerun loads the same rows again, which must land on the ones the
+	// first run inserted
+	for run := 1; run <= 2; run++ {
+		_, err := runFakeInsert(t, target, transfer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if copied := fake.copiedRows(); len(copied) != 0 {
+		t.Errorf("wanted upserts batched instead of copied, got %v", copied)
+	}
+	want := "INSERT INTO public.users_copy (id, name) VALUES (1,'a'),(2,'b') ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name"
+	committed := fake.committedStatements()
+	if len(committed) != 2 {
+		t.Fatalf("wanted one statement per run, got %q", committed)
+	}
+	for _, statement := range committed {
+		if statement != want {
+			t.Errorf("wanted %q, got %q", want, statement)
+		}
+	}
+}
+
+func TestInsertUpsertMergesIntoMSSQL(t *testing.T) {
+	target, fake := newFakeMSSQL(t, "target")
+
+	transfer := data.Transfer{
+		Query:           "select id, name from users",
+		TargetSchema:    "dbo",
+		TargetTable:     "users_copy",
+		WriteMode:       data.WriteModeUpsert,
+		ConflictColumns: []string{"id"},
+	}
+
+	_, err := runFakeInsert(t, target, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "MERGE INTO dbo.users_copy target USING (VALUES (1,'a'),(2,'b')) source (id, name)" +
+		" ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET name = source.name" +
+		" WHEN NOT MATCHED THEN INSERT (id, name) VALUES (source.id, source.name);"
+	if committed := fake.committedStatements(); len(committed) != 1 || committed[0] != want {
+		t.Errorf("wanted %q, got %q", want, committed)
+	}
+}
+
+func TestInsertUpsertNeedsConflictColumnsInResult(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+
+	transfer := data.Transfer{
+		Query:           "select id, name from users",
+		TargetTable:     "users_copy",
+		WriteMode:       data.WriteModeUpsert,
+		ConflictColumns: []string{"email"},
+	}
+
+	errProperties, err := runFakeInsert(t, target, transfer)
+	if err == nil || errProperties["conflictColumn"] != "email" {
+		t.Fatalf("wanted a missing conflict column error, got %v, %v", err, errProperties)
+	}
+	if executed := fake.executed(); len(executed) != 0 {
+		t.Errorf("wanted nothing run on the target, got %q", executed)
+	}
+}
+
+func TestGetUpsertQuery(t *testing.T) {
+	columnInfo := ResultSetColumnInfo{
+		ColumnNames:             []string{"id", "payload"},
+		ColumnIntermediateTypes: []string{"PostgreSQL_INT8", "PostgreSQL_JSONB"},
+	}
+
+	tests := []struct {
+		dsConn          DsConnection
+		conflictColumns []string
+		starter         string
+		ender           string
+	}{
+		{
+			MySQL{}, []string{"id"},
+			"INSERT INTO t (id, payload) VALUES (",
+			" ON DUPLICATE KEY UPDATE payload = VALUES(payload)",
+		},
+		{
+			MySQL{}, []string{"id", "payload"},
+			"INSERT INTO t (id, payload) VALUES (",
+			" ON DUPLICATE KEY UPDATE id = VALUES(id)",
+		},
+		{
+			PostgreSQL{}, []string{"id", "payload"},
+			"INSERT INTO s.t (id, payload) VALUES (",
+			" ON CONFLICT (id, payload) DO NOTHING",
+		},
+		{
+			Oracle{}, []string{"id"},
+			"MERGE INTO t target USING (with t_to_insert (id, payload) as ( SELECT ",
+			") SELECT * FROM t_to_insert) source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET payload = source.payload WHEN NOT MATCHED THEN INSERT (id, payload) VALUES (source.id, source.payload)",
+		},
+		{
+			Snowflake{}, []string{"id"},
+			"MERGE INTO s.t target USING (SELECT column1 AS id, PARSE_JSON(column2) AS payload FROM VALUES (",
+			") source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET payload = source.payload WHEN NOT MATCHED THEN INSERT (id, payload) VALUES (source.id, source.payload)",
+		},
+	}
+
+	for _, tt := range tests {
+		starter, ender, errProperties, err := tt.dsConn.getUpsertQuery("t", "s", columnInfo, tt.conflictColumns)
+		if err != nil {
+			t.Fatalf("%T: %v, %v", tt.dsConn, err, errProperties)
+		}
+		if starter != tt.starter || ender != tt.ender {
+			t.Errorf("%T: wanted %q ... %q, got %q ... %q", tt.dsConn, tt.starter, tt.ender, starter, ender)
+		}
+	}
+
+	if _, _, _, err := (Redshift{dsType: "redshift"}).getUpsertQuery("t", "s", columnInfo, []string{"id"}); err == nil {
+		t.Error("wanted redshift upserts rejected")
+	}
+}
+
+func TestJSONBCopiedFaithfully(t *testing.T) {
+	document := `{"b": {"c": [1, 2.5, {"d": null}], "e": []}, "a": "line\nbreak, \"quoted\", back\\slash and it's"}`
+	var want interface{}
+	if err := json.Unmarshal([]byte(document), &want); err != nil {
+		t.Fatal(err)
+	}
+
+	sameJSON := func(t *testing.T, js string) {
+		t.Helper()
+		var got interface{}
+		if err := json.Unmarshal([]byte(js), &got); err != nil {
+			t.Fatalf("target got invalid JSON %q: %v", js, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("wanted %v, got %v", want, got)
+		}
+	}
+
+	newSource := func(name string) PostgreSQL {
+		source, fake := newFakePostgreSQL(t, name)
+		fake.results["select id, payload from events"] = fakeResult{
+			columns: []string{"id", "payload"},
+			types:   []string{"INT8", "JSONB"},
+			rows:    [][]driver.Value{{int64(1), []byte(document)}},
+		}
+		return source
+	}
+
+	t.Run("copied", func(t *testing.T) {
+		target, fake := newFakePostgreSQL(t, "target")
+		transfer := data.Transfer{Query: "select id, payload from events", TargetSchema: "public", TargetTable: "events"}
+		if _, err := runFakeInsertFrom(t, newSource("source"), target, transfer); err != nil {
+			t.Fatal(err)
+		}
+		copied := fake.copiedRows()
+		if len(copied) != 1 {
+			t.Fatalf("wanted one row copied, got %v", copied)
+		}
+		sameJSON(t, copied[0][1].(string))
+	})
+
+	t.Run("inserted", func(t *testing.T) {
+		target, fake := newFakePostgreSQL(t, "target")
+		transfer := data.Transfer{
+			Query:           "select id, payload from events",
+			TargetSchema:    "public",
+			TargetTable:     "events",
+			WriteMode:       data.WriteModeUpsert,
+			ConflictColumns: []string{"id"},
+		}
+		if _, err := runFakeInsertFrom(t, newSource("source"), target, transfer); err != nil {
+			t.Fatal(err)
+		}
+		insert := fake.committedStatements()[0]
+		start, end := strings.Index(insert, "(1,'")+len("(1,'"), strings.Index(insert, "'::jsonb)")
+		if start < len("(1,'") || end < start {
+			t.Fatalf("wanted the payload cast to jsonb, got %q", insert)
+		}
+		sameJSON(t, strings.ReplaceAll(insert[start:end], "''", "'"))
+	})
+
+	// MySQL string literals read backslashes as escapes, so they're undone
+	// here the way MySQL would
+	t.Run("into mysql", func(t *testing.T) {
+		literal := writeMySQLJSON([]byte(document), "")
+		if !strings.HasPrefix(literal, "CAST('") || !strings.HasSuffix(literal, "' AS JSON)") {
+			t.Fatalf("wanted the payload cast to JSON, got %q", literal)
+		}
+		literal = strings.TrimSuffix(strings.TrimPrefix(literal, "CAST('"), "' AS JSON)")
+		sameJSON(t, strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\"`, `"`, "''", "'").Replace(literal))
+	})
+}
+
+func TestArrayColumnsCopied(t *testing.T) {
+	newSource := func(name string) PostgreSQL {
+		source, fake := newFakePostgreSQL(t, name)
+		fake.results["select id, scores, tags from posts"] = fakeResult{
+			columns: []string{"id", "scores", "tags"},
+			types:   []string{"INT8", "_INT4", "_TEXT"},
+			rows: [][]driver.Value{
+				{int64(1), "{1,2,3}", `{a,"b c","say \"hi\"",NULL}`},
+				{int64(2), "{}", nil},
+			},
+		}
+		return source
+	}
+	transfer := data.Transfer{
+		Query:        "select id, scores, tags from posts",
+		TargetSchema: "public",
+		TargetTable:  "posts",
+		WriteMode:    data.WriteModeRecreate,
+	}
+
+	target, fake := newFakePostgreSQL(t, "target")
+	if _, err := runFakeInsertFrom(t, newSource("source"), target, transfer); err != nil {
+		t.Fatal(err)
+	}
+	var create string
+	for _, statement := range fake.executed() {
+		if strings.HasPrefix(statement, "CREATE TABLE") {
+			create = statement
+		}
+	}
+	if !strings.Contains(create, "scores INT4[]") || !strings.Contains(create, "tags TEXT[]") {
+		t.Errorf("wanted array columns created, got %q", create)
+	}
+	want := [][]driver.Value{
+		{"1", "{1,2,3}", `{a,"b c","say \"hi\"",NULL}`},
+		{"2", "{}", nil},
+	}
+	if copied := fake.copiedRows(); !reflect.DeepEqual(copied, want) {
+		t.Errorf("wanted %v copied, got %v", want, copied)
+	}
+
+	upsert := transfer
+	upsert.WriteMode = data.WriteModeUpsert
+	upsert.ConflictColumns = []string{"id"}
+	target, fake = newFakePostgreSQL(t, "upsert-target")
+	if _, err := runFakeInsertFrom(t, newSource("upsert-source"), target, upsert); err != nil {
+		t.Fatal(err)
+	}
+	if insert := fake.committedStatements()[0]; !strings.Contains(insert, `(1,'{1,2,3}','{a,"b c","say \"hi\"",NULL}')`) {
+		t.Errorf("wanted the arrays inserted as they were, got %q", insert)
+	}
+
+	mssql, fake := newFakeMSSQL(t, "mssql-target")
+	transfer.TargetSchema = "dbo"
+	if _, err := runFakeInsertFrom(t, newSource("mssql-source"), mssql, transfer); err != nil {
+		t.Fatal(err)
+	}
+	insert := strings.Join(fake.committedStatements(), ";")
+	if !strings.Contains(insert, `'[1,2,3]'`) || !strings.Contains(insert, `'["a","b c","say \"hi\"",null]'`) {
+		t.Errorf("wanted the arrays inserted as JSON, got %q", insert)
+	}
+}
+
+func TestPostgreSQLArrayJSON(t *testing.T) {
+	tests := []struct {
+		text        string
+		elementType string
+		want        string
+	}{
+		{"{}", "INT4", "[]"},
+		{"{1,-2,NULL}", "INT8", "[1,-2,null]"},
+		{"{1.5,NaN}", "NUMERIC", `[1.5,"NaN"]`},
+		{"{t,f}", "BOOL", "[true,false]"},
+		{`{"NULL",NULL," x ","a\\b"}`, "TEXT", `["NULL",null," x ","a\\b"]`},
+		{"{{1,2},{3,4}}", "INT4", "[[1,2],[3,4]]"},
+		{"[0:1]={1,2}", "INT4", "[1,2]"},
+		{`{"{\"a\": 1}"}`, "JSONB", `[{"a":1}]`},
+	}
+	for _, test := range tests {
+		got, err := postgresqlArrayJSON(test.text, test.elementType)
+		if err != nil {
+			t.Errorf("%s: %v", test.text, err)
+		} else if got != test.want {
+			t.Errorf("%s: wanted %s, got %s", test.text, test.want, got)
+		}
+	}
+
+	for _, malformed := range []string{"", "{1,2", "1,2}", `{"a}`, "{1}x"} {
+		if _, err := postgresqlArrayJSON(malformed, "INT4"); err == nil {
+			t.Errorf("wanted an error for %q", malformed)
+		}
+	}
+}
+
+func TestBinaryCopiedByteExact(t *testing.T) {
+	payload := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(payload)
+	// bytes that would end or escape a string literal
+	copy(payload, []byte{0x00, '\'', '\\', '"'})
+
+	newSource := func(name string) PostgreSQL {
+		source, fake := newFakePostgreSQL(t, name)
+		fake.results["select id, payload from blobs"] = fakeResult{
+			columns: []string{"id", "payload"},
+			types:   []string{"INT8", "BYTEA"},
+			rows:    [][]driver.Value{{int64(1), payload}, {int64(2), []byte{}}, {int64(3), nil}},
+		}
+		return source
+	}
+	transfer := data.Transfer{Query: "select id, payload from blobs", TargetSchema: "public", TargetTable: "blobs"}
+
+	target, fake := newFakePostgreSQL(t, "target")
+	if _, err := runFakeInsertFrom(t, newSource("source"), target, transfer); err != nil {
+		t.Fatal(err)
+	}
+	copied := fake.copiedRows()
+	if len(copied) != 3 {
+		t.Fatalf("wanted three rows copied, got %d", len(copied))
+	}
+	if got := copied[0][1]; got != fmt.Sprintf(`\x%x`, payload) {
+		t.Errorf("wanted every byte copied as hex, got %.200q", got)
+	}
+	if copied[1][1] != `\x` || copied[2][1] != nil {
+		t.Errorf("wanted an empty payload and a NULL, got %#v and %#v", copied[1][1], copied[2][1])
+	}
+
+	upsert := transfer
+	upsert.WriteMode = data.WriteModeUpsert
+	upsert.ConflictColumns = []string{"id"}
+	target, fake = newFakePostgreSQL(t, "upsert-target")
+	if _, err := runFakeInsertFrom(t, newSource("upsert-source"), target, upsert); err != nil {
+		t.Fatal(err)
+	}
+	insert := fake.committedStatements()[0]
+	if !strings.Contains(insert, fmt.Sprintf(`(1,'\x%x')`, payload)) || !strings.Contains(insert, `(2,'\x')`) || !strings.Contains(insert, "(3,null)") {
+		t.Errorf("wanted every byte written as hex, got %.200q", insert)
+	}
+
+	mssql, fake := newFakeMSSQL(t, "mssql-target")
+	transfer.TargetSchema = "dbo"
+	if _, err := runFakeInsertFrom(t, newSource("mssql-source"), mssql, transfer); err != nil {
+		t.Fatal(err)
+	}
+	insert = strings.Join(fake.committedStatements(), ";")
+	if !strings.Contains(insert, fmt.Sprintf("CONVERT(VARBINARY(MAX), '0x%x', 1)", payload)) || !strings.Contains(insert, "(3,null)") {
+		t.Errorf("wanted the payload converted without a length limit, got %.200q", insert)
+	}
+}
+
+func TestInsertSkipsRejectedRowsUpToMaxErrors(t *testing.T) {
+	// SQL Server targets get a new batch every 1000 rows
+	newTarget := func() (MSSQL, *fakeDb) {
+		target, fake := newFakeMSSQL(t, "target")
+		fake.failOn = "'bad-"
+		return target, fake
+	}
+	newSource := func(query string) PostgreSQL {
+		source, fake := newFakePostgreSQL(t, "source")
+		result := fakeResult{
+			columns: []string{"id", "name"},
+			types:   []string{"INT8", "TEXT"},
+		}
+		for i := 1; i <= 2500; i++ {
+			name := fmt.Sprintf("row-%d", i)
+			if i == 3 || i == 1500 || i == 2200 {
+				name = fmt.Sprintf("bad-%d", i)
+			}
+			result.rows = append(result.rows, []driver.Value{int64(i), name})
+		}
+		fake.results[query] = result
+		return source
+	}
+
+	transfer := data.Transfer{
+		Query:        "select id, name from users",
+		TargetSchema: "dbo",
+		TargetTable:  "users_copy",
+		MaxErrors:    5,
+		Rejects:      &data.RejectCounter{},
+	}
+	target, fake := newTarget()
+	errProperties, err := runFakeInsertFrom(t, newSource(transfer.Query), target, transfer)
+	if err != nil {
+		t.Fatalf("wanted the rejected rows skipped, got err: %v, errProperties: %v", err, errProperties)
+	}
+	if transfer.Rejects.Count() != 3 {
+		t.Errorf("wanted 3 rejected rows, got %d", transfer.Rejects.Count())
+	}
+
+	inserted := 0
+	for _, statement := range fake.committedStatements() {
+		if strings.Contains(statement, "'bad-") {
+			t.Errorf("wanted no rejected row committed, got %q", statement)
+		}
+		inserted += strings.Count(statement, "'row-")
+	}
+	if inserted != 2497 {
+		t.Errorf("wanted 2497 rows committed, got %d", inserted)
+	}
+
+	transfer.MaxErrors = 2
+	transfer.Rejects = &data.RejectCounter{}
+	target, _ = newTarget()
+	_, err = runFakeInsertFrom(t, newSource(transfer.Query), target, transfer)
+	if err == nil {
+		t.Error("wanted an error once more rows than MaxErrors were rejected")
+	}
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	data.IsolationReadUncommitted: sql.LevelReadUncommitted,
+	data.IsolationReadCommitted:   sql.LevelReadCommitted,
+	data.IsolationRepeatableRead:  sql.LevelRepeatableRead,
+	data.IsolationSnapshot:        sql.LevelSnapshot,
+	data.IsolationSerializable:    sql.LevelSerializable,
+}
+
+// Starts a transaction on the source at the transfer's isolation level, and
+// returns a copy of the source that reads inside it. finish ends the
+// transaction, and must only be called once its rows are closed. Without an
+// isolation level, the source is returned as is.
+func beginSourceRead(
+	sourceSystem DsConnection,
+	transfer data.Transfer,
+) (
+	txSource DsConnection,
+	finish func(),
+	errProperties map[string]string,
+	err error,
+) {
+	if transfer.IsolationLevel == "" {
+		return sourceSystem, func() {}, nil, nil
+	}
+
+	level, ok := isolationLevels[transfer.IsolationLevel]
+	if !ok {
+		return sourceSystem, func() {}, map[string]string{"isolationLevel": transfer.IsolationLevel}, errors.New("unknown isolation level")
+	}
+
+	txSource, tx, errProperties, err := sourceSystem.begin(&sql.TxOptions{Isolation: level})
+	if err != nil {
+		errProperties["isolationLevel"] = transfer.IsolationLevel
+		return sourceSystem, func() {}, errProperties, err
+	}
+
+	// nothing was written, so there is nothing to commit
+	return txSource, func() { tx.Rollback() }, nil, nil
+}
+
+// Postgres snapshot IDs, like 00000003-0000001B-1
+var snapshotIDRX = regexp.MustCompile(`^[0-9A-Fa-f-]+$`)
+
+// Returns the ID of the snapshot a PostgreSQL read transaction sees, which
+// other transactions can then share
+func exportSnapshot(txSource DsConnection) (snapshot string, errProperties map[string]string, err error) {
+	rows, errProperties, err := txSource.execute("SELECT pg_export_snapshot()")
+	if err != nil {
+		return "", errProperties, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", map[string]string{"error": fmt.Sprint(rows.Err())}, errors.New("pg_export_snapshot() returned no rows")
+	}
+	err = rows.Scan(&snapshot)
+	if err != nil {
+		return "", map[string]string{"error": err.Error()}, errors.New("unable to scan exported snapshot")
+	}
+	if !snapshotIDRX.MatchString(snapshot) {
+		return "", map[string]string{"snapshot": snapshot}, errors.New("unexpected snapshot ID")
+	}
+	return snapshot, nil, nil
+}
+
+// Starts another read transaction on the source that sees the same snapshot
+// as the one that exported it
+func beginSnapshotRead(
+	sourceSystem DsConnection,
+	transfer data.Transfer,
+	snapshot string,
+) (
+	txSource DsConnection,
+	finish func(),
+	errProperties map[string]string,
+	err error,
+) {
+	txSource, finish, errProperties, err = beginSourceRead(sourceSystem, transfer)
+	if err != nil {
+		return txSource, finish, errProperties, err
+	}
+
+	// must be the transaction's first statement
+	_, errProperties, err = txSource.exec(fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", snapshot))
+	if err != nil {
+		finish()
+		return sourceSystem, func() {}, errProperties, err
+	}
+	return txSource, finish, nil, nil
+}
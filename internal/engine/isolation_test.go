@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestSourceReadIsolation(t *testing.T) {
+	query := "select id, name from users"
+	transfer := data.Transfer{Query: query, IsolationLevel: data.IsolationRepeatableRead}
+
+	source, fake := newFakePostgreSQL(t, "source")
+	fake.results[query] = fakeResult{
+		columns: []string{"id", "name"},
+		types:   []string{"INT8", "TEXT"},
+		rows:    [][]driver.Value{{int64(1), "a"}},
+	}
+
+	txSource, finish, errProperties, err := beginSourceRead(source, transfer)
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	rows, _, errProperties, err := txSource.getRows(transfer)
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	rows.Close()
+	finish()
+
+	if got := fake.beginLevels(); !reflect.DeepEqual(got, []sql.IsolationLevel{sql.LevelRepeatableRead}) {
+		t.Errorf("wanted one repeatable read transaction, got %v", got)
+	}
+	for _, statement := range fake.committedStatements() {
+		if statement == query {
+			t.Error("wanted the query read inside the transaction")
+		}
+	}
+
+	transfer.IsolationLevel = ""
+	plain, _, _, _ := beginSourceRead(source, transfer)
+	if plain != DsConnection(source) || len(fake.beginLevels()) != 1 {
+		t.Error("wanted no transaction without an isolation level")
+	}
+}
+
+func TestChunkedTransferSharesSnapshot(t *testing.T) {
+	transfer := data.Transfer{
+		Query:          "select id, name from users",
+		TargetSchema:   "public",
+		TargetTable:    "users_copy",
+		Overwrite:      true,
+		Parallelism:    4,
+		ChunkColumn:    "id",
+		IsolationLevel: data.IsolationSerializable,
+	}
+
+	source, sourceFake := newFakeChunkedSource(t)
+	target, targetFake := newFakePostgreSQL(t, "target")
+
+	txSource, finish, errProperties, err := beginSourceRead(source, transfer)
+	if err != nil {
+		t.Fatalf("%v, %v", err, errProperties)
+	}
+	_, err = chunkedTransfer(txSource, target, transfer)
+	finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	levels := sourceFake.beginLevels()
+	if len(levels) != 4 {
+		t.Fatalf("wanted a transaction for each chunk, got %v", levels)
+	}
+	for _, level := range levels {
+		if level != sql.LevelSerializable {
+			t.Errorf("wanted every chunk read serializable, got %v", levels)
+			break
+		}
+	}
+
+	imports := 0
+	for _, statement := range sourceFake.executed() {
+		if statement == "SET TRANSACTION SNAPSHOT '00000003-0000001B-1'" {
+			imports++
+		}
+	}
+	if imports != 3 {
+		t.Errorf("wanted the 3 later chunks to share the first's snapshot, got %d imports", imports)
+	}
+	if rows := len(targetFake.copiedRows()); rows != 101 {
+		t.Errorf("wanted 101 rows loaded, got %d", rows)
+	}
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// The connection string parameters that keep a connection alive while it
+// sits idle, starting with ? or & depending on whether connString already
+// has parameters. PostgreSQL has the server send TCP keepalives, SQL Server's
+// driver sends them itself, and Snowflake's driver heartbeats the session,
+// at its own interval, so its login doesn't expire. Empty without a
+// keepalive, and for drivers that take it some other way.
+func keepaliveParams(connection data.Connection, connString string) string {
+	if connection.Keepalive <= 0 {
+		return ""
+	}
+
+	sep := "?"
+	if strings.Contains(connString, "?") {
+		sep = "&"
+	}
+
+	switch connection.DsType {
+	case "postgresql":
+		return fmt.Sprintf("%stcp_keepalives_idle=%d", sep, connection.Keepalive)
+	case "mssql":
+		return fmt.Sprintf("%skeepAlive=%d", sep, connection.Keepalive)
+	case "snowflake":
+		return sep + "client_session_keep_alive=true"
+	default:
+		return ""
+	}
+}
+
+var (
+	mysqlKeepaliveMu       sync.Mutex
+	mysqlKeepaliveNetworks = map[int]string{}
+)
+
+// The network a MySQL connection string dials. Its driver has no keepalive
+// parameter, so connections with one dial through a network registered with
+// the driver for that keepalive.
+func mysqlNetwork(keepalive int) string {
+	if keepalive <= 0 {
+		return "tcp"
+	}
+
+	mysqlKeepaliveMu.Lock()
+	defer mysqlKeepaliveMu.Unlock()
+
+	name, ok := mysqlKeepaliveNetworks[keepalive]
+	if !ok {
+		name = fmt.Sprintf("tcp-keepalive-%ds", keepalive)
+		dialer := net.Dialer{KeepAlive: time.Duration(keepalive) * time.Second}
+		mysqlDriver.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", addr)
+		})
+		mysqlKeepaliveNetworks[keepalive] = name
+	}
+	return name
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denisenkom/go-mssqldb/msdsn"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/snowflakedb/gosnowflake"
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func keepaliveConnString(t *testing.T, connection data.Connection) string {
+	dsConn, _, err := GetDs(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dsConn.closeDb()
+
+	_, _, connString := dsConn.getConnectionInfo()
+	return connString
+}
+
+func TestKeepaliveInConnectionStrings(t *testing.T) {
+	connection := func(dsType string, port int) data.Connection {
+		return data.Connection{DsType: dsType, Hostname: "db.internal", Port: port, AccountId: "xy12345", DbName: "app", Username: "etl", Password: "pass", Keepalive: 45}
+	}
+
+	t.Run("postgresql", func(t *testing.T) {
+		config, err := pgconn.ParseConfig(keepaliveConnString(t, connection("postgresql", 5432)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := config.RuntimeParams["tcp_keepalives_idle"]; got != "45" {
+			t.Errorf("wanted the server asked for keepalives after 45s idle, got %q", got)
+		}
+	})
+
+	t.Run("mssql", func(t *testing.T) {
+		config, _, err := msdsn.Parse(keepaliveConnString(t, connection("mssql", 1433)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.KeepAlive != 45*time.Second {
+			t.Errorf("wanted keepalives after 45s idle, got %s", config.KeepAlive)
+		}
+		if config.Database != "app" {
+			t.Errorf("wanted the database parameter kept, got %q", config.Database)
+		}
+	})
+
+	t.Run("mysql", func(t *testing.T) {
+		config, err := mysqlDriver.ParseDSN(keepaliveConnString(t, connection("mysql", 3306)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Net != "tcp-keepalive-45s" || config.Addr != "db.internal:3306" {
+			t.Errorf("wanted db.internal:3306 dialed through the 45s keepalive network, got %s(%s)", config.Net, config.Addr)
+		}
+		if mysqlNetwork(45) != config.Net {
+			t.Errorf("wanted the same network reused for the same keepalive")
+		}
+	})
+
+	t.Run("snowflake", func(t *testing.T) {
+		config, err := gosnowflake.ParseDSN(keepaliveConnString(t, connection("snowflake", 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value := config.Params["client_session_keep_alive"]; value == nil || *value != "true" {
+			t.Errorf("wanted the session kept alive, got %v", value)
+		}
+	})
+
+	t.Run("no keepalive", func(t *testing.T) {
+		for _, dsType := range []string{"postgresql", "mssql", "mysql", "snowflake"} {
+			c := connection(dsType, 1)
+			c.Keepalive = 0
+			connString := keepaliveConnString(t, c)
+			if strings.Contains(strings.ToLower(connString), "keep") {
+				t.Errorf("%s: wanted no keepalive set, got %s", dsType, connString)
+			}
+		}
+	})
+}
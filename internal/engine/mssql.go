@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -18,14 +20,48 @@ type MSSQL struct {
 	connString      string `json:"-"`
 	debugConnString string
 	db              *sql.DB
+	tx              *sql.Tx
 }
 
-func (dsConn MSSQL) execute(query string) (rows *sql.Rows, errProperties map[string]string, err error) {
-	return standardExecute(query, dsConn.dsType, dsConn.db)
+func (dsConn MSSQL) execute(query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	return dsConn.executeContext(context.Background(), query, args...)
+}
+
+func (dsConn MSSQL) executeContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExecute(ctx, query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExecute(ctx, query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn MSSQL) exec(query string, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExec(query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExec(query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn MSSQL) begin(opts *sql.TxOptions) (DsConnection, *sql.Tx, map[string]string, error) {
+	tx, errProperties, err := standardBegin(dsConn.dsType, dsConn.db, opts)
+	dsConn.tx = tx
+	return dsConn, tx, errProperties, err
+}
+
+// SQL Server rolls back DROP and CREATE TABLE along with everything else
+func (dsConn MSSQL) transactionalDDL() bool {
+	return true
+}
+
+func (dsConn MSSQL) supportsCopy() bool {
+	return false
+}
+
+func (dsConn MSSQL) ping(ctx context.Context) error {
+	return dsConn.db.PingContext(ctx)
 }
 
 func (dsConn MSSQL) closeDb() {
-	dsConn.db.Close()
+	releaseDb(dsConn.db)
 }
 
 func getNewMSSQL(
@@ -44,8 +80,10 @@ func getNewMSSQL(
 		connection.Port,
 		connection.DbName,
 	)
+	connString += keepaliveParams(connection, connString)
+	connString += connectTimeoutParams(connection, connString)
 
-	mssql, err = sql.Open("mssql", connString)
+	mssql, err = openDb(connection, "mssql", connString)
 
 	if err != nil {
 		return dsConn, errProperties, err
@@ -54,14 +92,7 @@ func getNewMSSQL(
 	dsConn = MSSQL{
 		"mssql",
 		"mssql",
-		fmt.Sprintf(
-			"sqlserver://%s:%s@%s:%v?database=%s",
-			connection.Username,
-			connection.Password,
-			connection.Hostname,
-			connection.Port,
-			connection.DbName,
-		),
+		connString,
 		fmt.Sprintf(
 			"sqlserver://<USERNAME_MASKED>:<PASSWORD_MASKED>@%s:%v?database=%s",
 			connection.Hostname,
@@ -69,6 +100,7 @@ func getNewMSSQL(
 			connection.DbName,
 		),
 		mssql,
+		nil,
 	}
 
 	return dsConn, errProperties, err
@@ -211,6 +243,45 @@ func (dsConn MSSQL) dropTable(
 	return dropTableIfExistsWithSchema(dsConn, transfer)
 }
 
+func (dsConn MSSQL) isView(transfer data.Transfer) (bool, map[string]string, error) {
+	return standardIsView(dsConn, transfer, "SCHEMA_NAME()")
+}
+
+// Computed columns aren't marked in information_schema
+func (dsConn MSSQL) generatedColumns(transfer data.Transfer) ([]string, map[string]string, error) {
+	schema := "SCHEMA_NAME()"
+	if transfer.TargetSchema != "" {
+		schema = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+	return queryColumnNames(dsConn, fmt.Sprintf(
+		"SELECT c.name FROM sys.columns c JOIN sys.tables t ON t.object_id = c.object_id JOIN sys.schemas s ON s.schema_id = t.schema_id WHERE LOWER(s.name) = LOWER(%s) AND LOWER(t.name) = LOWER('%s') AND c.is_computed = 1",
+		schema,
+		transfer.TargetTable,
+	))
+}
+
+func (dsConn MSSQL) dropDenied(transfer data.Transfer) (bool, map[string]string, error) {
+	schema := "SCHEMA_NAME()"
+	if transfer.TargetSchema != "" {
+		schema = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+	// dropping takes CONTROL on the table, or ALTER on its schema
+	return queryCountsAny(dsConn, fmt.Sprintf(
+		"SELECT COUNT(*) FROM sys.tables t JOIN sys.schemas s ON s.schema_id = t.schema_id WHERE LOWER(s.name) = LOWER(%s) AND LOWER(t.name) = LOWER('%s') AND HAS_PERMS_BY_NAME(QUOTENAME(s.name) + '.' + QUOTENAME(t.name), 'OBJECT', 'CONTROL') = 0 AND HAS_PERMS_BY_NAME(QUOTENAME(s.name), 'SCHEMA', 'ALTER') = 0",
+		schema,
+		transfer.TargetTable,
+	))
+}
+
+func (dsConn MSSQL) truncateTable(
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	return truncateTableWithSchema(dsConn, transfer)
+}
+
 func (dsConn MSSQL) deleteFromTable(
 	transfer data.Transfer,
 ) (
@@ -250,6 +321,15 @@ func (dsConn MSSQL) getQueryStarter(targetTable string, targetSchema string, col
 	return standardGetQueryStarter(targetTable, targetSchema, columnInfo)
 }
 
+func (dsConn MSSQL) getUpsertQuery(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo, conflictColumns []string) (string, string, map[string]string, error) {
+	if targetSchema != "" {
+		targetTable = fmt.Sprintf("%s.%s", targetSchema, targetTable)
+	}
+	queryStarter := fmt.Sprintf("MERGE INTO %s target USING (VALUES (", targetTable)
+	queryEnder := fmt.Sprintf(") source (%s)%s;", strings.Join(columnInfo.ColumnNames, ", "), standardMergeClauses(columnInfo, conflictColumns))
+	return queryStarter, queryEnder, nil, nil
+}
+
 func mssqlWriteBit(value interface{}, terminator string) string {
 
 	var returnVal string
@@ -268,7 +348,8 @@ func mssqlWriteBit(value interface{}, terminator string) string {
 }
 
 func mssqlWriteHexBytes(value interface{}, terminator string) string {
-	return fmt.Sprintf("CONVERT(VARBINARY(8000), '0x%x', 1)%s", value, terminator)
+	// MAX, so values longer than 8000 bytes aren't cut short
+	return fmt.Sprintf("CONVERT(VARBINARY(MAX), '0x%x', 1)%s", value, terminator)
 }
 
 func mssqlWriteUniqueIdentifier(value interface{}, terminator string) string {
@@ -303,6 +384,12 @@ func (dsConn MSSQL) getQueryEnder(targetTable string) string {
 	return ""
 }
 
+func (dsConn MSSQL) getExplainQuery(query string, analyze bool) (string, map[string]string, error) {
+	// SHOWPLAN has to be switched on in its own batch, so it can't be
+	// wrapped around a single query
+	return "", map[string]string{"dsType": dsConn.dsType}, errors.New("explain is not supported for mssql")
+}
+
 func mssqlWriteDateTime(value interface{}, terminator string) string {
 	var returnVal string
 
@@ -384,7 +471,7 @@ func (dsConn MSSQL) getCreateTableType(
 	case "PostgreSQL_BOX":
 		createType = "VARCHAR(8000)"
 	case "PostgreSQL_BYTEA":
-		createType = "VARBINARY(8000)"
+		createType = "VARBINARY(MAX)"
 	case "PostgreSQL_BPCHAR":
 		createType = "NVARCHAR(4000)"
 	case "PostgreSQL_CIDR":
@@ -495,7 +582,7 @@ func (dsConn MSSQL) getCreateTableType(
 	case "MySQL_VARBINARY":
 		createType = "VARBINARY(8000)"
 	case "MySQL_BLOB":
-		createType = "VARBINARY(8000)"
+		createType = "VARBINARY(MAX)"
 	case "MySQL_GEOMETRY":
 		createType = "VARBINARY(8000)"
 	case "MySQL_JSON":
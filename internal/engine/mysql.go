@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -19,14 +20,48 @@ type MySQL struct {
 	connString      string `json:"-"`
 	debugConnString string
 	db              *sql.DB
+	tx              *sql.Tx
 }
 
-func (dsConn MySQL) execute(query string) (rows *sql.Rows, errProperties map[string]string, err error) {
-	return standardExecute(query, dsConn.dsType, dsConn.db)
+func (dsConn MySQL) execute(query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	return dsConn.executeContext(context.Background(), query, args...)
+}
+
+func (dsConn MySQL) executeContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExecute(ctx, query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExecute(ctx, query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn MySQL) exec(query string, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExec(query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExec(query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn MySQL) begin(opts *sql.TxOptions) (DsConnection, *sql.Tx, map[string]string, error) {
+	tx, errProperties, err := standardBegin(dsConn.dsType, dsConn.db, opts)
+	dsConn.tx = tx
+	return dsConn, tx, errProperties, err
+}
+
+// MySQL implicitly commits before and after DDL statements
+func (dsConn MySQL) transactionalDDL() bool {
+	return false
+}
+
+func (dsConn MySQL) supportsCopy() bool {
+	return false
+}
+
+func (dsConn MySQL) ping(ctx context.Context) error {
+	return dsConn.db.PingContext(ctx)
 }
 
 func (dsConn MySQL) closeDb() {
-	dsConn.db.Close()
+	releaseDb(dsConn.db)
 }
 
 func getNewMySQL(
@@ -37,16 +72,21 @@ func getNewMySQL(
 	err error,
 ) {
 
+	network := mysqlNetwork(connection.Keepalive)
+
 	connString := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s",
+		"%s:%s@%s(%s:%d)/%s",
 		connection.Username,
 		connection.Password,
+		network,
 		connection.Hostname,
 		connection.Port,
 		connection.DbName,
 	)
+	connString += connectTimeoutParams(connection, connString)
+	connString += sslModeParams(connection, connString)
 
-	mysql, err = sql.Open("mysql", connString)
+	mysql, err = openDb(connection, "mysql", connString)
 
 	if err != nil {
 		return dsConn, errProperties, err
@@ -55,21 +95,16 @@ func getNewMySQL(
 	dsConn = MySQL{
 		"mysql",
 		"mysql",
+		connString,
 		fmt.Sprintf(
-			"%s:%s@tcp(%s:%d)/%s",
-			connection.Username,
-			connection.Password,
-			connection.Hostname,
-			connection.Port,
-			connection.DbName,
-		),
-		fmt.Sprintf(
-			"<USERNAME_MASKED>:<PASSWORD_MASKED>@tcp(%s:%d)/%s",
+			"<USERNAME_MASKED>:<PASSWORD_MASKED>@%s(%s:%d)/%s",
+			network,
 			connection.Hostname,
 			connection.Port,
 			connection.DbName,
 		),
 		mysql,
+		nil,
 	}
 
 	return dsConn, errProperties, err
@@ -79,6 +114,10 @@ func (dsConn MySQL) getQueryEnder(targetTable string) string {
 	return ""
 }
 
+func (dsConn MySQL) getExplainQuery(query string, analyze bool) (string, map[string]string, error) {
+	return standardGetExplainQuery(dsConn.dsType, query, analyze, true)
+}
+
 func (dsConn MySQL) getIntermediateType(
 	colTypeFromDriver string,
 ) (
@@ -125,7 +164,7 @@ func (dsConn MySQL) getIntermediateType(
 		intermediateType = "MySQL_BINARY"
 	case "VARBINARY":
 		intermediateType = "MySQL_VARBINARY"
-	case "BLOB":
+	case "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB":
 		intermediateType = "MySQL_BLOB"
 	case "GEOMETRY":
 		intermediateType = "MySQL_GEOMETRY"
@@ -156,7 +195,70 @@ func (dsConn MySQL) getRows(
 	errProperties map[string]string,
 	err error,
 ) {
-	return standardGetRows(dsConn, transfer)
+	rows, resultSetColumnInfo, errProperties, err = standardGetRows(dsConn, transfer)
+	if err != nil {
+		return rows, resultSetColumnInfo, errProperties, err
+	}
+
+	args, _, _ := queryArgValues(transfer)
+	return rows, dsConn.describeBooleans(transfer.Query, args, resultSetColumnInfo), nil, nil
+}
+
+// MySQL's BOOLEAN is TINYINT(1), and the driver describes every TINYINT
+// column the same way, so the widths of a result's TINYINT columns are read
+// from an empty temporary table made from the query. TINYINT(1) columns are
+// then described as BOOLEAN, which has files write them as booleans like
+// other systems' are. Their intermediate type is left alone, so targets
+// still get numbers. If the table can't be made, say without the CREATE
+// TEMPORARY TABLES privilege, the columns stay TINYINT.
+func (dsConn MySQL) describeBooleans(query string, args []interface{}, resultSetColumnInfo ResultSetColumnInfo) ResultSetColumnInfo {
+	hasTinyints := false
+	for _, dbType := range resultSetColumnInfo.ColumnDbTypes {
+		hasTinyints = hasTinyints || dbType == "TINYINT"
+	}
+	if !hasTinyints {
+		return resultSetColumnInfo
+	}
+
+	// temporary tables belong to the session, so every statement runs on
+	// one connection
+	ctx := context.Background()
+	conn, err := dsConn.db.Conn(ctx)
+	if err != nil {
+		return resultSetColumnInfo
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("CREATE TEMPORARY TABLE sqlpipe_column_types AS SELECT * FROM (%s) sqlpipe_source LIMIT 0", query), args...)
+	if err != nil {
+		return resultSetColumnInfo
+	}
+	defer conn.ExecContext(ctx, "DROP TEMPORARY TABLE sqlpipe_column_types")
+
+	rows, err := conn.QueryContext(ctx, "SHOW COLUMNS FROM sqlpipe_column_types")
+	if err != nil {
+		return resultSetColumnInfo
+	}
+	defer rows.Close()
+
+	dbTypes := append([]string{}, resultSetColumnInfo.ColumnDbTypes...)
+	// Field, Type, Null, Key, Default and Extra
+	var field, columnType string
+	var rest [4]sql.RawBytes
+	for i := 0; rows.Next() && i < len(dbTypes); i++ {
+		if rows.Scan(&field, &columnType, &rest[0], &rest[1], &rest[2], &rest[3]) != nil {
+			return resultSetColumnInfo
+		}
+		if dbTypes[i] == "TINYINT" && strings.HasPrefix(strings.ToLower(columnType), "tinyint(1)") {
+			dbTypes[i] = "BOOLEAN"
+		}
+	}
+	if rows.Err() != nil {
+		return resultSetColumnInfo
+	}
+
+	resultSetColumnInfo.ColumnDbTypes = dbTypes
+	return resultSetColumnInfo
 }
 
 func (dsConn MySQL) getConnectionInfo() (string, string, string) {
@@ -184,6 +286,31 @@ func (dsConn MySQL) dropTable(
 	return dropTableIfExistsNoSchema(dsConn, transfer)
 }
 
+func (dsConn MySQL) isView(transfer data.Transfer) (bool, map[string]string, error) {
+	return standardIsView(dsConn, transfer, "DATABASE()")
+}
+
+// Columns with a DEFAULT (expr) have a generation expression too, so only
+// EXTRA tells virtual and stored generated columns apart from them
+func (dsConn MySQL) generatedColumns(transfer data.Transfer) ([]string, map[string]string, error) {
+	return standardGeneratedColumns(dsConn, transfer, "DATABASE()", "extra IN ('VIRTUAL GENERATED', 'STORED GENERATED')")
+}
+
+// MySQL 8 roles aren't reflected in information_schema's privilege tables,
+// so a missing permission shows up when the table is dropped
+func (dsConn MySQL) dropDenied(transfer data.Transfer) (bool, map[string]string, error) {
+	return false, nil, nil
+}
+
+func (dsConn MySQL) truncateTable(
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	return truncateTableNoSchema(dsConn, transfer)
+}
+
 func (dsConn MySQL) turboTransfer(
 	rows *sql.Rows,
 	transfer data.Transfer,
@@ -250,6 +377,22 @@ func (dsConn MySQL) getQueryStarter(targetTable string, targetSchema string, col
 	return standardGetQueryStarter(targetTable, "", columnInfo)
 }
 
+// MySQL has no way to name the columns a conflict is on, so it's up to the
+// target to have a primary or unique key on the conflict columns
+func (dsConn MySQL) getUpsertQuery(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo, conflictColumns []string) (string, string, map[string]string, error) {
+	updateColumns := upsertUpdateColumns(columnInfo, conflictColumns)
+	if len(updateColumns) == 0 {
+		// setting a column to itself leaves matching rows alone
+		updateColumns = conflictColumns[:1]
+	}
+
+	sets := make([]string, len(updateColumns))
+	for i, name := range updateColumns {
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", name, name)
+	}
+	return standardGetQueryStarter(targetTable, "", columnInfo), " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "), nil, nil
+}
+
 func mysqlWriteInsertBinary(value interface{}, terminator string) string {
 	return fmt.Sprintf("x'%x'%s", value, terminator)
 }
@@ -600,8 +743,8 @@ var mysqlInsertWriters = map[string]func(value interface{}, terminator string) s
 	"PostgreSQL_UUID":          writeInsertStringNoEscape,
 	"PostgreSQL_VARCHAR":       writeInsertEscapedString,
 	"PostgreSQL_BPCHAR":        writeInsertEscapedString,
-	"PostgreSQL_JSON":          writeBackslashJSON,
-	"PostgreSQL_JSONB":         writeBackslashJSON,
+	"PostgreSQL_JSON":          writeMySQLJSON,
+	"PostgreSQL_JSONB":         writeMySQLJSON,
 	"PostgreSQL_TEXT":          writeInsertEscapedString,
 	"PostgreSQL_TSQUERY":       writeInsertEscapedString,
 	"PostgreSQL_TSVECTOR":      writeInsertEscapedString,
@@ -630,7 +773,7 @@ var mysqlInsertWriters = map[string]func(value interface{}, terminator string) s
 	"MySQL_VARBINARY": mysqlWriteInsertBinary,
 	"MySQL_BLOB":      mysqlWriteInsertBinary,
 	"MySQL_GEOMETRY":  writeInsertStringNoEscape,
-	"MySQL_JSON":      writeBackslashJSON,
+	"MySQL_JSON":      writeMySQLJSON,
 
 	// MSSQL
 
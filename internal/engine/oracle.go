@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -18,14 +20,48 @@ type Oracle struct {
 	connString      string `json:"-"`
 	debugConnString string
 	db              *sql.DB
+	tx              *sql.Tx
 }
 
-func (dsConn Oracle) execute(query string) (rows *sql.Rows, errProperties map[string]string, err error) {
-	return standardExecute(query, dsConn.dsType, dsConn.db)
+func (dsConn Oracle) execute(query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	return dsConn.executeContext(context.Background(), query, args...)
+}
+
+func (dsConn Oracle) executeContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExecute(ctx, query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExecute(ctx, query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn Oracle) exec(query string, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExec(query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExec(query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn Oracle) begin(opts *sql.TxOptions) (DsConnection, *sql.Tx, map[string]string, error) {
+	tx, errProperties, err := standardBegin(dsConn.dsType, dsConn.db, opts)
+	dsConn.tx = tx
+	return dsConn, tx, errProperties, err
+}
+
+// Oracle implicitly commits before and after DDL statements
+func (dsConn Oracle) transactionalDDL() bool {
+	return false
+}
+
+func (dsConn Oracle) supportsCopy() bool {
+	return false
+}
+
+func (dsConn Oracle) ping(ctx context.Context) error {
+	return dsConn.db.PingContext(ctx)
 }
 
 func (dsConn Oracle) closeDb() {
-	dsConn.db.Close()
+	releaseDb(dsConn.db)
 }
 
 func getNewOracle(
@@ -45,7 +81,7 @@ func getNewOracle(
 		connection.DbName,
 	)
 
-	oracle, err = sql.Open("oracle", connString)
+	oracle, err = openDb(connection, "oracle", connString)
 
 	if err != nil {
 		return dsConn, errProperties, err
@@ -69,6 +105,7 @@ func getNewOracle(
 			connection.DbName,
 		),
 		oracle,
+		nil,
 	}
 
 	return dsConn, errProperties, err
@@ -150,7 +187,12 @@ func (dsConn Oracle) getRows(
 	errProperties map[string]string,
 	err error,
 ) {
-	rows, errProperties, err = dsConn.execute(transfer.Query)
+	args, errProperties, err := queryArgValues(transfer)
+	if err != nil {
+		return rows, resultSetColumnInfo, errProperties, err
+	}
+
+	rows, errProperties, err = dsConn.execute(transfer.Query, args...)
 	if err != nil {
 		return rows, resultSetColumnInfo, errProperties, err
 	}
@@ -178,9 +220,48 @@ func (dsConn Oracle) getRows(
 		}
 	}
 
+	// go-ora decodes NUMBER into an int64 or a float64, neither of which can
+	// hold every NUMBER exactly, so numbers are read as text instead. The
+	// column info still describes the original NUMBER columns.
+	if exactQuery, ok := oracleExactNumbersQuery(transfer.Query, resultSetColumnInfo); ok {
+		rows.Close()
+		rows, errProperties, err = dsConn.execute(exactQuery, args...)
+		return rows, resultSetColumnInfo, errProperties, err
+	}
+
 	return rows, resultSetColumnInfo, errProperties, err
 }
 
+// Wraps query so each NUMBER column comes back as its exact decimal text.
+// Returns false if the result has no NUMBER columns. A query's columns can
+// share a name, so the wrapper names them by position, and gives each its
+// own name back as it's read.
+func oracleExactNumbersQuery(query string, resultSetColumnInfo ResultSetColumnInfo) (string, bool) {
+	hasNumbers := false
+	positions := make([]string, resultSetColumnInfo.NumCols)
+	columns := make([]string, resultSetColumnInfo.NumCols)
+	for i, colName := range resultSetColumnInfo.ColumnNames {
+		positions[i] = fmt.Sprintf("c%d", i+1)
+		quoted := `"` + strings.ReplaceAll(colName, `"`, `""`) + `"`
+		columns[i] = fmt.Sprintf("%s AS %s", positions[i], quoted)
+		if resultSetColumnInfo.ColumnDbTypes[i] == "NUMBER" {
+			// the NLS parameter pins the decimal separator to a period
+			columns[i] = fmt.Sprintf("TO_CHAR(%s, 'TM9', 'NLS_NUMERIC_CHARACTERS=''.,''') AS %s", positions[i], quoted)
+			hasNumbers = true
+		}
+	}
+	if !hasNumbers {
+		return query, false
+	}
+
+	return fmt.Sprintf(
+		"WITH sqlpipe_numbers (%s) AS (%s) SELECT %s FROM sqlpipe_numbers",
+		strings.Join(positions, ", "),
+		query,
+		strings.Join(columns, ", "),
+	), true
+}
+
 func (dsConn Oracle) getFormattedResults(
 	query string,
 ) (
@@ -338,6 +419,53 @@ func (dsConn Oracle) dropTable(
 	return errProperties, err
 }
 
+func (dsConn Oracle) isView(transfer data.Transfer) (bool, map[string]string, error) {
+	owner := "SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA')"
+	if transfer.TargetSchema != "" {
+		owner = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+	return queryCountsAny(dsConn, fmt.Sprintf(
+		"SELECT COUNT(*) FROM all_views WHERE owner = UPPER(%s) AND view_name = UPPER('%s')",
+		owner,
+		transfer.TargetTable,
+	))
+}
+
+// Virtual columns, leaving out the hidden ones Oracle adds for its own use
+func (dsConn Oracle) generatedColumns(transfer data.Transfer) ([]string, map[string]string, error) {
+	owner := "SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA')"
+	if transfer.TargetSchema != "" {
+		owner = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+	return queryColumnNames(dsConn, fmt.Sprintf(
+		"SELECT column_name FROM all_tab_cols WHERE owner = UPPER(%s) AND table_name = UPPER('%s') AND virtual_column = 'YES' AND hidden_column = 'NO'",
+		owner,
+		transfer.TargetTable,
+	))
+}
+
+func (dsConn Oracle) dropDenied(transfer data.Transfer) (bool, map[string]string, error) {
+	owner := "SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA')"
+	if transfer.TargetSchema != "" {
+		owner = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+	// other users' tables can only be dropped with DROP ANY TABLE
+	return queryCountsAny(dsConn, fmt.Sprintf(
+		"SELECT COUNT(*) FROM all_tables WHERE owner = UPPER(%s) AND table_name = UPPER('%s') AND owner <> USER AND NOT EXISTS (SELECT 1 FROM session_privs WHERE privilege = 'DROP ANY TABLE')",
+		owner,
+		transfer.TargetTable,
+	))
+}
+
+func (dsConn Oracle) truncateTable(
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	return truncateTableNoSchema(dsConn, transfer)
+}
+
 func (dsConn Oracle) deleteFromTable(
 	transfer data.Transfer,
 ) (
@@ -379,11 +507,23 @@ func (dsConn Oracle) getQueryEnder(targetTable string) string {
 	return fmt.Sprintf(") SELECT * FROM %s_to_insert", targetTable)
 }
 
+func (dsConn Oracle) getExplainQuery(query string, analyze bool) (string, map[string]string, error) {
+	// EXPLAIN PLAN writes to a plan table instead of returning rows
+	return "", map[string]string{"dsType": dsConn.dsType}, errors.New("explain is not supported for oracle")
+}
+
 func (dsConn Oracle) getQueryStarter(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo) string {
 	queryStarter := fmt.Sprintf("insert into %s (%s) with %s_to_insert (%s) as ( SELECT ", targetTable, strings.Join(columnInfo.ColumnNames, ", "), targetTable, strings.Join(columnInfo.ColumnNames, ", "))
 	return queryStarter
 }
 
+func (dsConn Oracle) getUpsertQuery(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo, conflictColumns []string) (string, string, map[string]string, error) {
+	columns := strings.Join(columnInfo.ColumnNames, ", ")
+	queryStarter := fmt.Sprintf("MERGE INTO %s target USING (with %s_to_insert (%s) as ( SELECT ", targetTable, targetTable, columns)
+	queryEnder := fmt.Sprintf(") SELECT * FROM %s_to_insert) source%s", targetTable, standardMergeClauses(columnInfo, conflictColumns))
+	return queryStarter, queryEnder, nil, nil
+}
+
 func oracleWriteDateFromTime(value interface{}, terminator string) string {
 	var returnVal string
 
@@ -0,0 +1,37 @@
+package engine
+
+import "testing"
+
+func TestOracleExactNumbersQuery(t *testing.T) {
+	columnInfo := ResultSetColumnInfo{
+		ColumnNames:   []string{"ID", "AMOUNT", "NAME"},
+		ColumnDbTypes: []string{"NUMBER", "NUMBER", "NCHAR"},
+		NumCols:       3,
+	}
+
+	query, ok := oracleExactNumbersQuery("SELECT id, amount, name FROM ledger", columnInfo)
+	want := `WITH sqlpipe_numbers (c1, c2, c3) AS (SELECT id, amount, name FROM ledger) ` +
+		`SELECT TO_CHAR(c1, 'TM9', 'NLS_NUMERIC_CHARACTERS=''.,''') AS "ID", ` +
+		`TO_CHAR(c2, 'TM9', 'NLS_NUMERIC_CHARACTERS=''.,''') AS "AMOUNT", ` +
+		`c3 AS "NAME" FROM sqlpipe_numbers`
+	if !ok || query != want {
+		t.Errorf("wanted %q, got %q", want, query)
+	}
+
+	// a join can return two columns of the same name, which only their
+	// positions tell apart
+	columnInfo.ColumnNames = []string{"ID", "ID", "NAME"}
+	query, _ = oracleExactNumbersQuery("SELECT a.id, b.id, a.name FROM a JOIN b ON a.id = b.id", columnInfo)
+	want = `WITH sqlpipe_numbers (c1, c2, c3) AS (SELECT a.id, b.id, a.name FROM a JOIN b ON a.id = b.id) ` +
+		`SELECT TO_CHAR(c1, 'TM9', 'NLS_NUMERIC_CHARACTERS=''.,''') AS "ID", ` +
+		`TO_CHAR(c2, 'TM9', 'NLS_NUMERIC_CHARACTERS=''.,''') AS "ID", ` +
+		`c3 AS "NAME" FROM sqlpipe_numbers`
+	if query != want {
+		t.Errorf("wanted %q, got %q", want, query)
+	}
+
+	columnInfo.ColumnDbTypes = []string{"CHAR", "CHAR", "NCHAR"}
+	if _, ok := oracleExactNumbersQuery("SELECT id, amount, name FROM ledger", columnInfo); ok {
+		t.Error("wanted a result without numbers left alone")
+	}
+}
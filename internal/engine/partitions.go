@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Layouts tried, in order, when a date column comes back as text
+var partitionDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// The insert being built for one of the tables a pattern resolves to
+type partitionBatch struct {
+	query strings.Builder
+	rows  int
+}
+
+// Inserts each row into the table transfer.TargetTablePattern resolves to
+// for that row's date. Rows are batched per table, and each batch commits on
+// its own once it reaches the target's insert size limit. The first time a
+// row is routed to a table, the write mode's DDL runs on it, or it is
+// created if missing with CreateTargetTable.
+func partitionedInsert(
+	dsConn DsConnection,
+	rows sourceRows,
+	transfer data.Transfer,
+	resultSetColumnInfo ResultSetColumnInfo,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	tablePattern, err := data.ParseTablePattern(transfer.TargetTablePattern)
+	if err != nil {
+		return map[string]string{"targetTablePattern": transfer.TargetTablePattern}, err
+	}
+
+	dateCol := -1
+	for i, colName := range resultSetColumnInfo.ColumnNames {
+		if strings.EqualFold(colName, tablePattern.Column) {
+			dateCol = i
+		}
+	}
+	if dateCol == -1 {
+		return map[string]string{"column": tablePattern.Column}, errors.New("target table pattern column is not in the query's result")
+	}
+
+	errProperties, err = runPreLoadSQL(dsConn, transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	errProperties, err = ensureTargetSchema(dsConn, transfer)
+	if err != nil {
+		return errProperties, err
+	}
+
+	numCols := resultSetColumnInfo.NumCols
+	zeroIndexedNumCols := numCols - 1
+	colTypes := resultSetColumnInfo.ColumnIntermediateTypes
+
+	values := make([]interface{}, numCols)
+	valuePtrs := make([]interface{}, numCols)
+	for i := 0; i < numCols; i++ {
+		valuePtrs[i] = &values[i]
+	}
+
+	batches := map[string]*partitionBatch{}
+	rowsCommitted := 0
+
+	flush := func(table string, batch *partitionBatch) (map[string]string, error) {
+		noUnionAll := strings.TrimSuffix(batch.query.String(), " UNION ALL ")
+		queryString := sqlEndStringNilReplacer.Replace(noUnionAll + dsConn.getQueryEnder(table))
+
+		errProperties, err := insertBatch(dsConn, nil, nil, queryString)
+		if err != nil {
+			if errProperties == nil {
+				errProperties = map[string]string{}
+			}
+			errProperties["targetTable"] = table
+			errProperties["rowsCommitted"] = strconv.Itoa(rowsCommitted)
+			return errProperties, err
+		}
+
+		rowsCommitted += batch.rows
+		progress(transfer).OnBatch(int64(batch.rows))
+		batch.query.Reset()
+		batch.rows = 0
+		return nil, nil
+	}
+
+	for rows.Next() {
+		err = rows.Scan(valuePtrs...)
+		if err != nil {
+			return map[string]string{"error": err.Error()}, errors.New("unable to scan source row")
+		}
+
+		date, err := partitionDate(values[dateCol])
+		if err != nil {
+			return map[string]string{"column": tablePattern.Column, "error": err.Error()}, errors.New("unable to pick a target table for row")
+		}
+		table := tablePattern.Table(date)
+
+		batch, ok := batches[table]
+		if !ok {
+			tableTransfer := transfer
+			tableTransfer.TargetTable = table
+			errProperties, err = preparePartition(dsConn, tableTransfer, resultSetColumnInfo)
+			if err != nil {
+				return errProperties, err
+			}
+			batch = &partitionBatch{}
+			batches[table] = batch
+		}
+
+		if batch.rows == 0 {
+			batch.query.WriteString(dsConn.getQueryStarter(table, transfer.TargetSchema, resultSetColumnInfo))
+		} else {
+			batch.query.WriteString(dsConn.getRowStarter())
+		}
+		for j := 0; j < zeroIndexedNumCols; j++ {
+			batch.query.WriteString(dsConn.getValToWriteMidRow(colTypes[j], values[j]))
+		}
+		batch.query.WriteString(dsConn.getValToWriteRowEnd(colTypes[zeroIndexedNumCols], values[zeroIndexedNumCols]))
+		batch.rows++
+
+		if dsConn.insertChecker(batch.query.Len(), batch.rows) {
+			errProperties, err = flush(table, batch)
+			if err != nil {
+				return errProperties, err
+			}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return map[string]string{"error": err.Error()}, errors.New("error reading source rows")
+	}
+
+	// flush what's left in a stable order, so failures are repeatable
+	tables := make([]string, 0, len(batches))
+	for table := range batches {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		if batches[table].rows == 0 {
+			continue
+		}
+		errProperties, err = flush(table, batches[table])
+		if err != nil {
+			return errProperties, err
+		}
+	}
+
+	return nil, nil
+}
+
+// Gets a table ready for its first rows. The recreate write mode recreates
+// it, and CreateTargetTable creates it if it doesn't exist yet. Truncating
+// only applies to tables that already exist.
+func preparePartition(
+	dsConn DsConnection,
+	transfer data.Transfer,
+	resultSetColumnInfo ResultSetColumnInfo,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	mode := transfer.Mode()
+
+	if mode == data.WriteModeRecreate {
+		errProperties, err = checkOverwriteTarget(dsConn, transfer)
+		if err != nil {
+			return errProperties, err
+		}
+		return runWriteModeDDL(dsConn, transfer, resultSetColumnInfo)
+	}
+
+	if mode == data.WriteModeAppend && !transfer.CreateTargetTable {
+		return nil, nil
+	}
+
+	exists := tableExists(dsConn, transfer)
+	switch {
+	case !exists && transfer.CreateTargetTable:
+		return dsConn.createTable(transfer, resultSetColumnInfo)
+	case exists && mode == data.WriteModeTruncate:
+		errProperties, err = checkOverwriteTarget(dsConn, transfer)
+		if err != nil {
+			return errProperties, err
+		}
+		return runWriteModeDDL(dsConn, transfer, resultSetColumnInfo)
+	}
+
+	return nil, nil
+}
+
+// Checks for the table by selecting nothing from it, which works the same way
+// on every data system
+func tableExists(dsConn DsConnection, transfer data.Transfer) bool {
+	table := transfer.TargetTable
+	if transfer.TargetSchema != "" {
+		table = transfer.TargetSchema + "." + table
+	}
+
+	rows, _, err := dsConn.execute(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", table))
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+func partitionDate(value interface{}) (time.Time, error) {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case nil:
+		return time.Time{}, errors.New("value is NULL")
+	default:
+		return time.Time{}, fmt.Errorf("can't read a date from a %T", value)
+	}
+
+	for _, layout := range partitionDateLayouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't read a date from %q", text)
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestPartitionedInsertSplitsRowsByMonth(t *testing.T) {
+	transfer := data.Transfer{
+		Query:              "select id, created_at from events",
+		TargetSchema:       "dbo",
+		TargetTablePattern: "events_{created_at:YYYY_MM}",
+		CreateTargetTable:  true,
+	}
+
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 12, 0, 0, 0, time.UTC)
+	}
+	source, sourceFake := newFakePostgreSQL(t, "source")
+	sourceFake.results[transfer.Query] = fakeResult{
+		columns: []string{"id", "created_at"},
+		types:   []string{"INT8", "TIMESTAMP"},
+		rows: [][]driver.Value{
+			{int64(1), day(time.January, 30)},
+			{int64(2), day(time.February, 1)},
+			{int64(3), day(time.January, 31)},
+			{int64(4), day(time.February, 2)},
+			{int64(5), day(time.January, 1)},
+		},
+	}
+
+	target, fake := newFakeMSSQL(t, "target")
+	// neither table exists yet
+	fake.failOn = "WHERE 1 = 0"
+
+	_, err := runFakeInsertFrom(t, source, target, transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creates := map[string]bool{}
+	inserted := map[string]int{}
+	rowRX := regexp.MustCompile(`\(\d+,`)
+	for _, statement := range fake.committedStatements() {
+		switch {
+		case strings.HasPrefix(statement, "CREATE TABLE dbo.events_2024_01 "):
+			creates["events_2024_01"] = true
+		case strings.HasPrefix(statement, "CREATE TABLE dbo.events_2024_02 "):
+			creates["events_2024_02"] = true
+		case strings.HasPrefix(statement, "INSERT INTO dbo.events_2024_01 "):
+			inserted["events_2024_01"] += len(rowRX.FindAllString(statement, -1))
+		case strings.HasPrefix(statement, "INSERT INTO dbo.events_2024_02 "):
+			inserted["events_2024_02"] += len(rowRX.FindAllString(statement, -1))
+		default:
+			t.Errorf("unexpected statement %q", statement)
+		}
+	}
+
+	if !creates["events_2024_01"] || !creates["events_2024_02"] {
+		t.Errorf("wanted both monthly tables created, got %v", creates)
+	}
+	if inserted["events_2024_01"] != 3 || inserted["events_2024_02"] != 2 {
+		t.Errorf("wanted 3 January rows and 2 February rows, got %v", inserted)
+	}
+}
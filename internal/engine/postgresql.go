@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -18,14 +19,51 @@ type PostgreSQL struct {
 	connString      string `json:"-"`
 	debugConnString string
 	db              *sql.DB
+	tx              *sql.Tx
+	// the connection tx runs on, for copying rows into it
+	copier copyConn
 }
 
-func (dsConn PostgreSQL) execute(query string) (rows *sql.Rows, errProperties map[string]string, err error) {
-	return standardExecute(query, dsConn.dsType, dsConn.db)
+func (dsConn PostgreSQL) execute(query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	return dsConn.executeContext(context.Background(), query, args...)
+}
+
+func (dsConn PostgreSQL) executeContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExecute(ctx, query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExecute(ctx, query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn PostgreSQL) exec(query string, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExec(query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExec(query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn PostgreSQL) begin(opts *sql.TxOptions) (DsConnection, *sql.Tx, map[string]string, error) {
+	tx, copier, errProperties, err := copyBegin(dsConn.dsType, dsConn.db, opts)
+	dsConn.tx = tx
+	dsConn.copier = copier
+	return dsConn, tx, errProperties, err
+}
+
+// PostgreSQL rolls back DROP and CREATE TABLE along with everything else
+func (dsConn PostgreSQL) transactionalDDL() bool {
+	return true
+}
+
+func (dsConn PostgreSQL) supportsCopy() bool {
+	return true
+}
+
+func (dsConn PostgreSQL) ping(ctx context.Context) error {
+	return dsConn.db.PingContext(ctx)
 }
 
 func (dsConn PostgreSQL) closeDb() {
-	dsConn.db.Close()
+	releaseDb(dsConn.db)
 }
 
 func getNewPostgreSQL(
@@ -44,8 +82,11 @@ func getNewPostgreSQL(
 		connection.Port,
 		connection.DbName,
 	)
+	connString += keepaliveParams(connection, connString)
+	connString += connectTimeoutParams(connection, connString)
+	connString += sslModeParams(connection, connString)
 
-	postgresql, err = sql.Open("pgx", connString)
+	postgresql, err = openDb(connection, "pgx", connString)
 
 	if err != nil {
 		return dsConn, errProperties, err
@@ -54,14 +95,7 @@ func getNewPostgreSQL(
 	dsConn = PostgreSQL{
 		"postgresql",
 		"pgx",
-		fmt.Sprintf(
-			"postgres://%s:%s@%s:%v/%s",
-			connection.Username,
-			connection.Password,
-			connection.Hostname,
-			connection.Port,
-			connection.DbName,
-		),
+		connString,
 		fmt.Sprintf(
 			"postgres://<USERNAME_MASKED>:<PASSWORD_MASKED>@%s:%v/%s",
 			connection.Hostname,
@@ -69,6 +103,8 @@ func getNewPostgreSQL(
 			connection.DbName,
 		),
 		postgresql,
+		nil,
+		nil,
 	}
 
 	return dsConn, errProperties, err
@@ -131,6 +167,37 @@ func (dsConn PostgreSQL) dropTable(
 	return dropTableIfExistsWithSchema(dsConn, transfer)
 }
 
+func (dsConn PostgreSQL) isView(transfer data.Transfer) (bool, map[string]string, error) {
+	return standardIsView(dsConn, transfer, "current_schema()")
+}
+
+func (dsConn PostgreSQL) generatedColumns(transfer data.Transfer) ([]string, map[string]string, error) {
+	return standardGeneratedColumns(dsConn, transfer, "current_schema()", "is_generated = 'ALWAYS'")
+}
+
+func (dsConn PostgreSQL) dropDenied(transfer data.Transfer) (bool, map[string]string, error) {
+	schema := "current_schema()"
+	if transfer.TargetSchema != "" {
+		schema = fmt.Sprintf("'%s'", transfer.TargetSchema)
+	}
+	// only the table's owner, the schema's owner, or members of those
+	// roles may drop a table
+	return queryCountsAny(dsConn, fmt.Sprintf(
+		"SELECT COUNT(*) FROM pg_tables t JOIN pg_namespace n ON n.nspname = t.schemaname WHERE LOWER(t.schemaname) = LOWER(%s) AND LOWER(t.tablename) = LOWER('%s') AND NOT pg_has_role(t.tableowner, 'MEMBER') AND NOT pg_has_role(n.nspowner, 'MEMBER')",
+		schema,
+		transfer.TargetTable,
+	))
+}
+
+func (dsConn PostgreSQL) truncateTable(
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	return truncateTableWithSchema(dsConn, transfer)
+}
+
 func (dsConn PostgreSQL) deleteFromTable(
 	transfer data.Transfer,
 ) (
@@ -184,10 +251,33 @@ func (dsConn PostgreSQL) getQueryEnder(targetTable string) string {
 	return ""
 }
 
+func (dsConn PostgreSQL) getExplainQuery(query string, analyze bool) (string, map[string]string, error) {
+	return standardGetExplainQuery(dsConn.dsType, query, analyze, true)
+}
+
 func (dsConn PostgreSQL) getQueryStarter(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo) string {
 	return standardGetQueryStarter(targetTable, targetSchema, columnInfo)
 }
 
+func (dsConn PostgreSQL) getUpsertQuery(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo, conflictColumns []string) (string, string, map[string]string, error) {
+	return standardGetQueryStarter(targetTable, targetSchema, columnInfo), onConflictUpsertEnder(columnInfo, conflictColumns), nil, nil
+}
+
+// Generates an ON CONFLICT clause, which needs a unique index or constraint
+// on exactly the conflict columns
+func onConflictUpsertEnder(columnInfo ResultSetColumnInfo, conflictColumns []string) string {
+	updateColumns := upsertUpdateColumns(columnInfo, conflictColumns)
+	if len(updateColumns) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(conflictColumns, ", "))
+	}
+
+	sets := make([]string, len(updateColumns))
+	for i, name := range updateColumns {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", name, name)
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflictColumns, ", "), strings.Join(sets, ", "))
+}
+
 func postgresqlWriteByteArray(value interface{}, terminator string) string {
 	return fmt.Sprintf("'\\x%x'%s", value, terminator)
 }
@@ -312,7 +402,11 @@ func (dsConn PostgreSQL) getIntermediateType(
 	case "TEXT":
 		intermediateType = "PostgreSQL_TEXT"
 	default:
-		err = fmt.Errorf("no PostgreSQL intermediate type for driver type '%v'", colTypeFromDriver)
+		if isPostgreSQLArray(colTypeFromDriver) {
+			intermediateType = "PostgreSQL_ARRAY"
+		} else {
+			err = fmt.Errorf("no PostgreSQL intermediate type for driver type '%v'", colTypeFromDriver)
+		}
 	}
 
 	return intermediateType, errProperties, err
@@ -365,6 +459,8 @@ func (dsConn PostgreSQL) getCreateTableType(resultSetColInfo ResultSetColumnInfo
 		createType = "JSON"
 	case "PostgreSQL_JSONB":
 		createType = "JSONB"
+	case "PostgreSQL_ARRAY":
+		createType = postgresqlArrayCreateType(resultSetColInfo.ColumnDbTypes[colNum])
 	case "PostgreSQL_LINE":
 		createType = "LINE"
 	case "PostgreSQL_LSEG":
@@ -641,8 +737,9 @@ var postgresValWriters = map[string]func(value interface{}, terminator string) s
 	"PostgreSQL_UUID":          writeInsertStringNoEscape,
 	"PostgreSQL_VARCHAR":       writeInsertEscapedString,
 	"PostgreSQL_BPCHAR":        writeInsertEscapedString,
-	"PostgreSQL_JSON":          writeInsertEscapedString,
-	"PostgreSQL_JSONB":         writeInsertEscapedString,
+	"PostgreSQL_JSON":          writePostgreSQLJSON("json"),
+	"PostgreSQL_JSONB":         writePostgreSQLJSON("jsonb"),
+	"PostgreSQL_ARRAY":         writeInsertEscapedString,
 	"PostgreSQL_TEXT":          writeInsertEscapedString,
 	"PostgreSQL_TSQUERY":       writeInsertEscapedString,
 	"PostgreSQL_TSVECTOR":      writeInsertEscapedString,
@@ -671,7 +768,7 @@ var postgresValWriters = map[string]func(value interface{}, terminator string) s
 	"MySQL_VARBINARY": postgresqlWriteByteArray,
 	"MySQL_BLOB":      postgresqlWriteByteArray,
 	"MySQL_GEOMETRY":  postgresqlWriteByteArray,
-	"MySQL_JSON":      writeInsertEscapedString,
+	"MySQL_JSON":      writePostgreSQLJSON("json"),
 
 	// MSSQL
 
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Hands each callback to every one of the reporters, in order
+type MultiReporter []data.ProgressReporter
+
+func (m MultiReporter) OnBatch(rows int64) {
+	for _, reporter := range m {
+		reporter.OnBatch(rows)
+	}
+}
+
+func (m MultiReporter) OnStatus(status string) {
+	for _, reporter := range m {
+		reporter.OnStatus(status)
+	}
+}
+
+func (m MultiReporter) OnError(err error) {
+	for _, reporter := range m {
+		reporter.OnError(err)
+	}
+}
+
+func (m MultiReporter) OnReject(row string, err error) {
+	for _, reporter := range m {
+		reporter.OnReject(row, err)
+	}
+}
+
+func (m MultiReporter) OnRetry(dsType string, attempt int, err error, wait time.Duration) {
+	for _, reporter := range m {
+		reporter.OnRetry(dsType, attempt, err, wait)
+	}
+}
+
+type noProgress struct{}
+
+func (noProgress) OnBatch(rows int64)                                                {}
+func (noProgress) OnStatus(status string)                                            {}
+func (noProgress) OnError(err error)                                                 {}
+func (noProgress) OnReject(row string, err error)                                    {}
+func (noProgress) OnRetry(dsType string, attempt int, err error, wait time.Duration) {}
+
+// The transfer's reporter, or one that ignores everything if it has none
+func progress(transfer data.Transfer) data.ProgressReporter {
+	if transfer.Progress == nil {
+		return noProgress{}
+	}
+	return transfer.Progress
+}
+
+// Reports the transfer as active, runs it, then reports how it ended
+func reportProgress(
+	reporter data.ProgressReporter,
+	run func() (map[string]string, error),
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	reporter.OnStatus("active")
+
+	errProperties, err = run()
+	if err != nil {
+		reporter.OnError(err)
+		reporter.OnStatus("error")
+		return errProperties, err
+	}
+
+	reporter.OnStatus("complete")
+	return nil, nil
+}
+
+// Counts the rows read through it, for writers that don't batch
+type countingRows struct {
+	sourceRows
+	count int64
+}
+
+func (r *countingRows) Next() bool {
+	if r.sourceRows.Next() {
+		r.count++
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Records every callback as a line, in the order they came
+type fakeReporter struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *fakeReporter) record(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+}
+
+func (r *fakeReporter) OnBatch(rows int64)             { r.record("batch %d", rows) }
+func (r *fakeReporter) OnStatus(status string)         { r.record("status %s", status) }
+func (r *fakeReporter) OnError(err error)              { r.record("error %v", err) }
+func (r *fakeReporter) OnReject(row string, err error) { r.record("reject %s", row) }
+func (r *fakeReporter) OnRetry(dsType string, attempt int, err error, wait time.Duration) {
+	r.record("retry %s %d in %s", dsType, attempt, wait)
+}
+
+func TestProgressReporter(t *testing.T) {
+	tests := []struct {
+		name   string
+		failOn string
+		want   []string
+	}{
+		{"success", "", []string{"status active", "batch 1000", "batch 1000", "batch 500", "status complete"}},
+		{"failing batch", "row-1500", []string{"status active", "batch 1000", "error db.Query() threw an error", "status error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := &fakeReporter{}
+			transfer := data.Transfer{
+				Query:        "select id, name from events",
+				TargetSchema: "dbo",
+				TargetTable:  "events",
+				Progress:     reporter,
+			}
+			source := newFakeSourceRows(t, transfer.Query, 2500)
+			target, fake := newFakeMSSQL(t, "target")
+			fake.failOn = tt.failOn
+
+			_, err := reportProgress(progress(transfer), func() (map[string]string, error) {
+				return runFakeInsertFrom(t, source, target, transfer)
+			})
+			if (err != nil) != (tt.failOn != "") {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(reporter.calls, tt.want) {
+				t.Errorf("wanted callbacks %q, got %q", tt.want, reporter.calls)
+			}
+		})
+	}
+}
+
+func TestCopyReportsProgressWhileStreaming(t *testing.T) {
+	reporter := &fakeReporter{}
+	transfer := data.Transfer{
+		Query:        "select id, name from events",
+		TargetSchema: "public",
+		TargetTable:  "events",
+		Progress:     reporter,
+	}
+	source := newFakeSourceRows(t, transfer.Query, 2*copyProgressRows+500)
+	target, _ := newFakePostgreSQL(t, "target")
+
+	if _, err := runFakeInsertFrom(t, source, target, transfer); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{fmt.Sprintf("batch %d", copyProgressRows), fmt.Sprintf("batch %d", copyProgressRows), "batch 500"}
+	if !reflect.DeepEqual(reporter.calls, want) {
+		t.Errorf("wanted callbacks %q, got %q", want, reporter.calls)
+	}
+}
+
+func TestMultiReporter(t *testing.T) {
+	first, second := &fakeReporter{}, &fakeReporter{}
+	reporter := MultiReporter{first, second}
+
+	reporter.OnStatus("active")
+	reporter.OnBatch(10)
+	reporter.OnError(errors.New("boom"))
+
+	want := []string{"status active", "batch 10", "error boom"}
+	if !reflect.DeepEqual(first.calls, want) || !reflect.DeepEqual(second.calls, want) {
+		t.Errorf("wanted both reporters to get %q, got %q and %q", want, first.calls, second.calls)
+	}
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunQueryTimesOut(t *testing.T) {
+	conn, fake := newFakePostgreSQL(t, "db")
+	fake.slowOn = "pg_sleep"
+
+	start := time.Now()
+	errProperties, err := runQuery(conn, "select pg_sleep(3600)", 50*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrQueryTimedOut) {
+		t.Fatalf("wanted ErrQueryTimedOut, got %v", err)
+	}
+	if errProperties["timeout"] != "50ms" {
+		t.Errorf("wanted the timeout in the error properties, got %v", errProperties)
+	}
+	if elapsed < 50*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("wanted the query cancelled at its timeout, it took %s", elapsed)
+	}
+	if fake.cancelled != 1 {
+		t.Errorf("wanted the query cancelled on the database, got %d cancellations", fake.cancelled)
+	}
+
+	_, err = runQuery(conn, "select 1", 50*time.Millisecond)
+	if err != nil {
+		t.Errorf("wanted a quick query to finish within its timeout, got %v", err)
+	}
+}
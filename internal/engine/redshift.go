@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -17,14 +19,49 @@ type Redshift struct {
 	connString      string `json:"-"`
 	debugConnString string
 	db              *sql.DB
+	tx              *sql.Tx
 }
 
-func (dsConn Redshift) execute(query string) (rows *sql.Rows, errProperties map[string]string, err error) {
-	return standardExecute(query, dsConn.dsType, dsConn.db)
+func (dsConn Redshift) execute(query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	return dsConn.executeContext(context.Background(), query, args...)
+}
+
+func (dsConn Redshift) executeContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExecute(ctx, query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExecute(ctx, query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn Redshift) exec(query string, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExec(query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExec(query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn Redshift) begin(opts *sql.TxOptions) (DsConnection, *sql.Tx, map[string]string, error) {
+	tx, errProperties, err := standardBegin(dsConn.dsType, dsConn.db, opts)
+	dsConn.tx = tx
+	return dsConn, tx, errProperties, err
+}
+
+// Redshift rolls back DROP and CREATE TABLE along with everything else
+func (dsConn Redshift) transactionalDDL() bool {
+	return true
+}
+
+// Redshift only loads bulk data with COPY from S3, not from STDIN
+func (dsConn Redshift) supportsCopy() bool {
+	return false
+}
+
+func (dsConn Redshift) ping(ctx context.Context) error {
+	return dsConn.db.PingContext(ctx)
 }
 
 func (dsConn Redshift) closeDb() {
-	dsConn.db.Close()
+	releaseDb(dsConn.db)
 }
 
 func getNewRedshift(
@@ -43,8 +80,10 @@ func getNewRedshift(
 		connection.Port,
 		connection.DbName,
 	)
+	connString += connectTimeoutParams(connection, connString)
+	connString += sslModeParams(connection, connString)
 
-	redshift, err = sql.Open("pgx", connString)
+	redshift, err = openDb(connection, "pgx", connString)
 	if err != nil {
 		return dsConn, errProperties, err
 	}
@@ -52,14 +91,7 @@ func getNewRedshift(
 	dsConn = Redshift{
 		"redshift",
 		"pgx",
-		fmt.Sprintf(
-			"postgres://%s:%s@%s:%d/%s",
-			connection.Username,
-			connection.Password,
-			connection.Hostname,
-			connection.Port,
-			connection.DbName,
-		),
+		connString,
 		fmt.Sprintf(
 			"postgres://<USERNAME_MASKED>:<PASSWORD_MASKED>@%s:%d/%s",
 			connection.Hostname,
@@ -67,6 +99,7 @@ func getNewRedshift(
 			connection.DbName,
 		),
 		redshift,
+		nil,
 	}
 	return dsConn, errProperties, err
 }
@@ -185,6 +218,30 @@ func (dsConn Redshift) dropTable(
 	return dropTableIfExistsWithSchema(dsConn, transfer)
 }
 
+func (dsConn Redshift) isView(transfer data.Transfer) (bool, map[string]string, error) {
+	return standardIsView(dsConn, transfer, "current_schema()")
+}
+
+// Redshift has no generated columns
+func (dsConn Redshift) generatedColumns(transfer data.Transfer) ([]string, map[string]string, error) {
+	return nil, nil, nil
+}
+
+// DROP can be granted to groups and roles in ways that can't be read back
+// reliably, so a missing permission shows up when the table is dropped
+func (dsConn Redshift) dropDenied(transfer data.Transfer) (bool, map[string]string, error) {
+	return false, nil, nil
+}
+
+func (dsConn Redshift) truncateTable(
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	return truncateTableWithSchema(dsConn, transfer)
+}
+
 func (dsConn Redshift) deleteFromTable(
 	transfer data.Transfer,
 ) (
@@ -224,10 +281,18 @@ func (dsConn Redshift) getQueryEnder(targetTable string) string {
 	return ""
 }
 
+func (dsConn Redshift) getExplainQuery(query string, analyze bool) (string, map[string]string, error) {
+	return standardGetExplainQuery(dsConn.dsType, query, analyze, false)
+}
+
 func (dsConn Redshift) getQueryStarter(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo) string {
 	return standardGetQueryStarter(targetTable, targetSchema, columnInfo)
 }
 
+func (dsConn Redshift) getUpsertQuery(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo, conflictColumns []string) (string, string, map[string]string, error) {
+	return "", "", map[string]string{"dsType": dsConn.dsType}, errors.New("upsert is not supported for redshift")
+}
+
 func (dsConn Redshift) getCreateTableType(resultSetColInfo ResultSetColumnInfo, colNum int) (createType string) {
 
 	scanType := resultSetColInfo.ColumnScanTypes[colNum]
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// What the inserts read rows from. Satisfied by *sql.Rows and *rowBuffer.
+type sourceRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// Server wide caps on rows read from the source but not yet taken by the
+// target, used when a transfer doesn't set its own. 0 means no cap.
+var (
+	defaultMaxBufferedRows  int
+	defaultMaxBufferedBytes int64
+)
+
+// Sets the buffer caps for transfers that don't set their own
+func SetBufferLimits(maxRows int, maxBytes int64) {
+	defaultMaxBufferedRows = maxRows
+	defaultMaxBufferedBytes = maxBytes
+}
+
+// Values that aren't text or bytes are counted as this many bytes
+const bufferedValueSize = 16
+
+// Reads the source ahead of the target, one row at a time, and stops reading
+// while maxRows rows or maxBytes bytes are waiting to be taken. A row that is
+// bigger than maxBytes on its own is still let through once the buffer is
+// empty, so the transfer can't stall.
+type rowBuffer struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    [][]interface{}
+	sizes    []int64
+	bytes    int64
+	maxRows  int
+	maxBytes int64
+	current  []interface{}
+	done     bool
+	closed   bool
+	err      error
+	exited   chan struct{}
+
+	// the most rows and bytes that were ever waiting at once
+	peakRows  int
+	peakBytes int64
+}
+
+// Wraps rows in a rowBuffer if the transfer, or the server, caps buffering.
+// The returned stop function must be called once the rows are no longer
+// needed.
+func bufferRows(rows *sql.Rows, transfer data.Transfer, numCols int) (source sourceRows, stop func()) {
+	maxRows, maxBytes := transfer.MaxBufferedRows, transfer.MaxBufferedBytes
+	if maxRows == 0 {
+		maxRows = defaultMaxBufferedRows
+	}
+	if maxBytes == 0 {
+		maxBytes = defaultMaxBufferedBytes
+	}
+	if maxRows == 0 && maxBytes == 0 {
+		return rows, func() {}
+	}
+
+	buffer := newRowBuffer(rows, numCols, maxRows, maxBytes)
+	return buffer, buffer.Close
+}
+
+func newRowBuffer(rows sourceRows, numCols int, maxRows int, maxBytes int64) *rowBuffer {
+	buffer := &rowBuffer{
+		maxRows:  maxRows,
+		maxBytes: maxBytes,
+		exited:   make(chan struct{}),
+	}
+	buffer.cond = sync.NewCond(&buffer.mu)
+	go buffer.read(rows, numCols)
+	return buffer
+}
+
+func (b *rowBuffer) read(rows sourceRows, numCols int) {
+	defer close(b.exited)
+
+	var err error
+	for rows.Next() {
+		// the values are handed to the reader, so each row gets its own
+		values := make([]interface{}, numCols)
+		valuePtrs := make([]interface{}, numCols)
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err = rows.Scan(valuePtrs...); err != nil {
+			break
+		}
+		size := rowSize(values)
+
+		b.mu.Lock()
+		for !b.closed && len(b.queue) > 0 && b.full(size) {
+			b.cond.Wait()
+		}
+		if b.closed {
+			b.mu.Unlock()
+			return
+		}
+		b.queue = append(b.queue, values)
+		b.sizes = append(b.sizes, size)
+		b.bytes += size
+		if len(b.queue) > b.peakRows {
+			b.peakRows = len(b.queue)
+		}
+		if b.bytes > b.peakBytes {
+			b.peakBytes = b.bytes
+		}
+		b.cond.Broadcast()
+		b.mu.Unlock()
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+
+	b.mu.Lock()
+	b.err = err
+	b.done = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+}
+
+// Whether adding a row of size bytes would go over a cap. Callers must hold mu.
+func (b *rowBuffer) full(size int64) bool {
+	return (b.maxRows > 0 && len(b.queue) >= b.maxRows) ||
+		(b.maxBytes > 0 && b.bytes+size > b.maxBytes)
+}
+
+func (b *rowBuffer) Next() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.queue) == 0 && !b.done && !b.closed {
+		b.cond.Wait()
+	}
+	if len(b.queue) == 0 {
+		b.current = nil
+		return false
+	}
+
+	b.current = b.queue[0]
+	b.bytes -= b.sizes[0]
+	b.queue[0] = nil
+	b.queue = b.queue[1:]
+	b.sizes = b.sizes[1:]
+	b.cond.Broadcast()
+	return true
+}
+
+// Only scans into *interface{}, which is all the inserts use
+func (b *rowBuffer) Scan(dest ...interface{}) error {
+	if len(dest) != len(b.current) {
+		return fmt.Errorf("expected %d destination arguments in Scan, not %d", len(b.current), len(dest))
+	}
+	for i, value := range b.current {
+		ptr, ok := dest[i].(*interface{})
+		if !ok {
+			return fmt.Errorf("can't scan a buffered row into a %T", dest[i])
+		}
+		*ptr = value
+	}
+	return nil
+}
+
+func (b *rowBuffer) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// Stops reading the source, and waits for the reading goroutine to exit
+func (b *rowBuffer) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+
+	<-b.exited
+}
+
+func rowSize(values []interface{}) int64 {
+	var size int64
+	for _, value := range values {
+		switch v := value.(type) {
+		case []byte:
+			size += int64(len(v))
+		case string:
+			size += int64(len(v))
+		default:
+			size += bufferedValueSize
+		}
+	}
+	return size
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// A source of numRows rows that sends each row's number on fetched as it's
+// read
+type instrumentedRows struct {
+	numRows int
+	next    int
+	value   string
+	fetched chan int
+	err     error
+}
+
+func (r *instrumentedRows) Next() bool {
+	if r.next >= r.numRows {
+		return false
+	}
+	r.next++
+	r.fetched <- r.next
+	return true
+}
+
+func (r *instrumentedRows) Scan(dest ...interface{}) error {
+	*dest[0].(*interface{}) = int64(r.next)
+	*dest[1].(*interface{}) = r.value
+	return nil
+}
+
+func (r *instrumentedRows) Err() error { return r.err }
+
+// Reads every row from buffer slowly, failing if the source gets more than
+// maxAhead rows ahead of it
+func drainSlowly(t *testing.T, buffer *rowBuffer, source *instrumentedRows, maxAhead int) int {
+	t.Helper()
+
+	var fetched int64
+	done := make(chan struct{})
+	go func() {
+		for n := range source.fetched {
+			atomic.StoreInt64(&fetched, int64(n))
+		}
+		close(done)
+	}()
+
+	values := make([]interface{}, 2)
+	taken := 0
+	for buffer.Next() {
+		if err := buffer.Scan(&values[0], &values[1]); err != nil {
+			t.Fatal(err)
+		}
+		taken++
+		if values[0] != int64(taken) {
+			t.Fatalf("row %d came back as %v", taken, values[0])
+		}
+
+		// the slow target
+		time.Sleep(time.Millisecond)
+
+		if ahead := int(atomic.LoadInt64(&fetched)) - taken; ahead > maxAhead {
+			t.Fatalf("source was read %d rows ahead of the target, want at most %d", ahead, maxAhead)
+		}
+	}
+	buffer.Close()
+	close(source.fetched)
+	<-done
+
+	return taken
+}
+
+func TestRowBufferCapsRows(t *testing.T) {
+	source := &instrumentedRows{numRows: 200, fetched: make(chan int, 200)}
+	buffer := newRowBuffer(source, 2, 10, 0)
+
+	taken := drainSlowly(t, buffer, source, 10+1)
+	if taken != 200 {
+		t.Errorf("got %d rows, want 200", taken)
+	}
+	if buffer.peakRows > 10 {
+		t.Errorf("%d rows were buffered at once, want at most 10", buffer.peakRows)
+	}
+	if buffer.peakRows < 10 {
+		t.Errorf("the buffer never filled up, only %d rows were buffered at once", buffer.peakRows)
+	}
+}
+
+func TestRowBufferCapsBytes(t *testing.T) {
+	source := &instrumentedRows{numRows: 100, value: strings.Repeat("x", 84), fetched: make(chan int, 100)}
+	// each row is 84 bytes of text and a 16 byte number
+	buffer := newRowBuffer(source, 2, 0, 500)
+
+	taken := drainSlowly(t, buffer, source, 5+1)
+	if taken != 100 {
+		t.Errorf("got %d rows, want 100", taken)
+	}
+	if buffer.peakBytes > 500 {
+		t.Errorf("%d bytes were buffered at once, want at most 500", buffer.peakBytes)
+	}
+}
+
+func TestRowBufferLetsOversizedRowsThrough(t *testing.T) {
+	source := &instrumentedRows{numRows: 3, value: strings.Repeat("x", 1000), fetched: make(chan int, 3)}
+	buffer := newRowBuffer(source, 2, 0, 10)
+
+	if taken := drainSlowly(t, buffer, source, 1+1); taken != 3 {
+		t.Errorf("got %d rows, want 3", taken)
+	}
+}
+
+func TestRowBufferReportsSourceErrors(t *testing.T) {
+	sourceErr := errors.New("connection reset")
+	source := &instrumentedRows{numRows: 5, fetched: make(chan int, 5), err: sourceErr}
+	buffer := newRowBuffer(source, 2, 2, 0)
+
+	drainSlowly(t, buffer, source, 2+1)
+	if !errors.Is(buffer.Err(), sourceErr) {
+		t.Errorf("got error %v, want %v", buffer.Err(), sourceErr)
+	}
+}
+
+func TestInsertWithBufferLimit(t *testing.T) {
+	query := "select id, name from source_table"
+	source := newFakeSourceRows(t, query, 50)
+	target, fake := newFakeMSSQL(t, "target")
+
+	transfer := data.Transfer{Query: query, TargetTable: "target_table", MaxBufferedRows: 3}
+	errProperties, err := runFakeInsertFrom(t, source, target, transfer)
+	if err != nil {
+		t.Fatalf("insert failed. err: %v, errProperties: %v", err, errProperties)
+	}
+
+	inserted := strings.Join(fake.committedStatements(), "\n")
+	for i := 1; i <= 50; i++ {
+		if !strings.Contains(inserted, fmt.Sprintf("'row-%d'", i)) {
+			t.Fatalf("row-%d wasn't inserted", i)
+		}
+	}
+}
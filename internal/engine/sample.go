@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Rewrites transfer.Query to return only its first SourceLimit rows, or a
+// random SampleRate fraction of them. The query can be any query, not just a
+// table, so rather than TABLESAMPLE, each row is kept by chance.
+func sampleSource(dsConn DsConnection, transfer data.Transfer) string {
+	dsType, _, _ := dsConn.getConnectionInfo()
+
+	if transfer.SourceLimit > 0 {
+		switch dsType {
+		case "mssql":
+			return fmt.Sprintf("SELECT TOP %d * FROM (%s) sqlpipe_sample", transfer.SourceLimit, transfer.Query)
+		case "oracle":
+			return fmt.Sprintf("SELECT * FROM (%s) sqlpipe_sample WHERE ROWNUM <= %d", transfer.Query, transfer.SourceLimit)
+		default:
+			return fmt.Sprintf("SELECT * FROM (%s) sqlpipe_sample LIMIT %d", transfer.Query, transfer.SourceLimit)
+		}
+	}
+
+	if transfer.SampleRate > 0 {
+		return fmt.Sprintf("SELECT * FROM (%s) sqlpipe_sample WHERE %s < %s", transfer.Query, randomValue(dsType), strconv.FormatFloat(transfer.SampleRate, 'f', -1, 64))
+	}
+
+	return transfer.Query
+}
+
+// An expression for a random number from 0 to 1, new for each row
+func randomValue(dsType string) string {
+	switch dsType {
+	case "mysql":
+		return "RAND()"
+	case "mssql":
+		// RAND() alone is the same for every row of a query
+		return "RAND(CHECKSUM(NEWID()))"
+	case "oracle":
+		return "DBMS_RANDOM.VALUE"
+	case "snowflake":
+		return "UNIFORM(0::FLOAT, 1::FLOAT, RANDOM())"
+	default:
+		return "RANDOM()"
+	}
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestSourceLimitCopiesExactlyThatManyRows(t *testing.T) {
+	// the source honours LIMIT, out of 1000 rows
+	limitRX := regexp.MustCompile(`LIMIT ([0-9]+)$`)
+	source, sourceFake := newFakePostgreSQL(t, "source")
+	sourceFake.resolve = func(query string) fakeResult {
+		result := fakeResult{columns: []string{"id", "name"}, types: []string{"INT8", "TEXT"}}
+		numRows := 1000
+		if match := limitRX.FindStringSubmatch(query); match != nil {
+			numRows, _ = strconv.Atoi(match[1])
+		}
+		for i := 1; i <= numRows; i++ {
+			result.rows = append(result.rows, []driver.Value{int64(i), fmt.Sprintf("row-%d", i)})
+		}
+		return result
+	}
+
+	target, fake := newFakeMSSQL(t, "target")
+	useFakeDs(t, map[string]DsConnection{"source": source, "target": target})
+
+	transfer := data.Transfer{
+		Source:       data.Connection{Name: "source"},
+		Target:       data.Connection{Name: "target"},
+		Query:        "select id, name from users",
+		TargetSchema: "dbo",
+		TargetTable:  "users_copy",
+		SourceLimit:  100,
+	}
+	errProperties, err := runTransfer(&transfer)
+	if err != nil {
+		t.Fatalf("unexpected error %v, errProperties: %v", err, errProperties)
+	}
+	if queries := sourceFake.executed(); len(queries) != 1 || queries[0] != "SELECT * FROM (select id, name from users) sqlpipe_sample LIMIT 100" {
+		t.Fatalf("wanted only the limited query run on the source, got %q", queries)
+	}
+
+	inserted := 0
+	for _, statement := range fake.committedStatements() {
+		inserted += strings.Count(statement, "'row-")
+	}
+	if inserted != 100 {
+		t.Errorf("wanted 100 rows copied, got %d", inserted)
+	}
+}
+
+func TestSampleSource(t *testing.T) {
+	postgresql, _ := newFakePostgreSQL(t, "postgresql")
+	mssql, _ := newFakeMSSQL(t, "mssql")
+	query := "select * from users"
+
+	tests := []struct {
+		name     string
+		dsConn   DsConnection
+		transfer data.Transfer
+		want     string
+	}{
+		{"no sampling", postgresql, data.Transfer{Query: query}, query},
+		{"postgresql sample", postgresql, data.Transfer{Query: query, SampleRate: 0.25}, "SELECT * FROM (select * from users) sqlpipe_sample WHERE RANDOM() < 0.25"},
+		{"sql server limit", mssql, data.Transfer{Query: query, SourceLimit: 10}, "SELECT TOP 10 * FROM (select * from users) sqlpipe_sample"},
+		{"sql server sample", mssql, data.Transfer{Query: query, SampleRate: 0.1}, "SELECT * FROM (select * from users) sqlpipe_sample WHERE RAND(CHECKSUM(NEWID())) < 0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampleSource(tt.dsConn, tt.transfer); got != tt.want {
+				t.Errorf("wanted %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Checks the transfer's target schema exists, creating it if the transfer
+// asks for that. Target schemas are validated as plain identifiers, so they
+// go into SQL unquoted, folding to the target's usual case like the target
+// table does. The lookup matches the folded name, so a quoted schema that
+// only differs in case, such as "Analytics", isn't taken for it. MySQL and
+// Oracle targets put tables in the connection's database or user, so
+// there's nothing to check on them.
+func ensureTargetSchema(
+	dsConn DsConnection,
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	dsType, _, _ := dsConn.getConnectionInfo()
+	if transfer.TargetSchema == "" || dsType == "mysql" || dsType == "oracle" {
+		return nil, nil
+	}
+
+	exists, errProperties, err := queryCountsAny(dsConn, fmt.Sprintf(
+		"SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = '%s'",
+		foldedSchema(dsType, transfer.TargetSchema),
+	))
+	if err != nil || exists {
+		return errProperties, err
+	}
+
+	if !transfer.CreateTargetSchema {
+		return map[string]string{"targetSchema": transfer.TargetSchema}, errors.New("target schema doesn't exist. Create it, or set create target schema")
+	}
+
+	_, errProperties, err = dsConn.exec(fmt.Sprintf("CREATE SCHEMA %s", transfer.TargetSchema))
+	return errProperties, err
+}
+
+// The name an unquoted schema is stored under. PostgreSQL and Redshift fold
+// unquoted identifiers to lower case and Snowflake to upper case. SQL
+// Server keeps the case it's given, and its collation decides whether the
+// lookup minds it.
+func foldedSchema(dsType string, schema string) string {
+	switch dsType {
+	case "postgresql", "redshift":
+		return strings.ToLower(schema)
+	case "snowflake":
+		return strings.ToUpper(schema)
+	}
+	return schema
+}
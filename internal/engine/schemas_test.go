@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestInsertIntoOtherTargetSchema(t *testing.T) {
+	lookup := "SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = 'analytics'"
+	missing := fakeResult{columns: []string{"count"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(0)}}}
+
+	tests := []struct {
+		name         string
+		exists       bool
+		createSchema bool
+		want         []string
+	}{
+		{"existing schema", true, false, []string{"SELECT COUNT(*) FROM pg_tables", "DROP TABLE IF EXISTS analytics.users_copy", "CREATE TABLE analytics.users_copy", "COPY"}},
+		{"created schema", false, true, []string{"CREATE SCHEMA analytics", "SELECT COUNT(*) FROM pg_tables", "DROP TABLE IF EXISTS analytics.users_copy", "CREATE TABLE analytics.users_copy", "COPY"}},
+		{"missing schema", false, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, fake := newFakePostgreSQL(t, "target")
+			if !tt.exists {
+				fake.results[lookup] = missing
+			}
+
+			// the source query reads from the default schema
+			transfer := data.Transfer{
+				Query:              "select id, name from users",
+				TargetSchema:       "analytics",
+				TargetTable:        "users_copy",
+				WriteMode:          data.WriteModeRecreate,
+				CreateTargetSchema: tt.createSchema,
+			}
+
+			errProperties, err := runFakeInsert(t, target, transfer)
+			if tt.want == nil {
+				if err == nil || errProperties["targetSchema"] != "analytics" {
+					t.Fatalf("wanted the missing schema refused, got %v, %v", err, errProperties)
+				}
+				if executed := fake.executed(); len(executed) != 0 {
+					t.Errorf("wanted nothing run on the target, got %q", executed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var executed []string
+			for _, statement := range fake.executed() {
+				// the view check is the same whatever the schema
+				if !strings.Contains(statement, "information_schema.tables") {
+					executed = append(executed, statement)
+				}
+			}
+			if len(executed) != len(tt.want) {
+				t.Fatalf("wanted %d statements, got %q", len(tt.want), executed)
+			}
+			for i, prefix := range tt.want {
+				if !strings.HasPrefix(executed[i], prefix) {
+					t.Errorf("statement %d: wanted %q, got %q", i, prefix, executed[i])
+				}
+			}
+			if !strings.HasPrefix(executed[len(executed)-1], "COPY analytics.users_copy") {
+				t.Errorf("wanted rows copied into analytics.users_copy, got %q", executed[len(executed)-1])
+			}
+		})
+	}
+}
+
+func TestTargetSchemaLookupMatchesFoldedCase(t *testing.T) {
+	target, fake := newFakePostgreSQL(t, "target")
+	// only a quoted "Analytics" is there, which the unquoted Analytics isn't
+	lookup := "SELECT COUNT(*) FROM information_schema.schemata WHERE schema_name = '%s'"
+	fake.results[fmt.Sprintf(lookup, "Analytics")] = fakeResult{columns: []string{"count"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(1)}}}
+	fake.results[fmt.Sprintf(lookup, "analytics")] = fakeResult{columns: []string{"count"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(0)}}}
+
+	errProperties, err := ensureTargetSchema(target, data.Transfer{TargetSchema: "Analytics", CreateTargetSchema: true})
+	if err != nil {
+		t.Fatalf("err: %v, errProperties: %v", err, errProperties)
+	}
+	if executed := fake.executed(); len(executed) != 1 || executed[0] != "CREATE SCHEMA Analytics" {
+		t.Errorf("wanted the schema created, got %q", executed)
+	}
+
+	tests := []struct {
+		dsType string
+		want   string
+	}{
+		{"postgresql", "analytics"},
+		{"redshift", "analytics"},
+		{"snowflake", "ANALYTICS"},
+		{"mssql", "Analytics"},
+	}
+	for _, tt := range tests {
+		if got := foldedSchema(tt.dsType, "Analytics"); got != tt.want {
+			t.Errorf("%s: wanted %q, got %q", tt.dsType, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// Runs each statement of a script in query.Query, in order, writing what
+// each one did to out: its result set as csv, how many rows it changed, or
+// for statements that neither return nor change rows, their command tag.
+// Unless continueOnError is set, the first statement to fail stops the
+// script. Otherwise failures are written to out, and counted in the error.
+func RunScript(query *data.Query, continueOnError bool, out io.Writer) (
+	errProperties map[string]string,
+	err error,
+) {
+	statements, err := splitStatements(query.Query, query.Connection.DsType)
+	if err != nil {
+		return map[string]string{"error": err.Error()}, errors.New("unable to split script into statements")
+	}
+
+	dsConn, errProperties, err := GetDs(query.Connection)
+	defer dsConn.closeDb()
+	if err != nil {
+		return errProperties, err
+	}
+
+	return runScript(dsConn, statements, continueOnError, out)
+}
+
+func runScript(
+	dsConn DsConnection,
+	statements []string,
+	continueOnError bool,
+	out io.Writer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	failed := 0
+	for i, statement := range statements {
+		fmt.Fprintf(out, "-- statement %d: %s\n", i+1, firstLine(statement))
+
+		errProperties, err = runStatement(dsConn, statement, out)
+		if err != nil {
+			if errProperties == nil {
+				errProperties = map[string]string{}
+			}
+			errProperties["statement"] = strconv.Itoa(i + 1)
+			if !continueOnError {
+				return errProperties, err
+			}
+			fmt.Fprintf(out, "error: %v %v\n", err, errProperties)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return map[string]string{"failed": strconv.Itoa(failed)}, fmt.Errorf("%d of %d statements failed", failed, len(statements))
+	}
+	return nil, nil
+}
+
+func runStatement(dsConn DsConnection, statement string, out io.Writer) (
+	errProperties map[string]string,
+	err error,
+) {
+	if !returnsRows(statement) {
+		rowsAffected, errProperties, err := dsConn.exec(statement)
+		if err != nil {
+			return errProperties, err
+		}
+		fmt.Fprintln(out, statementSummary(statement, rowsAffected))
+		return nil, nil
+	}
+
+	rows, errProperties, err := dsConn.execute(statement)
+	if err != nil {
+		return errProperties, err
+	}
+	defer rows.Close()
+
+	columnInfo, errProperties, err := getResultSetColumnInfo(dsConn, rows)
+	if err != nil {
+		return errProperties, err
+	}
+
+	// some statements that look like they return rows, like SHOW of a
+	// setting on a few systems, come back with no columns to write
+	if columnInfo.NumCols == 0 {
+		fmt.Fprintln(out, commandTag(statement))
+		return nil, nil
+	}
+
+	err = writeCSVHeader(out, columnInfo)
+	if err != nil {
+		return map[string]string{"error": err.Error()}, errors.New("unable to write header")
+	}
+	return writeRows(out, rows, columnInfo, csvRowWriter(""), data.BinaryBase64, map[string]string{})
+}
+
+// Statements that are run for their result set, rather than for the rows
+// they change
+func returnsRows(statement string) bool {
+	if isSelectQuery(statement) {
+		return true
+	}
+	statement = leadingCommentRX.ReplaceAllString(statement, "")
+	switch strings.ToUpper(firstWordRX.FindString(statement)) {
+	case "SHOW", "EXPLAIN", "DESCRIBE", "DESC", "VALUES":
+		return true
+	default:
+		return false
+	}
+}
+
+// Statements whose rows affected count says what they did
+var rowChangingCommands = []string{"INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE", "COPY", "WITH"}
+
+// What a statement that returns no rows did. Statements that change rows
+// report how many, and others, like CREATE TABLE or SET, whose rows affected
+// count means nothing, report their command tag.
+func statementSummary(statement string, rowsAffected int64) string {
+	tag := commandTag(statement)
+	changesRows := false
+	for _, command := range rowChangingCommands {
+		changesRows = changesRows || strings.HasPrefix(tag, command)
+	}
+
+	if rowsAffected > 0 || (changesRows && rowsAffected == 0) {
+		return fmt.Sprintf("%d rows affected", rowsAffected)
+	}
+	return tag
+}
+
+var (
+	wordRX = regexp.MustCompile(`[a-zA-Z_]+`)
+	// words between CREATE and what it creates
+	ddlModifiers = map[string]bool{"OR": true, "REPLACE": true, "UNIQUE": true, "TEMP": true, "TEMPORARY": true, "GLOBAL": true, "LOCAL": true, "UNLOGGED": true}
+)
+
+// The statement's command, like PostgreSQL's command tags: its first word,
+// followed by the kind of object for CREATE, DROP and ALTER statements
+func commandTag(statement string) string {
+	statement = leadingCommentRX.ReplaceAllString(statement, "")
+	words := wordRX.FindAllString(strings.ToUpper(statement), 6)
+	if len(words) == 0 {
+		return "ok"
+	}
+
+	tag := words[0]
+	switch tag {
+	case "CREATE", "DROP", "ALTER":
+		for i, word := range words[1:] {
+			if ddlModifiers[word] && tag == "CREATE" {
+				continue
+			}
+			tag += " " + word
+			// two word kinds of object
+			if (word == "MATERIALIZED" || word == "FOREIGN") && i+2 < len(words) {
+				tag += " " + words[i+2]
+			}
+			break
+		}
+	case "TRUNCATE":
+		tag = "TRUNCATE TABLE"
+	}
+	return tag
+}
+
+func firstLine(statement string) string {
+	statement = strings.TrimSpace(statement)
+	if i := strings.IndexByte(statement, '\n'); i != -1 {
+		return statement[:i] + " ..."
+	}
+	return statement
+}
+
+// Splits a script on the semicolons that end its statements, skipping
+// those inside quotes and comments, and, on PostgreSQL and Redshift,
+// dollar quoted strings like function bodies. MySQL strings may escape
+// quotes with a backslash. Statements that are only whitespace and
+// comments are dropped. PL/SQL blocks, which hold semicolons of their own,
+// can't be split this way.
+func splitStatements(script string, dsType string) ([]string, error) {
+	var statements []string
+	start := 0
+	hasCode := false
+
+	endStatement := func(end int) {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(script[start:end]))
+		}
+		start = end + 1
+		hasCode = false
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+
+		switch {
+		case c == '\'' || c == '"' || (c == '`' && dsType == "mysql"):
+			end := closingQuote(script, i, dsType == "mysql")
+			if end == -1 {
+				return nil, errUnterminatedScript
+			}
+			i = end
+			hasCode = true
+		case c == '$' && (dsType == "postgresql" || dsType == "redshift") && dollarTag(script[i:]) != "":
+			tag := dollarTag(script[i:])
+			end := strings.Index(script[i+len(tag):], tag)
+			if end == -1 {
+				return nil, errUnterminatedScript
+			}
+			i += len(tag) + end + len(tag) - 1
+			hasCode = true
+		case strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				i = len(script)
+				continue
+			}
+			i += end
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end == -1 {
+				return nil, errUnterminatedScript
+			}
+			i += end + 3
+		case c == ';':
+			endStatement(i)
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+	}
+	endStatement(len(script))
+
+	return statements, nil
+}
+
+var errUnterminatedScript = errors.New("script has an unterminated quote or comment")
+
+// Finds the quote that closes the one at script[open]. A doubled quote is an
+// escaped quote, and so, if backslashEscapes is set, is one after a
+// backslash.
+func closingQuote(script string, open int, backslashEscapes bool) int {
+	quote := script[open]
+	for i := open + 1; i < len(script); i++ {
+		switch {
+		case backslashEscapes && script[i] == '\\':
+			i++
+		case script[i] == quote && i+1 < len(script) && script[i+1] == quote:
+			i++
+		case script[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the dollar quote tag s starts with, like $$ or $body$, or "" if
+// it doesn't start with one. Tags can't start with a digit, so $1 is a
+// placeholder rather than a tag.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c >= '0' && c <= '9' && i > 1, c == '_', (c >= 'a' && c <= 'z'), (c >= 'A' && c <= 'Z'):
+			continue
+		default:
+			return ""
+		}
+	}
+	return ""
+}
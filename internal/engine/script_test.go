@@ -0,0 +1,160 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `
+-- nightly maintenance; run as the owner
+UPDATE users SET name = 'O''Brien; Jr' WHERE id = 1;
+CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+	NEW.updated_at := now();
+	RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+/* a ; in a comment */ SELECT $1::int, "odd;name" FROM t;
+-- trailing comment`
+
+	got, err := splitStatements(script, "postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-- nightly maintenance; run as the owner\nUPDATE users SET name = 'O''Brien; Jr' WHERE id = 1",
+		"CREATE FUNCTION touch() RETURNS trigger AS $body$\nBEGIN\n\tNEW.updated_at := now();\n\tRETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql",
+		`/* a ; in a comment */ SELECT $1::int, "odd;name" FROM t`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wanted %d statements, got %q", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d: wanted %q, got %q", i+1, want[i], got[i])
+		}
+	}
+
+	// MySQL strings can escape quotes with a backslash, and $ isn't a quote
+	got, err = splitStatements(`SELECT 'it\'s; fine', '$x'; SELECT 2`, "mysql")
+	if err != nil || len(got) != 2 || got[0] != `SELECT 'it\'s; fine', '$x'` {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	for _, bad := range []string{"SELECT 'open", "SELECT 1 /* open", "DO $$ BEGIN END"} {
+		if _, err := splitStatements(bad, "postgresql"); err == nil {
+			t.Errorf("%q: wanted an unterminated error", bad)
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	statements := []string{
+		"UPDATE users SET active = false WHERE last_seen < now() - interval '1 year'",
+		"SELECT id, name FROM users",
+		"DELETE FROM sessions",
+		"VACUUM users",
+	}
+
+	t.Run("reports each statement", func(t *testing.T) {
+		target, fake := newFakePostgreSQL(t, "target")
+		fake.results[statements[0]] = fakeResult{rowsAffected: 3}
+		fake.results[statements[1]] = fakeResult{
+			columns: []string{"id", "name"},
+			types:   []string{"INT8", "TEXT"},
+			rows:    [][]driver.Value{{int64(1), "a"}, {int64(2), "b"}},
+		}
+
+		var out strings.Builder
+		_, err := runScript(target, statements, false, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := "-- statement 1: " + statements[0] + "\n3 rows affected\n" +
+			// result sets are written like csv files, with CRLF line endings
+			"-- statement 2: SELECT id, name FROM users\nid,name\r\n1,a\r\n2,b\r\n" +
+			"-- statement 3: DELETE FROM sessions\n0 rows affected\n" +
+			"-- statement 4: VACUUM users\nVACUUM\n"
+		if out.String() != want {
+			t.Errorf("wanted output %q, got %q", want, out.String())
+		}
+	})
+
+	t.Run("stops on first error", func(t *testing.T) {
+		target, fake := newFakePostgreSQL(t, "target")
+		fake.failOn = "DELETE"
+
+		var out strings.Builder
+		errProperties, err := runScript(target, statements, false, &out)
+		if err == nil || errProperties["statement"] != "3" {
+			t.Fatalf("wanted statement 3 to fail, got %v, %v", err, errProperties)
+		}
+		if executed := fake.executed(); len(executed) != 3 {
+			t.Errorf("wanted nothing run after the failure, got %q", executed)
+		}
+	})
+
+	t.Run("continues on error", func(t *testing.T) {
+		target, fake := newFakePostgreSQL(t, "target")
+		fake.failOn = "DELETE"
+
+		var out strings.Builder
+		errProperties, err := runScript(target, statements, true, &out)
+		if err == nil || errProperties["failed"] != "1" {
+			t.Fatalf("wanted one failed statement reported, got %v, %v", err, errProperties)
+		}
+		if executed := fake.executed(); len(executed) != 4 {
+			t.Errorf("wanted every statement run, got %q", executed)
+		}
+		if !strings.Contains(out.String(), "-- statement 3: DELETE FROM sessions\nerror: ") {
+			t.Errorf("wanted the failure in the output, got %q", out.String())
+		}
+	})
+}
+
+func TestRunScriptWithoutResultColumns(t *testing.T) {
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS archive (id int)",
+		"UPDATE users SET active = false",
+		"SET search_path TO archive",
+		// a statement that looks like it returns rows, but has no columns
+		"SHOW nothing",
+	}
+
+	target, fake := newFakePostgreSQL(t, "target")
+	fake.results[statements[1]] = fakeResult{rowsAffected: 12}
+
+	var out strings.Builder
+	_, err := runScript(target, statements, false, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "-- statement 1: " + statements[0] + "\nCREATE TABLE\n" +
+		"-- statement 2: " + statements[1] + "\n12 rows affected\n" +
+		"-- statement 3: " + statements[2] + "\nSET\n" +
+		"-- statement 4: " + statements[3] + "\nSHOW\n"
+	if out.String() != want {
+		t.Errorf("wanted output %q, got %q", want, out.String())
+	}
+}
+
+func TestCommandTag(t *testing.T) {
+	tests := map[string]string{
+		"create table users (id int)":               "CREATE TABLE",
+		"CREATE OR REPLACE VIEW active AS SELECT 1": "CREATE VIEW",
+		"create unique index users_id on users(id)": "CREATE INDEX",
+		"create materialized view m as select 1":    "CREATE MATERIALIZED VIEW",
+		"-- tidy up\nDROP TABLE IF EXISTS users":    "DROP TABLE",
+		"truncate users":                            "TRUNCATE TABLE",
+		"grant select on users to etl":              "GRANT",
+	}
+	for statement, want := range tests {
+		if got := commandTag(statement); got != want {
+			t.Errorf("%q: wanted %q, got %q", statement, want, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// The statements that set a new session up: pointing it at the connection's
+// default schema, and limiting how long its statements may run. Validation
+// has already checked the schema names, and that the system can set both.
+func sessionInitSQL(connection data.Connection) []string {
+	var statements []string
+	if schema := defaultSchemaSQL(connection); schema != "" {
+		statements = append(statements, schema)
+	}
+	if timeout := statementTimeoutSQL(connection); timeout != "" {
+		statements = append(statements, timeout)
+	}
+	return statements
+}
+
+// The statement that has the database itself cancel statements that run
+// longer than connection.StatementTimeout seconds, or "" for no limit.
+// MySQL's limit only applies to SELECTs.
+func statementTimeoutSQL(connection data.Connection) string {
+	if connection.StatementTimeout <= 0 {
+		return ""
+	}
+
+	millis := connection.StatementTimeout * 1000
+	switch connection.DsType {
+	case "postgresql", "redshift":
+		return fmt.Sprintf("SET statement_timeout = %d", millis)
+	case "mysql":
+		return fmt.Sprintf("SET SESSION max_execution_time = %d", millis)
+	case "snowflake":
+		return fmt.Sprintf("ALTER SESSION SET STATEMENT_TIMEOUT_IN_SECONDS = %d", connection.StatementTimeout)
+	default:
+		return ""
+	}
+}
+
+// The statement that points a new session at the connection's default
+// schema, or "" if it has none
+func defaultSchemaSQL(connection data.Connection) string {
+	if connection.DefaultSchema == "" {
+		return ""
+	}
+
+	switch connection.DsType {
+	case "postgresql", "redshift":
+		schemas := strings.Split(connection.DefaultSchema, ",")
+		for i, schema := range schemas {
+			schemas[i] = strings.TrimSpace(schema)
+		}
+		return fmt.Sprintf("SET search_path TO %s", strings.Join(schemas, ", "))
+	case "mysql":
+		return fmt.Sprintf("USE %s", connection.DefaultSchema)
+	case "oracle":
+		return fmt.Sprintf("ALTER SESSION SET CURRENT_SCHEMA = %s", connection.DefaultSchema)
+	case "snowflake":
+		return fmt.Sprintf("USE SCHEMA %s", connection.DefaultSchema)
+	default:
+		return ""
+	}
+}
+
+// Like sql.Open, but the initSQL statements run, in order, on every new
+// connection in the pool before the connection is used. Session settings
+// only last as long as the connection, so running them once on the *sql.DB
+// wouldn't reach the rest of the pool. Connections trust the CA bundle, if
+// there is one.
+func sqlOpen(driverName string, connString string, initSQL []string) (*sql.DB, error) {
+	connector, err := caBundleConnector(driverName, connString)
+	if err != nil {
+		return nil, err
+	}
+
+	if connector == nil {
+		db, err := sql.Open(driverName, connString)
+		if err != nil || len(initSQL) == 0 {
+			return db, err
+		}
+		connector = dsnConnector{drv: db.Driver(), connString: connString}
+		db.Close()
+	}
+
+	if len(initSQL) > 0 {
+		connector = sessionConnector{base: connector, initSQL: initSQL}
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// Opens connections the way sql.Open would
+type dsnConnector struct {
+	drv        driver.Driver
+	connString string
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if driverCtx, ok := c.drv.(driver.DriverContext); ok {
+		connector, err := driverCtx.OpenConnector(c.connString)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.drv.Open(c.connString)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+type sessionConnector struct {
+	base    driver.Connector
+	initSQL []string
+}
+
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range c.initSQL {
+		err = execOnConn(ctx, conn, statement)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to set up the session with %q: %w", statement, err)
+		}
+	}
+	return conn, nil
+}
+
+func (c sessionConnector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+func execOnConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if !errors.Is(err, driver.ErrSkip) {
+			return err
+		}
+	}
+
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(nil)
+	return err
+}
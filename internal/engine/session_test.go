@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+	"github.com/sqlpipe/sqlpipe/internal/testdb"
+)
+
+func TestDefaultSchemaSetsSearchPath(t *testing.T) {
+	_, fake := newFakeDb(t, "app")
+	setPath := "SET search_path TO analytics, public"
+	// users only resolves once the search path includes analytics
+	fake.resolve = func(query string) fakeResult {
+		for _, statement := range fake.statements {
+			if statement == setPath {
+				return fakeResult{columns: []string{"id"}, types: []string{"INT8"}, rows: [][]driver.Value{{int64(1)}}}
+			}
+		}
+		return fakeResult{}
+	}
+
+	connection := data.Connection{DsType: "postgresql", DefaultSchema: "analytics, public"}
+	db, err := openDb(connection, testdb.DriverName, t.Name()+"/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// every connection in the pool gets the setting, not just the first
+	ctx := context.Background()
+	first, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	var id int64
+	if err := second.QueryRowContext(ctx, "select id from users").Scan(&id); err != nil || id != 1 {
+		t.Fatalf("unqualified table didn't resolve: %v", err)
+	}
+
+	sets := 0
+	for _, statement := range fake.executed() {
+		if statement == setPath {
+			sets++
+		}
+	}
+	if sets != 2 {
+		t.Errorf("wanted the search path set on both connections, got %v", fake.executed())
+	}
+}
+
+func TestDefaultSchemaSQL(t *testing.T) {
+	for dsType, want := range map[string]string{
+		"mysql":     "USE analytics",
+		"oracle":    "ALTER SESSION SET CURRENT_SCHEMA = analytics",
+		"snowflake": "USE SCHEMA analytics",
+		"redshift":  "SET search_path TO analytics",
+	} {
+		if got := defaultSchemaSQL(data.Connection{DsType: dsType, DefaultSchema: "analytics"}); got != want {
+			t.Errorf("%s: wanted %q, got %q", dsType, want, got)
+		}
+	}
+	if got := sessionInitSQL(data.Connection{DsType: "postgresql"}); len(got) != 0 {
+		t.Errorf("wanted no statements without a default schema or timeout, got %q", got)
+	}
+}
+
+func TestStatementTimeoutSQL(t *testing.T) {
+	for dsType, want := range map[string]string{
+		"postgresql": "SET statement_timeout = 90000",
+		"redshift":   "SET statement_timeout = 90000",
+		"mysql":      "SET SESSION max_execution_time = 90000",
+		"snowflake":  "ALTER SESSION SET STATEMENT_TIMEOUT_IN_SECONDS = 90",
+	} {
+		if got := statementTimeoutSQL(data.Connection{DsType: dsType, StatementTimeout: 90}); got != want {
+			t.Errorf("%s: wanted %q, got %q", dsType, want, got)
+		}
+	}
+
+	got := sessionInitSQL(data.Connection{DsType: "postgresql", DefaultSchema: "analytics", StatementTimeout: 5})
+	if want := []string{"SET search_path TO analytics", "SET statement_timeout = 5000"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %q, got %q", want, got)
+	}
+}
+
+func TestStatementTimeoutSetOnSource(t *testing.T) {
+	_, fake := newFakeDb(t, "source")
+	connection := data.Connection{DsType: "mysql", StatementTimeout: 30}
+	db, err := openDb(connection, testdb.DriverName, t.Name()+"/source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("select 1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.executed(); len(got) != 2 || got[0] != "SET SESSION max_execution_time = 30000" {
+		t.Errorf("wanted the timeout set before the first statement, got %q", got)
+	}
+}
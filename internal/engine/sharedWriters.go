@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -25,7 +26,7 @@ var sqlEndStringNilReplacer = strings.NewReplacer(
 	`%!g(<nil>)`, "null",
 	`'\x%!x(<nil>)'`, "null",
 	`x'%!x(<nil>)'`, "null",
-	`CONVERT(VARBINARY(8000), '0x%!x(<nil>)', 1)`, "null",
+	`CONVERT(VARBINARY(MAX), '0x%!x(<nil>)', 1)`, "null",
 	`hextoraw('%!x(<nil>)')`, "null",
 	`to_binary('%!x(<nil>)')`, "null",
 	`'%!x(<nil>)'`, "null",
@@ -236,11 +237,46 @@ func writeMSSQLUniqueIdentifier(value interface{}, terminator string) string {
 	return returnVal
 }
 
+// For string literals that treat backslashes as escapes. Every backslash in
+// the JSON is doubled, so escapes like \n and \" reach the JSON intact.
 var backslashJSONReplacer = strings.NewReplacer(
 	"'", "''",
-	`\"`, `\\"`,
+	`\`, `\\`,
 )
 
+// The text of a JSON value. Drivers hand JSON over as text, but a decoded
+// value is encoded again rather than formatted into something that isn't
+// JSON. nil is left for the nil replacers to turn into null.
+func jsonText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Sprintf("%s", value)
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%s", value)
+	}
+	return string(js)
+}
+
 func writeBackslashJSON(value interface{}, terminator string) string {
-	return fmt.Sprintf("'%s'%s", backslashJSONReplacer.Replace(fmt.Sprintf("%s", value)), terminator)
+	return fmt.Sprintf("'%s'%s", backslashJSONReplacer.Replace(jsonText(value)), terminator)
+}
+
+// Casts the literal, so MySQL binds it as JSON rather than a string
+func writeMySQLJSON(value interface{}, terminator string) string {
+	return fmt.Sprintf("CAST(%s AS JSON)%s", writeBackslashJSON(value, ""), terminator)
+}
+
+// Casts the literal to json or jsonb, so PostgreSQL binds it as JSON rather
+// than text. json keeps the text as it was, key order included.
+func writePostgreSQLJSON(castType string) func(value interface{}, terminator string) string {
+	return func(value interface{}, terminator string) string {
+		return fmt.Sprintf("'%s'::%s%s", strings.ReplaceAll(jsonText(value), "'", "''"), castType, terminator)
+	}
 }
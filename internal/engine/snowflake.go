@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
@@ -23,14 +24,48 @@ type Snowflake struct {
 	connString      string `json:"-"`
 	debugConnString string
 	db              *sql.DB
+	tx              *sql.Tx
 }
 
-func (dsConn Snowflake) execute(query string) (rows *sql.Rows, errProperties map[string]string, err error) {
-	return standardExecute(query, dsConn.dsType, dsConn.db)
+func (dsConn Snowflake) execute(query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	return dsConn.executeContext(context.Background(), query, args...)
+}
+
+func (dsConn Snowflake) executeContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExecute(ctx, query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExecute(ctx, query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn Snowflake) exec(query string, args ...interface{}) (rowsAffected int64, errProperties map[string]string, err error) {
+	if dsConn.tx != nil {
+		return standardExec(query, dsConn.dsType, dsConn.tx, args...)
+	}
+	return standardExec(query, dsConn.dsType, dsConn.db, args...)
+}
+
+func (dsConn Snowflake) begin(opts *sql.TxOptions) (DsConnection, *sql.Tx, map[string]string, error) {
+	tx, errProperties, err := standardBegin(dsConn.dsType, dsConn.db, opts)
+	dsConn.tx = tx
+	return dsConn, tx, errProperties, err
+}
+
+// Snowflake commits any open transaction when it runs a DDL statement
+func (dsConn Snowflake) transactionalDDL() bool {
+	return false
+}
+
+func (dsConn Snowflake) supportsCopy() bool {
+	return false
+}
+
+func (dsConn Snowflake) ping(ctx context.Context) error {
+	return dsConn.db.PingContext(ctx)
 }
 
 func (dsConn Snowflake) closeDb() {
-	dsConn.db.Close()
+	releaseDb(dsConn.db)
 }
 
 func getNewSnowflake(
@@ -48,8 +83,10 @@ func getNewSnowflake(
 		connection.AccountId,
 		connection.DbName,
 	)
+	connString += keepaliveParams(connection, connString)
+	connString += connectTimeoutParams(connection, connString)
 
-	snowflake, err = sql.Open("snowflake", connString)
+	snowflake, err = openDb(connection, "snowflake", connString)
 
 	if err != nil {
 		return dsConn, errProperties, err
@@ -58,19 +95,14 @@ func getNewSnowflake(
 	dsConn = Snowflake{
 		"snowflake",
 		"snowflake",
-		fmt.Sprintf(
-			"%v:%v@%v/%v",
-			connection.Username,
-			connection.Password,
-			connection.AccountId,
-			connection.DbName,
-		),
+		connString,
 		fmt.Sprintf(
 			"<USERNAME_MASKED>:<PASSWORD_MASKED>@%v/%v",
 			connection.AccountId,
 			connection.DbName,
 		),
 		snowflake,
+		nil,
 	}
 
 	return dsConn, errProperties, err
@@ -322,6 +354,31 @@ func (dsConn Snowflake) dropTable(
 	return dropTableIfExistsWithSchema(dsConn, transfer)
 }
 
+func (dsConn Snowflake) isView(transfer data.Transfer) (bool, map[string]string, error) {
+	return standardIsView(dsConn, transfer, "CURRENT_SCHEMA()")
+}
+
+// Snowflake has no generated columns
+func (dsConn Snowflake) generatedColumns(transfer data.Transfer) ([]string, map[string]string, error) {
+	return nil, nil, nil
+}
+
+// Ownership belongs to roles, and which of the session's roles apply takes
+// more than a query to work out, so a missing permission shows up when the
+// table is dropped
+func (dsConn Snowflake) dropDenied(transfer data.Transfer) (bool, map[string]string, error) {
+	return false, nil, nil
+}
+
+func (dsConn Snowflake) truncateTable(
+	transfer data.Transfer,
+) (
+	errProperties map[string]string,
+	err error,
+) {
+	return truncateTableWithSchema(dsConn, transfer)
+}
+
 func (dsConn Snowflake) deleteFromTable(
 	transfer data.Transfer,
 ) (
@@ -369,6 +426,10 @@ func (dsConn Snowflake) getQueryEnder(targetTable string) string {
 	return ""
 }
 
+func (dsConn Snowflake) getExplainQuery(query string, analyze bool) (string, map[string]string, error) {
+	return standardGetExplainQuery(dsConn.dsType, query, analyze, false)
+}
+
 func (dsConn Snowflake) getQueryStarter(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo) string {
 	for _, colType := range columnInfo.ColumnIntermediateTypes {
 		switch colType {
@@ -408,6 +469,31 @@ func (dsConn Snowflake) getQueryStarter(targetTable string, targetSchema string,
 	return standardGetQueryStarter(targetTable, targetSchema, columnInfo)
 }
 
+func (dsConn Snowflake) getUpsertQuery(targetTable string, targetSchema string, columnInfo ResultSetColumnInfo, conflictColumns []string) (string, string, map[string]string, error) {
+	var queryBuilder strings.Builder
+	fmt.Fprintf(&queryBuilder, "MERGE INTO %s.%s target USING (SELECT ", targetSchema, targetTable)
+	for i, colType := range columnInfo.ColumnIntermediateTypes {
+		if i > 0 {
+			queryBuilder.WriteString(", ")
+		}
+		switch colType {
+		case
+			"PostgreSQL_JSON",
+			"PostgreSQL_JSONB",
+			"MySQL_JSON",
+			"Snowflake_VARIANT",
+			"Snowflake_OBJECT",
+			"Snowflake_ARRAY":
+			fmt.Fprintf(&queryBuilder, "PARSE_JSON(column%d) AS %s", i+1, columnInfo.ColumnNames[i])
+		default:
+			fmt.Fprintf(&queryBuilder, "column%d AS %s", i+1, columnInfo.ColumnNames[i])
+		}
+	}
+	queryBuilder.WriteString(" FROM VALUES (")
+
+	return queryBuilder.String(), ") source" + standardMergeClauses(columnInfo, conflictColumns), nil, nil
+}
+
 func (dsConn Snowflake) getCreateTableType(resultSetColInfo ResultSetColumnInfo, colNum int) (createType string) {
 	scanType := resultSetColInfo.ColumnScanTypes[colNum]
 	intermediateType := resultSetColInfo.ColumnIntermediateTypes[colNum]
@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"strings"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+// What MySQL's tls parameter calls each SSL mode. Its driver only verifies
+// the server with tls=true, which trusts the CA bundle when one is set.
+var mysqlTLSParams = map[string]string{
+	"disable":     "false",
+	"prefer":      "preferred",
+	"require":     "skip-verify",
+	"verify-full": "true",
+}
+
+// The connection string parameter that sets whether the driver connects over
+// TLS, starting with ? or & depending on whether connString already has
+// parameters. Empty without an SSL mode, and for drivers that can't set one.
+func sslModeParams(connection data.Connection, connString string) string {
+	if connection.SSLMode == "" {
+		return ""
+	}
+
+	sep := "?"
+	if strings.Contains(connString, "?") {
+		sep = "&"
+	}
+
+	switch connection.DsType {
+	case "postgresql", "redshift":
+		return sep + "sslmode=" + connection.SSLMode
+	case "mysql":
+		return sep + "tls=" + mysqlTLSParams[connection.SSLMode]
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/data"
+)
+
+func TestSSLModeParams(t *testing.T) {
+	tests := []struct {
+		dsType  string
+		sslMode string
+		want    string
+	}{
+		{"postgresql", "require", "?sslmode=require"},
+		{"redshift", "verify-full", "?sslmode=verify-full"},
+		{"mysql", "disable", "?tls=false"},
+		{"mysql", "prefer", "?tls=preferred"},
+		{"mysql", "require", "?tls=skip-verify"},
+		{"mysql", "verify-full", "?tls=true"},
+		{"mssql", "require", ""},
+		{"postgresql", "", ""},
+	}
+	for _, tt := range tests {
+		connection := data.Connection{DsType: tt.dsType, SSLMode: tt.sslMode}
+		if got := sslModeParams(connection, "db"); got != tt.want {
+			t.Errorf("%s %s: wanted %q, got %q", tt.dsType, tt.sslMode, tt.want, got)
+		}
+	}
+
+	if got := sslModeParams(data.Connection{DsType: "postgresql", SSLMode: "disable"}, "postgres://h/db?connect_timeout=7"); got != "&sslmode=disable" {
+		t.Errorf("wanted & after existing parameters, got %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package globals
+
+import "strings"
+
+// The environment variables psql reads, and the connection string keyword
+// each one sets
+var postgresEnvVars = []struct {
+	name    string
+	keyword string
+}{
+	{"PGHOST", "host"},
+	{"PGPORT", "port"},
+	{"PGUSER", "user"},
+	{"PGPASSWORD", "password"},
+	{"PGDATABASE", "dbname"},
+	{"PGSSLMODE", "sslmode"},
+}
+
+// Assembles a keyword/value PostgreSQL connection string, like
+// host=db port=5432 dbname=sqlpipe, from the PG* environment variables
+// getenv returns. Variables that aren't set are left out, and with none set
+// it's empty.
+func PostgresEnvDSN(getenv func(string) string) string {
+	var params []string
+	for _, envVar := range postgresEnvVars {
+		if value := getenv(envVar.name); value != "" {
+			params = append(params, envVar.keyword+"="+quoteDSNValue(value))
+		}
+	}
+	return strings.Join(params, " ")
+}
+
+// Values with spaces or quotes must be single quoted, with backslashes and
+// single quotes escaped
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,45 @@
+package globals
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestPostgresEnvDSN(t *testing.T) {
+	t.Setenv("PGHOST", "db.internal")
+	t.Setenv("PGPORT", "5433")
+	t.Setenv("PGUSER", "sqlpipe")
+	t.Setenv("PGPASSWORD", `it's a \secret`)
+	t.Setenv("PGDATABASE", "control")
+	t.Setenv("PGSSLMODE", "require")
+
+	dsn := PostgresEnvDSN(os.Getenv)
+	want := `host=db.internal port=5433 user=sqlpipe password='it\'s a \\secret' dbname=control sslmode=require`
+	if dsn != want {
+		t.Fatalf("wanted %s, got %s", want, dsn)
+	}
+
+	config, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "db.internal" || config.Port != 5433 || config.User != "sqlpipe" || config.Password != `it's a \secret` || config.Database != "control" {
+		t.Errorf("wanted sqlpipe:it's a \\secret@db.internal:5433/control, got %s:%s@%s:%d/%s", config.User, config.Password, config.Host, config.Port, config.Database)
+	}
+	if config.TLSConfig == nil {
+		t.Errorf("wanted sslmode=require to connect with TLS")
+	}
+
+	for _, name := range []string{"PGPORT", "PGPASSWORD", "PGSSLMODE"} {
+		os.Unsetenv(name)
+	}
+	if got := PostgresEnvDSN(os.Getenv); got != "host=db.internal user=sqlpipe dbname=control" {
+		t.Errorf("wanted unset variables left out, got %s", got)
+	}
+
+	if got := PostgresEnvDSN(func(string) string { return "" }); got != "" {
+		t.Errorf("wanted no DSN without any variables set, got %s", got)
+	}
+}
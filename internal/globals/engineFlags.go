@@ -0,0 +1,50 @@
+package globals
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sqlpipe/sqlpipe/internal/engine"
+	"github.com/sqlpipe/sqlpipe/internal/secrets"
+)
+
+// How the engine reaches data systems: where secret references in
+// connection passwords are looked up, and which CAs TLS connections trust.
+// Every command that connects to stored connections takes these flags, so
+// it connects the same way serve does.
+type EngineFlags struct {
+	SecretProvider string
+	VaultAddr      string
+	VaultToken     string
+	DBCABundle     string
+}
+
+func (f *EngineFlags) Register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.SecretProvider, "secret-provider", "", "Where connection passwords given as secret:<ref> are looked up. Must be empty or vault")
+	cmd.Flags().StringVar(&f.VaultAddr, "vault-addr", os.Getenv("VAULT_ADDR"), "Vault server address, for --secret-provider vault. Defaults to $VAULT_ADDR")
+	cmd.Flags().StringVar(&f.VaultToken, "vault-token", os.Getenv("VAULT_TOKEN"), "Vault token, for --secret-provider vault. Defaults to $VAULT_TOKEN")
+	cmd.Flags().StringVar(&f.DBCABundle, "db-ca-bundle", "", "PEM file of certificate authorities to trust, on top of the system's, when PostgreSQL, Redshift, MySQL and SQL Server connections verify their server over TLS, e.g. internal CAs missing from the system trust store")
+}
+
+// Sets the engine up as the flags ask
+func (f EngineFlags) Apply() (errProperties map[string]string, err error) {
+	err = engine.SetCABundle(f.DBCABundle)
+	if err != nil {
+		return map[string]string{"dbCABundle": f.DBCABundle}, err
+	}
+
+	switch f.SecretProvider {
+	case "":
+		engine.SetSecretProvider(nil)
+	case "vault":
+		if f.VaultAddr == "" {
+			return nil, errors.New("--vault-addr is required with --secret-provider vault")
+		}
+		engine.SetSecretProvider(secrets.NewVaultProvider(f.VaultAddr, f.VaultToken))
+	default:
+		return map[string]string{"secretProvider": f.SecretProvider}, errors.New("unknown secret provider")
+	}
+
+	return nil, nil
+}
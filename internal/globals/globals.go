@@ -2,4 +2,5 @@ package globals
 
 var GitHash string
 var SqlpipeVersion string
+var BuildDate string
 var Analytics bool
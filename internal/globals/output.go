@@ -0,0 +1,54 @@
+package globals
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sqlpipe/sqlpipe/internal/jsonLog"
+)
+
+// Set by the root command's --quiet and --verbose flags
+var (
+	Quiet   bool
+	Verbose bool
+)
+
+// Where command output goes, replaced in tests
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// Prints progress and success messages, which --quiet suppresses. A
+// command's results, like rows or a query plan, should be printed directly.
+func Infof(format string, a ...interface{}) {
+	if !Quiet {
+		fmt.Fprintf(Stdout, format, a...)
+	}
+}
+
+// Prints failures to stderr, even with --quiet
+func Errorf(format string, a ...interface{}) {
+	fmt.Fprintf(Stderr, format, a...)
+}
+
+// Prints detail that is only wanted with --verbose, to stderr so it doesn't
+// mix with results
+func Debugf(format string, a ...interface{}) {
+	if Verbose {
+		fmt.Fprintf(Stderr, "debug: "+format, a...)
+	}
+}
+
+// The minimum level for loggers, following --quiet and --verbose
+func LogLevel() jsonLog.Level {
+	switch {
+	case Quiet:
+		return jsonLog.LevelError
+	case Verbose:
+		return jsonLog.LevelDebug
+	default:
+		return jsonLog.LevelInfo
+	}
+}
@@ -0,0 +1,69 @@
+package globals
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sqlpipe/sqlpipe/internal/jsonLog"
+)
+
+// Points Stdout and Stderr at buffers, and puts the flags back afterwards
+func captureOutput(t *testing.T, quiet bool, verbose bool) (stdout *strings.Builder, stderr *strings.Builder) {
+	stdout, stderr = &strings.Builder{}, &strings.Builder{}
+	oldStdout, oldStderr := Stdout, Stderr
+	Stdout, Stderr = stdout, stderr
+	Quiet, Verbose = quiet, verbose
+	t.Cleanup(func() {
+		Stdout, Stderr = oldStdout, oldStderr
+		Quiet, Verbose = false, false
+	})
+	return stdout, stderr
+}
+
+func printAll() {
+	Infof("Transfer complete\n")
+	Errorf("unable to connect\n")
+	Debugf("GET /api/v1/whoami: 200 OK\n")
+}
+
+func TestQuiet(t *testing.T) {
+	stdout, stderr := captureOutput(t, true, false)
+	printAll()
+
+	if stdout.String() != "" {
+		t.Errorf("wanted --quiet to suppress progress, got %q", stdout.String())
+	}
+	if stderr.String() != "unable to connect\n" {
+		t.Errorf("wanted only the error on stderr, got %q", stderr.String())
+	}
+	if LogLevel() != jsonLog.LevelError {
+		t.Errorf("wanted loggers to only log errors, got level %v", LogLevel())
+	}
+}
+
+func TestVerbose(t *testing.T) {
+	stdout, stderr := captureOutput(t, false, true)
+	printAll()
+
+	if stdout.String() != "Transfer complete\n" {
+		t.Errorf("wanted progress on stdout, got %q", stdout.String())
+	}
+	if stderr.String() != "unable to connect\ndebug: GET /api/v1/whoami: 200 OK\n" {
+		t.Errorf("wanted the error and a debug line on stderr, got %q", stderr.String())
+	}
+	if LogLevel() != jsonLog.LevelDebug {
+		t.Errorf("wanted loggers to log debug lines, got level %v", LogLevel())
+	}
+}
+
+func TestDefaultOutput(t *testing.T) {
+	stdout, stderr := captureOutput(t, false, false)
+	printAll()
+
+	if stdout.String() != "Transfer complete\n" {
+		t.Errorf("wanted progress on stdout, got %q", stdout.String())
+	}
+	if strings.Contains(stderr.String(), "debug:") {
+		t.Errorf("wanted no debug lines without --verbose, got %q", stderr.String())
+	}
+}
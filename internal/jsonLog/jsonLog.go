@@ -12,7 +12,8 @@ import (
 type Level int8
 
 const (
-	LevelInfo Level = iota
+	LevelDebug Level = iota
+	LevelInfo
 	LevelError
 	LevelFatal
 	LevelOff
@@ -20,6 +21,8 @@ const (
 
 func (l Level) String() string {
 	switch l {
+	case LevelDebug:
+		return "DEBUG"
 	case LevelInfo:
 		return "INFO"
 	case LevelError:
@@ -44,6 +47,10 @@ func New(out io.Writer, minLevel Level) *Logger {
 	}
 }
 
+func (l *Logger) PrintDebug(message string, properties map[string]string) {
+	l.print(LevelDebug, message, properties)
+}
+
 func (l *Logger) PrintInfo(message string, properties map[string]string) {
 	l.print(LevelInfo, message, properties)
 }
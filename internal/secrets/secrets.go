@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+)
+
+// A connection password starting with this is a reference to a secret, which
+// is looked up by the configured provider each time the connection is used
+const referencePrefix = "secret:"
+
+// Looks up secrets held outside of SQLpipe's database
+type SecretProvider interface {
+	Resolve(ref string) (string, error)
+}
+
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, referencePrefix)
+}
+
+// Returns value unchanged unless it's a secret reference, in which case the
+// secret it points to is returned
+func Resolve(provider SecretProvider, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	ref := strings.TrimPrefix(value, referencePrefix)
+	if provider == nil {
+		return "", fmt.Errorf("%q is a secret reference, but no secret provider is configured", ref)
+	}
+
+	secret, err := provider.Resolve(ref)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve secret %q: %w", ref, err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mapProvider map[string]string
+
+func (p mapProvider) Resolve(ref string) (string, error) {
+	secret, ok := p[ref]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func TestResolve(t *testing.T) {
+	provider := mapProvider{"warehouse#password": "hunter2"}
+
+	tests := []struct {
+		name     string
+		provider SecretProvider
+		value    string
+		want     string
+		wantErr  error
+	}{
+		{"plain password", provider, "hunter2", "hunter2", nil},
+		{"plain password without a provider", nil, "hunter2", "hunter2", nil},
+		{"reference", provider, "secret:warehouse#password", "hunter2", nil},
+		{"missing secret", provider, "secret:warehouse#token", "", ErrSecretNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.provider, tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("wanted error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("wanted %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := Resolve(nil, "secret:warehouse#password"); err == nil {
+		t.Error("wanted an error resolving a reference without a provider")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/sqlpipe/warehouse":
+			fmt.Fprint(w, `{"data":{"data":{"password":"hunter2"},"metadata":{"version":3}}}`)
+		case "/v1/kv/sqlpipe/app":
+			fmt.Fprint(w, `{"data":{"password":"s3cret"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	provider := NewVaultProvider(srv.URL+"/", "root")
+
+	for ref, want := range map[string]string{
+		"secret/data/sqlpipe/warehouse#password": "hunter2",
+		"kv/sqlpipe/app#password":                "s3cret",
+	} {
+		got, err := provider.Resolve(ref)
+		if err != nil || got != want {
+			t.Errorf("%s: wanted %q, got %q, %v", ref, want, got, err)
+		}
+	}
+
+	for _, ref := range []string{"secret/data/sqlpipe/warehouse#token", "secret/data/sqlpipe/missing#password"} {
+		if _, err := provider.Resolve(ref); !errors.Is(err, ErrSecretNotFound) {
+			t.Errorf("%s: wanted ErrSecretNotFound, got %v", ref, err)
+		}
+	}
+
+	if _, err := provider.Resolve("secret/data/sqlpipe/warehouse"); err == nil {
+		t.Error("wanted an error for a reference without a key")
+	}
+
+	provider.Token = "wrong"
+	if _, err := provider.Resolve("secret/data/sqlpipe/warehouse#password"); err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("wanted a permission error, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Reads secrets from a HashiCorp Vault KV engine. References look like
+// <path>#<key>, e.g. secret/data/sqlpipe/warehouse#password. Both KV version
+// 1 and version 2 paths work.
+type VaultProvider struct {
+	Address string
+	Token   string
+	HTTP    *http.Client
+}
+
+func NewVaultProvider(address string, token string) *VaultProvider {
+	return &VaultProvider{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *VaultProvider) Resolve(ref string) (string, error) {
+	i := strings.LastIndex(ref, "#")
+	if i < 1 || i == len(ref)-1 {
+		return "", fmt.Errorf("vault references must look like <path>#<key>, got %q", ref)
+	}
+	path, key := strings.Trim(ref[:i], "/"), ref[i+1:]
+
+	req, err := http.NewRequest(http.MethodGet, p.Address+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to reach vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", ErrSecretNotFound
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("vault responded with status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read vault response: %w", err)
+	}
+
+	// KV version 2 nests the secret's fields, and its metadata, a level down
+	fields := body.Data
+	if nested, ok := fields["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := fields["metadata"]; hasMetadata {
+			fields = nested
+		}
+	}
+
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return value, nil
+}
@@ -0,0 +1,256 @@
+// Package testdb is a database/sql driver for tests. Each statement it's
+// given is answered by the test's handler, so code that queries a database
+// can be exercised without a live one.
+package testdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// What the driver is registered with database/sql as
+const DriverName = "sqlpipetest"
+
+// A statement's answer
+type Result struct {
+	Columns []string
+	// database type names of the columns, if the test cares about them
+	Types []string
+	Rows  [][]driver.Value
+	// returned by Exec
+	RowsAffected int64
+}
+
+// Answers a statement run on conn. ctx is done once the statement is
+// cancelled.
+type HandlerFunc func(ctx context.Context, conn *Conn, query string, args []driver.Value) (Result, error)
+
+// Adapts a handler that answers with rows alone. Exec reports how many it
+// returns as rows affected.
+func Rows(handler func(query string, args []driver.Value) (columns []string, rows [][]driver.Value, err error)) HandlerFunc {
+	return func(ctx context.Context, conn *Conn, query string, args []driver.Value) (Result, error) {
+		columns, rows, err := handler(query, args)
+		return Result{Columns: columns, Rows: rows, RowsAffected: int64(len(rows))}, err
+	}
+}
+
+// DB answers the statements of every connection opened with its name
+type DB struct {
+	Handler HandlerFunc
+
+	// When they're set, these are called as a transaction begins, commits and
+	// rolls back, and given pings. An error from OnBegin fails the Begin.
+	OnBegin    func(conn *Conn, opts driver.TxOptions) error
+	OnCommit   func(conn *Conn) error
+	OnRollback func(conn *Conn) error
+	OnPing     func(ctx context.Context) error
+
+	// Wraps each connection opened, so a test can give it methods a real
+	// driver's connections have
+	WrapConn func(conn *Conn) driver.Conn
+
+	mu     sync.Mutex
+	served int
+}
+
+// How many rows have been handed to database/sql so far
+func (db *DB) Served() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.served
+}
+
+var (
+	dbsMu sync.Mutex
+	dbs   = map[string]*DB{}
+)
+
+func init() {
+	sql.Register(DriverName, testDriver{})
+}
+
+// Has connections opened with dsn answered by db until the test ends
+func Register(t testing.TB, dsn string, db *DB) {
+	t.Helper()
+
+	dbsMu.Lock()
+	dbs[dsn] = db
+	dbsMu.Unlock()
+
+	t.Cleanup(func() {
+		dbsMu.Lock()
+		defer dbsMu.Unlock()
+		if dbs[dsn] == db {
+			delete(dbs, dsn)
+		}
+	})
+}
+
+// Registers db under the test's name and name, and opens it. The *sql.DB is
+// closed when the test ends.
+func Open(t testing.TB, name string, db *DB) *sql.DB {
+	t.Helper()
+
+	dsn := t.Name() + "/" + name
+	Register(t, dsn, db)
+
+	sqlDB, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return sqlDB
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(name string) (driver.Conn, error) {
+	dbsMu.Lock()
+	defer dbsMu.Unlock()
+
+	db, ok := dbs[name]
+	if !ok {
+		return nil, errors.New("unknown test db")
+	}
+	conn := &Conn{DB: db}
+	if db.WrapConn != nil {
+		return db.WrapConn(conn), nil
+	}
+	return conn, nil
+}
+
+// A connection to a DB
+type Conn struct {
+	DB *DB
+	// whether a transaction is open
+	InTx bool
+}
+
+func (c *Conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c, query}, nil
+}
+
+func (c *Conn) Close() error { return nil }
+
+func (c *Conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.DB.OnBegin != nil {
+		if err := c.DB.OnBegin(c, opts); err != nil {
+			return nil, err
+		}
+	}
+	c.InTx = true
+	return tx{c}, nil
+}
+
+func (c *Conn) Ping(ctx context.Context) error {
+	if c.DB.OnPing != nil {
+		return c.DB.OnPing(ctx)
+	}
+	return nil
+}
+
+type tx struct {
+	conn *Conn
+}
+
+func (tx tx) Commit() error {
+	tx.conn.InTx = false
+	if tx.conn.DB.OnCommit != nil {
+		return tx.conn.DB.OnCommit(tx.conn)
+	}
+	return nil
+}
+
+func (tx tx) Rollback() error {
+	tx.conn.InTx = false
+	if tx.conn.DB.OnRollback != nil {
+		return tx.conn.DB.OnRollback(tx.conn)
+	}
+	return nil
+}
+
+type stmt struct {
+	conn *Conn
+	text string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.exec(context.Background(), args)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.query(context.Background(), args)
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.exec(ctx, values(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.query(ctx, values(args))
+}
+
+func (s *stmt) exec(ctx context.Context, args []driver.Value) (driver.Result, error) {
+	result, err := s.conn.DB.Handler(ctx, s.conn, s.text, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.RowsAffected), nil
+}
+
+func (s *stmt) query(ctx context.Context, args []driver.Value) (driver.Rows, error) {
+	result, err := s.conn.DB.Handler(ctx, s.conn, s.text, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{db: s.conn.DB, result: result}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+type rows struct {
+	db     *DB
+	result Result
+	next   int
+}
+
+func (r *rows) Columns() []string { return r.result.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if index < len(r.result.Types) {
+		return r.result.Types[index]
+	}
+	return ""
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.Rows[r.next])
+	r.next++
+	r.db.mu.Lock()
+	r.db.served++
+	r.db.mu.Unlock()
+	return nil
+}
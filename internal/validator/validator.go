@@ -5,7 +5,8 @@ import (
 )
 
 var (
-	UsernameRX = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]\w{3,29}$`)
+	UsernameRX   = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]\w{3,29}$`)
+	IdentifierRX = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 )
 
 type Validator struct {